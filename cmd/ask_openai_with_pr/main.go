@@ -2,162 +2,180 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
 
+	"github.com/malsuke/PRalyzer/internal/cassette"
+	"github.com/malsuke/PRalyzer/internal/interrupt"
 	"github.com/malsuke/PRalyzer/internal/openai"
+	"github.com/malsuke/PRalyzer/internal/store"
 )
 
-const (
-	processedPRBufferSize = 100
-)
+const usage = `Usage: go run cmd/ask_openai_with_pr/main.go [-record <file> | -replay <file>] <input-directory> <output-file> <openai-api-key>
+       go run cmd/ask_openai_with_pr/main.go [-record <file> | -replay <file>] -db <database> <openai-api-key>`
 
 // RateLimitError はレート制限エラー（429）を表す
 var RateLimitError = errors.New("rate limit exceeded (429)")
 
 func main() {
-	if len(os.Args) < 4 {
-		log.Fatal("Usage: go run cmd/ask_openai_with_pr/main.go <input-directory> <output-file> <openai-api-key>")
-	}
-
-	inputDir := os.Args[1]
-	outputFile := os.Args[2]
-	openAIAPIKey := os.Args[3]
-
-	client := openai.NewClient(openAIAPIKey)
-
-	indexFile := getIndexFilePath(outputFile)
-	if err := initializeFiles(outputFile, indexFile); err != nil {
-		log.Fatalf("Failed to initialize files: %v", err)
+	tape := cassette.RegisterFlags(flag.CommandLine)
+	dbFile := flag.String("db", "", "read PRs from and save results to this SQLite database instead of a directory and a JSONL file")
+	flag.Parse()
+
+	// -dbが指定されていればAPIキーだけを、なければ入力ディレクトリと出力ファイルも引数で受け取る
+	var st store.Store
+	var openAIAPIKey, outputFile string
+	if *dbFile != "" {
+		if flag.NArg() < 1 {
+			log.Fatal(usage)
+		}
+		openAIAPIKey = flag.Arg(0)
+		db, err := store.OpenSQLite(*dbFile)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		st = db
+	} else {
+		if flag.NArg() < 3 {
+			log.Fatal(usage)
+		}
+		inputDir := flag.Arg(0)
+		outputFile = flag.Arg(1)
+		openAIAPIKey = flag.Arg(2)
+		st = store.NewFileStore(inputDir, store.WithResultsFile(outputFile))
 	}
 
-	processedPRs, err := loadProcessedPRs(indexFile)
+	// -record・-replayが指定されていれば、OpenAI APIとのやり取りをカセットに記録するか、カセットから再生する
+	recording, err := tape.Open()
 	if err != nil {
-		log.Fatalf("Failed to load processed PRs: %v", err)
+		log.Fatalf("Failed to open cassette: %v", err)
+	}
+	var httpClient *http.Client
+	if recording != nil {
+		defer recording.Close()
+		httpClient = recording.HTTPClient()
 	}
 
-	prBuffer := newProcessedPRBuffer(indexFile)
+	client := openai.NewClient(openAIAPIKey, httpClient)
 
-	if err := processDirectory(inputDir, client, outputFile, processedPRs, prBuffer); err != nil {
+	// Ctrl-C（SIGINT）やSIGTERMを受け取ったら処理中のリクエストをキャンセルし、処理済みPRを保存して終了する
+	ctx, stop := interrupt.NotifyContext(context.Background())
+	defer stop()
+
+	processed, err := processConversations(ctx, st, client)
+	// 中断やエラーで止まった場合も、分析済みのPRを保存してから終了する
+	if closeErr := st.Close(); closeErr != nil {
+		log.Printf("⚠️  Failed to flush processed PRs: %v", closeErr)
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			log.Fatalf("🛑 Interrupted. Processing stopped.\n   Processed PRs have been saved. You can resume later.")
+		}
 		if errors.Is(err, RateLimitError) {
-			// 429エラーの場合は処理済みPRを保存してから終了
-			if flushErr := prBuffer.flush(); flushErr != nil {
-				log.Printf("⚠️  Failed to flush processed PRs: %v", flushErr)
-			}
 			log.Fatalf("🛑 Rate limit exceeded (429). Processing stopped.\n   Processed PRs have been saved. You can resume later.")
 		}
-		log.Fatalf("Failed to process directory: %v", err)
+		if errors.Is(err, cassette.ErrUnmatched) {
+			log.Fatalf("🛑 Request not found in the cassette. Processing stopped.\n   %v", err)
+		}
+		log.Fatalf("Failed to process PRs: %v", err)
 	}
 
-	if err := prBuffer.flush(); err != nil {
-		log.Fatalf("Failed to flush processed PRs: %v", err)
+	fmt.Printf("\n✓ Successfully processed %d PRs\n", processed)
+	if *dbFile != "" {
+		fmt.Printf("✓ Results saved to: %s\n", *dbFile)
+		return
 	}
-
-	count := countProcessedPRs(outputFile)
-	fmt.Printf("\n✓ Successfully processed %d PRs\n", count)
-	fmt.Printf("✓ Results saved to: %s\n", outputFile)
-	fmt.Printf("✓ Index file: %s\n", indexFile)
+	fmt.Printf("✓ Results saved to: %s (%d results)\n", outputFile, countProcessedPRs(outputFile))
 }
 
-func processDirectory(inputDir string, client *openai.Client, outputFile string, processedPRs map[int]bool, prBuffer *processedPRBuffer) error {
-	err := filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
+// processConversations はstに保存されたPRのうち未分析のものをLLMに問い合わせ、結果を保存する。分析したPRの件数を返す
+func processConversations(ctx context.Context, st store.Store, client *openai.Client) (int, error) {
+	processed := 0
+	for conversation, err := range st.Conversations() {
 		if err != nil {
-			return err
+			log.Printf("⚠️  Skipping: %v", err)
+			continue
 		}
-
-		if !shouldProcessFile(path, info) {
-			return nil
+		if err := ctx.Err(); err != nil {
+			return processed, err
 		}
 
-		prNumber, err := extractPRNumber(path)
+		label := conversationLabel(conversation)
+		analyzed, err := st.Analyzed(conversation)
 		if err != nil {
-			log.Printf("⚠️  Skipping file %s: %v", filepath.Base(path), err)
-			return nil
+			return processed, err
 		}
-
-		if processedPRs[prNumber] {
-			log.Printf("⏭️  Skipping PR #%d (already processed)", prNumber)
-			return nil
+		if analyzed {
+			log.Printf("⏭️  Skipping %s (already processed)", label)
+			continue
 		}
 
-		result, err := processPRFile(path, prNumber, client)
+		result, err := processConversation(ctx, conversation, client)
 		if err != nil {
-			if errors.Is(err, RateLimitError) {
-				// 429エラーの場合は処理を停止
-				log.Printf("🛑 Rate limit exceeded (429) for PR #%d. Stopping processing.", prNumber)
-				return err
+			if errors.Is(err, RateLimitError) || errors.Is(err, context.Canceled) || errors.Is(err, cassette.ErrUnmatched) {
+				// 429エラーや中断、カセットに記録がない場合は処理を停止（このPRは未処理のまま残す）
+				log.Printf("🛑 Stopping processing at %s: %v", label, err)
+				return processed, err
 			}
-			log.Printf("⚠️  Failed to process PR #%d: %v", prNumber, err)
+			log.Printf("⚠️  Failed to process %s: %v", label, err)
 			// その他のエラーは空の結果を記録して続行
-			result = createEmptyResult(prNumber)
+			result = createEmptyResult(conversation.Number)
 		}
 
-		if err := appendResultJSONL(outputFile, result); err != nil {
-			log.Printf("⚠️  Failed to write result for PR #%d: %v", prNumber, err)
-			return nil
+		if err := st.SaveAnalysis(conversation, result); err != nil {
+			log.Printf("⚠️  Failed to write result for %s: %v", label, err)
+			continue
 		}
-
-		processedPRs[prNumber] = true
-		if err := prBuffer.add(prNumber); err != nil {
-			log.Printf("⚠️  Failed to buffer processed PR #%d: %v", prNumber, err)
-		}
-
-		return nil
-	})
-
-	return err
-}
-
-func shouldProcessFile(path string, info os.FileInfo) bool {
-	if info.IsDir() {
-		return false
+		processed++
 	}
-
-	if !strings.HasSuffix(strings.ToLower(path), ".json") {
-		return false
-	}
-
-	fileName := filepath.Base(path)
-	return !strings.HasPrefix(fileName, ".")
+	return processed, nil
 }
 
-func extractPRNumber(filePath string) (int, error) {
-	fileName := filepath.Base(filePath)
-	prNumberStr := strings.TrimSuffix(fileName, ".json")
-	prNumber, err := strconv.Atoi(prNumberStr)
-	if err != nil {
-		return 0, fmt.Errorf("invalid PR number format: %w", err)
+// conversationLabel はログに表示するPRの名前（リポジトリが分かればowner/repo#123、分からなければPR #123）を返す
+func conversationLabel(conversation store.SavedConversation) string {
+	if conversation.Repo.Name == "" {
+		return fmt.Sprintf("PR #%d", conversation.Number)
 	}
-	return prNumber, nil
+	return fmt.Sprintf("%s#%d", conversation.Repo, conversation.Number)
 }
 
-func processPRFile(filePath string, prNumber int, client *openai.Client) (openai.VulnerabilityDetectionResult, error) {
-	fmt.Printf("Processing PR #%d: %s\n", prNumber, filePath)
+func processConversation(ctx context.Context, conversation store.SavedConversation, client *openai.Client) (openai.VulnerabilityDetectionResult, error) {
+	prNumber := conversation.Number
+	label := conversationLabel(conversation)
+	fmt.Printf("Processing %s (%s)\n", label, strings.Join(conversation.Keywords, ", "))
 
-	conversationJSON, err := readAndValidateJSON(filePath)
-	if err != nil {
-		log.Printf("⚠️  Failed to read/validate JSON for PR #%d: %v", prNumber, err)
+	if !json.Valid(conversation.Data) {
+		log.Printf("⚠️  Failed to read/validate JSON for %s: invalid JSON format", label)
 		return createEmptyResult(prNumber), nil
 	}
 
-	result, err := client.DetectVulnerabilityDiscussion(conversationJSON)
+	result, err := client.DetectVulnerabilityDiscussion(ctx, conversation.Data)
 	if err != nil {
+		// 中断された場合は空の結果を記録せず、次回の実行で再処理する
+		if ctx.Err() != nil {
+			return openai.VulnerabilityDetectionResult{}, ctx.Err()
+		}
+		// カセットに記録がない場合は空の結果を記録せずに止める
+		if errors.Is(err, cassette.ErrUnmatched) {
+			return openai.VulnerabilityDetectionResult{}, err
+		}
 		// 429エラーを検出
 		if isRateLimitError(err) {
-			log.Printf("⚠️  Rate limit exceeded (429) for PR #%d", prNumber)
+			log.Printf("⚠️  Rate limit exceeded (429) for %s", label)
 			return openai.VulnerabilityDetectionResult{}, RateLimitError
 		}
-		log.Printf("⚠️  Failed to detect vulnerability discussion for PR #%d: %v", prNumber, err)
+		log.Printf("⚠️  Failed to detect vulnerability discussion for %s: %v", label, err)
 		return createEmptyResult(prNumber), nil
 	}
 
-	fmt.Printf("  ✓ Completed PR #%d\n", prNumber)
+	fmt.Printf("  ✓ Completed %s\n", label)
 
 	return openai.VulnerabilityDetectionResult{
 		PR:                 prNumber,
@@ -166,20 +184,6 @@ func processPRFile(filePath string, prNumber int, client *openai.Client) (openai
 	}, nil
 }
 
-func readAndValidateJSON(filePath string) ([]byte, error) {
-	conversationJSON, err := os.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
-	}
-
-	var jsonData interface{}
-	if err := json.Unmarshal(conversationJSON, &jsonData); err != nil {
-		return nil, fmt.Errorf("invalid JSON format: %w", err)
-	}
-
-	return conversationJSON, nil
-}
-
 func createEmptyResult(prNumber int) openai.VulnerabilityDetectionResult {
 	return openai.VulnerabilityDetectionResult{
 		PR:                 prNumber,
@@ -188,134 +192,6 @@ func createEmptyResult(prNumber int) openai.VulnerabilityDetectionResult {
 	}
 }
 
-func getIndexFilePath(outputFile string) string {
-	dir := filepath.Dir(outputFile)
-	base := filepath.Base(outputFile)
-	ext := filepath.Ext(base)
-	name := strings.TrimSuffix(base, ext)
-	return filepath.Join(dir, "."+name+"_index.json")
-}
-
-func initializeFiles(outputFile string, indexFile string) error {
-	outputDir := filepath.Dir(outputFile)
-	if outputDir != "." && outputDir != "" {
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			return fmt.Errorf("failed to create output directory: %w", err)
-		}
-	}
-
-	if _, err := os.Stat(outputFile); os.IsNotExist(err) {
-		file, err := os.Create(outputFile)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %w", err)
-		}
-		file.Close()
-	}
-
-	return nil
-}
-
-func loadProcessedPRs(indexFile string) (map[int]bool, error) {
-	processedPRs := make(map[int]bool)
-
-	if _, err := os.Stat(indexFile); os.IsNotExist(err) {
-		return processedPRs, nil
-	}
-
-	data, err := os.ReadFile(indexFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read index file: %w", err)
-	}
-
-	var prs []int
-	if err := json.Unmarshal(data, &prs); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal index file: %w", err)
-	}
-
-	for _, pr := range prs {
-		processedPRs[pr] = true
-	}
-
-	return processedPRs, nil
-}
-
-type processedPRBuffer struct {
-	buffer    []int
-	indexFile string
-}
-
-func newProcessedPRBuffer(indexFile string) *processedPRBuffer {
-	return &processedPRBuffer{
-		buffer:    make([]int, 0, processedPRBufferSize),
-		indexFile: indexFile,
-	}
-}
-
-func (pb *processedPRBuffer) add(prNumber int) error {
-	pb.buffer = append(pb.buffer, prNumber)
-
-	if len(pb.buffer) >= processedPRBufferSize {
-		return pb.flush()
-	}
-
-	return nil
-}
-
-func (pb *processedPRBuffer) flush() error {
-	if len(pb.buffer) == 0 {
-		return nil
-	}
-
-	existingPRs, err := loadProcessedPRs(pb.indexFile)
-	if err != nil {
-		existingPRs = make(map[int]bool)
-	}
-
-	for _, pr := range pb.buffer {
-		existingPRs[pr] = true
-	}
-
-	prs := make([]int, 0, len(existingPRs))
-	for pr := range existingPRs {
-		prs = append(prs, pr)
-	}
-
-	data, err := json.Marshal(prs)
-	if err != nil {
-		return fmt.Errorf("failed to marshal processed PRs: %w", err)
-	}
-
-	if err := os.WriteFile(pb.indexFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write index file: %w", err)
-	}
-
-	pb.buffer = pb.buffer[:0]
-	return nil
-}
-
-func appendResultJSONL(outputFile string, result openai.VulnerabilityDetectionResult) error {
-	file, err := os.OpenFile(outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open output file: %w", err)
-	}
-	defer file.Close()
-
-	jsonData, err := json.Marshal(result)
-	if err != nil {
-		return fmt.Errorf("failed to marshal result: %w", err)
-	}
-
-	if _, err := file.Write(jsonData); err != nil {
-		return fmt.Errorf("failed to write result: %w", err)
-	}
-
-	if _, err := file.WriteString("\n"); err != nil {
-		return fmt.Errorf("failed to write newline: %w", err)
-	}
-
-	return nil
-}
-
 func countProcessedPRs(outputFile string) int {
 	if _, err := os.Stat(outputFile); os.IsNotExist(err) {
 		return 0
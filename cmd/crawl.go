@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/store"
+)
+
+// clientCache はホストごとのClientを1つずつ作って使い回す。
+// 同じホストのリポジトリは認証とレート制限を共有する。
+type clientCache struct {
+	mu      sync.Mutex
+	newHost func(host string) (*github.Client, error)
+	clients map[string]*github.Client
+	onWait  func(resource string, wait time.Duration)
+}
+
+func newClientCache(newHost func(host string) (*github.Client, error), onWait func(resource string, wait time.Duration)) *clientCache {
+	return &clientCache{newHost: newHost, clients: make(map[string]*github.Client), onWait: onWait}
+}
+
+// get はhostのClientを返す。初めてのホストであればClientを作成する。
+func (c *clientCache) get(host string) (*github.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, ok := c.clients[host]; ok {
+		return client, nil
+	}
+	client, err := c.newHost(host)
+	if err != nil {
+		return nil, err
+	}
+	client.RateLimiter().OnLongWait(c.onWait)
+	c.clients[host] = client
+	return client, nil
+}
+
+// crawler はワードリストのキーワードでPRを検索し、会話をstoreに保存する
+type crawler struct {
+	// sources はリポジトリごとの検索と会話の取得に使う取得元（GitHubまたはGitLab）を返す
+	sources     sourceOpener
+	workSize    int
+	concurrency int
+	words       []string
+	baseQuery   github.SearchQuery
+	// store は会話・処理済みPR番号・差分同期の状態の保存先
+	store store.Store
+	// incremental は前回の同期以降に更新されたPRだけを検索し、保存済みのJSONに新しいコメントを取り込む
+	incremental bool
+
+	// current はクロール中のリポジトリの処理済みPR番号（レート制限で待機する前に保存する）
+	mu      sync.Mutex
+	current *processedSet
+}
+
+// saveCurrent はクロール中のリポジトリの処理済みPR番号を保存する
+func (c *crawler) saveCurrent() {
+	c.mu.Lock()
+	processed := c.current
+	c.mu.Unlock()
+
+	if processed == nil {
+		return
+	}
+	if err := processed.Save(); err != nil {
+		log.Printf("Failed to save processed PRs: %v", err)
+	}
+}
+
+func (c *crawler) setCurrent(processed *processedSet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = processed
+}
+
+/**
+ * 1つのリポジトリについて、キーワードごとにPRを検索して会話を保存する
+ * PRは一致したキーワードによらず1件だけ保存し、検索に一致したことはキーワード・クエリ・日時と共に
+ * 処理済みのPRも含めて記録する。キーワードごとのビューは最後に記録から作り直す
+ * 処理済みPR番号はリポジトリごとにstoreに保存し、次回はそこから再開する
+ * 差分同期では前回見た最新のupdated_at以降に更新されたPRを処理済みでも取得し直し、
+ * すべてのキーワードを取りこぼしなく処理できた場合だけ差分同期の状態の記録を進める
+ */
+func (c *crawler) crawlRepository(ctx context.Context, repo github.RepositoryRef) repoSummary {
+	started := time.Now()
+	stats := &crawlStats{}
+	summarize := func(err error, keywordErrors []string) repoSummary {
+		summary := stats.summary(repo.String(), c.store.Location(repo), time.Since(started))
+		summary.KeywordErrors = keywordErrors
+		if err != nil {
+			summary.Error = err.Error()
+		}
+		return summary
+	}
+
+	fmt.Printf("\n##### Repository: %s #####\n", repo)
+
+	source, fetcher, err := c.sources(repo)
+	if err != nil {
+		return summarize(err, nil)
+	}
+
+	// キーワードごとのディレクトリに保存した以前の形式のPRは、処理済みとして取得し直さないため先に移す
+	if migrator, ok := c.store.(store.LegacyLayoutMigrator); ok {
+		migrated, err := migrator.MigrateLegacyLayout(repo)
+		if err != nil {
+			return summarize(fmt.Errorf("failed to migrate the legacy layout: %w", err), nil)
+		}
+		if migrated > 0 {
+			fmt.Printf("Migrated %d PRs from the keyword directories\n", migrated)
+		}
+	}
+
+	// 処理済みPR番号を読み込む
+	processed, err := loadProcessedSet(c.store, repo)
+	if err != nil {
+		log.Printf("Failed to load processed PRs (will start fresh): %v", err)
+	} else {
+		fmt.Printf("Loaded %d previously processed PRs\n", processed.Len())
+	}
+	c.setCurrent(processed)
+	defer c.setCurrent(nil)
+
+	// 差分同期では前回の記録以降に更新されたPRに絞り込む
+	query := c.baseQuery
+	var state store.SyncState
+	if c.incremental {
+		state, err = c.store.LoadSyncState(repo)
+		if err != nil {
+			return summarize(err, nil)
+		}
+		if !state.LastUpdatedAt.IsZero() {
+			query.Updated = github.DateRange{From: state.LastUpdatedAt}
+			fmt.Printf("Fetching PRs updated since %s\n", state.LastUpdatedAt.Format(time.RFC3339))
+		}
+	}
+	// 前回の記録がある場合は、更新されたPRを処理済みでも取得し直して既存のJSONに取り込む
+	refresh := c.incremental && !state.LastUpdatedAt.IsZero()
+	lastUpdatedAt := state.LastUpdatedAt
+	// 取得し直すPRは複数のキーワードに一致しても、この実行では1回だけ取得する
+	refreshed := make(map[int]bool)
+
+	var keywordErrors []string
+
+	// キーワードごとに処理
+	for _, word := range c.words {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Printf("\n=== Processing keyword: %s ===\n", word)
+
+		// 1. キーワードでPRを検索（レート制限の待機はクライアントが行う）
+		keywordQuery := query.WithKeyword(word)
+		results, err := source.SearchPullRequestResults(ctx, keywordQuery)
+		if err != nil {
+			log.Printf("Failed to search PRs with keyword '%s': %v", word, err)
+			keywordErrors = append(keywordErrors, fmt.Sprintf("%s: %v", word, err))
+			continue // 次のキーワードへ
+		}
+
+		prNumbers := make([]int, len(results))
+		for i, result := range results {
+			prNumbers[i] = result.Number
+			if result.UpdatedAt.After(lastUpdatedAt) {
+				lastUpdatedAt = result.UpdatedAt
+			}
+		}
+
+		if len(prNumbers) == 0 {
+			fmt.Printf("No PRs found for keyword '%s'. Skipping.\n", word)
+			continue
+		}
+
+		fmt.Printf("Found %d PRs for keyword '%s'\n", len(prNumbers), word)
+		stats.found.Add(int64(len(prNumbers)))
+
+		// 2. 処理済みのPRも含めて、このキーワードに一致したことを記録する
+		hitQuery := keywordQuery.Build(repo.Owner, repo.Name)
+		if describer, ok := source.(queryDescriber); ok {
+			hitQuery = describer.DescribeQuery(repo, keywordQuery)
+		}
+		hit := store.KeywordHit{Keyword: word, Query: hitQuery, MatchedAt: time.Now()}
+		if err := c.store.SaveKeywordHits(repo, hit, prNumbers); err != nil {
+			log.Printf("Failed to save keyword hits for '%s': %v", word, err)
+			keywordErrors = append(keywordErrors, fmt.Sprintf("%s: %v", word, err))
+		}
+
+		// 3. 未処理のPRだけを残す
+		var pendingPRs []int
+		for _, prNumber := range prNumbers {
+			// 既に処理済みのPRはスキップ（差分同期で更新されたPRは取得し直す）
+			if !refresh && processed.Contains(prNumber) {
+				fmt.Printf("Skipping PR #%d (already processed)\n", prNumber)
+				stats.alreadyProcessed.Add(1)
+				continue
+			}
+			if refresh {
+				if refreshed[prNumber] {
+					fmt.Printf("Skipping PR #%d (already refreshed in this run)\n", prNumber)
+					stats.alreadyProcessed.Add(1)
+					continue
+				}
+				refreshed[prNumber] = true
+			}
+			pendingPRs = append(pendingPRs, prNumber)
+		}
+
+		// 4. ワーカーごとにPR本体と会話を取得して保存
+		runWorkerPool(ctx, chunkPRNumbers(pendingPRs, c.workSize), c.concurrency, func(batch []int) {
+			collectConversations(ctx, fetcher, batch, c.store, repo, processed, stats, refresh)
+		})
+
+		// キーワードごとの処理が完了したら処理済みPR番号を保存
+		if err := processed.Save(); err != nil {
+			log.Printf("Failed to save processed PRs: %v", err)
+		}
+	}
+
+	if err := c.store.BuildKeywordViews(repo); err != nil {
+		log.Printf("Failed to build keyword views: %v", err)
+	}
+
+	// 取りこぼしがあれば次回も同じ期間から検索し直せるよう、記録は進めない
+	if c.incremental && ctx.Err() == nil && len(keywordErrors) == 0 && stats.failed.Load() == 0 && lastUpdatedAt.After(state.LastUpdatedAt) {
+		if err := c.store.SaveSyncState(repo, store.SyncState{LastUpdatedAt: lastUpdatedAt}); err != nil {
+			log.Printf("Failed to save sync state: %v", err)
+		}
+	}
+
+	return summarize(nil, keywordErrors)
+}
+
+// collectConversations はbatchのPRの会話を取得してstに保存し、保存できたPRを処理済みにする
+// 取得や保存に失敗したPRは未処理のまま残し、次回の実行で再取得する
+// mergeExistingがtrueの場合、保存済みの会話があれば新しく取得した会話をそこに取り込む
+func collectConversations(ctx context.Context, fetcher github.ConversationFetcher, batch []int, st store.Store, repo github.RepositoryRef, processed *processedSet, stats *crawlStats, mergeExisting bool) {
+	fmt.Printf("Fetching conversations for PRs %v\n", batch)
+
+	conversations, err := fetcher.FetchConversations(ctx, batch)
+	if err != nil {
+		log.Printf("Failed to fetch some conversations: %v", err)
+	}
+
+	for _, prNumber := range batch {
+		conversation, ok := conversations[prNumber]
+		if !ok {
+			stats.failed.Add(1)
+			continue
+		}
+
+		if mergeExisting {
+			existing, err := st.LoadConversation(repo, prNumber)
+			switch {
+			case err == nil:
+				conversation = github.MergeConversation(existing.Conversation(), conversation)
+				stats.refreshed.Add(1)
+			case !errors.Is(err, store.ErrNotFound):
+				log.Printf("Failed to read saved comments for PR #%d (will overwrite): %v", prNumber, err)
+			}
+		}
+
+		// コメントも説明文もない場合はスキップ
+		if len(conversation.IssueComments) == 0 && len(conversation.ReviewComments) == 0 && len(conversation.Reviews) == 0 && conversation.PullRequest.GetBody() == "" {
+			fmt.Printf("No comments found for PR #%d. Skipping.\n", prNumber)
+			// 処理済みとしてマーク（コメントがない場合も処理済みとする）
+			markProcessed(processed, prNumber)
+			stats.withoutComments.Add(1)
+			continue
+		}
+
+		// コメントを時系列順にソート
+		sortCommentsByTime(conversation.IssueComments, conversation.ReviewComments)
+
+		// 保存先に書き込む
+		if err := st.SaveConversation(repo, prNumber, store.NewPRComments(conversation)); err != nil {
+			log.Printf("Failed to save comments for PR #%d: %v", prNumber, err)
+			stats.failed.Add(1)
+			continue
+		}
+
+		// 処理済みとしてマーク（一定件数ごとに進捗を保存）
+		markProcessed(processed, prNumber)
+		stats.saved.Add(1)
+		fmt.Printf("Saved comments for PR #%d to %s\n", prNumber, st.Location(repo))
+	}
+}
+
+func markProcessed(processed *processedSet, prNumber int) {
+	if err := processed.Mark(prNumber); err != nil {
+		log.Printf("Failed to save processed PRs: %v", err)
+	}
+}
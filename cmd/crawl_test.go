@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/malsuke/PRalyzer/internal/forge"
+	"github.com/malsuke/PRalyzer/internal/gitea"
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/githubtest"
+	"github.com/malsuke/PRalyzer/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCrawler はフェイクサーバーに接続してdataRootに保存するcrawlerを作成する
+func newTestCrawler(t *testing.T, server *githubtest.Server, dataRoot string, words ...string) *crawler {
+	t.Helper()
+
+	clients := newClientCache(func(host string) (*github.Client, error) {
+		return github.NewHostClient("test-token", host, nil, github.WithBaseURL(server.URL))
+	}, nil)
+	return &crawler{
+		sources:     githubSources(clients, backendREST),
+		workSize:    1,
+		concurrency: 2,
+		words:       words,
+		store:       store.NewFileStore(dataRoot),
+	}
+}
+
+func newCrawlServer(t *testing.T) *githubtest.Server {
+	t.Helper()
+
+	fixture, err := githubtest.SampleFixture()
+	require.NoError(t, err)
+	server := githubtest.NewServer(fixture)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// commentOnFirstPR はPR #1に新しいコメントを付けてserverに反映し、PR #1の更新日時を返す
+func commentOnFirstPR(t *testing.T, server *githubtest.Server) time.Time {
+	t.Helper()
+
+	fixture, err := githubtest.SampleFixture()
+	require.NoError(t, err)
+	repo := fixture.Repositories[0]
+	pr := &repo.PullRequests[0]
+	pr.UpdatedAt = time.Date(2023, 4, 1, 9, 0, 0, 0, time.UTC)
+	pr.IssueComments = append(pr.IssueComments, githubtest.Comment{
+		ID: 104, User: "bob", Body: "Backported to 1.x.", CreatedAt: pr.UpdatedAt,
+	})
+	server.AddRepository(repo)
+	return pr.UpdatedAt
+}
+
+var crawlTestRepo = github.RepositoryRef{Host: github.DefaultHost, Owner: "owner", Name: "repo"}
+
+func TestCrawlRepository(t *testing.T) {
+	server := newCrawlServer(t)
+	dataRoot := t.TempDir()
+	c := newTestCrawler(t, server, dataRoot, "xss", "readme", "nothing-matches")
+
+	summary := c.crawlRepository(context.Background(), crawlTestRepo)
+
+	assert.Empty(t, summary.Error)
+	assert.Empty(t, summary.KeywordErrors)
+	assert.Equal(t, int64(3), summary.PRsFound)
+	assert.Equal(t, int64(3), summary.PRsSaved)
+	assert.Zero(t, summary.PRsFailed)
+
+	repoDir := crawlTestRepo.DataDir(dataRoot)
+	saved, err := store.ReadConversationFile(filepath.Join(repoDir, "pulls", "1.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "Escape user input in templates", saved.PullRequest.Title)
+	assert.Len(t, saved.IssueComments, 2)
+	assert.Len(t, saved.ReviewComments, 2)
+	assert.Len(t, saved.Reviews, 1)
+	assert.FileExists(t, filepath.Join(repoDir, "pulls", "3.json"))
+	assert.FileExists(t, filepath.Join(repoDir, "keywords", "xss", "1.json"))
+	assert.FileExists(t, filepath.Join(repoDir, "keywords", "xss", "3.json"))
+	assert.FileExists(t, filepath.Join(repoDir, "keywords", "readme", "2.json"))
+	assert.NoDirExists(t, filepath.Join(repoDir, "keywords", "nothing-matches"))
+
+	processed, err := loadProcessedSet(c.store, crawlTestRepo)
+	require.NoError(t, err)
+	assert.Equal(t, 3, processed.Len())
+}
+
+func TestCrawlRepository_ResumesFromProcessedPRs(t *testing.T) {
+	server := newCrawlServer(t)
+	dataRoot := t.TempDir()
+
+	first := newTestCrawler(t, server, dataRoot, "xss").crawlRepository(context.Background(), crawlTestRepo)
+	require.Equal(t, int64(2), first.PRsSaved)
+	fetched := server.CountRequests("/repos/")
+
+	second := newTestCrawler(t, server, dataRoot, "xss").crawlRepository(context.Background(), crawlTestRepo)
+
+	assert.Equal(t, int64(2), second.PRsAlreadyProcessed)
+	assert.Zero(t, second.PRsSaved)
+	assert.Equal(t, fetched, server.CountRequests("/repos/"), "processed PRs must not be fetched again")
+}
+
+func TestCrawlRepository_MigratesLegacyLayout(t *testing.T) {
+	server := newCrawlServer(t)
+	dataRoot := t.TempDir()
+	repoDir := crawlTestRepo.DataDir(dataRoot)
+	// 以前の構成では、キーワードごとのディレクトリにPRを保存していた
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, "readme"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "readme", "2.json"), []byte(`{"issue_comments": []}`), 0644))
+
+	summary := newTestCrawler(t, server, dataRoot, "xss").crawlRepository(context.Background(), crawlTestRepo)
+
+	assert.Empty(t, summary.Error)
+	assert.FileExists(t, filepath.Join(repoDir, "pulls", "2.json"))
+	assert.NoDirExists(t, filepath.Join(repoDir, "readme"))
+	assert.FileExists(t, filepath.Join(repoDir, "keywords", "readme", "2.json"))
+	assert.FileExists(t, filepath.Join(repoDir, "keywords", "xss", "1.json"))
+}
+
+func TestCrawlRepository_RecoversFromRateLimits(t *testing.T) {
+	server := newCrawlServer(t)
+	server.Fail(githubtest.Failure{Path: "/search/issues", Status: http.StatusTooManyRequests, RetryAfter: time.Second})
+	server.Fail(githubtest.Failure{Path: "/repos/owner/repo/issues/", Status: http.StatusForbidden})
+	dataRoot := t.TempDir()
+
+	summary := newTestCrawler(t, server, dataRoot, "xss").crawlRepository(context.Background(), crawlTestRepo)
+
+	assert.Empty(t, summary.KeywordErrors)
+	assert.Equal(t, int64(2), summary.PRsSaved)
+	assert.Zero(t, summary.PRsFailed)
+	assert.Equal(t, 2, server.CountRequests("/search/issues"))
+}
+
+func TestCrawlRepository_ReportsFailedPRs(t *testing.T) {
+	server := newCrawlServer(t)
+	server.Fail(githubtest.Failure{Path: "/repos/owner/repo/pulls/3", Status: http.StatusInternalServerError, Count: 10})
+	dataRoot := t.TempDir()
+
+	c := newTestCrawler(t, server, dataRoot, "xss")
+	summary := c.crawlRepository(context.Background(), crawlTestRepo)
+
+	assert.Equal(t, int64(1), summary.PRsSaved)
+	assert.Equal(t, int64(1), summary.PRsFailed)
+
+	processed, err := loadProcessedSet(c.store, crawlTestRepo)
+	require.NoError(t, err)
+	assert.True(t, processed.Contains(1))
+	assert.False(t, processed.Contains(3), "failed PRs must be retried on the next run")
+}
+
+func TestCrawlRepository_Incremental(t *testing.T) {
+	server := newCrawlServer(t)
+	dataRoot := t.TempDir()
+	repoDir := crawlTestRepo.DataDir(dataRoot)
+
+	c := newTestCrawler(t, server, dataRoot, "xss")
+	c.incremental = true
+	first := c.crawlRepository(context.Background(), crawlTestRepo)
+	require.Equal(t, int64(2), first.PRsSaved)
+
+	state, err := c.store.LoadSyncState(crawlTestRepo)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 3, 2, 9, 0, 0, 0, time.UTC), state.LastUpdatedAt.UTC())
+
+	// PR #1に新しいコメントが付いた
+	updatedAt := commentOnFirstPR(t, server)
+
+	second := c.crawlRepository(context.Background(), crawlTestRepo)
+
+	// 検索は記録した時刻を含むため、前回最後に更新されたPR #3も取得し直す
+	assert.Equal(t, int64(2), second.PRsFound)
+	assert.Equal(t, int64(2), second.PRsRefreshed)
+	saved, err := store.ReadConversationFile(filepath.Join(repoDir, "pulls", "1.json"))
+	require.NoError(t, err)
+	assert.Len(t, saved.IssueComments, 3)
+
+	state, err = c.store.LoadSyncState(crawlTestRepo)
+	require.NoError(t, err)
+	assert.Equal(t, updatedAt, state.LastUpdatedAt.UTC())
+}
+
+func TestCrawlRepository_IncrementalRefreshesEachPROnce(t *testing.T) {
+	server := newCrawlServer(t)
+
+	c := newTestCrawler(t, server, t.TempDir(), "xss", "exploitable")
+	c.incremental = true
+	first := c.crawlRepository(context.Background(), crawlTestRepo)
+	require.Equal(t, int64(2), first.PRsSaved)
+
+	// PR #1に新しいコメントが付いた
+	commentOnFirstPR(t, server)
+
+	second := c.crawlRepository(context.Background(), crawlTestRepo)
+
+	// PR #1は両方のキーワードに一致するが、取得し直すのは1回だけ
+	assert.Equal(t, int64(3), second.PRsFound)
+	assert.Equal(t, int64(2), second.PRsRefreshed)
+	assert.Equal(t, int64(1), second.PRsAlreadyProcessed)
+	hits, err := c.store.LoadKeywordHits(crawlTestRepo)
+	require.NoError(t, err)
+	assert.Len(t, hits[1], 2)
+}
+
+func TestCrawlRepository_SQLite(t *testing.T) {
+	server := newCrawlServer(t)
+	db, err := store.OpenSQLite(filepath.Join(t.TempDir(), "pralyzer.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	c := newTestCrawler(t, server, t.TempDir(), "xss", "readme")
+	c.store = db
+	summary := c.crawlRepository(context.Background(), crawlTestRepo)
+
+	assert.Equal(t, int64(3), summary.PRsSaved)
+	saved, err := db.LoadConversation(crawlTestRepo, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Escape user input in templates", saved.PullRequest.Title)
+	assert.Len(t, saved.IssueComments, 2)
+
+	second := c.crawlRepository(context.Background(), crawlTestRepo)
+	assert.Equal(t, int64(3), second.PRsAlreadyProcessed)
+	assert.Zero(t, second.PRsSaved)
+}
+
+func TestCrawlRepository_Gitea(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/issues", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "pulls", r.URL.Query().Get("type"))
+		assert.Equal(t, "xss", r.URL.Query().Get("q"))
+		w.Header().Set("X-Total-Count", "1")
+		w.Write([]byte(`[{"number": 7, "title": "Escape output", "state": "closed", "user": {"login": "alice"},
+			"created_at": "2024-02-01T00:00:00Z", "updated_at": "2024-02-02T00:00:00Z",
+			"pull_request": {"merged": true, "merged_at": "2024-02-02T00:00:00Z"}}]`))
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/pulls/7", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"number": 7, "title": "Escape output", "body": "Fixes an xss.", "state": "closed", "merged": true,
+			"user": {"login": "alice"}, "base": {"ref": "main"}, "head": {"ref": "fix"}}`))
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id": 1, "body": "Thanks!", "user": {"login": "bob"}, "created_at": "2024-02-01T12:00:00Z"}]`))
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/pulls/7/reviews", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Total-Count", "1")
+		w.Write([]byte(`[{"id": 2, "state": "APPROVED", "user": {"login": "bob"}, "submitted_at": "2024-02-02T00:00:00Z"}]`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	dataRoot := t.TempDir()
+	c := &crawler{
+		sources: giteaSources(func(host string) (*gitea.Client, error) {
+			return gitea.NewClient("", host, nil, forge.WithBaseURL(server.URL+"/api/v1"))
+		}, nil),
+		workSize:    1,
+		concurrency: 1,
+		words:       []string{"xss"},
+		baseQuery:   github.DefaultSearchQuery(""),
+		store:       store.NewFileStore(dataRoot),
+	}
+	repo, err := gitea.ParseRepositoryRef("https://codeberg.org/owner/repo")
+	require.NoError(t, err)
+
+	summary := c.crawlRepository(context.Background(), repo)
+
+	assert.Equal(t, int64(1), summary.PRsSaved)
+	saved, err := store.ReadConversationFile(filepath.Join(dataRoot, "codeberg.org", "owner", "repo", "pulls", "7.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "Escape output", saved.PullRequest.Title)
+	assert.Equal(t, "main", saved.PullRequest.BaseRef)
+	require.Len(t, saved.IssueComments, 1)
+	require.Len(t, saved.Reviews, 1)
+	assert.Equal(t, "APPROVED", saved.Reviews[0].GetState())
+}
+
+func TestCrawlRepository_MailingList(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "lists", "devel.mbox")
+	require.NoError(t, os.MkdirAll(filepath.Dir(archive), 0755))
+	require.NoError(t, os.WriteFile(archive, []byte(`From alice@example.org Mon Mar  4 10:00:00 2024
+From: Alice <alice@example.org>
+Subject: [PATCH] parser: fix overflow
+Date: Mon, 4 Mar 2024 10:00:00 +0000
+Message-ID: <patch@example.org>
+
+Check the length first.
+---
+diff --git a/parser.c b/parser.c
+--- a/parser.c
++++ b/parser.c
+@@ -1,2 +1,3 @@
+ int parse(void) {
++	check();
+ }
+
+From bob@example.org Mon Mar  4 11:00:00 2024
+From: Bob <bob@example.org>
+Subject: Re: [PATCH] parser: fix overflow
+Date: Mon, 4 Mar 2024 11:00:00 +0000
+Message-ID: <reply@example.org>
+In-Reply-To: <patch@example.org>
+
+> @@ -1,2 +1,3 @@
+>  int parse(void) {
+> +	check();
+
+This still overflows when len is zero.
+`), 0644))
+
+	archives := newMailArchives()
+	dataRoot := t.TempDir()
+	c := &crawler{
+		sources:     archives.sources(),
+		workSize:    1,
+		concurrency: 1,
+		words:       []string{"overflow"},
+		baseQuery:   github.DefaultSearchQuery(""),
+		store:       store.NewFileStore(dataRoot),
+	}
+	repo, err := archives.parse(archive)
+	require.NoError(t, err)
+
+	summary := c.crawlRepository(context.Background(), repo)
+
+	assert.Equal(t, int64(1), summary.PRsSaved)
+	files, err := filepath.Glob(filepath.Join(dataRoot, "mail", "lists", "devel", "pulls", "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	saved, err := store.ReadConversationFile(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, "[PATCH] parser: fix overflow", saved.PullRequest.Title)
+	require.Len(t, saved.ReviewComments, 1)
+	assert.Equal(t, "parser.c", saved.ReviewComments[0].GetPath())
+	assert.Equal(t, 2, saved.ReviewComments[0].GetLine())
+}
+
+func TestCrawlRepository_GitHistory(t *testing.T) {
+	server := newCrawlServer(t)
+	dataRoot := t.TempDir()
+	c := newTestCrawler(t, server, dataRoot, "xss", "CVE-2024-1234")
+	c.sources = withGitHistory(c.sources, map[string][]int{"xss": {1}, "CVE-2024-1234": {1, 3}}, "")
+
+	summary := c.crawlRepository(context.Background(), crawlTestRepo)
+
+	assert.Empty(t, summary.KeywordErrors)
+	assert.Equal(t, int64(3), summary.PRsFound)
+	// 履歴から見つけたPRは検索APIを使わずに取得する
+	assert.Zero(t, server.CountRequests("/search/issues"))
+	repoDir := crawlTestRepo.DataDir(dataRoot)
+	assert.FileExists(t, filepath.Join(repoDir, "keywords", "xss", "1.json"))
+	assert.NoFileExists(t, filepath.Join(repoDir, "keywords", "xss", "3.json"))
+	assert.FileExists(t, filepath.Join(repoDir, "keywords", "CVE-2024-1234", "3.json"))
+	hits, err := c.store.LoadKeywordHits(crawlTestRepo)
+	require.NoError(t, err)
+	assert.Equal(t, "git log HEAD xss", hits[1][0].Query)
+}
+
+func TestCrawlRepository_RecordsEveryKeywordHit(t *testing.T) {
+	server := newCrawlServer(t)
+	dataRoot := t.TempDir()
+	c := newTestCrawler(t, server, dataRoot, "xss", "CVE-2024-1234")
+	c.sources = withGitHistory(c.sources, map[string][]int{"xss": {1, 3}, "CVE-2024-1234": {1}}, "v1.0..v2.0")
+
+	summary := c.crawlRepository(context.Background(), crawlTestRepo)
+
+	// PR #1は1回だけ取得し、2つ目のキーワードでは処理済みとして一致だけを記録する
+	assert.Equal(t, int64(2), summary.PRsSaved)
+	assert.Equal(t, int64(1), summary.PRsAlreadyProcessed)
+	hits, err := c.store.LoadKeywordHits(crawlTestRepo)
+	require.NoError(t, err)
+	require.Len(t, hits[1], 2)
+	assert.Equal(t, "xss", hits[1][0].Keyword)
+	assert.Equal(t, "CVE-2024-1234", hits[1][1].Keyword)
+	assert.Equal(t, "git log v1.0..v2.0 CVE-2024-1234", hits[1][1].Query)
+	assert.False(t, hits[1][1].MatchedAt.IsZero())
+	require.Len(t, hits[3], 1)
+
+	repoDir := crawlTestRepo.DataDir(dataRoot)
+	assert.FileExists(t, filepath.Join(repoDir, "keywords", "xss", "1.json"))
+	assert.FileExists(t, filepath.Join(repoDir, "keywords", "CVE-2024-1234", "1.json"))
+	assert.NoFileExists(t, filepath.Join(repoDir, "keywords", "CVE-2024-1234", "3.json"))
+}
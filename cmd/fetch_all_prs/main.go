@@ -1,64 +1,256 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"iter"
 	"log"
+	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/cassette"
 	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/interrupt"
+	"github.com/malsuke/PRalyzer/internal/target"
 )
 
+// fetchStateFileName は次回の実行で再開するページ番号を保存するファイル名
+const fetchStateFileName = ".fetch_all_prs_state.json"
+
 func main() {
-	if len(os.Args) < 3 {
-		log.Fatal("Usage: go run cmd/fetch_all_prs/main.go <repository-url> <github-pat>")
+	os.Exit(run())
+}
+
+/**
+ * 対象のリポジトリごとにすべてのPRを取得し、終了コードを返す
+ * deferした後片付けが終わってから終了できるよう、os.Exitはmainだけで呼ぶ
+ */
+func run() int {
+	targets := target.RegisterFlags(flag.CommandLine)
+	tape := cassette.RegisterFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: go run ./cmd/fetch_all_prs [-record <file> | -replay <file>] <repository-url> [github-pat]\n       go run ./cmd/fetch_all_prs [-record <file> | -replay <file>] -org <name> | -user <name> | -repos <file> [github-pat]\n       Set %s to spread requests across multiple tokens.\n", github.TokensEnvVar)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if err := targets.Validate(); err != nil {
+		log.Printf("Invalid repository options: %v", err)
+		return 2
 	}
 
-	repoURL := os.Args[1]
-	githubPAT := os.Args[2]
+	// 単一リポジトリのモードでは最初の引数がリポジトリ、残りがPAT
+	args := flag.Args()
+	var patArgs []string
+	var single github.RepositoryRef
+	if targets.MultiRepository() {
+		patArgs = args
+	} else {
+		if len(args) < 1 {
+			flag.Usage()
+			return 2
+		}
+		var err error
+		single, err = github.ParseRepositoryRef(args[0])
+		if err != nil {
+			log.Printf("Invalid repository: %v", err)
+			return 2
+		}
+		patArgs = args[1:]
+	}
 
-	client, err := github.NewClient(githubPAT, repoURL, nil)
+	tokens, err := github.LoadTokens("")
 	if err != nil {
-		log.Fatalf("Failed to create GitHub client: %v", err)
+		log.Printf("Failed to load GitHub tokens: %v", err)
+		return 1
+	}
+	if len(patArgs) >= 1 {
+		tokens = append([]string{patArgs[0]}, tokens...)
 	}
 
-	fmt.Printf("========================================\n")
-	fmt.Printf("Fetching all pull requests from %s/%s\n", client.Owner, client.Name)
-	fmt.Printf("========================================\n\n")
+	// -record・-replayが指定されていれば、すべてのリクエストをカセットに記録するか、カセットから再生する
+	recording, err := tape.Open()
+	if err != nil {
+		log.Printf("Failed to open cassette: %v", err)
+		return 1
+	}
+	var httpClient *http.Client
+	if recording != nil {
+		defer recording.Close()
+		httpClient = recording.HTTPClient()
+	}
+	// 再生ではネットワークに接続しないため、トークンは不要
+	if len(tokens) == 0 && (recording == nil || recording.Mode() != cassette.ModeReplay) {
+		log.Printf("No GitHub token provided: pass <github-pat> or set %s", github.TokensEnvVar)
+		return 1
+	}
 
-	startTime := time.Now()
+	// ホストごとにClientを1つ作り、リポジトリ間でトークンのレート制限を共有する
+	clients := make(map[string]*github.Client)
+	clientFor := func(host string) (*github.Client, error) {
+		if client, ok := clients[host]; ok {
+			return client, nil
+		}
+		client, err := github.NewHostClient("", host, httpClient, github.WithTokens(tokens))
+		if err != nil {
+			return nil, err
+		}
+		clients[host] = client
+		return client, nil
+	}
+
+	// Ctrl-C（SIGINT）やSIGTERMを受け取ったら取得を打ち切る（取得済みのページは保存済み）
+	ctx, stop := interrupt.NotifyContext(context.Background())
+	defer stop()
 
-	// すべてのPRを取得
-	prs, err := client.ListAllPullRequests()
+	repos := []github.RepositoryRef{single}
+	failedRepos := 0
+	if targets.MultiRepository() {
+		var unresolved []target.Failure
+		repos, unresolved, err = targets.Resolve(ctx, clientFor)
+		if err != nil {
+			log.Printf("Failed to list repositories: %v", err)
+			return 1
+		}
+		failedRepos += len(unresolved)
+		fmt.Printf("Fetching %d repositories\n\n", len(repos))
+	}
+
+	for _, ref := range repos {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := fetchRepository(ctx, clientFor, ref); err != nil {
+			log.Printf("❌ %s: %v", ref, err)
+			failedRepos++
+		}
+	}
+
+	if ctx.Err() != nil {
+		fmt.Printf("Interrupted before all pull requests were fetched. Run again to resume.\n")
+		return 1
+	}
+	if failedRepos > 0 {
+		fmt.Printf("%d repositories could not be fetched. Run again to resume.\n", failedRepos)
+		return 1
+	}
+	fmt.Printf("✓ Done!\n")
+	return 0
+}
+
+/**
+ * refのすべてのPRをdata/<owner>/<repo>/に保存し、結果を表示する
+ * 前回の実行で最後に保存し終えたページの次から再開する
+ */
+func fetchRepository(ctx context.Context, clientFor func(host string) (*github.Client, error), ref github.RepositoryRef) error {
+	hostClient, err := clientFor(ref.Host)
 	if err != nil {
-		log.Fatalf("Failed to list pull requests: %v", err)
+		return fmt.Errorf("failed to create GitHub client: %w", err)
+	}
+	client, err := hostClient.ForRepository(ref)
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub client: %w", err)
 	}
 
-	fetchDuration := time.Since(startTime)
-	fmt.Printf("\n✓ Successfully fetched %d pull requests in %v\n", len(prs), fetchDuration.Round(time.Second))
-	fmt.Printf("\n========================================\n")
-	fmt.Printf("Saving pull requests to JSON files...\n")
+	fmt.Printf("========================================\n")
+	fmt.Printf("Fetching all pull requests from %s\n", client.Repository)
 	fmt.Printf("========================================\n\n")
 
 	// 出力ディレクトリを作成
-	outputDir := filepath.Join("data", client.Owner, client.Name)
+	outputDir := client.Repository.DataDir("data")
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		log.Fatalf("Failed to create output directory: %v", err)
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	stateFile := filepath.Join(outputDir, fetchStateFileName)
+	state, err := loadFetchState(stateFile)
+	if err != nil {
+		return err
+	}
+	if state.NextPage > 1 {
+		fmt.Printf("Resuming from page %d (delete %s to start over)\n\n", state.NextPage, stateFile)
+	}
+
+	startTime := time.Now()
+	summary, fetchErr := fetchPages(client.PullRequestPages(ctx, state.NextPage), outputDir, stateFile)
+	if fetchErr != nil && ctx.Err() != nil {
+		log.Printf("⚠️  Interrupted while fetching. %d pull requests have been saved.", summary.saved)
+		fetchErr = nil
 	}
 
-	totalPRs := len(prs)
-	savedCount := 0
-	skippedCount := 0
-	errorCount := 0
-	lastProgressTime := time.Now()
+	totalDuration := time.Since(startTime)
+
+	fmt.Printf("\n========================================\n")
+	fmt.Printf("Summary\n")
+	fmt.Printf("========================================\n")
+	fmt.Printf("Successfully saved:     %d\n", summary.saved)
+	fmt.Printf("Skipped:                %d\n", summary.skipped)
+	fmt.Printf("Errors:                 %d\n", summary.failed)
+	fmt.Printf("Total duration:         %v\n", totalDuration.Round(time.Second))
+	fmt.Printf("Output directory:       %s\n", outputDir)
+	fmt.Printf("========================================\n\n")
+	if fetchErr != nil {
+		return fmt.Errorf("failed to list pull requests: %w (saved %d pull requests; run again to resume)", fetchErr, summary.saved)
+	}
+	return nil
+}
+
+// fetchSummary は保存・スキップ・失敗したPRの件数
+type fetchSummary struct {
+	saved   int
+	skipped int
+	failed  int
+}
 
-	// 各PRをJSONファイルに保存
-	for i, pr := range prs {
+/**
+ * pagesのPRを1ページ取得するごとにJSONファイルに保存し、再開位置をstateFileに記録する
+ * 書き込みに失敗したPRがあれば、次回はそのページからやり直すよう、以降のページでは再開位置を進めない
+ */
+func fetchPages(pages iter.Seq2[github.Page[*gh.PullRequest], error], outputDir, stateFile string) (fetchSummary, error) {
+	var summary fetchSummary
+	failedPage := 0
+	for page, err := range pages {
+		if err != nil {
+			return summary, err
+		}
+
+		saved, skipped, failed := savePullRequests(page.Items, outputDir)
+		summary.saved += saved
+		summary.skipped += skipped
+		summary.failed += failed
+		fmt.Printf("  ✓ Saved page %d: %d PRs (total: %d PRs)\n", page.Number, saved, summary.saved)
+
+		if failed > 0 && failedPage == 0 {
+			failedPage = page.Number
+		}
+		if failedPage != 0 {
+			continue
+		}
+		// 最後のページは次回もう一度取得し、その後に作られたPRを拾う
+		next := page.NextPage
+		if next == 0 {
+			next = page.Number
+		}
+		if err := saveFetchState(stateFile, fetchState{NextPage: next}); err != nil {
+			log.Printf("⚠️  Failed to save fetch state: %v", err)
+		}
+	}
+	if failedPage != 0 {
+		log.Printf("⚠️  Some pull requests could not be saved. The next run resumes from page %d.", failedPage)
+	}
+	return summary, nil
+}
+
+// savePullRequests は1ページ分のPRをoutputDir/<PR番号>.jsonに保存し、保存・スキップ・失敗の件数を返す
+func savePullRequests(prs []*gh.PullRequest, outputDir string) (saved, skipped, failed int) {
+	for _, pr := range prs {
 		if pr.Number == nil {
 			log.Printf("⚠️  Skipping PR with nil number")
-			skippedCount++
+			skipped++
 			continue
 		}
 
@@ -69,42 +261,58 @@ func main() {
 		data, err := json.MarshalIndent(pr, "", "  ")
 		if err != nil {
 			log.Printf("❌ Failed to marshal PR #%d: %v", prNumber, err)
-			errorCount++
+			failed++
 			continue
 		}
 
 		// ファイルに書き込む
 		if err := os.WriteFile(outputPath, data, 0644); err != nil {
 			log.Printf("❌ Failed to write PR #%d to file: %v", prNumber, err)
-			errorCount++
+			failed++
 			continue
 		}
 
-		savedCount++
-		currentProgress := i + 1
+		saved++
+	}
+	return saved, skipped, failed
+}
+
+// fetchState は次回の実行で取得を始めるページ番号
+type fetchState struct {
+	NextPage int `json:"next_page"`
+}
 
-		// 10件ごと、または5秒ごとに進捗を表示
-		if currentProgress%10 == 0 || time.Since(lastProgressTime) >= 5*time.Second {
-			percentage := float64(currentProgress) / float64(totalPRs) * 100
-			fmt.Printf("Progress: %d/%d (%.1f%%) - Saved PR #%d\n", currentProgress, totalPRs, percentage, prNumber)
-			lastProgressTime = time.Now()
+// loadFetchState はfileから再開位置を読み込む。ファイルがなければ最初のページから始める。
+func loadFetchState(file string) (fetchState, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fetchState{NextPage: 1}, nil
 		}
+		return fetchState{}, fmt.Errorf("failed to read fetch state file: %w", err)
 	}
 
-	saveDuration := time.Since(startTime.Add(fetchDuration))
-	totalDuration := time.Since(startTime)
+	var state fetchState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fetchState{}, fmt.Errorf("failed to parse fetch state JSON: %w", err)
+	}
+	return state, nil
+}
 
-	fmt.Printf("\n========================================\n")
-	fmt.Printf("Summary\n")
-	fmt.Printf("========================================\n")
-	fmt.Printf("Total PRs fetched:     %d\n", totalPRs)
-	fmt.Printf("Successfully saved:     %d\n", savedCount)
-	fmt.Printf("Skipped:                %d\n", skippedCount)
-	fmt.Printf("Errors:                 %d\n", errorCount)
-	fmt.Printf("Fetch duration:         %v\n", fetchDuration.Round(time.Second))
-	fmt.Printf("Save duration:          %v\n", saveDuration.Round(time.Second))
-	fmt.Printf("Total duration:         %v\n", totalDuration.Round(time.Second))
-	fmt.Printf("Output directory:       %s\n", outputDir)
-	fmt.Printf("========================================\n")
-	fmt.Printf("✓ Done!\n")
+// saveFetchState は再開位置をfileに保存する
+// 書き込み途中で中断しても既存のファイルが壊れないよう、一時ファイルに書いてから置き換える
+func saveFetchState(file string, state fetchState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal fetch state: %w", err)
+	}
+
+	tmpPath := file + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmpPath, file); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
 }
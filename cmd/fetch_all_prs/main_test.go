@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagesOf はpagesを順に返すイテレータ
+func pagesOf(pages ...github.Page[*gh.PullRequest]) iter.Seq2[github.Page[*gh.PullRequest], error] {
+	return func(yield func(github.Page[*gh.PullRequest], error) bool) {
+		for _, page := range pages {
+			if !yield(page, nil) {
+				return
+			}
+		}
+	}
+}
+
+func pullRequests(numbers ...int) []*gh.PullRequest {
+	prs := make([]*gh.PullRequest, 0, len(numbers))
+	for _, n := range numbers {
+		prs = append(prs, &gh.PullRequest{Number: gh.Ptr(n)})
+	}
+	return prs
+}
+
+func TestFetchPages(t *testing.T) {
+	pages := []github.Page[*gh.PullRequest]{
+		{Number: 1, NextPage: 2, Items: pullRequests(1, 2)},
+		{Number: 2, NextPage: 3, Items: pullRequests(3, 4)},
+		{Number: 3, NextPage: 4, Items: pullRequests(5, 6)},
+		{Number: 4, Items: pullRequests(7)},
+	}
+
+	tests := []struct {
+		name string
+		// unwritable は書き込みに失敗させるPRの番号
+		unwritable []int
+		want       fetchSummary
+		wantNext   int
+	}{
+		{name: "all saved", want: fetchSummary{saved: 7}, wantNext: 4},
+		{name: "first failed page is kept", unwritable: []int{3}, want: fetchSummary{saved: 6, failed: 1}, wantNext: 2},
+		{name: "later failures do not move the resume page", unwritable: []int{3, 6}, want: fetchSummary{saved: 5, failed: 2}, wantNext: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			stateFile := filepath.Join(outputDir, fetchStateFileName)
+			// 同じ名前のディレクトリがあるとファイルを書き込めない
+			for _, n := range tt.unwritable {
+				require.NoError(t, os.Mkdir(filepath.Join(outputDir, fmt.Sprintf("%d.json", n)), 0755))
+			}
+
+			summary, err := fetchPages(pagesOf(pages...), outputDir, stateFile)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, summary)
+
+			state, err := loadFetchState(stateFile)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNext, state.NextPage)
+		})
+	}
+}
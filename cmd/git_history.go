@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/malsuke/PRalyzer/internal/githistory"
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// historySource はgitの履歴から見つけたPR番号を、キーワードの検索結果として返すreviewSource
+// 会話の取得と一覧には元の取得元をそのまま使う
+type historySource struct {
+	reviewSource
+	// prs はキーワード（またはCVE・GHSAのID）ごとのPR番号
+	prs map[string][]int
+	// revisionRange はprsを見つけたgitの範囲
+	revisionRange string
+}
+
+// SearchPullRequestResults はquery.Keywordに一致したコミットのPR番号を返す。履歴には更新日時がないため、UpdatedAtは設定しない
+func (s historySource) SearchPullRequestResults(ctx context.Context, query github.SearchQuery) ([]github.SearchResult, error) {
+	var results []github.SearchResult
+	for _, number := range s.prs[query.Keyword] {
+		results = append(results, github.SearchResult{Number: number})
+	}
+	return results, nil
+}
+
+// DescribeQuery は検索APIのクエリの代わりに、履歴を読んだgitの範囲とキーワードを返す
+func (s historySource) DescribeQuery(repo github.RepositoryRef, query github.SearchQuery) string {
+	return fmt.Sprintf("git log %s %s", s.revisionRange, query.Keyword)
+}
+
+// withGitHistory はopenの取得元の検索を、gitのrevisionRangeの履歴から見つけたprsのPR番号に置き換える
+func withGitHistory(open sourceOpener, prs map[string][]int, revisionRange string) sourceOpener {
+	if revisionRange == "" {
+		revisionRange = githistory.DefaultRange
+	}
+	return func(repo github.RepositoryRef) (reviewSource, github.ConversationFetcher, error) {
+		source, fetcher, err := open(repo)
+		if err != nil {
+			return nil, nil, err
+		}
+		return historySource{reviewSource: source, prs: prs, revisionRange: revisionRange}, fetcher, nil
+	}
+}
+
+/**
+ * dirのgit logからwordsのキーワードを含むか、CVE・GHSAのIDに言及するコミットを探し、
+ * キーワードごとのPR番号と、クロールに使うキーワード（wordsに見つかったIDを加えたもの）を返す
+ * PRに結び付けられなかったコミットは件数だけを表示する
+ */
+func mineGitHistory(ctx context.Context, dir, revisionRange string, words []string) (map[string][]int, []string, error) {
+	commits, err := githistory.ReadLog(ctx, dir, revisionRange)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	findings := githistory.Mine(commits, words)
+	matched := make(map[string]bool)
+	unlinked := make(map[string]bool)
+	for _, finding := range findings {
+		matched[finding.Commit] = true
+		if finding.PullRequest == 0 {
+			unlinked[finding.Commit] = true
+		}
+	}
+	fmt.Printf("Scanned %d commits in %s: %d matched, %d could not be linked to a PR\n", len(commits), dir, len(matched), len(unlinked))
+
+	prs := githistory.GroupByKeyword(findings)
+	keywords := slices.Clone(words)
+	for _, keyword := range slices.Sorted(maps.Keys(prs)) {
+		if !slices.Contains(keywords, keyword) {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return prs, keywords, nil
+}
+
+// validateGitHistoryOptions は-git-historyと組み合わせられないオプションが指定されていないことを確認する
+// 履歴は1つのクローンから読むため単一リポジトリのモードでだけ使え、PR番号を持たないメーリングリストや、
+// 更新日時を使う差分同期とは組み合わせられない。履歴から見つけたPRには検索条件（searchFlags）を適用できないため、指定されていればエラーにする
+func validateGitHistoryOptions(provider string, multiRepository, incremental bool, searchFlags []string) error {
+	switch {
+	case multiRepository:
+		return fmt.Errorf("-git-history crawls a single repository; -org, -user and -repos cannot be used")
+	case provider == providerMailingList:
+		return fmt.Errorf("-git-history is not supported with -provider %s", provider)
+	case incremental:
+		return fmt.Errorf("-git-history cannot be combined with -incremental")
+	case len(searchFlags) > 0:
+		return fmt.Errorf("-git-history finds PRs through commits and cannot apply the search options %s", strings.Join(searchFlags, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateGitHistoryOptions_RejectsSearchFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "no search flags", args: []string{"-git-range", "v1.0..v2.0"}},
+		{name: "search flags", args: []string{"-merged", "any", "-author", "alice", "-created", ">=2024-01-01"}, wantErr: "-author, -created, -merged"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			search := registerSearchFlags(fs)
+			fs.String("git-range", "", "")
+			require.NoError(t, fs.Parse(tt.args))
+
+			err := validateGitHistoryOptions(providerGitHub, false, false, search.explicitFlags())
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
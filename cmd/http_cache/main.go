@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// defaultCacheDir はcmd/main.goの-cache-dirで例として案内しているキャッシュのディレクトリ
+const defaultCacheDir = "data/.http_cache"
+
+const usage = `Usage: go run ./cmd/http_cache [-dir <cache-dir>] stats
+       go run ./cmd/http_cache [-dir <cache-dir>] purge [-older-than <duration>]`
+
+func main() {
+	dir := flag.String("dir", defaultCacheDir, "directory of the HTTP response cache")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if _, err := os.Stat(*dir); err != nil {
+		log.Fatalf("HTTP cache directory not found: %v", err)
+	}
+
+	cache, err := github.NewHTTPCache(*dir)
+	if err != nil {
+		log.Fatalf("Failed to open HTTP cache: %v", err)
+	}
+
+	switch command := flag.Arg(0); command {
+	case "stats":
+		printUsage(cache)
+	case "purge":
+		purge(cache, flag.Args()[1:])
+	default:
+		log.Fatalf("Unknown command %q: use stats or purge", command)
+	}
+}
+
+// printUsage はキャッシュのエントリ数と合計サイズを表示する
+func printUsage(cache *github.HTTPCache) {
+	usage, err := cache.Usage()
+	if err != nil {
+		log.Fatalf("Failed to read HTTP cache: %v", err)
+	}
+	fmt.Printf("Directory: %s\n", cache.Dir())
+	fmt.Printf("Entries:   %d\n", usage.Entries)
+	fmt.Printf("Size:      %.1f MiB\n", float64(usage.Bytes)/(1<<20))
+}
+
+// purge はキャッシュのエントリを削除する。-older-thanを指定した場合は、その期間使われていないエントリだけを削除する。
+func purge(cache *github.HTTPCache, args []string) {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	olderThan := fs.Duration("older-than", 0, "only remove entries not used for this long (e.g. 720h); 0 removes everything")
+	fs.Parse(args)
+	if *olderThan < 0 {
+		log.Fatalf("Invalid -older-than %v: must not be negative", *olderThan)
+	}
+
+	removed, err := cache.Purge(*olderThan)
+	if err != nil {
+		log.Fatalf("Failed to purge HTTP cache (removed %d entries): %v", removed, err)
+	}
+	if *olderThan > 0 {
+		fmt.Printf("Removed %d entries not used for %v\n", removed, olderThan.Round(time.Second))
+	} else {
+		fmt.Printf("Removed %d entries\n", removed)
+	}
+}
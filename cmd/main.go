@@ -1,201 +1,346 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
-	"path/filepath"
+	"slices"
 	"sort"
-	"strings"
 	"time"
 
 	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/cassette"
+	"github.com/malsuke/PRalyzer/internal/gitea"
 	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/gitlab"
+	"github.com/malsuke/PRalyzer/internal/interrupt"
+	"github.com/malsuke/PRalyzer/internal/store"
+	"github.com/malsuke/PRalyzer/internal/target"
 )
 
+// 会話の取得に使うAPI
+const (
+	backendREST    = "rest"
+	backendGraphQL = "graphql"
+)
+
+// defaultBatchSize はGraphQLで1回のリクエストにまとめるPRの数
+const defaultBatchSize = 10
+
+// defaultConcurrency は会話を並行して取得するワーカーの数
+const defaultConcurrency = 4
+
+// dataRoot は取得したデータを保存するディレクトリ
+const dataRoot = "data"
+
+// defaultSummaryFile は実行結果のまとめを書き込むファイル
+const defaultSummaryFile = dataRoot + "/run_summary.json"
+
+// defaultCacheDir はレスポンスのキャッシュを置くディレクトリの例（-cache-dirのヘルプに表示する）
+const defaultCacheDir = dataRoot + "/.http_cache"
+
+const usage = `Usage: PRalyzer [flags] <repository-url> [github-pat]
+       PRalyzer [flags] -org <name> | -user <name> | -repos <file> [github-pat]
+       PRalyzer -provider gitlab [flags] <project-url> | -repos <file> [gitlab-token]
+       PRalyzer -provider gitea [flags] <repository-url> | -repos <file> [gitea-token]
+       PRalyzer -provider mailinglist [flags] <mbox-file|maildir> | -repos <file>
+Note: GitHub PAT is optional but recommended to avoid rate limiting.
+      Multiple tokens can be given with -token-file or the ` + github.TokensEnvVar + ` environment variable.
+      GitLab and Gitea tokens can also be given with the ` + gitlab.TokenEnvVar + ` and ` + gitea.TokenEnvVar + ` environment variables.`
+
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: PRalyzer <repository-url> [github-pat]\nNote: GitHub PAT is optional but recommended to avoid rate limiting")
+	os.Exit(run())
+}
+
+/**
+ * フラグで指定されたリポジトリをクロールし、終了コードを返す
+ * 終了コードを返してmainで終了することで、deferしたストア・カセットのCloseとシグナルのstopを必ず実行する
+ */
+func run() int {
+	search := registerSearchFlags(flag.CommandLine)
+	targets := target.RegisterFlags(flag.CommandLine)
+	provider := flag.String("provider", providerGitHub, "where the repositories are hosted: github, gitlab (merge requests), gitea (also Forgejo) or mailinglist (patches in a local mbox/maildir archive)")
+	backend := flag.String("backend", backendREST, "API used to fetch PR conversations: rest or graphql")
+	batchSize := flag.Int("batch-size", defaultBatchSize, "number of PRs fetched per request with the graphql backend")
+	concurrency := flag.Int("concurrency", defaultConcurrency, "number of workers fetching PR conversations in parallel")
+	tokenFile := flag.String("token-file", "", "file with one GitHub token per line; requests are spread across all tokens")
+	appID := flag.Int64("app-id", 0, "authenticate as this GitHub App instead of with tokens (requires -app-installation-id and -app-key)")
+	installationID := flag.Int64("app-installation-id", 0, "installation ID of the GitHub App")
+	appKeyFile := flag.String("app-key", "", "path to the GitHub App private key (PEM)")
+	summaryFile := flag.String("summary", defaultSummaryFile, "file to write the combined run summary to")
+	cacheDir := flag.String("cache-dir", "", "cache GET responses in this directory and revalidate them with ETag/Last-Modified (e.g. "+defaultCacheDir+")")
+	tape := cassette.RegisterFlags(flag.CommandLine)
+	incremental := flag.Bool("incremental", false, "only fetch PRs updated since the last incremental run and merge new comments into the saved JSON")
+	gitHistory := flag.String("git-history", "", "instead of searching the API, find PRs through commits in this local clone whose messages match the word list or mention CVE/GHSA IDs")
+	gitRange := flag.String("git-range", "", "revision range of the -git-history clone to scan (default: HEAD)")
+	dbFile := flag.String("db", "", "save PRs, processed PR numbers and sync state in this SQLite database instead of JSON files under "+dataRoot)
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
 	}
+	flag.Parse()
 
-	repoURL := os.Args[1]
-	var githubPAT string
-	if len(os.Args) >= 3 {
-		githubPAT = os.Args[2]
+	if err := targets.Validate(); err != nil {
+		log.Printf("Invalid repository options: %v", err)
+		return 1
+	}
+	if err := validateProvider(*provider); err != nil {
+		log.Printf("Invalid provider: %v", err)
+		return 1
+	}
+	if err := validateProviderOptions(*provider, *backend, targets, *appID); err != nil {
+		log.Printf("Invalid options: %v", err)
+		return 1
+	}
+	archives := newMailArchives()
+	if *gitHistory != "" {
+		if err := validateGitHistoryOptions(*provider, targets.MultiRepository(), *incremental, search.explicitFlags()); err != nil {
+			log.Printf("Invalid options: %v", err)
+			return 1
+		}
+	}
+	parseRef := parseRepositoryRef(*provider, archives)
+
+	// 単一リポジトリのモードでは最初の引数がリポジトリ、残りがPAT
+	args := flag.Args()
+	var patArgs []string
+	var single github.RepositoryRef
+	if targets.MultiRepository() {
+		patArgs = args
 	} else {
-		fmt.Println("Warning: No GitHub PAT provided. Rate limiting may occur.")
+		if len(args) < 1 {
+			flag.Usage()
+			return 2
+		}
+		var err error
+		single, err = parseRef(args[0])
+		if err != nil {
+			log.Printf("Invalid repository: %v", err)
+			return 1
+		}
+		patArgs = args[1:]
 	}
 
-	client, err := github.NewClient(githubPAT, repoURL, nil)
+	baseQuery, err := search.buildQuery()
 	if err != nil {
-		log.Fatalf("Failed to create GitHub client: %v", err)
+		log.Printf("Invalid search options: %v", err)
+		return 1
 	}
 
-	words, err := loadWordList("word_list.json")
+	if err := validateBackend(*backend); err != nil {
+		log.Printf("Invalid backend: %v", err)
+		return 1
+	}
+	if *batchSize < 1 {
+		log.Printf("Invalid -batch-size %d: must be at least 1", *batchSize)
+		return 1
+	}
+	if *concurrency < 1 {
+		log.Printf("Invalid -concurrency %d: must be at least 1", *concurrency)
+		return 1
+	}
+
+	// -record・-replayが指定されていれば、すべてのリクエストをカセットに記録するか、カセットから再生する
+	// 検索期間の終端は記録を開始した時刻に固定し、再生時も同じクエリになるようにする
+	var cache *github.HTTPCache
+	var httpClient *http.Client
+	var clientOpts []github.ClientOption
+	if tape.Enabled() && *cacheDir != "" {
+		log.Printf("-cache-dir cannot be combined with -record or -replay")
+		return 1
+	}
+	recording, err := tape.Open()
 	if err != nil {
-		log.Fatalf("Failed to load word list: %v", err)
+		log.Printf("Failed to open cassette: %v", err)
+		return 1
+	}
+	if recording != nil {
+		defer recording.Close()
+		httpClient = recording.HTTPClient()
+		clientOpts = append(clientOpts, github.WithClock(recording.RecordedAt))
 	}
 
-	// ベースデータディレクトリ
-	baseDataDir := filepath.Join("data", client.Owner, client.Name)
+	// -cache-dirが指定されていれば、変更のないレスポンスを304で済ませてレート制限を節約する
+	if *cacheDir != "" {
+		cache, err = github.NewHTTPCache(*cacheDir)
+		if err != nil {
+			log.Printf("Failed to open HTTP cache: %v", err)
+			return 1
+		}
+		httpClient = cache.HTTPClient()
+	}
 
-	// 処理済みPR番号を読み込む
-	processedPRsFile := filepath.Join(baseDataDir, ".processed_prs.json")
-	processedPRs, err := loadProcessedPRs(processedPRsFile)
+	var newHostClient func(host string) (*github.Client, error)
+	switch {
+	case *provider != providerGitHub:
+		// GitLab・Gitea・メーリングリストではGitHubのClientを使わない
+	case *appID != 0:
+		newHostClient, err = appClientFactory(*appID, *installationID, *appKeyFile, httpClient, clientOpts)
+	default:
+		newHostClient, err = tokenClientFactory(patArgs, *tokenFile, httpClient, clientOpts)
+	}
 	if err != nil {
-		log.Printf("Failed to load processed PRs (will start fresh): %v", err)
-		processedPRs = make(map[int]bool)
-	} else {
-		fmt.Printf("Loaded %d previously processed PRs\n", len(processedPRs))
+		log.Printf("Failed to create GitHub client: %v", err)
+		return 1
 	}
 
-	// キーワードごとに処理
-	for _, word := range words {
-		fmt.Printf("\n=== Processing keyword: %s ===\n", word)
+	// Ctrl-C（SIGINT）やSIGTERMを受け取ったら取得中の処理をキャンセルし、進捗を保存して終了する
+	ctx, stop := interrupt.NotifyContext(context.Background())
+	defer stop()
 
-		// 1. キーワードでPRを検索（リトライループ）
-		var prNumbers []int
-		var err error
-		for {
-			prNumbers, err = client.SearchPullRequestsWithCommentKeyword(word)
-			if err != nil {
-				if isRateLimitError(err) {
-					log.Printf("Rate limit exceeded while searching PRs with keyword '%s'.", word)
-					// 処理済みPR番号を保存
-					if err := saveProcessedPRs(processedPRsFile, processedPRs); err != nil {
-						log.Printf("Failed to save processed PRs: %v", err)
-					}
-					// 90分待機してからリトライ
-					waitForRateLimit(90 * time.Minute)
-					continue // リトライ
-				}
-				log.Printf("Failed to search PRs with keyword '%s': %v", word, err)
-				break // エラーがレート制限以外の場合はループを抜ける
-			}
-			break // 成功したらループを抜ける
-		}
+	words, err := loadWordList("word_list.json")
+	if err != nil {
+		log.Printf("Failed to load word list: %v", err)
+		return 1
+	}
+
+	// RESTではPR1件ずつ、GraphQLではbatch-size件ずつワーカーに渡す
+	workSize := 1
+	if *backend == backendGraphQL {
+		workSize = *batchSize
+	}
+
+	// -dbが指定されていればSQLiteに、なければ従来どおりdata/以下のJSONファイルに保存する
+	st, err := openStore(*dbFile)
+	if err != nil {
+		log.Printf("Failed to open store: %v", err)
+		return 1
+	}
+	defer st.Close()
+
+	c := &crawler{
+		workSize:    workSize,
+		concurrency: *concurrency,
+		words:       words,
+		baseQuery:   baseQuery,
+		store:       st,
+		incremental: *incremental,
+	}
+	// レート制限で長時間待機する前に処理済みPR番号を保存しておく
+	// 全ワーカーが同じクライアントを共有しているため、待機もまとめて行われる
+	onWait := func(resource string, wait time.Duration) {
+		log.Printf("Rate limit for '%s' exhausted. Saving progress before waiting %v.", resource, wait.Round(time.Second))
+		c.saveCurrent()
+	}
+	var clients *clientCache
+	switch *provider {
+	case providerGitLab:
+		c.sources = gitlabSources(gitlabClientFactory(patArgs, httpClient), onWait)
+	case providerGitea:
+		c.sources = giteaSources(giteaClientFactory(patArgs, httpClient), onWait)
+	case providerMailingList:
+		c.sources = archives.sources()
+	default:
+		clients = newClientCache(newHostClient, onWait)
+		c.sources = githubSources(clients, *backend)
+	}
 
+	// -git-historyが指定されていれば、APIでの検索の代わりにクローンのコミット履歴からPR番号を探す
+	if *gitHistory != "" {
+		prs, historyWords, err := mineGitHistory(ctx, *gitHistory, *gitRange, words)
 		if err != nil {
-			continue // エラーが残っている場合は次のキーワードへ
+			log.Printf("Failed to read git history: %v", err)
+			return 1
 		}
+		c.words = historyWords
+		c.sources = withGitHistory(c.sources, prs, *gitRange)
+	}
 
-		if len(prNumbers) == 0 {
-			fmt.Printf("No PRs found for keyword '%s'. Skipping.\n", word)
-			continue
+	repos := []github.RepositoryRef{single}
+	var unresolved []target.Failure
+	if targets.MultiRepository() {
+		if *provider != providerGitHub {
+			repos, err = github.ReadRepositoryList(targets.ReposFile(), parseRef)
+		} else {
+			repos, unresolved, err = targets.Resolve(ctx, clients.get)
 		}
-
-		fmt.Printf("Found %d PRs for keyword '%s'\n", len(prNumbers), word)
-
-		// 2. キーワードごとのディレクトリを作成
-		keywordDir := filepath.Join(baseDataDir, word)
-		if err := os.MkdirAll(keywordDir, 0755); err != nil {
-			log.Printf("Failed to create directory for keyword '%s': %v", word, err)
-			continue
+		if err != nil {
+			log.Printf("Failed to list repositories: %v", err)
+			return 1
 		}
+		fmt.Printf("Crawling %d repositories\n", len(repos))
+	}
 
-		// 3. 各PRのコメントを取得してファイルに保存
-		processedInThisKeyword := 0
-		for _, prNumber := range prNumbers {
-
-			// 既に処理済みのPRはスキップ
-			if processedPRs[prNumber] {
-				fmt.Printf("Skipping PR #%d (already processed)\n", prNumber)
-				continue
-			}
-
-			fmt.Printf("Fetching comments for PR #%d\n", prNumber)
-
-			// Issue Comments取得（リトライループ）
-			var issueComments []*gh.IssueComment
-			for {
-				var err error
-				issueComments, err = client.GetComments(prNumber)
-				if err != nil {
-					if isRateLimitError(err) {
-						log.Printf("Rate limit exceeded while fetching issue comments for PR #%d.", prNumber)
-						// 処理済みPR番号を保存
-						if err := saveProcessedPRs(processedPRsFile, processedPRs); err != nil {
-							log.Printf("Failed to save processed PRs: %v", err)
-						}
-						// 90分待機してからリトライ
-						waitForRateLimit(90 * time.Minute)
-						continue // リトライ
-					}
-					log.Printf("Failed to get issue comments for PR #%d: %v", prNumber, err)
-					break // エラーがレート制限以外の場合はループを抜ける
-				}
-				break // 成功したらループを抜ける
-			}
-
-			// Review Comments取得（リトライループ）
-			var reviewComments []*gh.PullRequestComment
-			for {
-				var err error
-				reviewComments, err = client.GetReviewComments(prNumber)
-				if err != nil {
-					if isRateLimitError(err) {
-						log.Printf("Rate limit exceeded while fetching review comments for PR #%d.", prNumber)
-						// 処理済みPR番号を保存
-						if err := saveProcessedPRs(processedPRsFile, processedPRs); err != nil {
-							log.Printf("Failed to save processed PRs: %v", err)
-						}
-						// 90分待機してからリトライ
-						waitForRateLimit(90 * time.Minute)
-						continue // リトライ
-					}
-					log.Printf("Failed to get review comments for PR #%d: %v", prNumber, err)
-					break // エラーがレート制限以外の場合はループを抜ける
-				}
-				break // 成功したらループを抜ける
-			}
-
-			// 両方のコメントが空の場合はスキップ
-			if len(issueComments) == 0 && len(reviewComments) == 0 {
-				fmt.Printf("No comments found for PR #%d. Skipping.\n", prNumber)
-				// 処理済みとしてマーク（コメントがない場合も処理済みとする）
-				processedPRs[prNumber] = true
-				continue
-			}
-
-			// コメントを時系列順にソート
-			sortCommentsByTime(issueComments, reviewComments)
-
-			// JSONファイルに書き込む
-			outputPath := filepath.Join(keywordDir, fmt.Sprintf("%d.json", prNumber))
-			if err := writeCommentsToFile(issueComments, reviewComments, outputPath); err != nil {
-				log.Printf("Failed to write comments to file for PR #%d: %v", prNumber, err)
-				continue
-			}
-
-			// 処理済みとしてマーク
-			processedPRs[prNumber] = true
-			processedInThisKeyword++
-
-			fmt.Printf("Saved comments for PR #%d to %s\n", prNumber, outputPath)
-
-			// 10件処理するごとに処理済みPR番号を保存（進捗を保存）
-			if processedInThisKeyword%10 == 0 {
-				if err := saveProcessedPRs(processedPRsFile, processedPRs); err != nil {
-					log.Printf("Failed to save processed PRs: %v", err)
-				}
-			}
+	// リポジトリごとに処理し、結果をまとめて保存する
+	summary := &runSummary{StartedAt: time.Now()}
+	for _, failure := range unresolved {
+		summary.add(repoSummary{Repository: failure.Repository.String(), Error: failure.Err.Error()})
+	}
+	for _, repo := range repos {
+		if ctx.Err() != nil {
+			break
 		}
+		summary.add(c.crawlRepository(ctx, repo))
+	}
+	summary.FinishedAt = time.Now()
+	summary.Interrupted = ctx.Err() != nil
+	if cache != nil {
+		stats := cache.Stats()
+		summary.HTTPCache = &stats
+	}
 
-		// キーワードごとの処理が完了したら処理済みPR番号を保存
-		if processedInThisKeyword > 0 {
-			if err := saveProcessedPRs(processedPRsFile, processedPRs); err != nil {
-				log.Printf("Failed to save processed PRs: %v", err)
-			}
-		}
+	printRunSummary(summary)
+	if err := writeRunSummary(summary, *summaryFile); err != nil {
+		log.Printf("Failed to write run summary: %v", err)
+	} else {
+		fmt.Printf("Run summary saved to %s\n", *summaryFile)
+	}
+
+	if recording != nil && recording.Mode() == cassette.ModeReplay && recording.Unmatched() > 0 {
+		log.Printf("%d requests were not found in the cassette; the replayed run is incomplete.", recording.Unmatched())
+		return 1
 	}
 
-	// 最終的に処理済みPR番号を保存
-	if err := saveProcessedPRs(processedPRsFile, processedPRs); err != nil {
-		log.Printf("Failed to save processed PRs: %v", err)
+	if summary.Interrupted {
+		saved := dataRoot
+		if *dbFile != "" {
+			saved = *dbFile
+		}
+		log.Printf("Interrupted. Processed PRs have been saved in %s; run again to resume.", saved)
+		return 1
 	}
 
 	fmt.Println("\nDone!")
+	return 0
+}
+
+// tokenClientFactory は引数のPATと-token-file・環境変数のトークンを使うClientの作成関数を返す
+func tokenClientFactory(patArgs []string, tokenFile string, httpClient *http.Client, opts []github.ClientOption) (func(host string) (*github.Client, error), error) {
+	tokens, err := github.LoadTokens(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(patArgs) > 0 {
+		tokens = append([]string{patArgs[0]}, tokens...)
+	}
+	if len(tokens) == 0 {
+		fmt.Println("Warning: No GitHub PAT provided. Rate limiting may occur.")
+	}
+	return func(host string) (*github.Client, error) {
+		return github.NewHostClient("", host, httpClient, append(slices.Clone(opts), github.WithTokens(tokens))...)
+	}, nil
+}
+
+// appClientFactory はGitHub Appのインストールトークンで認証するClientの作成関数を返す
+func appClientFactory(appID, installationID int64, keyFile string, httpClient *http.Client, opts []github.ClientOption) (func(host string) (*github.Client, error), error) {
+	if keyFile == "" {
+		return nil, fmt.Errorf("-app-key is required with -app-id")
+	}
+	privateKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+	}
+	return func(host string) (*github.Client, error) {
+		return github.NewHostClient("", host, httpClient, append(slices.Clone(opts), github.WithAppAuth(github.AppAuth{
+			AppID:          appID,
+			InstallationID: installationID,
+			PrivateKey:     privateKey,
+		}))...)
+	}, nil
 }
 
 // loadWordList はword_list.jsonファイルを読み込む
@@ -213,29 +358,43 @@ func loadWordList(filename string) ([]string, error) {
 	return words, nil
 }
 
-// PRComments はIssue CommentsとReview Commentsを保持する構造体
-type PRComments struct {
-	IssueComments  []*gh.IssueComment       `json:"issue_comments"`
-	ReviewComments []*gh.PullRequestComment `json:"review_comments"`
+// openStore はdbFileが指定されていればSQLiteのStoreを、なければdataRoot以下のJSONファイルのStoreを開く
+func openStore(dbFile string) (store.Store, error) {
+	if dbFile != "" {
+		return store.OpenSQLite(dbFile)
+	}
+	return store.NewFileStore(dataRoot), nil
 }
 
-// writeCommentsToFile はコメントをJSONファイルに書き込む
-func writeCommentsToFile(issueComments []*gh.IssueComment, reviewComments []*gh.PullRequestComment, filepath string) error {
-	prComments := PRComments{
-		IssueComments:  issueComments,
-		ReviewComments: reviewComments,
+// validateBackend はbackendが対応しているAPIかどうかを確認する
+func validateBackend(backend string) error {
+	switch backend {
+	case backendREST, backendGraphQL:
+		return nil
+	default:
+		return fmt.Errorf("unknown backend %q: use %s or %s", backend, backendREST, backendGraphQL)
 	}
+}
 
-	data, err := json.MarshalIndent(prComments, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal comments: %w", err)
+// newConversationFetcher はbackendに応じて会話の取得方法を選ぶ
+func newConversationFetcher(backend string, client *github.Client) (github.ConversationFetcher, error) {
+	if err := validateBackend(backend); err != nil {
+		return nil, err
 	}
-
-	if err := os.WriteFile(filepath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	if backend == backendGraphQL {
+		return github.NewGraphQLFetcher(client), nil
 	}
+	return client, nil
+}
 
-	return nil
+// chunkPRNumbers はPR番号をsize件ずつのバッチに分ける
+func chunkPRNumbers(prNumbers []int, size int) [][]int {
+	var batches [][]int
+	for start := 0; start < len(prNumbers); start += size {
+		end := min(start+size, len(prNumbers))
+		batches = append(batches, prNumbers[start:end])
+	}
+	return batches
 }
 
 // sortCommentsByTime はコメントを時系列順にソートする
@@ -256,114 +415,3 @@ func sortCommentsByTime(issueComments []*gh.IssueComment, reviewComments []*gh.P
 		return reviewComments[i].CreatedAt.Time.Before(reviewComments[j].CreatedAt.Time)
 	})
 }
-
-// isRateLimitError はエラーがレート制限エラーかどうかを判定する
-func isRateLimitError(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	errStr := err.Error()
-
-	// HTTPレスポンスエラーの場合
-	if strings.Contains(errStr, "403") || strings.Contains(errStr, "429") {
-		return true
-	}
-
-	// go-githubのレート制限エラーメッセージをチェック
-	if strings.Contains(strings.ToLower(errStr), "rate limit") {
-		return true
-	}
-
-	// RateLimitError型をチェック
-	if rateLimitErr, ok := err.(*gh.RateLimitError); ok {
-		return rateLimitErr != nil
-	}
-
-	// AbuseRateLimitError型をチェック
-	if abuseRateLimitErr, ok := err.(*gh.AbuseRateLimitError); ok {
-		return abuseRateLimitErr != nil
-	}
-
-	return false
-}
-
-// waitForRateLimit は指定時間待機する（レート制限リセット待ち）
-func waitForRateLimit(waitDuration time.Duration) {
-	log.Printf("Waiting %v for rate limit reset before resuming...", waitDuration)
-
-	// 待機中は定期的に進捗を表示
-	ticker := time.NewTicker(10 * time.Minute)
-	defer ticker.Stop()
-
-	done := make(chan bool)
-	go func() {
-		time.Sleep(waitDuration)
-		done <- true
-	}()
-
-	elapsed := time.Duration(0)
-	for {
-		select {
-		case <-done:
-			log.Printf("Rate limit wait completed. Resuming processing...")
-			return
-		case <-ticker.C:
-			elapsed += 10 * time.Minute
-			remaining := waitDuration - elapsed
-			if remaining > 0 {
-				log.Printf("Still waiting... %v remaining", remaining)
-			}
-		}
-	}
-}
-
-// loadProcessedPRs は処理済みPR番号をファイルから読み込む
-func loadProcessedPRs(filepath string) (map[int]bool, error) {
-	data, err := os.ReadFile(filepath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// ファイルが存在しない場合は空のマップを返す
-			return make(map[int]bool), nil
-		}
-		return nil, fmt.Errorf("failed to read processed PRs file: %w", err)
-	}
-
-	var prNumbers []int
-	if err := json.Unmarshal(data, &prNumbers); err != nil {
-		return nil, fmt.Errorf("failed to parse processed PRs JSON: %w", err)
-	}
-
-	processedPRs := make(map[int]bool)
-	for _, prNumber := range prNumbers {
-		processedPRs[prNumber] = true
-	}
-
-	return processedPRs, nil
-}
-
-// saveProcessedPRs は処理済みPR番号をファイルに保存する
-func saveProcessedPRs(filePath string, processedPRs map[int]bool) error {
-	// マップをスライスに変換
-	prNumbers := make([]int, 0, len(processedPRs))
-	for prNumber := range processedPRs {
-		prNumbers = append(prNumbers, prNumber)
-	}
-
-	data, err := json.MarshalIndent(prNumbers, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal processed PRs: %w", err)
-	}
-
-	// ディレクトリが存在することを確認
-	dir := filepath.Dir(filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
-	}
-
-	return nil
-}
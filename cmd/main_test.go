@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkPRNumbers(t *testing.T) {
+	tests := []struct {
+		name      string
+		prNumbers []int
+		size      int
+		want      [][]int
+	}{
+		{name: "even chunks", prNumbers: []int{5, 3, 9, 1}, size: 2, want: [][]int{{5, 3}, {9, 1}}},
+		{name: "last chunk is shorter", prNumbers: []int{5, 3, 9, 1, 7}, size: 2, want: [][]int{{5, 3}, {9, 1}, {7}}},
+		{name: "size larger than input", prNumbers: []int{5, 3}, size: 10, want: [][]int{{5, 3}}},
+		{name: "size of one", prNumbers: []int{5, 3, 9}, size: 1, want: [][]int{{5}, {3}, {9}}},
+		{name: "empty input", prNumbers: nil, size: 3, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, chunkPRNumbers(tt.prNumbers, tt.size))
+		})
+	}
+}
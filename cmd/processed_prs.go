@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/store"
+)
+
+// checkpointInterval は何件処理するごとに処理済みPR番号を保存するか
+const checkpointInterval = 10
+
+// processedSet は処理済みPR番号の集合。複数のワーカーから同時に更新できる。
+// PRは保存が完了してからMarkするため、途中で中断しても未保存のPRが処理済みとして記録されることはない。
+type processedSet struct {
+	mu      sync.Mutex
+	store   store.Store
+	repo    github.RepositoryRef
+	prs     map[int]bool
+	unsaved int
+}
+
+// loadProcessedSet はstoreからrepoの処理済みPR番号を読み込む
+func loadProcessedSet(st store.Store, repo github.RepositoryRef) (*processedSet, error) {
+	prs, err := st.LoadProcessed(repo)
+	if err != nil {
+		return &processedSet{store: st, repo: repo, prs: make(map[int]bool)}, err
+	}
+	return &processedSet{store: st, repo: repo, prs: prs}, nil
+}
+
+// Contains はprNumberが処理済みかどうかを返す
+func (s *processedSet) Contains(prNumber int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prs[prNumber]
+}
+
+// Len は処理済みPRの件数を返す
+func (s *processedSet) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.prs)
+}
+
+// Mark はprNumberを処理済みにし、checkpointInterval件ごとに保存する
+func (s *processedSet) Mark(prNumber int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prs[prNumber] = true
+	s.unsaved++
+	if s.unsaved < checkpointInterval {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// Save は処理済みPR番号を保存する
+func (s *processedSet) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked()
+}
+
+func (s *processedSet) saveLocked() error {
+	if err := s.store.SaveProcessed(s.repo, s.prs); err != nil {
+		return err
+	}
+	s.unsaved = 0
+	return nil
+}
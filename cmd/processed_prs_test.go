@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readSavedPRs はprocessedFileに保存されたPR番号を読み込む。まだ保存されていなければnilを返す
+func readSavedPRs(t *testing.T, processedFile string) []int {
+	t.Helper()
+
+	data, err := os.ReadFile(processedFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	require.NoError(t, err)
+	var prs []int
+	require.NoError(t, json.Unmarshal(data, &prs), "the file must never be partially written: %q", data)
+	return prs
+}
+
+func TestProcessedSet_MarkCheckpoints(t *testing.T) {
+	dataRoot := t.TempDir()
+	repo := github.RepositoryRef{Host: github.DefaultHost, Owner: "owner", Name: "repo"}
+	processedFile := filepath.Join(repo.DataDir(dataRoot), store.ProcessedPRsFileName)
+	processed, err := loadProcessedSet(store.NewFileStore(dataRoot), repo)
+	require.NoError(t, err)
+
+	// checkpointInterval件に達するまでは保存しない
+	for n := 1; n < checkpointInterval; n++ {
+		require.NoError(t, processed.Mark(n))
+		assert.Nil(t, readSavedPRs(t, processedFile))
+	}
+	require.NoError(t, processed.Mark(checkpointInterval))
+	assert.Len(t, readSavedPRs(t, processedFile), checkpointInterval)
+
+	// 複数のワーカーから同時にMarkしても、保存されたファイルは常にチェックポイントの時点の完全な内容になる
+	const workers = 4
+	const perWorker = 2 * checkpointInterval
+	done := make(chan struct{})
+	var reader sync.WaitGroup
+	reader.Add(1)
+	go func() {
+		defer reader.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			prs := readSavedPRs(t, processedFile)
+			assert.Zero(t, len(prs)%checkpointInterval, "saved %d PRs", len(prs))
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWorker {
+				assert.NoError(t, processed.Mark(checkpointInterval+1+w*perWorker+i))
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	reader.Wait()
+
+	total := checkpointInterval + workers*perWorker
+	assert.Equal(t, total, processed.Len())
+	prs := readSavedPRs(t, processedFile)
+	require.Len(t, prs, total)
+	for i, n := range prs {
+		assert.Equal(t, i+1, n)
+	}
+	assert.NoFileExists(t, processedFile+".tmp", "the file is replaced by renaming the temporary file")
+}
@@ -12,20 +12,8 @@ import (
 	"github.com/malsuke/PRalyzer/internal/llm"
 )
 
-type ReviewCommentJson struct {
-	IssueComments  []llm.PullRequestCommentsPayload `json:"issue_comments"`
-	ReviewComments []ReviewComment                  `json:"review_comments"`
-}
-
-type ReviewComment struct {
-	CommentID int    `json:"id"`
-	UserName  string `json:"user_name"`
-	Path      string `json:"path"`
-	DiffHunk  string `json:"diff_hunk"`
-	Body      string `json:"body"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-}
+// issueCommentsKey は変換後のJSONでIssue Commentsを保持するキー
+const issueCommentsKey = "issue_comments"
 
 func main() {
 	outputDir := "data"
@@ -39,6 +27,10 @@ func main() {
 		if err != nil {
 			return err
 		}
+		// .http_cacheなどの隠しディレクトリは処理対象外
+		if info.IsDir() && path != outputDir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
 
 		// JSONファイルのみを処理
 		if !strings.HasSuffix(strings.ToLower(path), ".json") {
@@ -59,17 +51,25 @@ func main() {
 			return nil // エラーがあっても続行
 		}
 
-		// ReviewCommentJsonとしてパース
-		var reviewCommentJson ReviewCommentJson
-		if err := json.Unmarshal(data, &reviewCommentJson); err != nil {
+		// セクションごとにパース（issue_comments以外のpull_requestなどはそのまま書き戻す）
+		var sections map[string]json.RawMessage
+		if err := json.Unmarshal(data, &sections); err != nil {
 			log.Printf("Failed to parse JSON file %s: %v", path, err)
 			return nil // エラーがあっても続行
 		}
 
+		var issueComments []llm.PullRequestCommentsPayload
+		if raw, ok := sections[issueCommentsKey]; ok {
+			if err := json.Unmarshal(raw, &issueComments); err != nil {
+				log.Printf("Failed to parse issue comments in %s: %v", path, err)
+				return nil // エラーがあっても続行
+			}
+		}
+
 		// issue_commentsから「## Stats from current PR」で始まるコメントを削除
 		var filteredComments []llm.PullRequestCommentsPayload
 		removedCount := 0
-		for _, comment := range reviewCommentJson.IssueComments {
+		for _, comment := range issueComments {
 			// bodyが「## Stats from current PR」で始まるかチェック
 			bodyTrimmed := strings.TrimSpace(comment.Body)
 			if strings.HasPrefix(bodyTrimmed, "## Stats from current PR") {
@@ -80,10 +80,15 @@ func main() {
 		}
 
 		if removedCount > 0 {
-			reviewCommentJson.IssueComments = filteredComments
+			filteredData, err := json.Marshal(filteredComments)
+			if err != nil {
+				log.Printf("Failed to marshal issue comments for %s: %v", path, err)
+				return nil
+			}
+			sections[issueCommentsKey] = filteredData
 
 			// JSONファイルに書き込む
-			outputData, err := json.MarshalIndent(reviewCommentJson, "", "  ")
+			outputData, err := json.MarshalIndent(sections, "", "  ")
 			if err != nil {
 				log.Printf("Failed to marshal JSON for %s: %v", path, err)
 				return nil
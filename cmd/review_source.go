@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"iter"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/gitea"
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/gitlab"
+	"github.com/malsuke/PRalyzer/internal/mailinglist"
+)
+
+// reviewSource はコードレビューの場（GitHubのPull Request、GitLabのMerge Requestなど）から
+// キーワードで変更要求を探し、一覧を取得し、会話を取得するためのインターフェース。
+// 変更要求はPRと同じ形（*gh.PullRequestとConversation）で返すため、
+// 保存形式（PRComments）やLLMに渡す形式（PullRequestCommentsPayload）は取得元によらず同じになる。
+type reviewSource interface {
+	github.ConversationFetcher
+	// SearchPullRequestResults はqueryに一致する変更要求の番号と最終更新日時を重複なく返す
+	SearchPullRequestResults(ctx context.Context, query github.SearchQuery) ([]github.SearchResult, error)
+	// PullRequests はすべての変更要求を作成日時の古い順に1件ずつ返す
+	PullRequests(ctx context.Context) iter.Seq2[*gh.PullRequest, error]
+}
+
+// queryDescriber はキーワードの一致の記録に残すクエリを、検索APIのクエリの代わりに返す取得元
+type queryDescriber interface {
+	DescribeQuery(repo github.RepositoryRef, query github.SearchQuery) string
+}
+
+var (
+	_ reviewSource = (*github.Client)(nil)
+	_ reviewSource = (*gitlab.Client)(nil)
+	_ reviewSource = (*gitea.Client)(nil)
+	_ reviewSource = (*mailinglist.Archive)(nil)
+)
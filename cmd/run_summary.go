@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// crawlStats は1つのリポジトリのクロール中に数える件数。複数のワーカーから同時に更新できる。
+type crawlStats struct {
+	found            atomic.Int64
+	alreadyProcessed atomic.Int64
+	saved            atomic.Int64
+	refreshed        atomic.Int64
+	withoutComments  atomic.Int64
+	failed           atomic.Int64
+}
+
+// repoSummary は1つのリポジトリのクロール結果
+type repoSummary struct {
+	Repository          string   `json:"repository"`
+	DataDir             string   `json:"data_dir"`
+	PRsFound            int64    `json:"prs_found"`
+	PRsAlreadyProcessed int64    `json:"prs_already_processed"`
+	PRsSaved            int64    `json:"prs_saved"`
+	PRsRefreshed        int64    `json:"prs_refreshed"`
+	PRsWithoutComments  int64    `json:"prs_without_comments"`
+	PRsFailed           int64    `json:"prs_failed"`
+	KeywordErrors       []string `json:"keyword_errors,omitempty"`
+	Error               string   `json:"error,omitempty"`
+	DurationSeconds     float64  `json:"duration_seconds"`
+}
+
+func (s *crawlStats) summary(repository, dataDir string, duration time.Duration) repoSummary {
+	return repoSummary{
+		Repository:          repository,
+		DataDir:             dataDir,
+		PRsFound:            s.found.Load(),
+		PRsAlreadyProcessed: s.alreadyProcessed.Load(),
+		PRsSaved:            s.saved.Load(),
+		PRsRefreshed:        s.refreshed.Load(),
+		PRsWithoutComments:  s.withoutComments.Load(),
+		PRsFailed:           s.failed.Load(),
+		DurationSeconds:     duration.Round(time.Second).Seconds(),
+	}
+}
+
+// runSummary は1回の実行で対象にしたすべてのリポジトリの結果をまとめたもの
+type runSummary struct {
+	StartedAt    time.Time     `json:"started_at"`
+	FinishedAt   time.Time     `json:"finished_at"`
+	Interrupted  bool          `json:"interrupted"`
+	Totals       repoSummary   `json:"totals"`
+	Repositories []repoSummary `json:"repositories"`
+	// HTTPCache は-cache-dirを指定した場合のキャッシュの利用状況
+	HTTPCache *github.CacheStats `json:"http_cache,omitempty"`
+}
+
+// add はリポジトリの結果を追加して合計を更新する
+func (s *runSummary) add(repo repoSummary) {
+	s.Repositories = append(s.Repositories, repo)
+	s.Totals.PRsFound += repo.PRsFound
+	s.Totals.PRsAlreadyProcessed += repo.PRsAlreadyProcessed
+	s.Totals.PRsSaved += repo.PRsSaved
+	s.Totals.PRsRefreshed += repo.PRsRefreshed
+	s.Totals.PRsWithoutComments += repo.PRsWithoutComments
+	s.Totals.PRsFailed += repo.PRsFailed
+	s.Totals.DurationSeconds += repo.DurationSeconds
+}
+
+// writeRunSummary は実行結果をJSONファイルに書き込む
+func writeRunSummary(summary *runSummary, path string) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal run summary: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write run summary: %w", err)
+	}
+	return nil
+}
+
+// printRunSummary は実行結果を表形式で表示する
+func printRunSummary(summary *runSummary) {
+	fmt.Printf("\n========================================\n")
+	fmt.Printf("Summary (%d repositories)\n", len(summary.Repositories))
+	fmt.Printf("========================================\n")
+	for _, repo := range summary.Repositories {
+		status := "ok"
+		if repo.Error != "" {
+			status = "error: " + repo.Error
+		}
+		fmt.Printf("%-40s found %5d  saved %5d  refreshed %5d  empty %5d  skipped %5d  failed %5d  %s\n",
+			repo.Repository, repo.PRsFound, repo.PRsSaved, repo.PRsRefreshed, repo.PRsWithoutComments, repo.PRsAlreadyProcessed, repo.PRsFailed, status)
+	}
+	fmt.Printf("%-40s found %5d  saved %5d  refreshed %5d  empty %5d  skipped %5d  failed %5d\n",
+		"Total", summary.Totals.PRsFound, summary.Totals.PRsSaved, summary.Totals.PRsRefreshed, summary.Totals.PRsWithoutComments, summary.Totals.PRsAlreadyProcessed, summary.Totals.PRsFailed)
+	if cache := summary.HTTPCache; cache != nil {
+		fmt.Printf("HTTP cache: %d revalidated (304), %d missed, %d stored, %d errors\n",
+			cache.Revalidated, cache.Missed, cache.Stored, cache.Errors)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// searchFlags はコマンドラインから指定するPRの検索条件
+type searchFlags struct {
+	state          string
+	merged         string
+	scopes         string
+	labels         string
+	authors        string
+	base           string
+	created        string
+	mergedAt       string
+	excludeLabels  string
+	excludeAuthors string
+
+	fs *flag.FlagSet
+}
+
+// registerSearchFlags は検索条件のフラグをfsに登録する
+func registerSearchFlags(fs *flag.FlagSet) *searchFlags {
+	f := &searchFlags{fs: fs}
+	fs.StringVar(&f.state, "state", "", "PR state to search: open, closed (default: any)")
+	fs.StringVar(&f.merged, "merged", string(github.MergeMerged), "merge status to search: merged, unmerged, any")
+	fs.StringVar(&f.scopes, "in", string(github.ScopeComments), "comma-separated fields to match the keyword in: comments, title, body")
+	fs.StringVar(&f.labels, "label", "", "comma-separated labels the PR must have")
+	fs.StringVar(&f.authors, "author", "", "comma-separated PR authors")
+	fs.StringVar(&f.base, "base", "", "base branch of the PR")
+	fs.StringVar(&f.created, "created", "", "creation date range (FROM..TO, >=FROM or <=TO)")
+	fs.StringVar(&f.mergedAt, "merged-at", "", "merge date range (FROM..TO, >=FROM or <=TO)")
+	fs.StringVar(&f.excludeLabels, "exclude-label", "", "comma-separated labels to exclude")
+	fs.StringVar(&f.excludeAuthors, "exclude-author", "", "comma-separated authors to exclude")
+	return f
+}
+
+// searchFlagNames は検索条件のフラグ名
+var searchFlagNames = []string{
+	"state", "merged", "in", "label", "author", "base", "created", "merged-at", "exclude-label", "exclude-author",
+}
+
+// explicitFlags はコマンドラインで指定された検索条件のフラグ名を返す
+func (f *searchFlags) explicitFlags() []string {
+	var names []string
+	f.fs.Visit(func(fl *flag.Flag) {
+		if slices.Contains(searchFlagNames, fl.Name) {
+			names = append(names, "-"+fl.Name)
+		}
+	})
+	return names
+}
+
+// buildQuery はフラグの値から検索クエリのテンプレートを作る（キーワードはワードリストごとに差し替える）
+func (f *searchFlags) buildQuery() (github.SearchQuery, error) {
+	query := github.SearchQuery{
+		Labels:         splitList(f.labels),
+		Authors:        splitList(f.authors),
+		Base:           strings.TrimSpace(f.base),
+		ExcludeLabels:  splitList(f.excludeLabels),
+		ExcludeAuthors: splitList(f.excludeAuthors),
+	}
+
+	switch state := github.PRState(f.state); state {
+	case github.StateAny, github.StateOpen, github.StateClosed:
+		query.State = state
+	default:
+		return github.SearchQuery{}, fmt.Errorf("invalid -state %q: use open or closed", f.state)
+	}
+
+	switch merged := f.merged; merged {
+	case "any", "":
+		query.Merged = github.MergeAny
+	case string(github.MergeMerged), string(github.MergeUnmerged):
+		query.Merged = github.MergeStatus(merged)
+	default:
+		return github.SearchQuery{}, fmt.Errorf("invalid -merged %q: use merged, unmerged or any", f.merged)
+	}
+
+	for _, scope := range splitList(f.scopes) {
+		switch s := github.SearchScope(scope); s {
+		case github.ScopeComments, github.ScopeTitle, github.ScopeBody:
+			query.Scopes = append(query.Scopes, s)
+		default:
+			return github.SearchQuery{}, fmt.Errorf("invalid -in %q: use comments, title or body", scope)
+		}
+	}
+
+	var err error
+	if query.Created, err = github.ParseDateRange(f.created); err != nil {
+		return github.SearchQuery{}, fmt.Errorf("invalid -created: %w", err)
+	}
+	if query.MergedAt, err = github.ParseDateRange(f.mergedAt); err != nil {
+		return github.SearchQuery{}, fmt.Errorf("invalid -merged-at: %w", err)
+	}
+
+	return query, nil
+}
+
+// splitList はカンマ区切りの値を空要素を除いて分割する
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
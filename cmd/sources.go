@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/malsuke/PRalyzer/internal/gitea"
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/gitlab"
+	"github.com/malsuke/PRalyzer/internal/mailinglist"
+	"github.com/malsuke/PRalyzer/internal/target"
+)
+
+// 変更要求（PR・Merge Request）の取得元
+const (
+	providerGitHub = "github"
+	providerGitLab = "gitlab"
+	providerGitea  = "gitea"
+	// providerMailingList はローカルのメーリングリストのアーカイブ（mbox・maildir）のパッチのスレッド
+	providerMailingList = "mailinglist"
+)
+
+// sourceOpener はリポジトリの検索に使うreviewSourceと、会話の取得に使うConversationFetcherを返す
+type sourceOpener func(repo github.RepositoryRef) (reviewSource, github.ConversationFetcher, error)
+
+// validateProvider はproviderが対応している取得元かどうかを確認する
+func validateProvider(provider string) error {
+	switch provider {
+	case providerGitHub, providerGitLab, providerGitea, providerMailingList:
+		return nil
+	default:
+		return fmt.Errorf("unknown provider %q: use %s, %s, %s or %s", provider, providerGitHub, providerGitLab, providerGitea, providerMailingList)
+	}
+}
+
+// githubSources はclientsのClientでPRを検索し、backendのAPIで会話を取得する
+func githubSources(clients *clientCache, backend string) sourceOpener {
+	return func(repo github.RepositoryRef) (reviewSource, github.ConversationFetcher, error) {
+		hostClient, err := clients.get(repo.Host)
+		if err != nil {
+			return nil, nil, err
+		}
+		client, err := hostClient.ForRepository(repo)
+		if err != nil {
+			return nil, nil, err
+		}
+		fetcher, err := newConversationFetcher(backend, client)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, fetcher, nil
+	}
+}
+
+// gitlabSources はホストごとにnewHostで作ったClientを使い回し、Merge Requestの検索と会話の取得の両方に使う
+// onWaitはレート制限で長時間待機する前に呼ばれる
+func gitlabSources(newHost func(host string) (*gitlab.Client, error), onWait func(resource string, wait time.Duration)) sourceOpener {
+	get := perHost(newHost, func(client *gitlab.Client) { client.OnLongWait(onWait) })
+	return func(repo github.RepositoryRef) (reviewSource, github.ConversationFetcher, error) {
+		client, err := get(repo.Host)
+		if err != nil {
+			return nil, nil, err
+		}
+		project := client.ForProject(gitlab.ProjectPath(repo))
+		return project, project, nil
+	}
+}
+
+// giteaSources はホストごとにnewHostで作ったClientを使い回し、PRの検索と会話の取得の両方に使う
+func giteaSources(newHost func(host string) (*gitea.Client, error), onWait func(resource string, wait time.Duration)) sourceOpener {
+	get := perHost(newHost, func(client *gitea.Client) { client.OnLongWait(onWait) })
+	return func(repo github.RepositoryRef) (reviewSource, github.ConversationFetcher, error) {
+		client, err := get(repo.Host)
+		if err != nil {
+			return nil, nil, err
+		}
+		repository := client.ForRepository(repo.Owner, repo.Name)
+		return repository, repository, nil
+	}
+}
+
+// mailArchives はアーカイブのパスとRepositoryRefの対応。RepositoryRefにはパスを持たせられないため、
+// 解釈したときのパスを覚えておき、開くときに使う
+type mailArchives struct {
+	mu    sync.Mutex
+	paths map[github.RepositoryRef]string
+}
+
+func newMailArchives() *mailArchives {
+	return &mailArchives{paths: make(map[github.RepositoryRef]string)}
+}
+
+// parse はアーカイブのパスをRepositoryRefにして、パスを覚えておく。同じ保存先になる別のアーカイブはエラーにする
+func (a *mailArchives) parse(path string) (github.RepositoryRef, error) {
+	ref, err := mailinglist.ArchiveRef(path)
+	if err != nil {
+		return github.RepositoryRef{}, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if existing, ok := a.paths[ref]; ok && existing != path {
+		return github.RepositoryRef{}, fmt.Errorf("mail archives %s and %s would be saved to the same directory", existing, path)
+	}
+	a.paths[ref] = path
+	return ref, nil
+}
+
+// sources はparseで覚えたパスのアーカイブを読み込み、パッチのスレッドの検索と会話の取得の両方に使う
+func (a *mailArchives) sources() sourceOpener {
+	return func(repo github.RepositoryRef) (reviewSource, github.ConversationFetcher, error) {
+		a.mu.Lock()
+		path, ok := a.paths[repo]
+		a.mu.Unlock()
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown mail archive %s", repo)
+		}
+		archive, err := mailinglist.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return archive, archive, nil
+	}
+}
+
+// perHost はホストごとに1つだけnewHostでClientを作り、作成直後にsetupを呼ぶ関数を返す
+func perHost[T any](newHost func(host string) (T, error), setup func(T)) func(host string) (T, error) {
+	var mu sync.Mutex
+	clients := make(map[string]T)
+	return func(host string) (T, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if client, ok := clients[host]; ok {
+			return client, nil
+		}
+		client, err := newHost(host)
+		if err != nil {
+			return client, err
+		}
+		setup(client)
+		clients[host] = client
+		return client, nil
+	}
+}
+
+// gitlabClientFactory は引数のトークン（なければ環境変数のトークン）でGitLabに接続するClientの作成関数を返す
+func gitlabClientFactory(patArgs []string, httpClient *http.Client) func(host string) (*gitlab.Client, error) {
+	token := os.Getenv(gitlab.TokenEnvVar)
+	if len(patArgs) > 0 {
+		token = patArgs[0]
+	}
+	if token == "" {
+		fmt.Println("Warning: No GitLab token provided. Only public projects can be read.")
+	}
+	return func(host string) (*gitlab.Client, error) {
+		return gitlab.NewClient(token, host, httpClient)
+	}
+}
+
+// giteaClientFactory は引数のトークン（なければ環境変数のトークン）でGitea・Forgejoに接続するClientの作成関数を返す
+func giteaClientFactory(patArgs []string, httpClient *http.Client) func(host string) (*gitea.Client, error) {
+	token := os.Getenv(gitea.TokenEnvVar)
+	if len(patArgs) > 0 {
+		token = patArgs[0]
+	}
+	if token == "" {
+		fmt.Println("Warning: No Gitea token provided. Only public repositories can be read.")
+	}
+	return func(host string) (*gitea.Client, error) {
+		return gitea.NewClient(token, host, httpClient)
+	}
+}
+
+// parseRepositoryRef はproviderに応じてリポジトリの参照を解釈する関数を返す
+// メーリングリストではリポジトリの代わりにアーカイブのパスを指定し、archivesに覚えておく
+func parseRepositoryRef(provider string, archives *mailArchives) func(ref string) (github.RepositoryRef, error) {
+	switch provider {
+	case providerMailingList:
+		return archives.parse
+	case providerGitLab:
+		return gitlab.ParseProjectRef
+	case providerGitea:
+		return gitea.ParseRepositoryRef
+	default:
+		return github.ParseRepositoryRef
+	}
+}
+
+// validateProviderOptions はGitHub以外の取得元で使えないオプションが指定されていないことを確認する
+// GitHubのAPIを使う-org・-user・リポジトリの絞り込み・GraphQL・GitHub AppはGitHubでだけ使える
+func validateProviderOptions(provider, backend string, targets *target.Flags, appID int64) error {
+	if provider == providerGitHub {
+		return nil
+	}
+	switch {
+	case targets.Account():
+		return fmt.Errorf("-org and -user are not supported with -provider %s; use -repos", provider)
+	case !targets.Filter().IsZero():
+		return fmt.Errorf("repository filters are not supported with -provider %s", provider)
+	case backend != backendREST:
+		return fmt.Errorf("-backend %s is not supported with -provider %s", backend, provider)
+	case appID != 0:
+		return fmt.Errorf("GitHub App authentication is not supported with -provider %s", provider)
+	}
+	return nil
+}
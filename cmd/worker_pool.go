@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// runWorkerPool はitemsをworkers個のgoroutineで並行にworkへ渡し、すべて終わるまで待つ
+// ctxがキャンセルされた場合は残りのitemsを渡さず、処理中のworkが終わるのを待って戻る
+func runWorkerPool[T any](ctx context.Context, items []T, workers int, work func(item T)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	queue := make(chan T)
+	var wg sync.WaitGroup
+	for range min(workers, len(items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				work(item)
+			}
+		}()
+	}
+
+feed:
+	for _, item := range items {
+		select {
+		case queue <- item:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWorkerPool(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6, 7}
+
+	tests := []struct {
+		name    string
+		items   []int
+		workers int
+	}{
+		{name: "single worker", items: items, workers: 1},
+		{name: "several workers", items: items, workers: 3},
+		{name: "more workers than items", items: items, workers: 20},
+		{name: "zero workers fall back to one", items: items, workers: 0},
+		{name: "no items", items: nil, workers: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []int
+			runWorkerPool(context.Background(), tt.items, tt.workers, func(item int) {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, item)
+			})
+
+			// すべての項目が1回ずつ処理される。ワーカーが1つなら渡した順に処理される
+			if tt.workers <= 1 {
+				assert.Equal(t, tt.items, got)
+			} else {
+				slices.Sort(got)
+				assert.Equal(t, tt.items, got)
+			}
+		})
+	}
+}
+
+func TestRunWorkerPool_StopsFeedingWhenCanceled(t *testing.T) {
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var got []int
+	runWorkerPool(ctx, items, 1, func(item int) {
+		got = append(got, item)
+		cancel()
+	})
+
+	// キャンセル後に渡された項目があっても、渡した順の先頭の一部だけが処理される
+	assert.NotEmpty(t, got)
+	assert.Less(t, len(got), len(items))
+	assert.Equal(t, items[:len(got)], got)
+}
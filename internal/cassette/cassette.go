@@ -0,0 +1,371 @@
+// Package cassette はHTTPのリクエストとレスポンスをファイル（カセット）に記録し、
+// ネットワークに接続せずに同じ順序で再生するhttp.RoundTripperを提供する。
+package cassette
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// formatVersion はカセットファイルの形式のバージョン
+const formatVersion = 1
+
+// maxLineSize はカセットファイルの1行（1件のやり取り）の最大サイズ
+const maxLineSize = 64 << 20
+
+// Mode はカセットを記録に使うか再生に使うか
+type Mode string
+
+const (
+	ModeRecord Mode = "record"
+	ModeReplay Mode = "replay"
+)
+
+// installationTokenPathSuffix はGitHub Appのインストールトークンを発行するエンドポイントのパスの末尾
+const installationTokenPathSuffix = "/access_tokens"
+
+// redactedToken は記録から取り除いたトークンの代わりに書き込む値
+const redactedToken = "REDACTED"
+
+// ErrUnmatched は再生中に、記録されていないリクエストが送られたことを表す
+var ErrUnmatched = errors.New("no recorded interaction matches the request")
+
+// header はカセットファイルの先頭行
+type header struct {
+	Version    int       `json:"version"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Interaction は記録した1回分のリクエストとレスポンス。
+// リクエストのヘッダーはトークンを含むため記録しない。発行されたインストールトークンもレスポンスから取り除く。
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest はリクエストのうち、再生時の突き合わせに使う部分
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// RecordedResponse は記録したレスポンス
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+}
+
+// key はリクエストを突き合わせるキー（メソッド・URL・本文）
+func (r RecordedRequest) key() string {
+	return r.Method + " " + r.URL + "\n" + r.Body
+}
+
+// Cassette はJSON Lines形式のカセットファイル。1行目がヘッダー、2行目以降が1件ずつのやり取り。
+// 記録ではやり取りのたびに追記するため、途中で中断してもそれまでのやり取りは残る。
+// 複数のgoroutineから共有できる。
+type Cassette struct {
+	mode       Mode
+	path       string
+	recordedAt time.Time
+
+	mu sync.Mutex
+	// 記録用
+	file *os.File
+	// 再生用：キーごとの記録済みのやり取りと、次に返す位置
+	interactions map[string][]*Interaction
+	served       map[string]int
+	unmatched    int
+}
+
+/**
+ * pathのカセットを開く
+ * ModeRecordでは新しいファイルを作成し（既存のファイルは上書きする）、ModeReplayでは記録済みのファイルを読み込む
+ */
+func Open(path string, mode Mode) (*Cassette, error) {
+	switch mode {
+	case ModeRecord:
+		return create(path)
+	case ModeReplay:
+		return load(path)
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q", mode)
+	}
+}
+
+func create(path string) (*Cassette, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cassette: %w", err)
+	}
+
+	c := &Cassette{mode: ModeRecord, path: path, recordedAt: time.Now().UTC().Truncate(time.Second), file: file}
+	if err := c.writeLine(header{Version: formatVersion, RecordedAt: c.recordedAt}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func load(path string) (*Cassette, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cassette: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		return nil, fmt.Errorf("cassette %s is empty", path)
+	}
+	var h header
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette header: %w", err)
+	}
+	if h.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported cassette version %d", h.Version)
+	}
+
+	c := &Cassette{
+		mode:         ModeReplay,
+		path:         path,
+		recordedAt:   h.RecordedAt,
+		interactions: make(map[string][]*Interaction),
+		served:       make(map[string]int),
+	}
+	for lineNumber := 2; scanner.Scan(); lineNumber++ {
+		var interaction Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return nil, fmt.Errorf("%s:%d: failed to parse interaction: %w", path, lineNumber, err)
+		}
+		key := interaction.Request.key()
+		c.interactions[key] = append(c.interactions[key], &interaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	return c, nil
+}
+
+// Mode はカセットを記録と再生のどちらに使っているかを返す。
+func (c *Cassette) Mode() Mode {
+	return c.mode
+}
+
+// RecordedAt は記録を開始した時刻を返す。
+// 検索期間の終端のように現在時刻に依存する値は、記録時も再生時もこの時刻を使うことで同じリクエストになる。
+func (c *Cassette) RecordedAt() time.Time {
+	return c.recordedAt
+}
+
+// Unmatched は再生中に記録が見つからなかったリクエストの数を返す。
+func (c *Cassette) Unmatched() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.unmatched
+}
+
+// Close は記録中のファイルを閉じる。再生では何もしない。
+func (c *Cassette) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
+
+// HTTPClient はカセットを通してリクエストを送るhttp.Clientを返す。
+func (c *Cassette) HTTPClient() *http.Client {
+	return &http.Client{Transport: c.Transport(nil)}
+}
+
+// Transport はカセットを通すRoundTripperを返す。
+// 記録ではbaseで実際に送ったやり取りを記録し、再生ではbaseを使わずに記録したレスポンスを返す。
+// 認証ヘッダーを付けるTransportより内側（ネットワークに近い側）に置く。
+func (c *Cassette) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if c.mode == ModeReplay {
+		return replayTransport{cassette: c}
+	}
+	return recordTransport{cassette: c, base: base}
+}
+
+type recordTransport struct {
+	cassette *Cassette
+	base     http.RoundTripper
+}
+
+func (t recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, req, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Request:  request,
+		Response: RecordedResponse{StatusCode: resp.StatusCode, Header: resp.Header.Clone(), Body: redactResponseBody(req, body)},
+	}
+	t.cassette.mu.Lock()
+	defer t.cassette.mu.Unlock()
+	if err := t.cassette.writeLine(interaction); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type replayTransport struct {
+	cassette *Cassette
+}
+
+/**
+ * 同じメソッド・URL・本文で記録したやり取りを、記録した順に返す
+ * 同じリクエストが記録より多く送られた場合は最後に記録したレスポンスを返し続ける
+ * 記録がないリクエストはネットワークに送らずにErrUnmatchedを返す
+ */
+func (t replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	request, _, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	key := request.key()
+
+	t.cassette.mu.Lock()
+	recorded := t.cassette.interactions[key]
+	if len(recorded) == 0 {
+		t.cassette.unmatched++
+		t.cassette.mu.Unlock()
+		return nil, fmt.Errorf("%w in cassette %s: %s %s", ErrUnmatched, t.cassette.path, req.Method, req.URL)
+	}
+	index := min(t.cassette.served[key], len(recorded)-1)
+	t.cassette.served[key]++
+	t.cassette.mu.Unlock()
+
+	response := recorded[index].Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode)),
+		StatusCode:    response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader([]byte(response.Body))),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// recordRequest はリクエストから突き合わせに使う部分を取り出す。
+// 本文を読んだ場合は、同じ本文を読み直せるリクエストのコピーを返す（元のリクエストは変更しない）。
+func recordRequest(req *http.Request) (RecordedRequest, *http.Request, error) {
+	recorded := RecordedRequest{Method: req.Method, URL: req.URL.String()}
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, req, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return recorded, nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	readable := req.Clone(req.Context())
+	readable.Body = io.NopCloser(bytes.NewReader(body))
+	recorded.Body = string(body)
+	return recorded, readable, nil
+}
+
+// redactResponseBody はインストールトークンの発行に対するレスポンスのtokenを伏せた本文を返す。
+// それ以外のレスポンスや、JSONとして読めない本文はそのまま返す。
+func redactResponseBody(req *http.Request, body []byte) string {
+	if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, installationTokenPathSuffix) {
+		return string(body)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return string(body)
+	}
+	if _, ok := fields["token"]; !ok {
+		return string(body)
+	}
+	fields["token"], _ = json.Marshal(redactedToken)
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return string(body)
+	}
+	return string(redacted)
+}
+
+// writeLine はvalueを1行のJSONとして追記する（呼び出し側でmuを保持するか、まだ共有していないこと）
+func (c *Cassette) writeLine(value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette entry: %w", err)
+	}
+	if _, err := c.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// Flags は-recordと-replayのフラグ
+type Flags struct {
+	record string
+	replay string
+}
+
+// RegisterFlags は-recordと-replayのフラグをfsに登録する。
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.record, "record", "", "record every HTTP request and response to this cassette file")
+	fs.StringVar(&f.replay, "replay", "", "serve HTTP responses from this cassette file instead of the network")
+	return f
+}
+
+// Enabled は-recordか-replayが指定されているかどうかを返す。
+func (f *Flags) Enabled() bool {
+	return f.record != "" || f.replay != ""
+}
+
+// Open はフラグで指定されたカセットを開く。どちらも指定されていなければnilを返す。
+func (f *Flags) Open() (*Cassette, error) {
+	switch {
+	case f.record != "" && f.replay != "":
+		return nil, errors.New("use only one of -record and -replay")
+	case f.record != "":
+		return Open(f.record, ModeRecord)
+	case f.replay != "":
+		return Open(f.replay, ModeReplay)
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,200 @@
+package cassette
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// get はclientでurlにGETし、ステータスコードと本文を返す
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestCassette_RecordAndReplay(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "posted %s", body)
+			return
+		}
+		fmt.Fprintf(w, "call %d", n)
+	}))
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	recorder, err := Open(path, ModeRecord)
+	require.NoError(t, err)
+	client := &http.Client{Transport: recorder.Transport(server.Client().Transport)}
+	_, first := get(t, client, server.URL+"/items?page=1")
+	_, second := get(t, client, server.URL+"/items?page=1")
+	resp, err := client.Post(server.URL+"/graphql", "application/json", strings.NewReader(`{"query":"a"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.NoError(t, recorder.Close())
+	server.Close()
+
+	player, err := Open(path, ModeReplay)
+	require.NoError(t, err)
+	assert.Equal(t, recorder.RecordedAt(), player.RecordedAt())
+	client = player.HTTPClient()
+
+	// 同じリクエストは記録した順に返し、記録より多ければ最後のレスポンスを返す
+	_, body := get(t, client, server.URL+"/items?page=1")
+	assert.Equal(t, first, body)
+	_, body = get(t, client, server.URL+"/items?page=1")
+	assert.Equal(t, second, body)
+	status, body := get(t, client, server.URL+"/items?page=1")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, second, body)
+
+	resp, err = client.Post(server.URL+"/graphql", "application/json", strings.NewReader(`{"query":"a"}`))
+	require.NoError(t, err)
+	posted, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, `posted {"query":"a"}`, string(posted))
+	assert.Equal(t, "4999", resp.Header.Get("X-RateLimit-Remaining"))
+	assert.Zero(t, player.Unmatched())
+}
+
+func TestCassette_RedactsInstallationToken(t *testing.T) {
+	const token = "ghs_secret"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"token":%q,"expires_at":"2030-01-01T00:00:00Z"}`, token)
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	tokenURL := server.URL + "/app/installations/1/access_tokens"
+
+	recorder, err := Open(path, ModeRecord)
+	require.NoError(t, err)
+	client := &http.Client{Transport: recorder.Transport(server.Client().Transport)}
+	resp, err := client.Post(tokenURL, "application/json", nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	// 呼び出し側には実際のトークンを返す
+	assert.Contains(t, string(body), token)
+	// 同じ形の本文でも、トークンの発行以外のレスポンスはそのまま記録する
+	_, other := get(t, client, server.URL+"/user")
+	require.NoError(t, recorder.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), token), "only the GET response keeps the token field")
+
+	player, err := Open(path, ModeReplay)
+	require.NoError(t, err)
+	resp, err = player.HTTPClient().Post(tokenURL, "application/json", nil)
+	require.NoError(t, err)
+	replayed, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.JSONEq(t, `{"token":"REDACTED","expires_at":"2030-01-01T00:00:00Z"}`, string(replayed))
+	_, replayedOther := get(t, player.HTTPClient(), server.URL+"/user")
+	assert.Equal(t, other, replayedOther)
+}
+
+func TestCassette_UnmatchedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	recorder, err := Open(path, ModeRecord)
+	require.NoError(t, err)
+	get(t, &http.Client{Transport: recorder.Transport(server.Client().Transport)}, server.URL+"/recorded")
+	require.NoError(t, recorder.Close())
+	server.Close()
+
+	player, err := Open(path, ModeReplay)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+	}{
+		{name: "different path", method: http.MethodGet, url: server.URL + "/other"},
+		{name: "different query", method: http.MethodGet, url: server.URL + "/recorded?page=2"},
+		{name: "different method", method: http.MethodPost, url: server.URL + "/recorded", body: "{}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			_, err = player.HTTPClient().Do(req)
+			require.ErrorIs(t, err, ErrUnmatched)
+			assert.Contains(t, err.Error(), tt.url)
+		})
+	}
+	assert.Equal(t, len(tests), player.Unmatched())
+}
+
+func TestOpen_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Open(filepath.Join(dir, "missing.jsonl"), ModeReplay)
+	assert.Error(t, err)
+
+	_, err = Open(filepath.Join(dir, "cassette.jsonl"), Mode("rewind"))
+	assert.Error(t, err)
+}
+
+func TestFlags_Open(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantMode Mode
+		wantErr  bool
+	}{
+		{name: "neither"},
+		{name: "record", args: []string{"-record", filepath.Join(dir, "a.jsonl")}, wantMode: ModeRecord},
+		{name: "both", args: []string{"-record", filepath.Join(dir, "b.jsonl"), "-replay", filepath.Join(dir, "a.jsonl")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags := RegisterFlags(fs)
+			require.NoError(t, fs.Parse(tt.args))
+
+			cassette, err := flags.Open()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantMode == "" {
+				assert.Nil(t, cassette)
+				return
+			}
+			require.NotNil(t, cassette)
+			assert.Equal(t, tt.wantMode, cassette.Mode())
+			require.NoError(t, cassette.Close())
+		})
+	}
+}
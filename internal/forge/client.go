@@ -0,0 +1,287 @@
+// Package forge はGitea・GitLabのようにJSONを返すREST APIで、GitHub以外のホスティングサービスから
+// PRを取得するクライアントの共通部分（リクエスト、ページ送り、レート制限の待機）を提供する。
+// 認証ヘッダーや次のページの判断のようにサービスごとに異なる部分は、Configで指定する。
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRateLimitRetries はレート制限（429）で待機してリトライする最大回数
+	maxRateLimitRetries = 5
+	// defaultRateLimitWait はRetry-AfterもRateLimit-Resetも返されなかった場合に待つ時間
+	defaultRateLimitWait = time.Minute
+	// longRateLimitWait はこの時間以上待つ場合にOnLongWaitで登録した関数を呼ぶ
+	longRateLimitWait = time.Minute
+	// firstPage は一覧系APIの最初のページ番号
+	firstPage = 1
+	// pageParam はページ番号を指定するクエリパラメータ名
+	pageParam = "page"
+)
+
+const (
+	headerAuthorization  = "Authorization"
+	headerAccept         = "Accept"
+	headerRetryAfter     = "Retry-After"
+	headerRateLimitReset = "RateLimit-Reset"
+)
+
+// Paging は一覧系APIのページ送りの方法
+type Paging struct {
+	// SizeParam は1ページの件数を指定するクエリパラメータ名
+	SizeParam string
+	// PerPage は1ページに取得する件数
+	PerPage int
+	// Next は取得したページの番号・ここまでに取得した件数・レスポンスヘッダーから次のページの番号を返す。
+	// 最後のページなら0を返す。空のページが返された場合はNextを呼ばずに終える
+	Next func(page, fetched int, header http.Header) int
+}
+
+// Config はClientの接続先とサービスごとの違い
+type Config struct {
+	// Service はサービスの表示名（"Gitea"など）。メッセージに使い、小文字にしてOnLongWaitのリソース名にする
+	Service string
+	// APIPath はホストのルートから見たREST APIのパス（"api/v1/"など）
+	APIPath string
+	// HTTPClient はリクエストに使うクライアント。nilの場合はhttp.DefaultClientを使う
+	HTTPClient *http.Client
+	// Authorization はAuthorizationヘッダーの値。空の場合は認証しない
+	Authorization string
+	Paging        Paging
+}
+
+// Client はREST APIのクライアント。複数のgoroutineから共有できる。
+type Client struct {
+	// Now は現在時刻を返す（RateLimit-Resetまでの待機時間の計算に使う）
+	Now func() time.Time
+	// Sleep はレート制限の解除をwaitだけ待つ
+	Sleep func(ctx context.Context, wait time.Duration) error
+
+	service       string
+	baseURL       *url.URL
+	httpClient    *http.Client
+	authorization string
+	paging        Paging
+
+	mu     sync.Mutex
+	onWait func(resource string, wait time.Duration)
+}
+
+// Option はNewの動作を変更する
+type Option func(*Client)
+
+// WithBaseURL はREST APIのベースURLをbaseURLに置き換える。
+// テスト用のサーバーや、サブパスで公開されているインスタンスに接続するために使う。
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if u, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/"); err == nil {
+			c.baseURL = u
+		}
+	}
+}
+
+/**
+ * hostのconfig.Serviceに接続するClientを作成する
+ * REST APIのベースURLはhttps://<host>/<APIPath>になる（WithBaseURLで置き換えられる）
+ */
+func New(host string, config Config, opts ...Option) (*Client, error) {
+	baseURL, err := url.Parse(fmt.Sprintf("https://%s/%s", host, config.APIPath))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s host %q: %w", config.Service, host, err)
+	}
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	c := &Client{
+		Now:           time.Now,
+		Sleep:         sleep,
+		service:       config.Service,
+		baseURL:       baseURL,
+		httpClient:    httpClient,
+		authorization: config.Authorization,
+		paging:        config.Paging,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// OnLongWait はレート制限で長時間待機する直前に呼ばれる関数を登録する。
+// 待機前に進捗を保存したい場合に使う。
+func (c *Client) OnLongWait(fn func(resource string, wait time.Duration)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onWait = fn
+}
+
+func (c *Client) waitHook() func(resource string, wait time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.onWait
+}
+
+// APIError はAPIが2xx以外のステータスを返したことを表す
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Message)
+}
+
+/**
+ * pathにGETリクエストを送り、レスポンスの本文をJSONとしてvに読み込む
+ * レート制限（429）の場合はRetry-AfterかRateLimit-Resetの時刻まで待ってリトライする
+ * ページ送りに使えるよう、レスポンスヘッダーを返す
+ */
+func (c *Client) Get(ctx context.Context, path string, params url.Values, v any) (http.Header, error) {
+	u, err := c.baseURL.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API path %q: %w", path, err)
+	}
+	if len(params) > 0 {
+		u.RawQuery = params.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(headerAccept, "application/json")
+		if c.authorization != "" {
+			req.Header.Set(headerAuthorization, c.authorization)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response from %s: %w", u, err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries {
+			if err := c.waitForRateLimit(ctx, resp.Header); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return nil, &APIError{Method: req.Method, URL: u.String(), StatusCode: resp.StatusCode, Message: errorMessage(resp.StatusCode, body)}
+		}
+
+		if err := json.Unmarshal(body, v); err != nil {
+			return nil, fmt.Errorf("failed to parse response from %s: %w", u, err)
+		}
+		return resp.Header, nil
+	}
+}
+
+// waitForRateLimit はレート制限が解除されるまで待機する
+func (c *Client) waitForRateLimit(ctx context.Context, header http.Header) error {
+	wait := defaultRateLimitWait
+	if seconds, err := strconv.Atoi(header.Get(headerRetryAfter)); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if reset, err := strconv.ParseInt(header.Get(headerRateLimitReset), 10, 64); err == nil {
+		wait = max(time.Unix(reset, 0).Sub(c.Now()), 0)
+	}
+
+	if onWait := c.waitHook(); wait >= longRateLimitWait && onWait != nil {
+		onWait(strings.ToLower(c.service), wait)
+	}
+	fmt.Printf("%s rate limit exceeded. Waiting %v before retrying...\n", c.service, wait.Round(time.Second))
+	return c.Sleep(ctx, wait)
+}
+
+// errorMessage はエラーレスポンスの本文からメッセージを取り出す（{"message": ...}または{"error": ...}）
+func errorMessage(statusCode int, body []byte) string {
+	var payload struct {
+		Message any    `json:"message"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		if message := fmt.Sprint(payload.Message); payload.Message != nil && message != "" {
+			return message
+		}
+		if payload.Error != "" {
+			return payload.Error
+		}
+	}
+	return http.StatusText(statusCode)
+}
+
+func sleep(ctx context.Context, wait time.Duration) error {
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+/**
+ * pathの一覧をページ単位で返すイテレータ
+ * 空のページが返されるまで、次のページはConfigのPaging.Nextで判断する
+ * 途中でループを抜ければ残りのページは取得しない
+ */
+func Pages[T any](ctx context.Context, c *Client, path string, params url.Values) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		query := url.Values{}
+		for key, values := range params {
+			query[key] = append([]string(nil), values...)
+		}
+		query.Set(c.paging.SizeParam, strconv.Itoa(c.paging.PerPage))
+
+		fetched := 0
+		for page := firstPage; page > 0; {
+			query.Set(pageParam, strconv.Itoa(page))
+			var items []T
+			header, err := c.Get(ctx, path, query, &items)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if len(items) == 0 || !yield(items, nil) {
+				return
+			}
+			fetched += len(items)
+			page = c.paging.Next(page, fetched, header)
+		}
+	}
+}
+
+// ListAll はpathの一覧をすべてのページにわたって取得する
+func ListAll[T any](ctx context.Context, c *Client, path string, params url.Values) ([]T, error) {
+	var all []T
+	for items, err := range Pages[T](ctx, c, path, params) {
+		if err != nil {
+			return all, err
+		}
+		all = append(all, items...)
+	}
+	return all, nil
+}
@@ -0,0 +1,156 @@
+package forge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/malsuke/PRalyzer/internal/forgetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPerPage はテスト用のクライアントが1ページに取得する件数
+const testPerPage = 2
+
+// newTestClient はserverに接続し、X-Next-Pageで次のページを判断するClientを作成する
+func newTestClient(t *testing.T, serverURL string, authorization string) *Client {
+	t.Helper()
+
+	client, err := New("forge.example.com", Config{
+		Service:       "Test",
+		APIPath:       "api/",
+		Authorization: authorization,
+		Paging: Paging{SizeParam: "per_page", PerPage: testPerPage, Next: func(_, _ int, header http.Header) int {
+			next, _ := strconv.Atoi(header.Get("X-Next-Page"))
+			return next
+		}},
+	}, WithBaseURL(serverURL+"/api/"))
+	require.NoError(t, err)
+	return client
+}
+
+func TestPages(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	server := forgetest.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/items", r.URL.Path)
+		assert.Equal(t, "merged", r.URL.Query().Get("state"))
+
+		page, start, end := forgetest.Page(r, "per_page", len(items), 0)
+		if end < len(items) {
+			w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+		}
+		forgetest.WriteJSON(w, items[start:end])
+	}))
+	// 最初のリクエストはレート制限され、Retry-Afterだけ待ってリトライする
+	server.RateLimit(1, "2")
+
+	client := newTestClient(t, server.URL, "Bearer secret")
+	var waits []time.Duration
+	client.Sleep = func(ctx context.Context, wait time.Duration) error {
+		waits = append(waits, wait)
+		return nil
+	}
+	got, err := ListAll[int](context.Background(), client, "items", url.Values{"state": {"merged"}})
+
+	require.NoError(t, err)
+	assert.Equal(t, items, got)
+	assert.Equal(t, []time.Duration{2 * time.Second}, waits)
+	assert.Equal(t, []string{"Bearer secret", "Bearer secret", "Bearer secret", "Bearer secret"}, server.Authorizations())
+}
+
+func TestClient_Get(t *testing.T) {
+	reset := time.Date(2024, 3, 1, 12, 0, 30, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		header    map[string]string
+		wantWaits []time.Duration
+	}{
+		{name: "retry after", header: map[string]string{"Retry-After": "3"}, wantWaits: []time.Duration{3 * time.Second}},
+		{name: "rate limit reset", header: map[string]string{"RateLimit-Reset": strconv.FormatInt(reset.Unix(), 10)}, wantWaits: []time.Duration{30 * time.Second}},
+		{name: "no hint", wantWaits: []time.Duration{defaultRateLimitWait}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limited := true
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if limited {
+					limited = false
+					for key, value := range tt.header {
+						w.Header().Set(key, value)
+					}
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				fmt.Fprint(w, `{}`)
+			}))
+			defer server.Close()
+
+			client := newTestClient(t, server.URL, "")
+			client.Now = func() time.Time { return reset.Add(-30 * time.Second) }
+			var waits []time.Duration
+			client.Sleep = func(ctx context.Context, wait time.Duration) error {
+				waits = append(waits, wait)
+				return nil
+			}
+			var resources []string
+			client.OnLongWait(func(resource string, wait time.Duration) { resources = append(resources, resource) })
+
+			var v map[string]any
+			_, err := client.Get(context.Background(), "item", nil, &v)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantWaits, waits)
+			if tt.wantWaits[0] >= longRateLimitWait {
+				assert.Equal(t, []string{"test"}, resources)
+			} else {
+				assert.Empty(t, resources)
+			}
+		})
+	}
+}
+
+func TestClient_ReportsAPIErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "message", body: `{"message": "not found"}`, want: "not found"},
+		{name: "structured message", body: `{"message": {"title": ["is too long"]}}`, want: "map[title:[is too long]]"},
+		{name: "error", body: `{"error": "invalid_token"}`, want: "invalid_token"},
+		{name: "empty message", body: `{"message": ""}`, want: "Not Found"},
+		{name: "not json", body: `<html>`, want: "Not Found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			var v any
+			_, err := newTestClient(t, server.URL, "").Get(context.Background(), "missing", nil, &v)
+
+			var apiErr *APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+			assert.Equal(t, tt.want, apiErr.Message)
+		})
+	}
+}
+
+func TestContainsFold(t *testing.T) {
+	assert.True(t, ContainsFold([]string{"Security", "bug"}, "security"))
+	assert.False(t, ContainsFold([]string{"Security", "bug"}, "docs"))
+	assert.False(t, ContainsFold(nil, "security"))
+}
@@ -0,0 +1,14 @@
+package forge
+
+import (
+	"slices"
+	"strings"
+)
+
+// ContainsFold はvaluesに大文字・小文字を区別せずにvalueと一致する値があるかどうかを返す。
+// APIで絞り込めない条件（ラベルや作成者）を取得後に確かめるときに使う。
+func ContainsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
+	})
+}
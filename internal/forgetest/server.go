@@ -0,0 +1,119 @@
+// Package forgetest はテスト用に、forgeのクライアント（Gitea・GitLab）が接続するREST APIのスタンドインの共通部分を提供する。
+// リクエストの記録、レート制限（429）の注入、エラーレスポンスとページの範囲の計算を扱い、
+// エンドポイントとフィクスチャは各サービスのテストでhttp.Handlerとして用意する。
+package forgetest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// firstPage は一覧系APIの最初のページ番号
+const firstPage = 1
+
+const (
+	headerAuthorization = "Authorization"
+	headerRetryAfter    = "Retry-After"
+)
+
+// Server はhandlerにリクエストを渡すREST APIのスタンドイン。複数のgoroutineから同時に使える。
+type Server struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	requests       []*http.Request
+	authorizations []string
+	rateLimited    int
+	retryAfter     string
+}
+
+// NewServer はhandlerにリクエストを渡すServerを起動する。テストの終了時に停止する
+func NewServer(t testing.TB, handler http.Handler) *Server {
+	t.Helper()
+
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.authorizations = append(s.authorizations, r.Header.Get(headerAuthorization))
+		limited := s.rateLimited > 0
+		if limited {
+			s.rateLimited--
+		}
+		retryAfter := s.retryAfter
+		s.mu.Unlock()
+
+		if limited {
+			if retryAfter != "" {
+				w.Header().Set(headerRetryAfter, retryAfter)
+			}
+			WriteError(w, http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// RateLimit は次のcount件のリクエストに429を返す。retryAfterが空でなければRetry-Afterヘッダー（秒数）に付ける
+func (s *Server) RateLimit(count int, retryAfter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimited = count
+	s.retryAfter = retryAfter
+}
+
+// Requests はエスケープされたパスがsuffixで終わるリクエストを、受け取った順に返す
+func (s *Server) Requests(suffix string) []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []*http.Request
+	for _, r := range s.requests {
+		if strings.HasSuffix(r.URL.EscapedPath(), suffix) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// Authorizations は受け取ったリクエストのAuthorizationヘッダーを、受け取った順に返す
+func (s *Server) Authorizations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.authorizations...)
+}
+
+// WriteJSON はvをJSONとして返す
+func WriteJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// WriteError はstatusと{"message": message}の本文を返す
+func WriteError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+/**
+ * rのpageとsizeParamのクエリパラメータから、total件の一覧のうち返すページの番号と範囲[start, end)を求める
+ * maxSizeが正なら、サーバーの上限として1ページの件数をmaxSizeまでに減らす
+ */
+func Page(r *http.Request, sizeParam string, total, maxSize int) (page, start, end int) {
+	page, _ = strconv.Atoi(r.URL.Query().Get("page"))
+	page = max(page, firstPage)
+	size, _ := strconv.Atoi(r.URL.Query().Get(sizeParam))
+	if maxSize > 0 {
+		size = min(size, maxSize)
+	}
+	start = min((page-firstPage)*size, total)
+	end = min(start+size, total)
+	return page, start, end
+}
@@ -0,0 +1,62 @@
+package forgetest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	server := NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, map[string]string{"path": r.URL.Path})
+	}))
+	server.RateLimit(1, "3")
+
+	get := func(path, authorization string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", authorization)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	limited := get("/api/items", "token a")
+	assert.Equal(t, http.StatusTooManyRequests, limited.StatusCode)
+	assert.Equal(t, "3", limited.Header.Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, get("/api/items", "token b").StatusCode)
+	assert.Equal(t, http.StatusOK, get("/api/other", "").StatusCode)
+
+	assert.Len(t, server.Requests("/items"), 2)
+	assert.Equal(t, []string{"token a", "token b", ""}, server.Authorizations())
+}
+
+func TestPage(t *testing.T) {
+	tests := []struct {
+		query     string
+		maxSize   int
+		wantPage  int
+		wantStart int
+		wantEnd   int
+	}{
+		{query: "page=1&limit=2", wantPage: 1, wantStart: 0, wantEnd: 2},
+		{query: "page=3&limit=2", wantPage: 3, wantStart: 4, wantEnd: 5},
+		{query: "limit=2", wantPage: 1, wantStart: 0, wantEnd: 2},
+		{query: "page=2&limit=10", maxSize: 3, wantPage: 2, wantStart: 3, wantEnd: 5},
+		{query: "page=9&limit=2", wantPage: 9, wantStart: 5, wantEnd: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s max %d", tt.query, tt.maxSize), func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/items?"+tt.query, nil)
+			page, start, end := Page(r, "limit", 5, tt.maxSize)
+			assert.Equal(t, []int{tt.wantPage, tt.wantStart, tt.wantEnd}, []int{page, start, end})
+		})
+	}
+}
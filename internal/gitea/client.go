@@ -0,0 +1,82 @@
+// Package gitea はGitea・ForgejoのPull Requestをキーワードで検索し、一覧と会話を取得するクライアントを提供する。
+// GiteaのREST API（v1）のPR・コメントはGitHubとほぼ同じ形のため、可能なものはgo-githubの型にそのまま読み込み、
+// 異なる部分（レビューの状態やレビューコメントのスレッド）だけを変換する。
+package gitea
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/malsuke/PRalyzer/internal/forge"
+)
+
+// TokenEnvVar はGitea・Forgejoのアクセストークンを渡す環境変数
+const TokenEnvVar = "GITEA_TOKEN"
+
+const (
+	// perPage は一覧系APIで1ページに取得する件数（GiteaのMAX_RESPONSE_ITEMSのデフォルト値）
+	perPage = 50
+	// limitParam は1ページの件数を指定するクエリパラメータ名
+	limitParam = "limit"
+	// headerTotalCount は一覧の総数を返すヘッダー
+	headerTotalCount = "X-Total-Count"
+)
+
+// Client はGitea・ForgejoのREST API（v1）のクライアント。
+// ForRepositoryで対象のリポジトリを指定したClientで、Pull Requestを検索・取得する。
+// リクエスト・ページ送り・レート制限の待機（OnLongWaitなど）はforge.Clientが扱う。
+type Client struct {
+	Owner string
+	Name  string
+
+	*forge.Client
+}
+
+/**
+ * hostのGitea・Forgejoに接続するClientを作成する
+ * tokenが空の場合は認証せずに公開リポジトリだけを読む
+ * httpClientがnilの場合はhttp.DefaultClientを使う
+ */
+func NewClient(token string, host string, httpClient *http.Client, opts ...forge.Option) (*Client, error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if host == "" {
+		return nil, fmt.Errorf("gitea host is empty")
+	}
+
+	config := forge.Config{
+		Service:    "Gitea",
+		APIPath:    "api/v1/",
+		HTTPClient: httpClient,
+		Paging:     forge.Paging{SizeParam: limitParam, PerPage: perPage, Next: nextPage},
+	}
+	if token != "" {
+		config.Authorization = "token " + token
+	}
+	rest, err := forge.New(host, config, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Client: rest}, nil
+}
+
+// ForRepository はowner/nameのPull Requestを扱うClientを返す。認証と接続先はcと共有する。
+func (c *Client) ForRepository(owner, name string) *Client {
+	return &Client{Owner: owner, Name: name, Client: c.Client}
+}
+
+// repoPath はリポジトリ配下のAPIのパスを返す
+func (c *Client) repoPath(format string, args ...any) string {
+	return "repos/" + url.PathEscape(c.Owner) + "/" + url.PathEscape(c.Name) + fmt.Sprintf(format, args...)
+}
+
+// nextPage は次のページの番号を返す。X-Total-Countの件数に達したら最後のページとみなす。
+// サーバーのMAX_RESPONSE_ITEMSがperPageより小さいと1ページの件数は減るため、件数では判断しない
+func nextPage(page, fetched int, header http.Header) int {
+	if total, err := strconv.Atoi(header.Get(headerTotalCount)); err == nil && fetched >= total {
+		return 0
+	}
+	return page + 1
+}
@@ -0,0 +1,332 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malsuke/PRalyzer/internal/forge"
+	"github.com/malsuke/PRalyzer/internal/forgetest"
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGitea はテスト用のGitea API（v1）のスタンドイン。owner/repoのPR・コメント・レビューだけに対応する
+type fakeGitea struct {
+	*forgetest.Server
+
+	issues         []map[string]any
+	pulls          map[int]map[string]any
+	comments       map[int][]map[string]any
+	reviews        map[int][]map[string]any
+	reviewComments map[int64][]map[string]any
+
+	// maxItems はサーバーのMAX_RESPONSE_ITEMS（0なら要求された件数をそのまま返す）
+	maxItems int
+	// omitTotal はX-Total-Countを返さない古いサーバーを再現する
+	omitTotal bool
+}
+
+func newFakeGitea(t *testing.T) *fakeGitea {
+	t.Helper()
+
+	f := &fakeGitea{
+		pulls:          make(map[int]map[string]any),
+		comments:       make(map[int][]map[string]any),
+		reviews:        make(map[int][]map[string]any),
+		reviewComments: make(map[int64][]map[string]any),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/issues", func(w http.ResponseWriter, r *http.Request) {
+		f.writePage(w, r, f.searchIssues(r))
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+		var pulls []map[string]any
+		for number := 1; number <= len(f.pulls); number++ {
+			pulls = append(pulls, f.pulls[number])
+		}
+		f.writePage(w, r, pulls)
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/pulls/{number}", func(w http.ResponseWriter, r *http.Request) {
+		number, _ := strconv.Atoi(r.PathValue("number"))
+		pr, ok := f.pulls[number]
+		if !ok {
+			forgetest.WriteError(w, http.StatusNotFound, "pull request does not exist")
+			return
+		}
+		forgetest.WriteJSON(w, pr)
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/issues/{number}/comments", func(w http.ResponseWriter, r *http.Request) {
+		number, _ := strconv.Atoi(r.PathValue("number"))
+		forgetest.WriteJSON(w, append([]map[string]any{}, f.comments[number]...))
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/pulls/{number}/reviews", func(w http.ResponseWriter, r *http.Request) {
+		number, _ := strconv.Atoi(r.PathValue("number"))
+		f.writePage(w, r, f.reviews[number])
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/pulls/{number}/reviews/{id}/comments", func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		forgetest.WriteJSON(w, append([]map[string]any{}, f.reviewComments[id]...))
+	})
+	f.Server = forgetest.NewServer(t, mux)
+	return f
+}
+
+// searchIssues はtype・state・qで一覧を絞り込む（qはタイトル・本文・コメントのいずれかに含まれるか）
+func (f *fakeGitea) searchIssues(r *http.Request) []map[string]any {
+	query := r.URL.Query()
+	var issues []map[string]any
+	for _, issue := range f.issues {
+		number := issue["number"].(int)
+		if query.Get("type") == "pulls" && issue["pull_request"] == nil {
+			continue
+		}
+		if state := query.Get("state"); state != stateAll && state != issue["state"] {
+			continue
+		}
+		if q := strings.ToLower(query.Get("q")); q != "" {
+			text := strings.ToLower(issue["title"].(string) + " " + issue["body"].(string))
+			for _, comment := range f.comments[number] {
+				text += " " + strings.ToLower(comment["body"].(string))
+			}
+			if !strings.Contains(text, q) {
+				continue
+			}
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// writePage はitemsのうちpage・limitのページを返し、総数をX-Total-Countに付ける
+func (f *fakeGitea) writePage(w http.ResponseWriter, r *http.Request, items []map[string]any) {
+	_, start, end := forgetest.Page(r, limitParam, len(items), f.maxItems)
+	if !f.omitTotal {
+		w.Header().Set(headerTotalCount, strconv.Itoa(len(items)))
+	}
+	forgetest.WriteJSON(w, append([]map[string]any{}, items[start:end]...))
+}
+
+func (f *fakeGitea) client(t *testing.T, token string) *Client {
+	t.Helper()
+
+	client, err := NewClient(token, "gitea.example.com", nil, forge.WithBaseURL(f.URL+"/api/v1"))
+	require.NoError(t, err)
+	return client.ForRepository("owner", "repo")
+}
+
+func stamp(day int) string {
+	return time.Date(2024, 2, day, 9, 0, 0, 0, time.UTC).Format(time.RFC3339)
+}
+
+// addPullRequest はIssue一覧とPRの詳細の両方に同じPRを登録する
+func (f *fakeGitea) addPullRequest(number int, title, body, state, author, base string, merged bool, day int) {
+	var mergedAt any
+	if merged {
+		mergedAt = stamp(day + 1)
+	}
+	user := map[string]any{"id": 1, "login": author}
+	labels := []map[string]any{}
+	if strings.Contains(title, "security") {
+		labels = append(labels, map[string]any{"name": "security"})
+	}
+	f.issues = append(f.issues, map[string]any{
+		"number": number, "title": title, "body": body, "state": state, "user": user, "labels": labels,
+		"created_at": stamp(day), "updated_at": stamp(day + 1),
+		"pull_request": map[string]any{"merged": merged, "merged_at": mergedAt},
+	})
+	f.pulls[number] = map[string]any{
+		"number": number, "title": title, "body": body, "state": state, "user": user, "labels": labels,
+		"merged": merged, "merged_at": mergedAt, "created_at": stamp(day), "updated_at": stamp(day + 1),
+		"base": map[string]any{"ref": base}, "head": map[string]any{"ref": fmt.Sprintf("pr-%d", number), "sha": "abc"},
+		"html_url": fmt.Sprintf("https://gitea.example.com/owner/repo/pulls/%d", number), "changed_files": 2,
+	}
+}
+
+func seed(f *fakeGitea) {
+	f.addPullRequest(1, "Fix security issue in login", "Escapes the redirect.", stateClosed, "alice", "main", true, 1)
+	f.addPullRequest(2, "Refactor session", "", stateClosed, "bob", "release", true, 2)
+	f.addPullRequest(3, "Draft redirect handling", "", stateOpen, "carol", "main", false, 3)
+	f.issues = append(f.issues, map[string]any{"number": 4, "title": "Open redirect report", "body": "", "state": stateOpen, "created_at": stamp(4), "updated_at": stamp(4)})
+	f.comments[2] = []map[string]any{{"id": 20, "body": "This also fixes the open redirect.", "user": map[string]any{"login": "alice"}, "created_at": stamp(3)}}
+}
+
+func TestClient_SearchPullRequestResults(t *testing.T) {
+	tests := []struct {
+		name  string
+		query github.SearchQuery
+		want  []int
+	}{
+		{name: "merged with the keyword anywhere", query: github.DefaultSearchQuery("redirect"), want: []int{1, 2}},
+		{name: "any state", query: github.SearchQuery{Keyword: "redirect"}, want: []int{1, 2, 3}},
+		{name: "title only", query: github.SearchQuery{Keyword: "redirect", Scopes: []github.SearchScope{github.ScopeTitle}}, want: []int{3}},
+		{name: "title or body", query: github.SearchQuery{Keyword: "redirect", Scopes: []github.SearchScope{github.ScopeTitle, github.ScopeBody}}, want: []int{1, 3}},
+		{name: "open", query: github.SearchQuery{Keyword: "redirect", State: github.StateOpen}, want: []int{3}},
+		{name: "label", query: github.SearchQuery{Labels: []string{"Security"}}, want: []int{1}},
+		{name: "excluded authors", query: github.SearchQuery{ExcludeAuthors: []string{"alice", "carol"}}, want: []int{2}},
+		{name: "base branch", query: github.SearchQuery{Keyword: "redirect", Base: "main"}, want: []int{1, 3}},
+		{name: "created range", query: github.SearchQuery{Created: github.DateRange{From: time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)}}, want: []int{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeGitea(t)
+			seed(f)
+
+			results, err := f.client(t, "").SearchPullRequestResults(context.Background(), tt.query)
+			require.NoError(t, err)
+
+			var numbers []int
+			for _, result := range results {
+				numbers = append(numbers, result.Number)
+			}
+			assert.Equal(t, tt.want, numbers)
+		})
+	}
+}
+
+func TestSearchParams(t *testing.T) {
+	query := github.DefaultSearchQuery("xss")
+	query.Labels = []string{"security", "bug"}
+	query.Authors = []string{"alice"}
+	query.Updated = github.DateRange{From: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+
+	params := searchParams(query)
+
+	assert.Equal(t, "pulls", params.Get("type"))
+	assert.Equal(t, "xss", params.Get("q"))
+	assert.Equal(t, stateClosed, params.Get("state"))
+	assert.Equal(t, "security,bug", params.Get("labels"))
+	assert.Equal(t, "alice", params.Get("created_by"))
+	assert.Equal(t, "2024-03-01T00:00:00Z", params.Get("since"))
+}
+
+func TestClient_PullRequests(t *testing.T) {
+	// smallLimit はperPageより小さいMAX_RESPONSE_ITEMS
+	const smallLimit = 30
+
+	tests := []struct {
+		name         string
+		count        int
+		maxItems     int
+		omitTotal    bool
+		wantRequests int
+	}{
+		{name: "stops at the total count", count: perPage + 5, wantRequests: 2},
+		{name: "server limits the page size", count: perPage + 5, maxItems: smallLimit, wantRequests: 2},
+		{name: "stops at an empty page without the total count", count: perPage + 5, maxItems: smallLimit, omitTotal: true, wantRequests: 3},
+		{name: "full last page without the total count", count: perPage * 2, omitTotal: true, wantRequests: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeGitea(t)
+			f.maxItems = tt.maxItems
+			f.omitTotal = tt.omitTotal
+			for number := 1; number <= tt.count; number++ {
+				f.addPullRequest(number, "PR", "", stateOpen, "alice", "main", false, 1)
+			}
+
+			var numbers []int
+			for pr, err := range f.client(t, "").PullRequests(context.Background()) {
+				require.NoError(t, err)
+				numbers = append(numbers, pr.GetNumber())
+			}
+
+			assert.Len(t, numbers, tt.count)
+			assert.Equal(t, tt.wantRequests, len(f.Requests("/api/v1/repos/owner/repo/pulls")))
+		})
+	}
+}
+
+func TestClient_FetchConversations(t *testing.T) {
+	f := newFakeGitea(t)
+	seed(f)
+	f.comments[1] = []map[string]any{
+		{"id": 10, "body": "Can an attacker control the host?", "user": map[string]any{"login": "bob"}, "created_at": stamp(1), "html_url": "https://gitea.example.com/owner/repo/pulls/1#issuecomment-10"},
+	}
+	f.reviews[1] = []map[string]any{
+		{"id": 100, "user": map[string]any{"login": "bob"}, "body": "", "state": "REQUEST_CHANGES", "submitted_at": stamp(1), "comments_count": 2},
+		{"id": 101, "user": map[string]any{"login": "alice"}, "body": "", "state": "COMMENT", "submitted_at": stamp(2), "comments_count": 1},
+		{"id": 102, "user": map[string]any{"login": "bob"}, "body": "LGTM", "state": "APPROVED", "submitted_at": stamp(3), "comments_count": 0},
+		{"id": 103, "user": map[string]any{"login": "carol"}, "body": "", "state": "REQUEST_REVIEW", "comments_count": 0},
+	}
+	f.reviewComments[100] = []map[string]any{
+		{"id": 1000, "body": "Validate the URL here.", "user": map[string]any{"login": "bob"}, "path": "login.go", "position": 12, "original_position": 0, "commit_id": "abc", "diff_hunk": "@@ -10,3 +10,4 @@", "created_at": stamp(1)},
+		{"id": 1001, "body": "Why was this check removed?", "user": map[string]any{"login": "bob"}, "path": "login.go", "position": 0, "original_position": 30, "created_at": stamp(1)},
+	}
+	f.reviewComments[101] = []map[string]any{
+		{"id": 1002, "body": "Done.", "user": map[string]any{"login": "alice"}, "path": "login.go", "position": 12, "original_position": 0, "created_at": stamp(2), "resolver": map[string]any{"login": "bob"}},
+	}
+
+	conversations, err := f.client(t, "secret").FetchConversations(context.Background(), []int{1, 99})
+	require.Error(t, err)
+	require.Len(t, conversations, 1)
+	assert.Equal(t, "token secret", f.Authorizations()[0])
+
+	conversation := conversations[1]
+	pr := conversation.PullRequest
+	assert.Equal(t, "Fix security issue in login", pr.GetTitle())
+	assert.True(t, pr.GetMerged())
+	assert.Equal(t, "alice", pr.GetUser().GetLogin())
+	assert.Equal(t, "main", pr.GetBase().GetRef())
+	assert.Equal(t, 2, pr.GetChangedFiles())
+
+	require.Len(t, conversation.IssueComments, 1)
+	assert.Equal(t, "bob", conversation.IssueComments[0].GetUser().GetLogin())
+
+	require.Len(t, conversation.Reviews, 3)
+	assert.Equal(t, "CHANGES_REQUESTED", conversation.Reviews[0].GetState())
+	assert.Equal(t, "COMMENTED", conversation.Reviews[1].GetState())
+	assert.Equal(t, "APPROVED", conversation.Reviews[2].GetState())
+
+	require.Len(t, conversation.ReviewComments, 3)
+	root, removed, reply := conversation.ReviewComments[0], conversation.ReviewComments[1], conversation.ReviewComments[2]
+	assert.Equal(t, 12, root.GetLine())
+	assert.Equal(t, "RIGHT", root.GetSide())
+	assert.Nil(t, root.Position)
+	assert.Equal(t, "@@ -10,3 +10,4 @@", root.GetDiffHunk())
+	assert.Equal(t, 30, removed.GetLine())
+	assert.Equal(t, "LEFT", removed.GetSide())
+	assert.Zero(t, removed.GetInReplyTo())
+	assert.Equal(t, int64(1000), reply.GetInReplyTo())
+	assert.Equal(t, map[int64]bool{1000: true, 1001: false}, conversation.ResolvedThreads)
+
+	// コメントのないレビューのコメントは取得しない
+	assert.Empty(t, f.Requests("/pulls/1/reviews/102/comments"))
+}
+
+func TestParseRepositoryRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     string
+		want    github.RepositoryRef
+		wantErr bool
+	}{
+		{name: "url", ref: "https://codeberg.org/forgejo/forgejo", want: github.RepositoryRef{Host: "codeberg.org", Owner: "forgejo", Name: "forgejo"}},
+		{name: "pull request url", ref: "https://Gitea.example.com/owner/repo/pulls/12", want: github.RepositoryRef{Host: "gitea.example.com", Owner: "owner", Name: "repo"}},
+		{name: "custom port", ref: "http://gitea.local:3000/owner/repo.git", want: github.RepositoryRef{Host: "gitea.local:3000", Owner: "owner", Name: "repo"}},
+		{name: "ssh", ref: "git@codeberg.org:owner/repo.git", want: github.RepositoryRef{Host: "codeberg.org", Owner: "owner", Name: "repo"}},
+		{name: "ssh url ignores the ssh port", ref: "ssh://git@gitea.local:2222/owner/repo.git", want: github.RepositoryRef{Host: "gitea.local", Owner: "owner", Name: "repo"}},
+		{name: "without host", ref: "owner/repo", wantErr: true},
+		{name: "without name", ref: "https://codeberg.org/owner", wantErr: true},
+		{name: "empty", ref: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRepositoryRef(tt.ref)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
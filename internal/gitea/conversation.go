@@ -0,0 +1,166 @@
+package gitea
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/forge"
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// レビューの状態（Giteaのstateの値）
+const (
+	reviewStatePending        = "PENDING"
+	reviewStateRequestReview  = "REQUEST_REVIEW"
+	reviewStateComment        = "COMMENT"
+	reviewStateRequestChanges = "REQUEST_CHANGES"
+)
+
+// review はPRのレビュー。GitHubとほぼ同じ形だが、状態の値が異なる
+type review struct {
+	gh.PullRequestReview
+	// CommentsCount はレビューに含まれるレビューコメントの数
+	CommentsCount int `json:"comments_count"`
+}
+
+// reviewComment はレビューコメント。positionは新しい側、original_positionは古い側の行番号を表す。
+// Giteaには返信先（in_reply_to_id）がないため、同じ位置へのコメントを1つのスレッドとみなす
+type reviewComment struct {
+	gh.PullRequestComment
+	// Resolver はスレッドを解決したユーザー（未解決ならnil）
+	Resolver *gh.User `json:"resolver"`
+}
+
+/**
+ * PRごとに本体・Issue Comments・Reviews・Review Commentsを取得する
+ * Review Commentsはレビューごとに取得し、同じファイル・行へのコメントを作成日時順に並べて
+ * 2件目以降を先頭のコメントへの返信にする。解決済みのスレッドはResolvedThreadsに設定する
+ */
+func (c *Client) FetchConversations(ctx context.Context, prNumbers []int) (map[int]*github.Conversation, error) {
+	conversations := make(map[int]*github.Conversation, len(prNumbers))
+	var errs []error
+
+	for _, prNumber := range prNumbers {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		conversation, err := c.fetchConversation(ctx, prNumber)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		conversations[prNumber] = conversation
+	}
+
+	return conversations, errors.Join(errs...)
+}
+
+func (c *Client) fetchConversation(ctx context.Context, prNumber int) (*github.Conversation, error) {
+	pullRequest, err := c.GetPullRequest(ctx, prNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// Issueのコメント一覧はページ分割されず、すべてのコメントが返される
+	var issueComments []*gh.IssueComment
+	if _, err := c.Get(ctx, c.repoPath("/issues/%d/comments", prNumber), nil, &issueComments); err != nil {
+		return nil, fmt.Errorf("failed to get comments of pull request #%d: %w", prNumber, err)
+	}
+
+	reviews, err := forge.ListAll[review](ctx, c.Client, c.repoPath("/pulls/%d/reviews", prNumber), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviews of pull request #%d: %w", prNumber, err)
+	}
+
+	var comments []reviewComment
+	conversation := &github.Conversation{PullRequest: pullRequest, IssueComments: issueComments}
+	for _, r := range reviews {
+		if r.CommentsCount > 0 {
+			var reviewComments []reviewComment
+			if _, err := c.Get(ctx, c.repoPath("/pulls/%d/reviews/%d/comments", prNumber, r.GetID()), nil, &reviewComments); err != nil {
+				return nil, fmt.Errorf("failed to get review comments of pull request #%d: %w", prNumber, err)
+			}
+			comments = append(comments, reviewComments...)
+		}
+		if converted := r.githubReview(); converted != nil {
+			conversation.Reviews = append(conversation.Reviews, converted)
+		}
+	}
+	conversation.ReviewComments, conversation.ResolvedThreads = threadReviewComments(comments)
+	return conversation, nil
+}
+
+// githubReview はレビューの状態をGitHubの値に変換する。下書き（PENDING）とレビュー依頼はレビューとして扱わずnilを返す
+func (r *review) githubReview() *gh.PullRequestReview {
+	converted := r.PullRequestReview
+	switch r.GetState() {
+	case reviewStatePending, reviewStateRequestReview:
+		return nil
+	case reviewStateComment:
+		converted.State = gh.Ptr(github.ReviewCommented)
+	case reviewStateRequestChanges:
+		converted.State = gh.Ptr(github.ReviewChangesRequested)
+	}
+	return &converted
+}
+
+// threadKey はレビューコメントのスレッドを見分けるキー（ファイル・side・行）
+type threadKey struct {
+	path string
+	side string
+	line int
+}
+
+/**
+ * レビューコメントをファイル・行ごとのスレッドにまとめ、GitHubのレビューコメントの形に変換する
+ * positionがあれば新しい側（RIGHT）、なければoriginal_positionの古い側（LEFT）の行とする
+ * スレッドの先頭以外のコメントはInReplyToに先頭のコメントのIDを設定する
+ */
+func threadReviewComments(comments []reviewComment) ([]*gh.PullRequestComment, map[int64]bool) {
+	slices.SortStableFunc(comments, func(a, b reviewComment) int {
+		return cmp.Compare(a.GetCreatedAt().Unix(), b.GetCreatedAt().Unix())
+	})
+
+	var converted []*gh.PullRequestComment
+	var resolved map[int64]bool
+	roots := make(map[threadKey]int64)
+	for _, rc := range comments {
+		comment := rc.PullRequestComment
+		key := threadKey{path: comment.GetPath()}
+		if position := comment.GetPosition(); position > 0 {
+			key.side, key.line = github.SideRight, position
+		} else if original := comment.GetOriginalPosition(); original > 0 {
+			key.side, key.line = github.SideLeft, original
+		}
+		// Giteaのposition・original_positionは行番号のため、差分内の位置として解釈されないようLine・OriginalLineに移す
+		comment.Position, comment.OriginalPosition = nil, nil
+		if key.line > 0 {
+			comment.SubjectType = gh.Ptr(github.SubjectTypeLine)
+			comment.Side = gh.Ptr(key.side)
+			comment.Line = gh.Ptr(key.line)
+			comment.OriginalLine = gh.Ptr(key.line)
+		}
+
+		rootID, ok := roots[key]
+		if !ok {
+			rootID = comment.GetID()
+			roots[key] = rootID
+		} else {
+			comment.InReplyTo = gh.Ptr(rootID)
+		}
+		// Giteaではスレッドのどのコメントからでも解決できるため、いずれかに解決者がいれば解決済みとする
+		if resolved == nil {
+			resolved = make(map[int64]bool)
+		}
+		resolved[rootID] = resolved[rootID] || rc.Resolver != nil
+		converted = append(converted, &comment)
+	}
+
+	return converted, resolved
+}
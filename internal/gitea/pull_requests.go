@@ -0,0 +1,193 @@
+package gitea
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/forge"
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// Issueの状態（stateの値）
+const (
+	stateOpen   = "open"
+	stateClosed = "closed"
+	stateAll    = "all"
+)
+
+// issue はIssue一覧（type=pulls）で返されるPR
+type issue struct {
+	Number      int          `json:"number"`
+	Title       string       `json:"title"`
+	Body        string       `json:"body"`
+	State       string       `json:"state"`
+	User        *gh.User     `json:"user"`
+	Labels      []*gh.Label  `json:"labels"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	PullRequest *pullRequest `json:"pull_request"`
+}
+
+// pullRequest はIssueに付くPRの情報
+type pullRequest struct {
+	Merged   bool       `json:"merged"`
+	MergedAt *time.Time `json:"merged_at"`
+}
+
+/**
+ * Issueの一覧をtype=pullsとキーワード（q）で検索し、queryに一致するPRの番号と最終更新日時を返す
+ * Giteaのキーワード検索はタイトル・本文・コメントをまとめて対象にするため、
+ * コメントを含まない範囲が指定された場合はタイトル・本文にキーワードが含まれるものだけを残す
+ * 一覧で絞り込めない条件（マージ状態・作成日時・マージ日時・除外条件など）は取得後に適用し、
+ * baseブランチの条件はPRの詳細を取得して判定する
+ */
+func (c *Client) SearchPullRequestResults(ctx context.Context, query github.SearchQuery) ([]github.SearchResult, error) {
+	issues, err := forge.ListAll[issue](ctx, c.Client, c.repoPath("/issues"), searchParams(query))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search pull requests: %w", err)
+	}
+
+	var results []github.SearchResult
+	for _, issue := range issues {
+		if issue.PullRequest == nil || !matches(&issue, query) {
+			continue
+		}
+		if query.Base != "" {
+			pr, err := c.GetPullRequest(ctx, issue.Number)
+			if err != nil {
+				return nil, err
+			}
+			if pr.GetBase().GetRef() != query.Base {
+				continue
+			}
+		}
+		results = append(results, github.SearchResult{Number: issue.Number, UpdatedAt: issue.UpdatedAt})
+	}
+
+	slices.SortFunc(results, func(a, b github.SearchResult) int {
+		return a.Number - b.Number
+	})
+	return slices.CompactFunc(results, func(a, b github.SearchResult) bool {
+		return a.Number == b.Number
+	}), nil
+}
+
+/**
+ * Pull Requestを作成日時の古い順に1件ずつ返すイテレータ
+ * ページは必要になった時点で取得する
+ */
+func (c *Client) PullRequests(ctx context.Context) iter.Seq2[*gh.PullRequest, error] {
+	return func(yield func(*gh.PullRequest, error) bool) {
+		params := url.Values{"state": {stateAll}, "sort": {"oldest"}}
+		for prs, err := range forge.Pages[*gh.PullRequest](ctx, c.Client, c.repoPath("/pulls"), params) {
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to list pull requests: %w", err))
+				return
+			}
+			for _, pr := range prs {
+				if !yield(pr, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// GetPullRequest は番号がnumberのPull Requestを取得する。GiteaのPRはGitHubと同じ形のため、そのまま読み込む
+func (c *Client) GetPullRequest(ctx context.Context, number int) (*gh.PullRequest, error) {
+	var pr gh.PullRequest
+	if _, err := c.Get(ctx, c.repoPath("/pulls/%d", number), nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get pull request #%d: %w", number, err)
+	}
+	return &pr, nil
+}
+
+// searchParams はqueryのうちIssueの一覧APIで絞り込める条件をパラメータにする
+func searchParams(query github.SearchQuery) url.Values {
+	params := url.Values{"type": {"pulls"}, "state": {stateAll}}
+	if query.Keyword != "" {
+		params.Set("q", query.Keyword)
+	}
+	switch {
+	case query.State == github.StateOpen:
+		params.Set("state", stateOpen)
+	case query.State == github.StateClosed || query.Merged == github.MergeMerged:
+		// マージ済みのPRは閉じている
+		params.Set("state", stateClosed)
+	}
+	if len(query.Labels) > 0 {
+		params.Set("labels", strings.Join(query.Labels, ","))
+	}
+	if len(query.Authors) == 1 {
+		params.Set("created_by", query.Authors[0])
+	}
+	if !query.Updated.From.IsZero() {
+		params.Set("since", query.Updated.From.UTC().Format(time.RFC3339))
+	}
+	if !query.Updated.To.IsZero() {
+		params.Set("before", query.Updated.To.UTC().Format(time.RFC3339))
+	}
+	return params
+}
+
+// matches はissueがqueryの条件（baseブランチ以外）をすべて満たすかどうかを返す
+func matches(issue *issue, query github.SearchQuery) bool {
+	merged := issue.PullRequest.Merged
+	switch {
+	case query.State == github.StateOpen && issue.State != stateOpen,
+		query.State == github.StateClosed && issue.State != stateClosed,
+		query.Merged == github.MergeMerged && !merged,
+		query.Merged == github.MergeUnmerged && merged:
+		return false
+	}
+
+	labels := make([]string, 0, len(issue.Labels))
+	for _, label := range issue.Labels {
+		labels = append(labels, label.GetName())
+	}
+	for _, label := range query.Labels {
+		if !forge.ContainsFold(labels, label) {
+			return false
+		}
+	}
+	for _, label := range query.ExcludeLabels {
+		if forge.ContainsFold(labels, label) {
+			return false
+		}
+	}
+	author := issue.User.GetLogin()
+	if len(query.Authors) > 0 && !forge.ContainsFold(query.Authors, author) {
+		return false
+	}
+	if forge.ContainsFold(query.ExcludeAuthors, author) {
+		return false
+	}
+
+	var mergedAt time.Time
+	if issue.PullRequest.MergedAt != nil {
+		mergedAt = *issue.PullRequest.MergedAt
+	}
+	if !query.Created.Contains(issue.CreatedAt) || !query.MergedAt.Contains(mergedAt) || !query.Updated.Contains(issue.UpdatedAt) {
+		return false
+	}
+
+	return matchesScopes(issue, query)
+}
+
+// matchesScopes はコメントを含まない範囲が指定されている場合に、タイトル・本文にキーワードが含まれるかどうかを返す
+func matchesScopes(issue *issue, query github.SearchQuery) bool {
+	if query.Keyword == "" || len(query.Scopes) == 0 || slices.Contains(query.Scopes, github.ScopeComments) {
+		return true
+	}
+	keyword := strings.ToLower(query.Keyword)
+	if slices.Contains(query.Scopes, github.ScopeTitle) && strings.Contains(strings.ToLower(issue.Title), keyword) {
+		return true
+	}
+	return slices.Contains(query.Scopes, github.ScopeBody) && strings.Contains(strings.ToLower(issue.Body), keyword)
+}
@@ -0,0 +1,58 @@
+package gitea
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+/**
+ * Gitea・Forgejoのリポジトリの参照を解釈する
+ *   - https://codeberg.org/owner/repo（/pulls/12のような後続のパスは無視する）
+ *   - git@codeberg.org:owner/repo.git、ssh://git@codeberg.org:2222/owner/repo.git
+ * Giteaには決まったホストがないため、ホストを含まない参照はエラーにする
+ */
+func ParseRepositoryRef(ref string) (github.RepositoryRef, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return github.RepositoryRef{}, fmt.Errorf("repository reference is empty")
+	}
+
+	var host, path string
+	switch {
+	case strings.HasPrefix(ref, "git@") && !strings.Contains(ref, "://"):
+		var ok bool
+		host, path, ok = strings.Cut(strings.TrimPrefix(ref, "git@"), ":")
+		if !ok {
+			return github.RepositoryRef{}, fmt.Errorf("invalid repository reference: %s", ref)
+		}
+	case strings.Contains(ref, "://"):
+		u, err := url.Parse(ref)
+		if err != nil {
+			return github.RepositoryRef{}, fmt.Errorf("invalid repository url: %w", err)
+		}
+		host, path = u.Hostname(), u.Path
+		if u.Scheme != "ssh" && u.Port() != "" {
+			// Webの画面が標準以外のポートで公開されている場合はAPIも同じポートにある
+			host = u.Host
+		}
+	default:
+		return github.RepositoryRef{}, fmt.Errorf("gitea repository %q must be a URL that includes the host", ref)
+	}
+	if host == "" {
+		return github.RepositoryRef{}, fmt.Errorf("gitea repository %q must include the host", ref)
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 {
+		return github.RepositoryRef{}, fmt.Errorf("repository path must contain owner and name: %s", ref)
+	}
+	owner := strings.TrimSpace(parts[0])
+	name := strings.TrimSuffix(strings.TrimSpace(parts[1]), ".git")
+	if err := github.ValidateRepository(owner, name); err != nil {
+		return github.RepositoryRef{}, err
+	}
+	return github.RepositoryRef{Host: strings.ToLower(host), Owner: owner, Name: name}, nil
+}
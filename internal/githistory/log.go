@@ -0,0 +1,125 @@
+// Package githistory はローカルのgitリポジトリのコミット履歴から、ワードリストのキーワードや
+// CVE・GHSAのIDに言及するコミットを探し、マージコミットのメッセージや件名の"(#123)"からPR番号に結び付ける。
+package githistory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// git logの1コミット分の区切り（レコード）と、コミット内の項目の区切り
+const (
+	recordSeparator = "\x1e"
+	fieldSeparator  = "\x00"
+)
+
+// logFormat はハッシュ・親のハッシュ・コミット日時・メッセージを区切り文字で並べるgit logの書式
+const logFormat = "--format=%H%x00%P%x00%cI%x00%B%x1e"
+
+// logFieldCount はlogFormatの1コミットあたりの項目数
+const logFieldCount = 4
+
+// Commit はgit logの1コミット
+type Commit struct {
+	Hash    string
+	Parents []string
+	Date    time.Time
+	Message string
+}
+
+// Subject はコミットメッセージの1行目を返す
+func (c *Commit) Subject() string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return strings.TrimSpace(subject)
+}
+
+// IsMerge は親が2つ以上あるマージコミットかどうかを返す
+func (c *Commit) IsMerge() bool {
+	return len(c.Parents) > 1
+}
+
+// DefaultRange はrevisionRangeを指定しないときに読むコミットの範囲
+const DefaultRange = "HEAD"
+
+/**
+ * dirのgitリポジトリでgit logを実行し、revisionRange（空ならHEAD）のコミットを新しい順に返す
+ * マージされたブランチのコミットをマージコミットに結び付けられるよう、トポロジカル順で取得する
+ */
+func ReadLog(ctx context.Context, dir, revisionRange string) ([]Commit, error) {
+	if revisionRange == "" {
+		revisionRange = DefaultRange
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "log", "--topo-order", logFormat, revisionRange, "--")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run git log: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run git log: %w", err)
+	}
+
+	commits, parseErr := ParseLog(stdout)
+	if parseErr != nil {
+		// 残りの出力を読み捨ててからプロセスの終了を待つ
+		io.Copy(io.Discard, stdout)
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("git log failed in %s: %w: %s", dir, err, strings.TrimSpace(stderr.String()))
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return commits, nil
+}
+
+// ParseLog はlogFormatの書式のgit logの出力を読み込む
+func ParseLog(r io.Reader) ([]Commit, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20)
+	scanner.Split(splitRecords)
+
+	var commits []Commit
+	for scanner.Scan() {
+		record := strings.TrimLeft(scanner.Text(), "\n")
+		if record == "" {
+			continue
+		}
+		fields := strings.SplitN(record, fieldSeparator, logFieldCount)
+		if len(fields) != logFieldCount {
+			return nil, fmt.Errorf("invalid git log record %d: expected %d fields, got %d", len(commits)+1, logFieldCount, len(fields))
+		}
+		date, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid commit date for %s: %w", fields[0], err)
+		}
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Parents: strings.Fields(fields[1]),
+			Date:    date,
+			Message: strings.TrimSpace(fields[3]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read git log: %w", err)
+	}
+	return commits, nil
+}
+
+// splitRecords はgit logの出力をrecordSeparatorごとに区切るbufio.SplitFunc
+func splitRecords(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, recordSeparator[0]); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
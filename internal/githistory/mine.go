@@ -0,0 +1,165 @@
+package githistory
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// securityIDPattern はコミットメッセージ中のCVE・GHSAのID
+var securityIDPattern = regexp.MustCompile(`(?i)\b(CVE-\d{4}-\d{4,}|GHSA(?:-[23456789cfghjmpqrvwx]{4}){3})\b`)
+
+// コミットメッセージからPR番号を読み取るパターン
+var (
+	// mergePullRequest はGitHubのマージコミットの件名（Merge pull request #123 from owner/branch）
+	mergePullRequest = regexp.MustCompile(`^Merge pull request #(\d+)\b`)
+	// pullRequestSuffix はSquash mergeなどで件名の末尾に付くPR番号（Fix overflow (#123)）
+	pullRequestSuffix = regexp.MustCompile(`\(#(\d+)\)$`)
+	// mergeRequestTrailer はGitLabのマージコミットの本文（See merge request group/project!123）
+	mergeRequestTrailer = regexp.MustCompile(`(?m)^See merge request \S*!(\d+)\s*$`)
+	// reviewedOnPull はGiteaのマージコミットの本文（Reviewed-on: https://codeberg.org/owner/repo/pulls/123）
+	reviewedOnPull = regexp.MustCompile(`(?m)^Reviewed-on: \S+/pulls/(\d+)\s*$`)
+)
+
+// Finding はキーワードまたはCVE・GHSAのIDに一致したコミットと、それを取り込んだPR
+type Finding struct {
+	// Keyword は一致したワードリストのキーワード、またはメッセージに含まれていたCVE・GHSAのID（大文字）
+	Keyword string
+	Commit  string
+	// PullRequest はコミットを取り込んだPRの番号。PRに結び付けられなければ0
+	PullRequest int
+}
+
+// PullRequestNumber はコミットメッセージに書かれたPR番号（GitHub・GitLab・Giteaのマージコミット、件名末尾の"(#123)"）を返す
+func PullRequestNumber(message string) (int, bool) {
+	subject, _, _ := strings.Cut(message, "\n")
+	subject = strings.TrimSpace(subject)
+	for _, pattern := range []*regexp.Regexp{mergePullRequest, pullRequestSuffix} {
+		if match := pattern.FindStringSubmatch(subject); match != nil {
+			return atoi(match[1])
+		}
+	}
+	for _, pattern := range []*regexp.Regexp{mergeRequestTrailer, reviewedOnPull} {
+		if match := pattern.FindStringSubmatch(message); match != nil {
+			return atoi(match[1])
+		}
+	}
+	return 0, false
+}
+
+func atoi(value string) (int, bool) {
+	number, err := strconv.Atoi(value)
+	return number, err == nil && number > 0
+}
+
+/**
+ * commits（git logの新しい順）から、wordsのいずれかを含むか、CVE・GHSAのIDに言及するコミットを探す
+ * キーワードは大文字小文字を区別せずにメッセージの部分一致で探し、1つのコミットが複数のキーワードに一致すれば
+ * それぞれをFindingにする
+ * PR番号はコミット自身のメッセージ（Squash mergeの"(#123)"など）から読み取り、なければそのコミットを
+ * ブランチごと取り込んだマージコミットのPR番号を使う（pullRequestsByCommitを参照）
+ */
+func Mine(commits []Commit, words []string) []Finding {
+	merged := pullRequestsByCommit(commits)
+
+	var findings []Finding
+	for _, commit := range commits {
+		keywords := matchKeywords(commit.Message, words)
+		if len(keywords) == 0 {
+			continue
+		}
+		pr, ok := PullRequestNumber(commit.Message)
+		if !ok {
+			pr = merged[commit.Hash]
+		}
+		for _, keyword := range keywords {
+			findings = append(findings, Finding{Keyword: keyword, Commit: commit.Hash, PullRequest: pr})
+		}
+	}
+	return findings
+}
+
+// matchKeywords はmessageに含まれるwordsのキーワードと、CVE・GHSAのID（大文字、重複なし）を返す
+func matchKeywords(message string, words []string) []string {
+	lower := strings.ToLower(message)
+	var keywords []string
+	for _, word := range words {
+		if word != "" && strings.Contains(lower, strings.ToLower(word)) {
+			keywords = append(keywords, word)
+		}
+	}
+	for _, id := range securityIDPattern.FindAllString(message, -1) {
+		if id = strings.ToUpper(id); !slices.Contains(keywords, id) {
+			keywords = append(keywords, id)
+		}
+	}
+	return keywords
+}
+
+/**
+ * マージコミットのPR番号を、そのマージで取り込まれたブランチ上のコミットに割り当てる
+ * 先頭のコミットから第1親をたどった履歴（メインライン）以外で、マージコミットの第2親以降から
+ * たどれるコミットをそのPRのコミットとする。古いマージから順に割り当て、一度割り当てたコミットと
+ * メインラインのコミットではたどるのをやめるため、後のマージが以前に取り込まれたコミットを奪うことはない
+ */
+func pullRequestsByCommit(commits []Commit) map[string]int {
+	byHash := make(map[string]*Commit, len(commits))
+	for i := range commits {
+		byHash[commits[i].Hash] = &commits[i]
+	}
+
+	mainline := make(map[string]bool)
+	if len(commits) > 0 {
+		for commit := &commits[0]; commit != nil && !mainline[commit.Hash]; {
+			mainline[commit.Hash] = true
+			if len(commit.Parents) == 0 {
+				break
+			}
+			commit = byHash[commit.Parents[0]]
+		}
+	}
+
+	assigned := make(map[string]int)
+	for i := len(commits) - 1; i >= 0; i-- {
+		merge := &commits[i]
+		if !merge.IsMerge() {
+			continue
+		}
+		pr, ok := PullRequestNumber(merge.Message)
+		if !ok {
+			continue
+		}
+		queue := slices.Clone(merge.Parents[1:])
+		for len(queue) > 0 {
+			hash := queue[0]
+			queue = queue[1:]
+			commit, ok := byHash[hash]
+			if !ok || mainline[hash] {
+				continue
+			}
+			if _, done := assigned[hash]; done {
+				continue
+			}
+			assigned[hash] = pr
+			queue = append(queue, commit.Parents...)
+		}
+	}
+	return assigned
+}
+
+// GroupByKeyword はPRに結び付けられたFindingを、キーワードごとのPR番号（昇順、重複なし）にまとめる
+func GroupByKeyword(findings []Finding) map[string][]int {
+	groups := make(map[string][]int)
+	for _, finding := range findings {
+		if finding.PullRequest == 0 {
+			continue
+		}
+		groups[finding.Keyword] = append(groups[finding.Keyword], finding.PullRequest)
+	}
+	for keyword, prs := range groups {
+		slices.Sort(prs)
+		groups[keyword] = slices.Compact(prs)
+	}
+	return groups
+}
@@ -0,0 +1,128 @@
+package githistory
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullRequestNumber(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    int
+	}{
+		{name: "github merge", message: "Merge pull request #42 from alice/fix-xss\n\nEscape output", want: 42},
+		{name: "squash suffix", message: "Escape output (#17)\n\nFixes an xss.", want: 17},
+		{name: "gitlab merge request", message: "Merge branch 'fix' into 'main'\n\nFix overflow\n\nSee merge request group/sub/project!8", want: 8},
+		{name: "gitea reviewed-on", message: "Fix overflow\n\nReviewed-on: https://codeberg.org/owner/repo/pulls/5\n", want: 5},
+		{name: "issue reference in the body is not a PR", message: "Fix overflow\n\nFixes #99", want: 0},
+		{name: "suffix must end the subject", message: "Revert (#12) partially", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PullRequestNumber(tt.message)
+			assert.Equal(t, tt.want != 0, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseLog(t *testing.T) {
+	output := "aaa\x00bbb ccc\x002024-03-04T10:00:00+09:00\x00Merge pull request #3 from x/y\n\nBody\n\x1e\n" +
+		"bbb\x00\x002024-03-01T00:00:00Z\x00Initial commit\n\x1e\n"
+
+	commits, err := ParseLog(strings.NewReader(output))
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, "aaa", commits[0].Hash)
+	assert.Equal(t, []string{"bbb", "ccc"}, commits[0].Parents)
+	assert.True(t, commits[0].IsMerge())
+	assert.Equal(t, "Merge pull request #3 from x/y\n\nBody", commits[0].Message)
+	assert.Equal(t, time.Date(2024, 3, 4, 1, 0, 0, 0, time.UTC), commits[0].Date.UTC())
+	assert.Empty(t, commits[1].Parents)
+	assert.Equal(t, "Initial commit", commits[1].Subject())
+
+	_, err = ParseLog(strings.NewReader("broken\x1e"))
+	assert.Error(t, err)
+}
+
+func TestMine(t *testing.T) {
+	// m2 ─ m1 ─ c0 がメインライン。b1・b2はm1（#10）で、s1はm2（#11）で取り込まれたブランチ。
+	// d1はメインラインに直接コミットされたCVEの修正
+	commits := []Commit{
+		{Hash: "m2", Parents: []string{"d1", "s1"}, Message: "Merge pull request #11 from bob/cleanup"},
+		{Hash: "s1", Parents: []string{"b2"}, Message: "Tidy up the XSS helpers"},
+		{Hash: "d1", Parents: []string{"m1"}, Message: "Fix CVE-2024-1234 and cve-2024-1234 in the parser"},
+		{Hash: "m1", Parents: []string{"c0", "b2"}, Message: "Merge pull request #10 from alice/fix"},
+		{Hash: "b2", Parents: []string{"b1"}, Message: "Escape output to prevent xss"},
+		{Hash: "b1", Parents: []string{"c0"}, Message: "Add a test\n\nSee GHSA-xxxx-2345-6789 for details"},
+		{Hash: "c0", Message: "Initial commit (#1)"},
+		{Hash: "sq", Parents: []string{"c0"}, Message: "Prevent buffer overflow (#12)"},
+	}
+
+	findings := Mine(commits, []string{"xss", "buffer overflow"})
+
+	assert.ElementsMatch(t, []Finding{
+		{Keyword: "xss", Commit: "s1", PullRequest: 11},
+		{Keyword: "CVE-2024-1234", Commit: "d1", PullRequest: 0},
+		{Keyword: "xss", Commit: "b2", PullRequest: 10},
+		{Keyword: "GHSA-XXXX-2345-6789", Commit: "b1", PullRequest: 10},
+		{Keyword: "buffer overflow", Commit: "sq", PullRequest: 12},
+	}, findings)
+
+	assert.Equal(t, map[string][]int{
+		"xss":                 {10, 11},
+		"GHSA-XXXX-2345-6789": {10},
+		"buffer overflow":     {12},
+	}, GroupByKeyword(findings))
+}
+
+func TestReadLog(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.org",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.org",
+			"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+	commit := func(file, message string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(message), 0644))
+		git("add", file)
+		git("commit", "-q", "-m", message)
+	}
+
+	git("init", "-q", "-b", "main")
+	commit("README", "Initial commit")
+	git("checkout", "-q", "-b", "fix")
+	commit("escape.go", "Escape output to prevent xss")
+	git("checkout", "-q", "main")
+	commit("CHANGELOG", "Update the changelog")
+	git("merge", "-q", "--no-ff", "-m", "Merge pull request #7 from alice/fix", "fix")
+
+	commits, err := ReadLog(context.Background(), dir, "")
+	require.NoError(t, err)
+	require.Len(t, commits, 4)
+	assert.True(t, commits[0].IsMerge())
+
+	assert.Equal(t, map[string][]int{"xss": {7}}, GroupByKeyword(Mine(commits, []string{"xss"})))
+
+	_, err = ReadLog(context.Background(), t.TempDir(), "")
+	assert.ErrorContains(t, err, "git log failed")
+}
@@ -0,0 +1,238 @@
+package github
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAPIBaseURL はgithub.comのREST APIのベースURL
+const defaultAPIBaseURL = "https://api.github.com/"
+
+const (
+	// GitHubはJWTの有効期限を最大10分までしか受け付けない
+	appJWTLifetime = 10 * time.Minute
+	// GitHubとの時計のずれを見込んで、発行時刻を少し過去にする
+	appJWTClockSkew = time.Minute
+	// インストールトークンの有効期限（1時間）が切れる前に、この時間の余裕をもって更新する
+	installationTokenRefreshMargin = 5 * time.Minute
+)
+
+const (
+	pemTypeRSAPrivateKey = "RSA PRIVATE KEY"
+	pemTypePrivateKey    = "PRIVATE KEY"
+	headerAccept         = "Accept"
+	mediaTypeGitHubJSON  = "application/vnd.github+json"
+)
+
+// AppAuth はGitHub Appとして認証するための設定
+type AppAuth struct {
+	AppID          int64
+	InstallationID int64
+	// PrivateKey はGitHub Appの秘密鍵（PEM形式）
+	PrivateKey []byte
+	// BaseURL はインストールトークンを発行するAPIのベースURL。空ならgithub.comを使う。
+	BaseURL string
+}
+
+// WithAppAuth はGitHub Appのインストールトークンで認証する。指定した場合、NewClientのtokenは使わない。
+func WithAppAuth(auth AppAuth) ClientOption {
+	return func(o *clientOptions) {
+		o.appAuth = &auth
+	}
+}
+
+// appTokenSource はAppの秘密鍵で署名したJWTをインストールトークンに交換し、期限が切れる前に更新する
+type appTokenSource struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	tokenURL       string
+	httpClient     *http.Client
+	now            func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func newAppTokenSource(auth AppAuth, base http.RoundTripper) (*appTokenSource, error) {
+	if auth.AppID == 0 || auth.InstallationID == 0 {
+		return nil, errors.New("GitHub App auth requires both an app ID and an installation ID")
+	}
+	key, err := parseAppPrivateKey(auth.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := auth.BaseURL
+	if baseURL == "" {
+		baseURL = defaultAPIBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	return &appTokenSource{
+		appID:          auth.AppID,
+		installationID: auth.InstallationID,
+		key:            key,
+		tokenURL:       fmt.Sprintf("%sapp/installations/%d/access_tokens", baseURL, auth.InstallationID),
+		httpClient:     &http.Client{Transport: base},
+		now:            time.Now,
+	}, nil
+}
+
+// parseAppPrivateKey はPKCS#1（GitHubが発行する形式）またはPKCS#8のRSA秘密鍵を読み込む
+func parseAppPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("GitHub App private key is not PEM encoded")
+	}
+
+	switch block.Type {
+	case pemTypeRSAPrivateKey:
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+		}
+		return key, nil
+	case pemTypePrivateKey:
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+		}
+		key, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("GitHub App private key must be an RSA key")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported GitHub App private key type %q", block.Type)
+	}
+}
+
+/**
+ * 有効なインストールトークンを返す
+ * まだ取得していないか、期限切れが近い場合はJWTで新しいトークンを発行してもらう
+ */
+func (s *appTokenSource) Token(req *http.Request) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && s.now().Before(s.expiresAt.Add(-installationTokenRefreshMargin)) {
+		return s.token, nil
+	}
+
+	token, expiresAt, err := s.exchange(req)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	s.expiresAt = expiresAt
+	return s.token, nil
+}
+
+// exchange はJWTをインストールトークンに交換する
+func (s *appTokenSource) exchange(req *http.Request) (string, time.Time, error) {
+	jwt, err := s.signJWT()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	tokenReq, err := http.NewRequestWithContext(req.Context(), http.MethodPost, s.tokenURL, nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	tokenReq.Header.Set(headerAuthorization, "Bearer "+jwt)
+	tokenReq.Header.Set(headerAccept, mediaTypeGitHubJSON)
+
+	resp, err := s.httpClient.Do(tokenReq)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to request installation token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read installation token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("failed to create installation token: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse installation token response: %w", err)
+	}
+	if result.Token == "" {
+		return "", time.Time{}, errors.New("installation token response has no token")
+	}
+	return result.Token, result.ExpiresAt, nil
+}
+
+// signJWT はAppとして認証するためのRS256署名付きJWTを作る
+func (s *appTokenSource) signJWT() (string, error) {
+	now := s.now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-appJWTClockSkew).Unix(),
+		"exp": now.Add(appJWTLifetime - appJWTClockSkew).Unix(),
+		"iss": strconv.FormatInt(s.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Transport はbaseの前段でインストールトークンを付与するRoundTripperを返す。
+func (s *appTokenSource) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &appTransport{source: s, base: base}
+}
+
+type appTransport struct {
+	source *appTokenSource
+	base   http.RoundTripper
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(req)
+	if err != nil {
+		return nil, err
+	}
+
+	authorized := req.Clone(req.Context())
+	authorized.Header.Set(headerAuthorization, "token "+token)
+	return t.base.RoundTrip(authorized)
+}
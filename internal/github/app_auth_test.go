@@ -0,0 +1,221 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAppID          = 1234
+	testInstallationID = 42
+)
+
+// fakeTokenEndpoint はJWTを検証してインストールトークンを発行するテスト用のトークンエンドポイント
+type fakeTokenEndpoint struct {
+	t         *testing.T
+	publicKey *rsa.PublicKey
+	lifetime  time.Duration
+
+	mu     sync.Mutex
+	issued int
+}
+
+func (e *fakeTokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	require.Equal(e.t, http.MethodPost, r.Method)
+	claims := verifyTestJWT(e.t, e.publicKey, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	assert.Equal(e.t, fmt.Sprint(testAppID), claims["iss"])
+
+	e.mu.Lock()
+	e.issued++
+	token := fmt.Sprintf("ghs_%d", e.issued)
+	e.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"token":%q,"expires_at":%q}`, token, time.Now().Add(e.lifetime).UTC().Format(time.RFC3339))
+}
+
+func (e *fakeTokenEndpoint) issuedCount() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.issued
+}
+
+// ヘルパー関数: テスト用のRSA鍵をPKCS#1のPEM形式で作る
+func newTestAppKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key, pem.EncodeToMemory(&pem.Block{Type: pemTypeRSAPrivateKey, Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+// ヘルパー関数: JWTの署名を検証してクレームを返す
+func verifyTestJWT(t *testing.T, publicKey *rsa.PublicKey, jwt string) map[string]any {
+	t.Helper()
+
+	parts := strings.Split(jwt, ".")
+	require.Len(t, parts, 3)
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"alg":"RS256","typ":"JWT"}`, string(header))
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.NoError(t, rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature))
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims map[string]any
+	require.NoError(t, json.Unmarshal(payload, &claims))
+
+	iat, exp := int64(claims["iat"].(float64)), int64(claims["exp"].(float64))
+	assert.Positive(t, iat)
+	assert.LessOrEqual(t, exp-iat, int64(appJWTLifetime/time.Second))
+	return claims
+}
+
+// ヘルパー関数: トークンエンドポイントとAPIを同じhttptestサーバーで提供し、App認証のClientを作る
+func newTestAppClient(t *testing.T, lifetime time.Duration, api http.HandlerFunc) (*Client, *fakeTokenEndpoint) {
+	t.Helper()
+
+	key, keyPEM := newTestAppKey(t)
+	endpoint := &fakeTokenEndpoint{t: t, publicKey: &key.PublicKey, lifetime: lifetime}
+
+	mux := http.NewServeMux()
+	mux.Handle(fmt.Sprintf("/app/installations/%d/access_tokens", testInstallationID), endpoint)
+	mux.HandleFunc("/repos/owner/repo/", api)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient("", "owner/repo", server.Client(), WithAppAuth(AppAuth{
+		AppID:          testAppID,
+		InstallationID: testInstallationID,
+		PrivateKey:     keyPEM,
+		BaseURL:        server.URL,
+	}))
+	require.NoError(t, err)
+
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	client.github.BaseURL = baseURL
+
+	return client, endpoint
+}
+
+func TestAppAuth_UsesInstallationToken(t *testing.T) {
+	var authorizations []string
+	client, endpoint := newTestAppClient(t, time.Hour, func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		writePagedJSON(t, w, r, []string{`[{"id":1}]`})
+	})
+
+	for range 2 {
+		_, err := client.GetComments(context.Background(), 7)
+		require.NoError(t, err)
+	}
+
+	// 期限内のトークンは使い回す
+	assert.Equal(t, 1, endpoint.issuedCount())
+	assert.Equal(t, []string{"token ghs_1", "token ghs_1"}, authorizations)
+}
+
+func TestAppAuth_RefreshesTokenBeforeExpiry(t *testing.T) {
+	var authorizations []string
+	client, endpoint := newTestAppClient(t, time.Hour, func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		writePagedJSON(t, w, r, []string{`[{"id":1}]`})
+	})
+
+	source := client.github.Client().Transport.(*appTransport).source
+	now := time.Now()
+	source.now = func() time.Time { return now }
+
+	_, err := client.GetComments(context.Background(), 7)
+	require.NoError(t, err)
+
+	// 期限切れの少し前になったら新しいトークンに切り替える
+	now = now.Add(time.Hour - installationTokenRefreshMargin + time.Second)
+	_, err = client.GetComments(context.Background(), 7)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, endpoint.issuedCount())
+	assert.Equal(t, []string{"token ghs_1", "token ghs_2"}, authorizations)
+}
+
+func TestAppAuth_TokenEndpointError(t *testing.T) {
+	_, keyPEM := newTestAppKey(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"A JSON web token could not be decoded"}`, http.StatusUnauthorized)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient("", "owner/repo", server.Client(), WithAppAuth(AppAuth{
+		AppID:          testAppID,
+		InstallationID: testInstallationID,
+		PrivateKey:     keyPEM,
+		BaseURL:        server.URL,
+	}))
+	require.NoError(t, err)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	client.github.BaseURL = baseURL
+
+	_, err = client.GetComments(context.Background(), 7)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
+
+func TestParseAppPrivateKey(t *testing.T) {
+	key, pkcs1 := newTestAppKey(t)
+	pkcs8Bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: pemTypePrivateKey, Bytes: pkcs8Bytes})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "PKCS#1", data: pkcs1},
+		{name: "PKCS#8", data: pkcs8},
+		{name: "not PEM", data: []byte("not a key"), wantErr: true},
+		{name: "unsupported type", data: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := parseAppPrivateKey(tt.data)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, key.Equal(parsed))
+		})
+	}
+}
+
+func TestNewClient_AppAuthRequiresIDs(t *testing.T) {
+	_, keyPEM := newTestAppKey(t)
+	_, err := NewClient("", "owner/repo", nil, WithAppAuth(AppAuth{PrivateKey: keyPEM}))
+	require.Error(t, err)
+}
@@ -1,23 +1,126 @@
 package github
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/google/go-github/v77/github"
 )
 
 type Client struct {
-	Owner  string
-	Name   string
-	github *github.Client
+	Owner string
+	Name  string
+	// Repository は対象リポジトリのホストを含む識別情報
+	Repository RepositoryRef
+	github     *github.Client
+	limiter    *RateLimiter
+	// graphQLPath はREST APIのベースURLから見たGraphQLエンドポイントのパス
+	graphQLPath string
+	// now は検索期間の終端などに使う現在時刻
+	now func() time.Time
 }
 
-func NewClient(token string, repo string, httpClient *http.Client) (*Client, error) {
-	owner, name, err := ParseRepository(repo)
+// GHESのAPIのパス
+const (
+	enterpriseAPIPath = "api/v3/"
+	// enterpriseGraphQLPath は/api/v3/から見た/api/graphqlの相対パス
+	enterpriseGraphQLPath = "../graphql"
+)
+
+// ClientOption はNewClientの動作を変更する
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	token     string
+	tokenPool *TokenPool
+	appAuth   *AppAuth
+	now       func() time.Time
+	baseURL   string
+}
+
+// WithTokenPool はリクエストごとにpoolのトークンを使い分ける。指定した場合、NewClientのtokenは使わない。
+func WithTokenPool(pool *TokenPool) ClientOption {
+	return func(o *clientOptions) {
+		o.tokenPool = pool
+	}
+}
+
+// WithClock は検索期間の終端などに使う現在時刻をnowから取る。
+// 記録したリクエストを再生するときに、記録時と同じ検索クエリを組み立てるために使う。
+func WithClock(now func() time.Time) ClientOption {
+	return func(o *clientOptions) {
+		o.now = now
+	}
+}
+
+// WithBaseURL はREST APIのベースURLをbaseURLに置き換える。テスト用のフェイクサーバーに接続するために使う。
+// GraphQLのエンドポイントはbaseURLのgraphqlになる。
+func WithBaseURL(baseURL string) ClientOption {
+	return func(o *clientOptions) {
+		o.baseURL = baseURL
+	}
+}
+
+func NewClient(token string, repo string, httpClient *http.Client, opts ...ClientOption) (*Client, error) {
+	ref, err := ParseRepositoryRef(repo)
 	if err != nil {
 		return nil, err
 	}
 
+	client, err := NewHostClient(token, ref.Host, httpClient, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return client.ForRepository(ref)
+}
+
+// NewHostClient はリポジトリを指定せずにhostのAPIを使うClientを作成する。
+// Organizationのリポジトリ一覧のようなアカウント単位のAPIに使い、
+// リポジトリ単位のAPIはForRepositoryで作ったClientから呼び出す。
+func NewHostClient(token string, host string, httpClient *http.Client, opts ...ClientOption) (*Client, error) {
+	ref := RepositoryRef{Host: normalizeHost(host)}
+	if ref.Host == "" {
+		ref.Host = DefaultHost
+	}
+
+	options := clientOptions{token: token, now: time.Now}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	token = options.token
+
+	if options.tokenPool != nil && options.appAuth != nil {
+		return nil, errors.New("token pool and GitHub App auth cannot be used together")
+	}
+	if options.tokenPool != nil {
+		httpClient = withTransport(httpClient, options.tokenPool.Transport)
+		token = ""
+	}
+	if options.appAuth != nil {
+		var base http.RoundTripper
+		if httpClient != nil {
+			base = httpClient.Transport
+		}
+		auth := *options.appAuth
+		switch {
+		case auth.BaseURL != "":
+		case options.baseURL != "":
+			auth.BaseURL = options.baseURL
+		case ref.IsEnterprise():
+			auth.BaseURL = ref.enterpriseBaseURL() + enterpriseAPIPath
+		}
+		source, err := newAppTokenSource(auth, base)
+		if err != nil {
+			return nil, err
+		}
+		httpClient = withTransport(httpClient, source.Transport)
+		token = ""
+	}
+
 	var ghClient *github.Client
 	if httpClient != nil {
 		ghClient = github.NewClient(httpClient)
@@ -25,9 +128,73 @@ func NewClient(token string, repo string, httpClient *http.Client) (*Client, err
 		ghClient = github.NewClient(nil)
 	}
 
+	gqlPath := graphQLPath
+	if ref.IsEnterprise() {
+		// GHESのREST APIは/api/v3/、GraphQLは/api/graphqlで提供される
+		var err error
+		ghClient, err = ghClient.WithEnterpriseURLs(ref.enterpriseBaseURL(), ref.enterpriseBaseURL())
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure GitHub Enterprise URLs for %s: %w", ref.Host, err)
+		}
+		gqlPath = enterpriseGraphQLPath
+	}
+
+	if options.baseURL != "" {
+		baseURL, err := url.Parse(strings.TrimSuffix(options.baseURL, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("invalid base URL %q: %w", options.baseURL, err)
+		}
+		ghClient.BaseURL = baseURL
+		ghClient.UploadURL = baseURL
+		gqlPath = graphQLPath
+	}
+
 	if token != "" {
 		ghClient = ghClient.WithAuthToken(token)
 	}
 
-	return &Client{Owner: owner, Name: name, github: ghClient}, nil
+	return &Client{
+		Owner:       ref.Owner,
+		Name:        ref.Name,
+		Repository:  ref,
+		github:      ghClient,
+		limiter:     NewRateLimiter(),
+		graphQLPath: gqlPath,
+		now:         options.now,
+	}, nil
+}
+
+// ForRepository はrepoを対象とするClientを返す。
+// 返したClientは接続先・認証・レート制限をこのClientと共有する。
+func (c *Client) ForRepository(repo RepositoryRef) (*Client, error) {
+	if err := ValidateRepository(repo.Owner, repo.Name); err != nil {
+		return nil, err
+	}
+	if repo.Host == "" {
+		repo.Host = DefaultHost
+	}
+	if repo.Host != c.Repository.Host {
+		return nil, fmt.Errorf("repository %s is not on host %s", repo, c.Repository.Host)
+	}
+
+	forRepo := *c
+	forRepo.Owner = repo.Owner
+	forRepo.Name = repo.Name
+	forRepo.Repository = repo
+	return &forRepo, nil
+}
+
+// withTransport はhttpClientのTransportをwrapで包んだコピーを返す（httpClient自体は変更しない）
+func withTransport(httpClient *http.Client, wrap func(base http.RoundTripper) http.RoundTripper) *http.Client {
+	var wrapped http.Client
+	if httpClient != nil {
+		wrapped = *httpClient
+	}
+	wrapped.Transport = wrap(wrapped.Transport)
+	return &wrapped
+}
+
+// RateLimiter はこのClientが共有しているレート制限の追跡状態を返す。
+func (c *Client) RateLimiter() *RateLimiter {
+	return c.limiter
 }
@@ -1,6 +1,11 @@
 package github
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -22,3 +27,66 @@ func TestNewClient_InvalidRepository(t *testing.T) {
 	require.Error(t, err)
 	assert.Nil(t, client)
 }
+
+// newTestClient はhttptestサーバーに向けたClientを作成するテスト用ヘルパー
+func newTestClient(t *testing.T, handler http.Handler, opts ...ClientOption) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient("", "owner/repo", server.Client(), opts...)
+	require.NoError(t, err)
+
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	client.github.BaseURL = baseURL
+
+	return client
+}
+
+// writePagedJSON はpageクエリに応じたJSONを返し、次ページがあればLinkヘッダーを付与する
+func writePagedJSON(t *testing.T, w http.ResponseWriter, r *http.Request, pages []string) {
+	t.Helper()
+
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		var err error
+		page, err = strconv.Atoi(p)
+		require.NoError(t, err)
+	}
+	require.LessOrEqual(t, page, len(pages))
+
+	if page < len(pages) {
+		next := *r.URL
+		query := next.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next"`, r.Host, next.RequestURI()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, pages[page-1])
+}
+
+func TestNewClient_Enterprise(t *testing.T) {
+	client, err := NewClient("", "https://ghe.example.com/owner/repo", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, RepositoryRef{Host: "ghe.example.com", Owner: "owner", Name: "repo"}, client.Repository)
+	assert.Equal(t, "https://ghe.example.com/api/v3/", client.github.BaseURL.String())
+	assert.Equal(t, "https://ghe.example.com/api/uploads/", client.github.UploadURL.String())
+
+	// GraphQLはREST APIのベースURLではなく/api/graphqlに送る
+	req, err := client.github.NewRequest(http.MethodPost, client.graphQLPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://ghe.example.com/api/graphql", req.URL.String())
+}
+
+func TestNewClient_PublicGraphQLEndpoint(t *testing.T) {
+	client, err := NewClient("", "owner/repo", nil)
+	require.NoError(t, err)
+
+	req, err := client.github.NewRequest(http.MethodPost, client.graphQLPath, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.github.com/graphql", req.URL.String())
+}
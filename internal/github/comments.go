@@ -2,28 +2,88 @@ package github
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/google/go-github/v77/github"
 )
 
 /**
  * issues/<prNumber>/commentsエンドポイントを使ってコメントを取得する
+ * すべてのページを辿り、PR内のコメントを漏れなく返す
  */
-func (c *Client) GetComments(prNumber int) ([]*github.IssueComment, error) {
-	comments, _, err := c.github.Issues.ListComments(context.Background(), c.Owner, c.Name, prNumber, &github.IssueListCommentsOptions{})
+func (c *Client) GetComments(ctx context.Context, prNumber int) ([]*github.IssueComment, error) {
+	comments, err := collectAllPages(c.listCommentsPage(ctx, prNumber))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to list issue comments for PR #%d: %w", prNumber, err)
 	}
 	return comments, nil
 }
 
+/**
+ * issues/<prNumber>/commentsエンドポイントのコメントを1ページ取得するごとにhandleへ渡す
+ * 全件をメモリに保持せずに処理したい場合に使う
+ */
+func (c *Client) StreamComments(ctx context.Context, prNumber int, handle func(page []*github.IssueComment) error) error {
+	if err := forEachPage(c.listCommentsPage(ctx, prNumber), handle); err != nil {
+		return fmt.Errorf("failed to stream issue comments for PR #%d: %w", prNumber, err)
+	}
+	return nil
+}
+
 /**
  * pulls/<prNumber>/commentsエンドポイントを使ってレビューコメントを取得する
+ * すべてのページを辿り、PR内のレビューコメントを漏れなく返す
  */
-func (c *Client) GetReviewComments(prNumber int) ([]*github.PullRequestComment, error) {
-	comments, _, err := c.github.PullRequests.ListComments(context.Background(), c.Owner, c.Name, prNumber, &github.PullRequestListCommentsOptions{})
+func (c *Client) GetReviewComments(ctx context.Context, prNumber int) ([]*github.PullRequestComment, error) {
+	comments, err := collectAllPages(c.listReviewCommentsPage(ctx, prNumber))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to list review comments for PR #%d: %w", prNumber, err)
 	}
 	return comments, nil
 }
+
+/**
+ * pulls/<prNumber>/commentsエンドポイントのレビューコメントを1ページ取得するごとにhandleへ渡す
+ */
+func (c *Client) StreamReviewComments(ctx context.Context, prNumber int, handle func(page []*github.PullRequestComment) error) error {
+	if err := forEachPage(c.listReviewCommentsPage(ctx, prNumber), handle); err != nil {
+		return fmt.Errorf("failed to stream review comments for PR #%d: %w", prNumber, err)
+	}
+	return nil
+}
+
+func (c *Client) listCommentsPage(ctx context.Context, prNumber int) func(opts github.ListOptions) ([]*github.IssueComment, *github.Response, error) {
+	return func(opts github.ListOptions) ([]*github.IssueComment, *github.Response, error) {
+		return callWithRateLimit(ctx, c.limiter, RateResourceCore, func() ([]*github.IssueComment, *github.Response, error) {
+			return c.github.Issues.ListComments(ctx, c.Owner, c.Name, prNumber, &github.IssueListCommentsOptions{ListOptions: opts})
+		})
+	}
+}
+
+func (c *Client) listReviewCommentsPage(ctx context.Context, prNumber int) func(opts github.ListOptions) ([]*github.PullRequestComment, *github.Response, error) {
+	return func(opts github.ListOptions) ([]*github.PullRequestComment, *github.Response, error) {
+		return callWithRateLimit(ctx, c.limiter, RateResourceCore, func() ([]*github.PullRequestComment, *github.Response, error) {
+			return c.github.PullRequests.ListComments(ctx, c.Owner, c.Name, prNumber, &github.PullRequestListCommentsOptions{ListOptions: opts})
+		})
+	}
+}
+
+/**
+ * pulls/<prNumber>/reviewsエンドポイントを使ってレビュー（APPROVE / REQUEST_CHANGESなどのサマリー）を取得する
+ * すべてのページを辿り、PR内のレビューを漏れなく返す
+ */
+func (c *Client) GetReviews(ctx context.Context, prNumber int) ([]*github.PullRequestReview, error) {
+	reviews, err := collectAllPages(c.listReviewsPage(ctx, prNumber))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reviews for PR #%d: %w", prNumber, err)
+	}
+	return reviews, nil
+}
+
+func (c *Client) listReviewsPage(ctx context.Context, prNumber int) func(opts github.ListOptions) ([]*github.PullRequestReview, *github.Response, error) {
+	return func(opts github.ListOptions) ([]*github.PullRequestReview, *github.Response, error) {
+		return callWithRateLimit(ctx, c.limiter, RateResourceCore, func() ([]*github.PullRequestReview, *github.Response, error) {
+			return c.github.PullRequests.ListReviews(ctx, c.Owner, c.Name, prNumber, &opts)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetComments_FollowsAllPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+		writePagedJSON(t, w, r, []string{`[{"id":1},{"id":2}]`, `[{"id":3}]`})
+	})
+	client := newTestClient(t, mux)
+
+	comments, err := client.GetComments(context.Background(), 7)
+	require.NoError(t, err)
+
+	var ids []int64
+	for _, comment := range comments {
+		ids = append(ids, comment.GetID())
+	}
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+}
+
+func TestGetReviewComments_FollowsAllPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/pulls/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+		writePagedJSON(t, w, r, []string{`[{"id":10}]`, `[{"id":11}]`, `[{"id":12}]`})
+	})
+	client := newTestClient(t, mux)
+
+	comments, err := client.GetReviewComments(context.Background(), 7)
+	require.NoError(t, err)
+	assert.Len(t, comments, 3)
+}
+
+func TestStreamComments_DeliversEachPage(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		writePagedJSON(t, w, r, []string{`[{"id":1},{"id":2}]`, `[{"id":3}]`})
+	})
+	client := newTestClient(t, mux)
+
+	var pageSizes []int
+	err := client.StreamComments(context.Background(), 7, func(page []*gh.IssueComment) error {
+		pageSizes = append(pageSizes, len(page))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, pageSizes)
+}
+
+func TestStreamComments_StopsOnHandlerError(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writePagedJSON(t, w, r, []string{`[{"id":1}]`, `[{"id":2}]`})
+	})
+	client := newTestClient(t, mux)
+
+	stop := assert.AnError
+	err := client.StreamComments(context.Background(), 7, func(page []*gh.IssueComment) error {
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, requests)
+}
+
+func TestGetReviews_FollowsAllPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/pulls/7/reviews", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+		writePagedJSON(t, w, r, []string{`[{"id":20,"state":"CHANGES_REQUESTED"}]`, `[{"id":21,"state":"APPROVED"}]`})
+	})
+	client := newTestClient(t, mux)
+
+	reviews, err := client.GetReviews(context.Background(), 7)
+	require.NoError(t, err)
+
+	require.Len(t, reviews, 2)
+	assert.Equal(t, "CHANGES_REQUESTED", reviews[0].GetState())
+	assert.Equal(t, int64(21), reviews[1].GetID())
+}
@@ -0,0 +1,105 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v77/github"
+)
+
+// Conversation はPR1件分の本体情報と、そこで交わされた会話をまとめたもの
+type Conversation struct {
+	PullRequest    *github.PullRequest
+	IssueComments  []*github.IssueComment
+	ReviewComments []*github.PullRequestComment
+	Reviews        []*github.PullRequestReview
+	// ResolvedThreads はレビュースレッドのルートコメントIDごとの解決状態（取得できた場合のみ設定される）
+	ResolvedThreads map[int64]bool
+}
+
+// 会話のReviewのstateと、Review Commentのside・subject_typeの値。
+// GitHub以外のソース（GitLab・Gitea・メーリングリスト）も、この値に変換して会話を返す
+const (
+	ReviewApproved         = "APPROVED"
+	ReviewChangesRequested = "CHANGES_REQUESTED"
+	ReviewCommented        = "COMMENTED"
+	SideLeft               = "LEFT"
+	SideRight              = "RIGHT"
+	SubjectTypeLine        = "line"
+	SubjectTypeFile        = "file"
+)
+
+// ConversationFetcher は複数のPRの本体と会話をまとめて取得する。
+// 取得できたPRはPR番号をキーとするmapで返し、取得できなかったPRのエラーはまとめて返す。
+// そのためmapとエラーの両方が返ることがある。
+// ctxがキャンセルされた場合は残りのPRを取得せずに、それまでに取得できた分を返す。
+type ConversationFetcher interface {
+	FetchConversations(ctx context.Context, prNumbers []int) (map[int]*Conversation, error)
+}
+
+var _ ConversationFetcher = (*Client)(nil)
+
+/**
+ * REST APIでPRごとに本体・Issue Comments・Review Comments・Reviewsを順に取得する
+ * REST APIではスレッドの解決状態が取得できないため、ResolvedThreadsは設定しない
+ */
+func (c *Client) FetchConversations(ctx context.Context, prNumbers []int) (map[int]*Conversation, error) {
+	conversations := make(map[int]*Conversation, len(prNumbers))
+	var errs []error
+
+	for _, prNumber := range prNumbers {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		conversation, err := c.fetchConversation(ctx, prNumber)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		conversations[prNumber] = conversation
+	}
+
+	return conversations, errors.Join(errs...)
+}
+
+func (c *Client) fetchConversation(ctx context.Context, prNumber int) (*Conversation, error) {
+	pullRequest, err := c.GetPullRequest(ctx, prNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	issueComments, err := c.GetComments(ctx, prNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	reviewComments, err := c.GetReviewComments(ctx, prNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews, err := c.GetReviews(ctx, prNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conversation{
+		PullRequest:    pullRequest,
+		IssueComments:  issueComments,
+		ReviewComments: reviewComments,
+		Reviews:        reviews,
+	}, nil
+}
+
+// ConversationError はPR単位の取得失敗を表す
+type ConversationError struct {
+	PRNumber int
+	Message  string
+}
+
+func (e *ConversationError) Error() string {
+	return fmt.Sprintf("failed to fetch conversation for PR #%d: %s", e.PRNumber, e.Message)
+}
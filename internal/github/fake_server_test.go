@@ -0,0 +1,181 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/malsuke/PRalyzer/internal/githubtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeServerClient はフェイクサーバーを起動し、そこに接続するClientを返す
+func newFakeServerClient(t *testing.T, fixture *githubtest.Fixture) (*githubtest.Server, *Client) {
+	t.Helper()
+
+	server := githubtest.NewServer(fixture)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient("test-token", "owner/repo", nil, WithBaseURL(server.URL))
+	require.NoError(t, err)
+	return server, client
+}
+
+func loadFakeServerFixture(t *testing.T) *githubtest.Fixture {
+	t.Helper()
+
+	fixture, err := githubtest.SampleFixture()
+	require.NoError(t, err)
+	return fixture
+}
+
+func TestFakeServer_SearchPullRequests(t *testing.T) {
+	_, client := newFakeServerClient(t, loadFakeServerFixture(t))
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []int
+	}{
+		{name: "keyword in title, body or comments", query: SearchQuery{Keyword: "xss"}, want: []int{1, 3}},
+		{name: "keyword in comments only", query: SearchQuery{Keyword: "xss", Scopes: []SearchScope{ScopeComments}}, want: []int{3}},
+		{name: "merged only", query: SearchQuery{Keyword: "xss", Merged: MergeMerged}, want: []int{1}},
+		{name: "label", query: SearchQuery{Labels: []string{"security"}}, want: []int{1}},
+		{name: "excluded author", query: SearchQuery{ExcludeAuthors: []string{"dependabot"}, State: StateClosed}, want: []int{1}},
+		{
+			name:  "updated since",
+			query: SearchQuery{Updated: DateRange{From: time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)}},
+			want:  []int{2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prNumbers, err := client.SearchPullRequests(context.Background(), tt.query)
+			require.NoError(t, err)
+			sort.Ints(prNumbers)
+			assert.Equal(t, tt.want, prNumbers)
+		})
+	}
+}
+
+func TestFakeServer_SearchSplitsBeyondResultCap(t *testing.T) {
+	const total = 1500
+	repo := githubtest.Repository{Owner: "owner", Name: "repo"}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= total; i++ {
+		created := start.Add(time.Duration(i) * 12 * time.Hour)
+		repo.PullRequests = append(repo.PullRequests, githubtest.PullRequest{
+			Number:    i,
+			Title:     fmt.Sprintf("Fix injection #%d", i),
+			CreatedAt: created,
+			UpdatedAt: created,
+		})
+	}
+	server, client := newFakeServerClient(t, &githubtest.Fixture{Repositories: []githubtest.Repository{repo}})
+
+	prNumbers, err := client.SearchPullRequests(context.Background(), SearchQuery{Keyword: "injection"})
+	require.NoError(t, err)
+
+	assert.Len(t, prNumbers, total)
+	seen := make(map[int]bool)
+	for _, n := range prNumbers {
+		seen[n] = true
+	}
+	assert.Len(t, seen, total)
+	assert.Greater(t, server.CountRequests("/search/issues"), total/listPerPage)
+}
+
+func TestFakeServer_FetchConversations(t *testing.T) {
+	_, client := newFakeServerClient(t, loadFakeServerFixture(t))
+
+	conversations, err := client.FetchConversations(context.Background(), []int{1, 2, 404})
+	require.Error(t, err)
+	require.Len(t, conversations, 2)
+
+	conversation := conversations[1]
+	assert.Equal(t, "Escape user input in templates", conversation.PullRequest.GetTitle())
+	assert.True(t, conversation.PullRequest.GetMerged())
+	require.Len(t, conversation.IssueComments, 2)
+	assert.Equal(t, "bob", conversation.IssueComments[0].GetUser().GetLogin())
+	require.Len(t, conversation.ReviewComments, 2)
+	assert.Equal(t, int64(201), conversation.ReviewComments[1].GetInReplyTo())
+	require.Len(t, conversation.Reviews, 1)
+	assert.Equal(t, "APPROVED", conversation.Reviews[0].GetState())
+
+	assert.Empty(t, conversations[2].IssueComments)
+}
+
+func TestFakeServer_PaginatesComments(t *testing.T) {
+	pr := githubtest.PullRequest{Number: 1, CreatedAt: time.Now()}
+	for i := 1; i <= 250; i++ {
+		pr.IssueComments = append(pr.IssueComments, githubtest.Comment{ID: int64(i), Body: fmt.Sprintf("comment %d", i)})
+	}
+	server, client := newFakeServerClient(t, &githubtest.Fixture{Repositories: []githubtest.Repository{
+		{Owner: "owner", Name: "repo", PullRequests: []githubtest.PullRequest{pr}},
+	}})
+
+	comments, err := client.GetComments(context.Background(), 1)
+	require.NoError(t, err)
+
+	assert.Len(t, comments, 250)
+	assert.Equal(t, 3, server.CountRequests("/repos/owner/repo/issues/1/comments"))
+}
+
+func TestFakeServer_RecoversFromRateLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		failure      githubtest.Failure
+		wantRequests int
+	}{
+		{name: "primary rate limit exhausted", failure: githubtest.Failure{Path: "/repos/", Status: http.StatusForbidden}, wantRequests: 2},
+		{name: "secondary rate limit", failure: githubtest.Failure{Path: "/repos/", Status: http.StatusForbidden, RetryAfter: time.Second}, wantRequests: 2},
+		{name: "too many requests", failure: githubtest.Failure{Path: "/repos/", Status: http.StatusTooManyRequests, RetryAfter: time.Second}, wantRequests: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newFakeServerClient(t, loadFakeServerFixture(t))
+			server.Fail(tt.failure)
+
+			pr, err := client.GetPullRequest(context.Background(), 1)
+			require.NoError(t, err)
+
+			assert.Equal(t, 1, pr.GetNumber())
+			assert.Equal(t, tt.wantRequests, server.CountRequests("/repos/owner/repo/pulls/1"))
+		})
+	}
+}
+
+func TestFakeServer_WaitsForExhaustedQuota(t *testing.T) {
+	server, client := newFakeServerClient(t, loadFakeServerFixture(t))
+	server.SetRateLimit(githubtest.ResourceCore, 2)
+
+	prs, err := client.ListAllPullRequests(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, prs, 3)
+
+	for i := 1; i <= 3; i++ {
+		_, err := client.GetPullRequest(context.Background(), i)
+		require.NoError(t, err)
+	}
+	// 一覧と1件目の取得で残量を使い切り、2件目で403が返る。待機してリトライした後は残量が戻っている
+	assert.Len(t, server.Requests(), 5)
+	assert.Equal(t, 2, server.CountRequests("/repos/owner/repo/pulls/2"))
+}
+
+func TestFakeServer_NotFound(t *testing.T) {
+	_, client := newFakeServerClient(t, loadFakeServerFixture(t))
+
+	_, err := client.GetPullRequest(context.Background(), 999)
+	require.Error(t, err)
+
+	other, err := client.ForRepository(RepositoryRef{Host: DefaultHost, Owner: "owner", Name: "missing"})
+	require.NoError(t, err)
+	_, err = other.SearchPullRequests(context.Background(), SearchQuery{Keyword: "xss"})
+	assert.Error(t, err)
+}
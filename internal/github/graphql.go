@@ -0,0 +1,495 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v77/github"
+)
+
+const (
+	// graphQLPath はREST APIのベースURLから見たGraphQLエンドポイントのパス
+	graphQLPath = "graphql"
+	// graphQLRateLimited はレート制限に達したときにGraphQLのerrorsで返される種別
+	graphQLRateLimited = "RATE_LIMITED"
+	// graphQLAliasPrefix はバッチ内の各PRに付けるエイリアスの接頭辞
+	graphQLAliasPrefix = "pr"
+)
+
+// GraphQLのフラグメント。GitHubは使われていないフラグメントを含むクエリを拒否するため、クエリごとに必要なものだけを連結する
+const (
+	authorFragment = `
+fragment Author on Actor { login __typename }
+`
+	issueCommentFragment = `
+fragment IssueCommentFields on IssueComment {
+  databaseId body createdAt updatedAt author { ...Author }
+}
+`
+	reviewFragment = `
+fragment ReviewFields on PullRequestReview {
+  databaseId body state submittedAt author { ...Author }
+}
+`
+	reviewThreadFragment = `
+fragment ReviewThreadFields on PullRequestReviewThread {
+  id isResolved isOutdated subjectType
+  comments(first: 100) { pageInfo { hasNextPage endCursor } nodes { ...ReviewCommentFields } }
+}
+`
+	reviewCommentFragment = `
+fragment ReviewCommentFields on PullRequestReviewComment {
+  databaseId body createdAt updatedAt diffHunk path line originalLine
+  commit { oid }
+  replyTo { databaseId }
+  author { ...Author }
+}
+`
+	pullRequestFragment = `
+fragment PullRequestConversation on PullRequest {
+  number title body url state createdAt updatedAt mergedAt changedFiles
+  baseRefName headRefName
+  author { ...Author }
+  labels(first: 100) { nodes { name } }
+  comments(first: 100) { pageInfo { hasNextPage endCursor } nodes { ...IssueCommentFields } }
+  reviews(first: 100) { pageInfo { hasNextPage endCursor } nodes { ...ReviewFields } }
+  reviewThreads(first: 100) { pageInfo { hasNextPage endCursor } nodes { ...ReviewThreadFields } }
+}
+`
+)
+
+// connectionPageQuery は1つのコネクションの続きのページを取得するクエリ（%sにコネクション名とフラグメント名が入る）
+const connectionPageQuery = `
+query($owner: String!, $name: String!, $number: Int!, $after: String) {
+  repository(owner: $owner, name: $name) {
+    pullRequest(number: $number) {
+      %s(first: 100, after: $after) { pageInfo { hasNextPage endCursor } nodes { ...%s } }
+    }
+  }
+}
+`
+
+// threadCommentsPageQuery はレビュースレッドのコメントの続きのページを取得するクエリ
+const threadCommentsPageQuery = `
+query($id: ID!, $after: String) {
+  node(id: $id) {
+    ... on PullRequestReviewThread {
+      comments(first: 100, after: $after) { pageInfo { hasNextPage endCursor } nodes { ...ReviewCommentFields } }
+    }
+  }
+}
+`
+
+// GraphQLFetcher はGraphQL API（v4）を使って複数PRの本体と会話を1回のリクエストで取得する
+type GraphQLFetcher struct {
+	client *Client
+}
+
+var _ ConversationFetcher = (*GraphQLFetcher)(nil)
+
+// NewGraphQLFetcher はclientと同じ認証・接続先・レート制限を共有するGraphQLFetcherを作成する。
+func NewGraphQLFetcher(client *Client) *GraphQLFetcher {
+	return &GraphQLFetcher{client: client}
+}
+
+/**
+ * prNumbersのPRをエイリアスで並べた1つのクエリで取得する
+ * 100件を超えるコメント・レビュー・スレッドとスレッド内のコメントは、続きのページを追加で取得する
+ */
+func (f *GraphQLFetcher) FetchConversations(ctx context.Context, prNumbers []int) (map[int]*Conversation, error) {
+	conversations := make(map[int]*Conversation, len(prNumbers))
+	if len(prNumbers) == 0 {
+		return conversations, nil
+	}
+
+	var data struct {
+		Repository map[string]*gqlPullRequest `json:"repository"`
+	}
+	gqlErrs, err := f.query(ctx, buildBatchQuery(prNumbers), f.variables(nil), &data)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := aliasErrors(prNumbers, gqlErrs)
+	for i, prNumber := range prNumbers {
+		pr := data.Repository[batchAlias(i)]
+		if pr == nil {
+			if _, failed := errs[prNumber]; !failed {
+				errs[prNumber] = &ConversationError{PRNumber: prNumber, Message: "pull request not found"}
+			}
+			continue
+		}
+
+		if err := f.fetchRemainingPages(ctx, pr); err != nil {
+			errs[prNumber] = err
+			continue
+		}
+		conversations[prNumber] = pr.toConversation()
+	}
+
+	joined := make([]error, 0, len(errs))
+	for _, prNumber := range prNumbers {
+		if err, ok := errs[prNumber]; ok {
+			joined = append(joined, err)
+		}
+	}
+	return conversations, errors.Join(joined...)
+}
+
+func (f *GraphQLFetcher) variables(extra map[string]any) map[string]any {
+	variables := map[string]any{
+		"owner": f.client.Owner,
+		"name":  f.client.Name,
+	}
+	for key, value := range extra {
+		variables[key] = value
+	}
+	return variables
+}
+
+// fetchRemainingPages は1ページ目で取りきれなかったコネクションとスレッド内のコメントの続きを取得してprに追記する
+func (f *GraphQLFetcher) fetchRemainingPages(ctx context.Context, pr *gqlPullRequest) error {
+	if err := fetchConnectionPages(ctx, f, pr.Number, "comments", "IssueCommentFields", issueCommentFragment, &pr.Comments); err != nil {
+		return err
+	}
+	if err := fetchConnectionPages(ctx, f, pr.Number, "reviews", "ReviewFields", reviewFragment, &pr.Reviews); err != nil {
+		return err
+	}
+	if err := fetchConnectionPages(ctx, f, pr.Number, "reviewThreads", "ReviewThreadFields", reviewThreadFragment+reviewCommentFragment, &pr.ReviewThreads); err != nil {
+		return err
+	}
+
+	for i := range pr.ReviewThreads.Nodes {
+		if err := f.fetchThreadComments(ctx, pr.Number, &pr.ReviewThreads.Nodes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchThreadComments はスレッドのIDからコメントの続きのページを取得してthreadに追記する
+func (f *GraphQLFetcher) fetchThreadComments(ctx context.Context, prNumber int, thread *gqlReviewThread) error {
+	query := threadCommentsPageQuery + reviewCommentFragment + authorFragment
+
+	for thread.Comments.PageInfo.HasNextPage {
+		var data struct {
+			Node *struct {
+				Comments gqlConnection[gqlReviewComment] `json:"comments"`
+			} `json:"node"`
+		}
+		gqlErrs, err := f.query(ctx, query, map[string]any{"id": thread.ID, "after": thread.Comments.PageInfo.EndCursor}, &data)
+		if err != nil {
+			return err
+		}
+		if len(gqlErrs) > 0 {
+			return &ConversationError{PRNumber: prNumber, Message: gqlErrs[0].Message}
+		}
+		if data.Node == nil {
+			return &ConversationError{PRNumber: prNumber, Message: "missing review thread comments page"}
+		}
+		thread.Comments.Nodes = append(thread.Comments.Nodes, data.Node.Comments.Nodes...)
+		thread.Comments.PageInfo = data.Node.Comments.PageInfo
+	}
+	return nil
+}
+
+func fetchConnectionPages[T any](ctx context.Context, f *GraphQLFetcher, prNumber int, field, fragmentName, fragment string, conn *gqlConnection[T]) error {
+	query := fmt.Sprintf(connectionPageQuery, field, fragmentName) + fragment + authorFragment
+
+	for conn.PageInfo.HasNextPage {
+		var data struct {
+			Repository struct {
+				PullRequest map[string]*gqlConnection[T] `json:"pullRequest"`
+			} `json:"repository"`
+		}
+		gqlErrs, err := f.query(ctx, query, f.variables(map[string]any{"number": prNumber, "after": conn.PageInfo.EndCursor}), &data)
+		if err != nil {
+			return err
+		}
+		if len(gqlErrs) > 0 {
+			return &ConversationError{PRNumber: prNumber, Message: gqlErrs[0].Message}
+		}
+
+		next := data.Repository.PullRequest[field]
+		if next == nil {
+			return &ConversationError{PRNumber: prNumber, Message: fmt.Sprintf("missing %s page", field)}
+		}
+		conn.Nodes = append(conn.Nodes, next.Nodes...)
+		conn.PageInfo = next.PageInfo
+	}
+	return nil
+}
+
+/**
+ * GraphQLクエリを送信し、dataをoutにデコードする
+ * HTTPレベルのエラーはそのまま、GraphQLのerrorsは呼び出し側で扱えるように返す
+ * GraphQLのレート制限エラーはRateLimitErrorに変換して、リセットまで待ってリトライする
+ */
+func (f *GraphQLFetcher) query(ctx context.Context, query string, variables map[string]any, out any) ([]gqlError, error) {
+	body, _, err := callWithRateLimit(ctx, f.client.limiter, RateResourceGraphQL, func() (*gqlResponse, *github.Response, error) {
+		req, err := f.client.github.NewRequest(http.MethodPost, f.client.graphQLPath, map[string]any{
+			"query":     query,
+			"variables": variables,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var body gqlResponse
+		resp, err := f.client.github.Do(ctx, req, &body)
+		if err != nil {
+			return nil, resp, err
+		}
+		for _, gqlErr := range body.Errors {
+			if gqlErr.Type == graphQLRateLimited {
+				return nil, resp, &github.RateLimitError{Rate: resp.Rate, Response: resp.Response, Message: gqlErr.Message}
+			}
+		}
+		return &body, resp, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query GraphQL API: %w", err)
+	}
+
+	if len(body.Data) == 0 || string(body.Data) == "null" {
+		if len(body.Errors) > 0 {
+			return nil, fmt.Errorf("GraphQL query failed: %s", body.Errors[0].Message)
+		}
+		return nil, fmt.Errorf("GraphQL response has no data")
+	}
+	if err := json.Unmarshal(body.Data, out); err != nil {
+		return nil, fmt.Errorf("failed to decode GraphQL response: %w", err)
+	}
+	return body.Errors, nil
+}
+
+// buildBatchQuery はPRごとにエイリアスを付けたpullRequestフィールドを並べたクエリを作る
+func buildBatchQuery(prNumbers []int) string {
+	var b strings.Builder
+	b.WriteString("query($owner: String!, $name: String!) {\n  repository(owner: $owner, name: $name) {\n")
+	for i, prNumber := range prNumbers {
+		fmt.Fprintf(&b, "    %s: pullRequest(number: %d) { ...PullRequestConversation }\n", batchAlias(i), prNumber)
+	}
+	b.WriteString("  }\n}\n")
+	for _, fragment := range []string{pullRequestFragment, issueCommentFragment, reviewFragment, reviewThreadFragment, reviewCommentFragment, authorFragment} {
+		b.WriteString(fragment)
+	}
+	return b.String()
+}
+
+func batchAlias(index int) string {
+	return fmt.Sprintf("%s%d", graphQLAliasPrefix, index)
+}
+
+// aliasErrors はGraphQLのerrorsをpathのエイリアスからPR番号ごとのエラーに振り分ける
+func aliasErrors(prNumbers []int, gqlErrs []gqlError) map[int]error {
+	errs := make(map[int]error)
+	for _, gqlErr := range gqlErrs {
+		for i, prNumber := range prNumbers {
+			if gqlErr.hasPathElement(batchAlias(i)) {
+				errs[prNumber] = &ConversationError{PRNumber: prNumber, Message: gqlErr.Message}
+			}
+		}
+	}
+	return errs
+}
+
+type gqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []gqlError      `json:"errors"`
+}
+
+type gqlError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Path    []any  `json:"path"`
+}
+
+func (e gqlError) hasPathElement(element string) bool {
+	for _, p := range e.Path {
+		if s, ok := p.(string); ok && s == element {
+			return true
+		}
+	}
+	return false
+}
+
+type gqlPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type gqlConnection[T any] struct {
+	PageInfo gqlPageInfo `json:"pageInfo"`
+	Nodes    []T         `json:"nodes"`
+}
+
+type gqlActor struct {
+	Login    string `json:"login"`
+	TypeName string `json:"__typename"`
+}
+
+func (a *gqlActor) toUser() *github.User {
+	if a == nil {
+		return nil
+	}
+	return &github.User{Login: github.Ptr(a.Login), Type: github.Ptr(a.TypeName)}
+}
+
+type gqlIssueComment struct {
+	DatabaseID int64             `json:"databaseId"`
+	Body       string            `json:"body"`
+	CreatedAt  *github.Timestamp `json:"createdAt"`
+	UpdatedAt  *github.Timestamp `json:"updatedAt"`
+	Author     *gqlActor         `json:"author"`
+}
+
+type gqlReview struct {
+	DatabaseID  int64             `json:"databaseId"`
+	Body        string            `json:"body"`
+	State       string            `json:"state"`
+	SubmittedAt *github.Timestamp `json:"submittedAt"`
+	Author      *gqlActor         `json:"author"`
+}
+
+type gqlReviewComment struct {
+	DatabaseID   int64             `json:"databaseId"`
+	Body         string            `json:"body"`
+	CreatedAt    *github.Timestamp `json:"createdAt"`
+	UpdatedAt    *github.Timestamp `json:"updatedAt"`
+	DiffHunk     string            `json:"diffHunk"`
+	Path         string            `json:"path"`
+	Line         *int              `json:"line"`
+	OriginalLine *int              `json:"originalLine"`
+	Commit       *struct {
+		OID string `json:"oid"`
+	} `json:"commit"`
+	ReplyTo *struct {
+		DatabaseID int64 `json:"databaseId"`
+	} `json:"replyTo"`
+	Author *gqlActor `json:"author"`
+}
+
+type gqlReviewThread struct {
+	ID         string `json:"id"`
+	IsResolved bool   `json:"isResolved"`
+	IsOutdated bool   `json:"isOutdated"`
+	// SubjectType はスレッドが行（LINE）とファイル全体（FILE）のどちらに対するものか
+	SubjectType string                          `json:"subjectType"`
+	Comments    gqlConnection[gqlReviewComment] `json:"comments"`
+}
+
+type gqlPullRequest struct {
+	Number       int               `json:"number"`
+	Title        string            `json:"title"`
+	Body         string            `json:"body"`
+	URL          string            `json:"url"`
+	State        string            `json:"state"`
+	CreatedAt    *github.Timestamp `json:"createdAt"`
+	UpdatedAt    *github.Timestamp `json:"updatedAt"`
+	MergedAt     *github.Timestamp `json:"mergedAt"`
+	ChangedFiles int               `json:"changedFiles"`
+	BaseRefName  string            `json:"baseRefName"`
+	HeadRefName  string            `json:"headRefName"`
+	Author       *gqlActor         `json:"author"`
+	Labels       struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"labels"`
+	Comments      gqlConnection[gqlIssueComment] `json:"comments"`
+	Reviews       gqlConnection[gqlReview]       `json:"reviews"`
+	ReviewThreads gqlConnection[gqlReviewThread] `json:"reviewThreads"`
+}
+
+// toConversation はGraphQLの結果をREST APIと同じgo-githubの型に変換する
+func (pr *gqlPullRequest) toConversation() *Conversation {
+	labels := make([]*github.Label, 0, len(pr.Labels.Nodes))
+	for _, label := range pr.Labels.Nodes {
+		labels = append(labels, &github.Label{Name: github.Ptr(label.Name)})
+	}
+
+	conversation := &Conversation{
+		PullRequest: &github.PullRequest{
+			Number:       github.Ptr(pr.Number),
+			Title:        github.Ptr(pr.Title),
+			Body:         github.Ptr(pr.Body),
+			HTMLURL:      github.Ptr(pr.URL),
+			State:        github.Ptr(strings.ToLower(pr.State)),
+			CreatedAt:    pr.CreatedAt,
+			UpdatedAt:    pr.UpdatedAt,
+			MergedAt:     pr.MergedAt,
+			Merged:       github.Ptr(pr.MergedAt != nil),
+			ChangedFiles: github.Ptr(pr.ChangedFiles),
+			User:         pr.Author.toUser(),
+			Labels:       labels,
+			Base:         &github.PullRequestBranch{Ref: github.Ptr(pr.BaseRefName)},
+			Head:         &github.PullRequestBranch{Ref: github.Ptr(pr.HeadRefName)},
+		},
+		ResolvedThreads: make(map[int64]bool),
+	}
+
+	for _, comment := range pr.Comments.Nodes {
+		conversation.IssueComments = append(conversation.IssueComments, &github.IssueComment{
+			ID:        github.Ptr(comment.DatabaseID),
+			Body:      github.Ptr(comment.Body),
+			User:      comment.Author.toUser(),
+			CreatedAt: comment.CreatedAt,
+			UpdatedAt: comment.UpdatedAt,
+		})
+	}
+
+	for _, review := range pr.Reviews.Nodes {
+		conversation.Reviews = append(conversation.Reviews, &github.PullRequestReview{
+			ID:          github.Ptr(review.DatabaseID),
+			Body:        github.Ptr(review.Body),
+			State:       github.Ptr(review.State),
+			SubmittedAt: review.SubmittedAt,
+			User:        review.Author.toUser(),
+		})
+	}
+
+	for _, thread := range pr.ReviewThreads.Nodes {
+		for i, comment := range thread.Comments.Nodes {
+			if i == 0 {
+				conversation.ResolvedThreads[comment.DatabaseID] = thread.IsResolved
+			}
+			conversation.ReviewComments = append(conversation.ReviewComments, comment.toPullRequestComment(thread))
+		}
+	}
+
+	return conversation
+}
+
+// toPullRequestComment はthreadのコメントをREST APIと同じ型に変換する
+// REST APIと同じく、古くなった（outdated）スレッドのコメントはlineを持たず、スレッドの対象の種別をsubject_typeにする
+func (c gqlReviewComment) toPullRequestComment(thread gqlReviewThread) *github.PullRequestComment {
+	comment := &github.PullRequestComment{
+		ID:           github.Ptr(c.DatabaseID),
+		Body:         github.Ptr(c.Body),
+		Path:         github.Ptr(c.Path),
+		DiffHunk:     github.Ptr(c.DiffHunk),
+		Line:         c.Line,
+		OriginalLine: c.OriginalLine,
+		User:         c.Author.toUser(),
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+	}
+	if thread.SubjectType != "" {
+		comment.SubjectType = github.Ptr(strings.ToLower(thread.SubjectType))
+	}
+	if thread.IsOutdated {
+		comment.Line = nil
+	}
+	if c.Commit != nil {
+		comment.CommitID = github.Ptr(c.Commit.OID)
+	}
+	if c.ReplyTo != nil {
+		comment.InReplyTo = github.Ptr(c.ReplyTo.DatabaseID)
+	}
+	return comment
+}
@@ -0,0 +1,113 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables"`
+}
+
+// replayGraphQL は受け取ったクエリに応じてtestdata/graphqlに記録したレスポンスを返す
+func replayGraphQL(t *testing.T, requests *[]graphQLRequest) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+
+		var req graphQLRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		*requests = append(*requests, req)
+
+		fixture := "batch.json"
+		if _, ok := req.Variables["id"]; ok {
+			fixture = "thread_comments_page2.json"
+		} else if _, ok := req.Variables["after"]; ok {
+			fixture = "comments_page2.json"
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", "graphql", fixture))
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-RateLimit-Resource", RateResourceGraphQL)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+	}
+}
+
+func TestGraphQLFetcher_FetchConversations(t *testing.T) {
+	var requests []graphQLRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", replayGraphQL(t, &requests))
+	client := newTestClient(t, mux)
+
+	conversations, err := NewGraphQLFetcher(client).FetchConversations(context.Background(), []int{12, 99})
+
+	// 存在しないPRはエラーとして返しつつ、取得できたPRは返す
+	var convErr *ConversationError
+	require.ErrorAs(t, err, &convErr)
+	assert.Equal(t, 99, convErr.PRNumber)
+	require.Len(t, conversations, 1)
+
+	require.Len(t, requests, 3)
+	assert.Contains(t, requests[0].Query, "pr0: pullRequest(number: 12)")
+	assert.Contains(t, requests[0].Query, "pr1: pullRequest(number: 99)")
+	assert.Equal(t, "owner", requests[0].Variables["owner"])
+	assert.Equal(t, "Y3Vyc29yOjE=", requests[1].Variables["after"])
+	assert.False(t, strings.Contains(requests[1].Query, "fragment ReviewFields"), "unused fragments must not be sent")
+	// 100件を超えるスレッド内のコメントは、スレッドのIDで続きを取得する
+	assert.Equal(t, map[string]any{"id": "PRRT_2", "after": "dGhyZWFkOjI="}, requests[2].Variables)
+	assert.Contains(t, requests[2].Query, "fragment ReviewCommentFields")
+
+	conversation := conversations[12]
+	require.NotNil(t, conversation)
+
+	pr := conversation.PullRequest
+	assert.Equal(t, "Escape HTML in comment previews", pr.GetTitle())
+	assert.Equal(t, "author", pr.GetUser().GetLogin())
+	assert.Equal(t, "main", pr.GetBase().GetRef())
+	assert.Equal(t, "fix-preview-xss", pr.GetHead().GetRef())
+	assert.Equal(t, 2, pr.GetChangedFiles())
+	require.Len(t, pr.Labels, 1)
+	assert.Equal(t, "security", pr.Labels[0].GetName())
+
+	require.Len(t, conversation.IssueComments, 2)
+	assert.Equal(t, int64(1002), conversation.IssueComments[1].GetID())
+	assert.Equal(t, "Bot", conversation.IssueComments[1].GetUser().GetType())
+
+	require.Len(t, conversation.Reviews, 1)
+	assert.Equal(t, "CHANGES_REQUESTED", conversation.Reviews[0].GetState())
+
+	require.Len(t, conversation.ReviewComments, 4)
+	reply := conversation.ReviewComments[1]
+	assert.Equal(t, int64(2001), reply.GetInReplyTo())
+	assert.Equal(t, "web/preview.js", reply.GetPath())
+	assert.Equal(t, 12, reply.GetLine())
+	assert.Equal(t, "def456", reply.GetCommitID())
+	assert.Equal(t, "line", reply.GetSubjectType())
+	assert.Equal(t, map[int64]bool{2001: true, 2003: false}, conversation.ResolvedThreads)
+
+	// 古くなったスレッドのコメントは、REST APIと同じくlineを持たない
+	for _, comment := range conversation.ReviewComments[2:] {
+		assert.Nil(t, comment.Line, "comment %d", comment.GetID())
+		assert.Equal(t, 4, comment.GetOriginalLine())
+	}
+	assert.Equal(t, int64(2003), conversation.ReviewComments[3].GetInReplyTo())
+}
+
+func TestGraphQLFetcher_EmptyBatch(t *testing.T) {
+	client := newTestClient(t, http.NewServeMux())
+
+	conversations, err := NewGraphQLFetcher(client).FetchConversations(context.Background(), nil)
+
+	require.NoError(t, err)
+	assert.Empty(t, conversations)
+}
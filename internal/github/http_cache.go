@@ -0,0 +1,332 @@
+package github
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	headerETag            = "ETag"
+	headerLastModified    = "Last-Modified"
+	headerIfNoneMatch     = "If-None-Match"
+	headerIfModifiedSince = "If-Modified-Since"
+	headerContentLength   = "Content-Length"
+	headerRange           = "Range"
+	// HeaderFromCache はキャッシュから返したレスポンスに付けるヘッダー
+	HeaderFromCache = "X-From-Cache"
+	// cacheEntryExt はキャッシュのエントリを保存するファイルの拡張子
+	cacheEntryExt = ".json"
+)
+
+// HTTPCache はGETリクエストのレスポンスをディスクに保存し、次回からETag・Last-Modifiedによる条件付きリクエストを送る。
+// GitHubは304 Not Modifiedをレート制限に数えないため、変更のないページを何度取得しても残量が減らない。
+// エントリはURL・Acceptヘッダー・トークン（のハッシュ）ごとに分けて保存する。複数のgoroutineから共有できる。
+type HTTPCache struct {
+	dir string
+
+	revalidated atomic.Int64
+	missed      atomic.Int64
+	stored      atomic.Int64
+	failed      atomic.Int64
+}
+
+// CacheStats はこの実行中のキャッシュの利用状況
+type CacheStats struct {
+	// Revalidated は304が返り、保存済みのレスポンスを使ったリクエストの数
+	Revalidated int64 `json:"revalidated"`
+	// Missed は保存済みのレスポンスがないか、内容が変わっていたリクエストの数
+	Missed int64 `json:"missed"`
+	// Stored は新しく保存（更新）したレスポンスの数
+	Stored int64 `json:"stored"`
+	// Errors はエントリの読み書きに失敗した回数（失敗してもリクエスト自体は続行する）
+	Errors int64 `json:"errors"`
+}
+
+// CacheUsage はディスク上のキャッシュの大きさ
+type CacheUsage struct {
+	Entries int
+	Bytes   int64
+}
+
+// cacheEntry はディスクに保存する1件分のレスポンス
+type cacheEntry struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+// NewHTTPCache はdirにレスポンスを保存するHTTPCacheを作成する。dirがなければ作る。
+func NewHTTPCache(dir string) (*HTTPCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create HTTP cache directory: %w", err)
+	}
+	return &HTTPCache{dir: dir}, nil
+}
+
+// Dir はキャッシュを保存しているディレクトリを返す。
+func (c *HTTPCache) Dir() string {
+	return c.dir
+}
+
+// Stats はこの実行中のキャッシュの利用状況を返す。
+func (c *HTTPCache) Stats() CacheStats {
+	return CacheStats{
+		Revalidated: c.revalidated.Load(),
+		Missed:      c.missed.Load(),
+		Stored:      c.stored.Load(),
+		Errors:      c.failed.Load(),
+	}
+}
+
+// HTTPClient はキャッシュを通してリクエストを送るhttp.Clientを返す。NewClientのhttpClientに渡せる。
+func (c *HTTPCache) HTTPClient() *http.Client {
+	return &http.Client{Transport: c.Transport(nil)}
+}
+
+// Transport はbaseの前段でレスポンスをキャッシュするRoundTripperを返す。
+// 認証ヘッダーを付けるTransportより内側（ネットワークに近い側）に置く必要がある。
+func (c *HTTPCache) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &cacheTransport{cache: c, base: base}
+}
+
+type cacheTransport struct {
+	cache *HTTPCache
+	base  http.RoundTripper
+}
+
+/**
+ * 保存済みのレスポンスがあればETag・Last-Modifiedを付けて条件付きリクエストを送り、304なら保存済みの本文を返す
+ * 304のヘッダー（レート制限の残量など）は最新の値で上書きする
+ * ETagかLast-Modifiedのある200のレスポンスは本文ごと保存する
+ */
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !cacheable(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	key := cacheKey(req)
+	entry, err := t.cache.load(key)
+	if err != nil {
+		t.cache.failed.Add(1)
+	}
+
+	outgoing := req
+	if entry != nil {
+		outgoing = req.Clone(req.Context())
+		if etag := entry.Header.Get(headerETag); etag != "" {
+			outgoing.Header.Set(headerIfNoneMatch, etag)
+		}
+		if lastModified := entry.Header.Get(headerLastModified); lastModified != "" {
+			outgoing.Header.Set(headerIfModifiedSince, lastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		t.cache.revalidated.Add(1)
+		t.cache.touch(key)
+		return entry.response(req, resp), nil
+	}
+
+	t.cache.missed.Add(1)
+	if resp.StatusCode != http.StatusOK || (resp.Header.Get(headerETag) == "" && resp.Header.Get(headerLastModified) == "") {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := t.cache.save(key, &cacheEntry{
+		URL:        req.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		StoredAt:   time.Now(),
+	}); err != nil {
+		t.cache.failed.Add(1)
+	} else {
+		t.cache.stored.Add(1)
+	}
+	return resp, nil
+}
+
+// cacheable は保存済みのレスポンスを使ってよいリクエストかどうかを返す。
+// 呼び出し側が自分で条件付きリクエストや部分取得をしている場合は関与しない。
+func cacheable(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	for _, header := range []string{headerIfNoneMatch, headerIfModifiedSince, headerRange} {
+		if req.Header.Get(header) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheKey はURL・Acceptヘッダー・認証ヘッダーからエントリのキーを作る。
+// トークンそのものはディスクに残さないよう、ハッシュ値だけをキーに含める。
+func cacheKey(req *http.Request) string {
+	identity := sha256.Sum256([]byte(req.Header.Get(headerAuthorization)))
+	key := sha256.Sum256([]byte(strings.Join([]string{
+		req.URL.String(),
+		req.Header.Get(headerAccept),
+		hex.EncodeToString(identity[:]),
+	}, "\n")))
+	return hex.EncodeToString(key[:])
+}
+
+// entryPath はキーのエントリを保存するファイルのパスを返す（1つのディレクトリにファイルが集中しないよう先頭2文字で分ける）
+func (c *HTTPCache) entryPath(key string) string {
+	return filepath.Join(c.dir, key[:2], key+cacheEntryExt)
+}
+
+// load はキーのエントリを読み込む。保存されていなければnilを返す。
+func (c *HTTPCache) load(key string) (*cacheEntry, error) {
+	data, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse cache entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// save はエントリを保存する。書き込み途中で中断しても壊れたエントリが残らないよう、一時ファイルに書いてから置き換える。
+func (c *HTTPCache) save(key string, entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+
+	path := c.entryPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace cache entry: %w", err)
+	}
+	return nil
+}
+
+// touch はエントリの更新日時を現在時刻にする（Purgeで最近使ったエントリを残すため）
+func (c *HTTPCache) touch(key string) {
+	now := time.Now()
+	if err := os.Chtimes(c.entryPath(key), now, now); err != nil {
+		c.failed.Add(1)
+	}
+}
+
+// response は保存済みのレスポンスを、304で返ってきた最新のヘッダーで上書きしたレスポンスにする
+func (e *cacheEntry) response(req *http.Request, notModified *http.Response) *http.Response {
+	header := e.Header.Clone()
+	for name, values := range notModified.Header {
+		header[name] = values
+	}
+	header.Set(headerContentLength, strconv.Itoa(len(e.Body)))
+	header.Set(HeaderFromCache, "1")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// Usage はディスク上のエントリの数と合計サイズを返す。
+func (c *HTTPCache) Usage() (CacheUsage, error) {
+	var usage CacheUsage
+	err := c.walkEntries(func(path string, info fs.FileInfo) error {
+		usage.Entries++
+		usage.Bytes += info.Size()
+		return nil
+	})
+	return usage, err
+}
+
+// Purge はolderThanより長く使われていないエントリを削除し、削除した数を返す。olderThanが0ならすべて削除する。
+func (c *HTTPCache) Purge(olderThan time.Duration) (int, error) {
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	err := c.walkEntries(func(path string, info fs.FileInfo) error {
+		if olderThan > 0 && info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+func (c *HTTPCache) walkEntries(visit func(path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != cacheEntryExt {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return visit(path, info)
+	})
+}
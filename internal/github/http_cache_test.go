@@ -0,0 +1,164 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etagServer はETagが一致すれば304を返すテスト用サーバー。受け取ったIf-None-Matchを記録する。
+type etagServer struct {
+	mu          sync.Mutex
+	ifNoneMatch []string
+}
+
+func (s *etagServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ifNoneMatch = append(s.ifNoneMatch, r.Header.Get(headerIfNoneMatch))
+	s.mu.Unlock()
+
+	etag := `"v1-` + r.Header.Get(headerAuthorization) + `"`
+	w.Header().Set(headerRateLimitRemaining, "4999")
+	w.Header().Set(headerRateLimitReset, fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+	if r.Header.Get(headerIfNoneMatch) == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set(headerETag, etag)
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `[{"id":1,"body":"cached"}]`)
+}
+
+func (s *etagServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ifNoneMatch...)
+}
+
+// newCachedTestClient はcacheを通してserverに接続するClientを作成する
+func newCachedTestClient(t *testing.T, server *httptest.Server, cache *HTTPCache, token string) *Client {
+	t.Helper()
+
+	client, err := NewClient(token, "owner/repo", &http.Client{Transport: cache.Transport(server.Client().Transport)})
+	require.NoError(t, err)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	client.github.BaseURL = baseURL
+	return client
+}
+
+func TestHTTPCache_RevalidatesWithETag(t *testing.T) {
+	handler := &etagServer{}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cache, err := NewHTTPCache(t.TempDir())
+	require.NoError(t, err)
+	client := newCachedTestClient(t, server, cache, "token-a")
+
+	for range 2 {
+		comments, err := client.GetComments(context.Background(), 7)
+		require.NoError(t, err)
+		require.Len(t, comments, 1)
+		assert.Equal(t, "cached", comments[0].GetBody())
+	}
+
+	requests := handler.requests()
+	require.Len(t, requests, 2)
+	assert.Empty(t, requests[0])
+	assert.NotEmpty(t, requests[1])
+	assert.Equal(t, CacheStats{Revalidated: 1, Missed: 1, Stored: 1}, cache.Stats())
+}
+
+func TestHTTPCache_SeparatesTokens(t *testing.T) {
+	handler := &etagServer{}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	cache, err := NewHTTPCache(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = newCachedTestClient(t, server, cache, "token-a").GetComments(context.Background(), 7)
+	require.NoError(t, err)
+	_, err = newCachedTestClient(t, server, cache, "token-b").GetComments(context.Background(), 7)
+	require.NoError(t, err)
+
+	// 別のトークンのエントリは使わない
+	assert.Equal(t, []string{"", ""}, handler.requests())
+	assert.Equal(t, CacheStats{Missed: 2, Stored: 2}, cache.Stats())
+}
+
+func TestHTTPCache_StoresOnlyValidatedGETResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		status     int
+		header     string
+		wantStored int64
+	}{
+		{name: "etag", method: http.MethodGet, status: http.StatusOK, header: headerETag, wantStored: 1},
+		{name: "last modified", method: http.MethodGet, status: http.StatusOK, header: headerLastModified, wantStored: 1},
+		{name: "no validator", method: http.MethodGet, status: http.StatusOK},
+		{name: "error status", method: http.MethodGet, status: http.StatusNotFound, header: headerETag},
+		{name: "post", method: http.MethodPost, status: http.StatusOK, header: headerETag},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set(tt.header, "value")
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, `{}`)
+			}))
+			t.Cleanup(server.Close)
+			cache, err := NewHTTPCache(t.TempDir())
+			require.NoError(t, err)
+
+			req, err := http.NewRequest(tt.method, server.URL+"/resource", nil)
+			require.NoError(t, err)
+			resp, err := cache.Transport(server.Client().Transport).RoundTrip(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.wantStored, cache.Stats().Stored)
+		})
+	}
+}
+
+func TestHTTPCache_UsageAndPurge(t *testing.T) {
+	server := httptest.NewServer(&etagServer{})
+	t.Cleanup(server.Close)
+	cache, err := NewHTTPCache(t.TempDir())
+	require.NoError(t, err)
+	client := newCachedTestClient(t, server, cache, "token-a")
+
+	for _, prNumber := range []int{1, 2} {
+		_, err := client.GetComments(context.Background(), prNumber)
+		require.NoError(t, err)
+	}
+
+	usage, err := cache.Usage()
+	require.NoError(t, err)
+	assert.Equal(t, 2, usage.Entries)
+	assert.Positive(t, usage.Bytes)
+
+	removed, err := cache.Purge(time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+
+	removed, err = cache.Purge(0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	usage, err = cache.Usage()
+	require.NoError(t, err)
+	assert.Zero(t, usage.Entries)
+}
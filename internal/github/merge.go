@@ -0,0 +1,64 @@
+package github
+
+import "github.com/google/go-github/v77/github"
+
+/**
+ * 前回保存した会話に、今回取得した会話を取り込む
+ * コメントとレビューはIDで突き合わせ、両方にあるものは今回の内容で置き換え、今回だけにあるものを末尾に追加する
+ * 前回だけにあるもの（削除されたコメントなど）は残す。PR本体は今回取得したものを使う
+ */
+func MergeConversation(previous, latest *Conversation) *Conversation {
+	if previous == nil {
+		return latest
+	}
+	if latest == nil {
+		return previous
+	}
+
+	merged := &Conversation{
+		PullRequest:    latest.PullRequest,
+		IssueComments:  mergeByID(previous.IssueComments, latest.IssueComments, (*github.IssueComment).GetID),
+		ReviewComments: mergeByID(previous.ReviewComments, latest.ReviewComments, (*github.PullRequestComment).GetID),
+		Reviews:        mergeByID(previous.Reviews, latest.Reviews, (*github.PullRequestReview).GetID),
+	}
+	if merged.PullRequest == nil {
+		merged.PullRequest = previous.PullRequest
+	}
+
+	if len(previous.ResolvedThreads) > 0 || len(latest.ResolvedThreads) > 0 {
+		merged.ResolvedThreads = make(map[int64]bool, len(previous.ResolvedThreads)+len(latest.ResolvedThreads))
+		for id, resolved := range previous.ResolvedThreads {
+			merged.ResolvedThreads[id] = resolved
+		}
+		for id, resolved := range latest.ResolvedThreads {
+			merged.ResolvedThreads[id] = resolved
+		}
+	}
+
+	return merged
+}
+
+// mergeByID はpreviousの順序を保ったまま、同じIDの要素をlatestのもので置き換え、latestだけにある要素を末尾に追加する
+func mergeByID[T any](previous, latest []T, id func(T) int64) []T {
+	latestByID := make(map[int64]T, len(latest))
+	for _, item := range latest {
+		latestByID[id(item)] = item
+	}
+
+	merged := make([]T, 0, len(previous)+len(latest))
+	seen := make(map[int64]bool, len(previous))
+	for _, item := range previous {
+		key := id(item)
+		seen[key] = true
+		if updated, ok := latestByID[key]; ok {
+			item = updated
+		}
+		merged = append(merged, item)
+	}
+	for _, item := range latest {
+		if !seen[id(item)] {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
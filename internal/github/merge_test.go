@@ -0,0 +1,71 @@
+package github
+
+import (
+	"testing"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeConversation(t *testing.T) {
+	previous := &Conversation{
+		PullRequest: &gh.PullRequest{Number: gh.Ptr(7), Title: gh.Ptr("old title")},
+		IssueComments: []*gh.IssueComment{
+			{ID: gh.Ptr(int64(1)), Body: gh.Ptr("first")},
+			{ID: gh.Ptr(int64(2)), Body: gh.Ptr("deleted later")},
+		},
+		ReviewComments:  []*gh.PullRequestComment{{ID: gh.Ptr(int64(10)), Body: gh.Ptr("nit")}},
+		Reviews:         []*gh.PullRequestReview{{ID: gh.Ptr(int64(20)), State: gh.Ptr("COMMENTED")}},
+		ResolvedThreads: map[int64]bool{10: false},
+	}
+	latest := &Conversation{
+		PullRequest: &gh.PullRequest{Number: gh.Ptr(7), Title: gh.Ptr("new title")},
+		IssueComments: []*gh.IssueComment{
+			{ID: gh.Ptr(int64(1)), Body: gh.Ptr("first (edited)")},
+			{ID: gh.Ptr(int64(3)), Body: gh.Ptr("new")},
+		},
+		ReviewComments:  []*gh.PullRequestComment{{ID: gh.Ptr(int64(10)), Body: gh.Ptr("nit")}},
+		Reviews:         []*gh.PullRequestReview{{ID: gh.Ptr(int64(21)), State: gh.Ptr("APPROVED")}},
+		ResolvedThreads: map[int64]bool{10: true},
+	}
+
+	merged := MergeConversation(previous, latest)
+	require.NotNil(t, merged)
+
+	assert.Equal(t, "new title", merged.PullRequest.GetTitle())
+
+	var bodies []string
+	for _, comment := range merged.IssueComments {
+		bodies = append(bodies, comment.GetBody())
+	}
+	assert.Equal(t, []string{"first (edited)", "deleted later", "new"}, bodies)
+
+	assert.Len(t, merged.ReviewComments, 1)
+
+	var reviewIDs []int64
+	for _, review := range merged.Reviews {
+		reviewIDs = append(reviewIDs, review.GetID())
+	}
+	assert.Equal(t, []int64{20, 21}, reviewIDs)
+
+	assert.Equal(t, map[int64]bool{10: true}, merged.ResolvedThreads)
+}
+
+func TestMergeConversation_KeepsPreviousPullRequest(t *testing.T) {
+	previous := &Conversation{PullRequest: &gh.PullRequest{Number: gh.Ptr(7)}}
+	latest := &Conversation{IssueComments: []*gh.IssueComment{{ID: gh.Ptr(int64(1))}}}
+
+	merged := MergeConversation(previous, latest)
+
+	assert.Equal(t, 7, merged.PullRequest.GetNumber())
+	assert.Len(t, merged.IssueComments, 1)
+	assert.Nil(t, merged.ResolvedThreads)
+}
+
+func TestMergeConversation_NilSide(t *testing.T) {
+	conversation := &Conversation{PullRequest: &gh.PullRequest{Number: gh.Ptr(7)}}
+
+	assert.Same(t, conversation, MergeConversation(nil, conversation))
+	assert.Same(t, conversation, MergeConversation(conversation, nil))
+}
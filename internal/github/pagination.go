@@ -0,0 +1,98 @@
+package github
+
+import (
+	"iter"
+
+	"github.com/google/go-github/v77/github"
+)
+
+// listPerPage は一覧系APIで1ページあたりに取得する件数（GitHub APIの上限）
+const listPerPage = 100
+
+// firstPage は一覧系APIの最初のページ番号
+const firstPage = 1
+
+// Page は一覧系APIの1ページ分の取得結果
+type Page[T any] struct {
+	// Number はこのページのページ番号
+	Number int
+	// NextPage は次のページのページ番号。最後のページでは0になる
+	NextPage int
+	Items    []T
+}
+
+/**
+ * 一覧系APIをstartPageからresp.NextPageに従って最後のページまで辿るイテレータを返す
+ * エラーになった場合はそのエラーを1度だけ返して終わる。呼び出し側がループを抜けた時点で以降のページは取得しない
+ */
+func paginate[T any](fetch func(opts github.ListOptions) ([]T, *github.Response, error), startPage int) iter.Seq2[Page[T], error] {
+	return func(yield func(Page[T], error) bool) {
+		opts := github.ListOptions{Page: max(startPage, firstPage), PerPage: listPerPage}
+
+		for {
+			items, resp, err := fetch(opts)
+			if err != nil {
+				yield(Page[T]{Number: opts.Page}, err)
+				return
+			}
+
+			page := Page[T]{Number: opts.Page, Items: items}
+			if resp != nil {
+				page.NextPage = resp.NextPage
+			}
+			if !yield(page, nil) || page.NextPage == 0 {
+				return
+			}
+			opts.Page = page.NextPage
+		}
+	}
+}
+
+// Items はページのイテレータを要素ごとのイテレータに変換する。
+func Items[T any](pages iter.Seq2[Page[T], error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for page, err := range pages {
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page.Items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+/**
+ * 一覧系APIを最後のページまで辿り、取得したページごとにhandleを呼び出す
+ * handleがエラーを返した場合はその時点で打ち切る
+ */
+func forEachPage[T any](fetch func(opts github.ListOptions) ([]T, *github.Response, error), handle func(page []T) error) error {
+	for page, err := range paginate(fetch, firstPage) {
+		if err != nil {
+			return err
+		}
+		if err := handle(page.Items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * forEachPageで取得したすべてのページを1つのスライスにまとめる
+ */
+func collectAllPages[T any](fetch func(opts github.ListOptions) ([]T, *github.Response, error)) ([]T, error) {
+	var all []T
+	err := forEachPage(fetch, func(page []T) error {
+		all = append(all, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
@@ -0,0 +1,120 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePages はpages[i]をi+1ページ目として返すfetch関数と、要求されたページ番号の記録を作る
+func fakePages(pages [][]int) (func(opts gh.ListOptions) ([]int, *gh.Response, error), *[]int) {
+	var requested []int
+	return func(opts gh.ListOptions) ([]int, *gh.Response, error) {
+		requested = append(requested, opts.Page)
+		resp := &gh.Response{}
+		if opts.Page < len(pages) {
+			resp.NextPage = opts.Page + 1
+		}
+		return pages[opts.Page-1], resp, nil
+	}, &requested
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name          string
+		startPage     int
+		stopAfter     int
+		wantNumbers   []int
+		wantRequested []int
+	}{
+		{name: "from the first page", startPage: 1, wantNumbers: []int{1, 2, 3}, wantRequested: []int{1, 2, 3}},
+		{name: "zero start page means the first page", startPage: 0, wantNumbers: []int{1, 2, 3}, wantRequested: []int{1, 2, 3}},
+		{name: "resume from a later page", startPage: 2, wantNumbers: []int{2, 3}, wantRequested: []int{2, 3}},
+		{name: "stop early", startPage: 1, stopAfter: 1, wantNumbers: []int{1}, wantRequested: []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetch, requested := fakePages([][]int{{1, 2}, {3}, {4}})
+
+			var numbers []int
+			for page, err := range paginate(fetch, tt.startPage) {
+				require.NoError(t, err)
+				numbers = append(numbers, page.Number)
+				if tt.stopAfter > 0 && len(numbers) == tt.stopAfter {
+					break
+				}
+			}
+
+			assert.Equal(t, tt.wantNumbers, numbers)
+			assert.Equal(t, tt.wantRequested, *requested)
+		})
+	}
+}
+
+func TestPaginate_StopsAtError(t *testing.T) {
+	fetchErr := errors.New("boom")
+	fetch := func(opts gh.ListOptions) ([]int, *gh.Response, error) {
+		if opts.Page == 2 {
+			return nil, nil, fetchErr
+		}
+		return []int{opts.Page}, &gh.Response{NextPage: opts.Page + 1}, nil
+	}
+
+	var items []int
+	var gotErr error
+	for item, err := range Items(paginate(fetch, firstPage)) {
+		if err != nil {
+			gotErr = err
+			continue
+		}
+		items = append(items, item)
+	}
+
+	assert.Equal(t, []int{1}, items)
+	assert.ErrorIs(t, gotErr, fetchErr)
+}
+
+func TestPullRequestPages_ResumesFromStartPage(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "all", r.URL.Query().Get("state"))
+		assert.Equal(t, "created", r.URL.Query().Get("sort"))
+		assert.Equal(t, "asc", r.URL.Query().Get("direction"))
+		writePagedJSON(t, w, r, []string{`[{"number":1}]`, `[{"number":2}]`, `[{"number":3}]`})
+	})
+	client := newTestClient(t, mux)
+
+	var pageNumbers, prNumbers []int
+	for page, err := range client.PullRequestPages(context.Background(), 2) {
+		require.NoError(t, err)
+		pageNumbers = append(pageNumbers, page.Number)
+		for _, pr := range page.Items {
+			prNumbers = append(prNumbers, pr.GetNumber())
+		}
+	}
+
+	assert.Equal(t, []int{2, 3}, pageNumbers)
+	assert.Equal(t, []int{2, 3}, prNumbers)
+}
+
+func TestPullRequests_YieldsEachPullRequest(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+		writePagedJSON(t, w, r, []string{`[{"number":1},{"number":2}]`, `[{"number":3}]`})
+	})
+	client := newTestClient(t, mux)
+
+	var prNumbers []int
+	for pr, err := range client.PullRequests(context.Background()) {
+		require.NoError(t, err)
+		prNumbers = append(prNumbers, pr.GetNumber())
+	}
+
+	assert.Equal(t, []int{1, 2, 3}, prNumbers)
+}
@@ -3,7 +3,7 @@ package github
 import (
 	"context"
 	"fmt"
-	"strings"
+	"iter"
 	"time"
 
 	"github.com/google/go-github/v77/github"
@@ -13,164 +13,104 @@ import (
  * /search/issueを使ってコメントにkeywordが含まれるPRを検索する
  * PR番号のスライスを返す（API呼び出しを削減するため、完全なPRオブジェクトは取得しない）
  */
-func (c *Client) SearchPullRequestsWithCommentKeyword(keyword string) ([]int, error) {
-	ctx := context.Background()
-
-	query := fmt.Sprintf("repo:%s/%s in:comments type:pr is:merged %s", c.Owner, c.Name, keyword)
-
-	var allPRNumbers []int
-	page := 1
-	perPage := 100
-
-	for {
-		opts := &github.SearchOptions{
-			ListOptions: github.ListOptions{
-				Page:    page,
-				PerPage: perPage,
-			},
-		}
+func (c *Client) SearchPullRequestsWithCommentKeyword(ctx context.Context, keyword string) ([]int, error) {
+	return c.SearchPullRequests(ctx, DefaultSearchQuery(keyword))
+}
 
-		result, resp, err := c.github.Search.Issues(ctx, query, opts)
-		if err != nil {
-			return nil, fmt.Errorf("failed to search issues: %w", err)
-		}
+/**
+ * /search/issueを使ってqueryに一致するPRを検索し、PR番号のスライスを返す
+ * 検索結果が1000件を超える場合はマージ日時（マージ済みに限定しない場合は作成日時）で期間を分割し、重複を除いてまとめる
+ */
+func (c *Client) SearchPullRequests(ctx context.Context, query SearchQuery) ([]int, error) {
+	results, err := c.SearchPullRequestResults(ctx, query)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, issue := range result.Issues {
-			if issue.PullRequestLinks == nil {
-				continue
-			}
+	prNumbers := make([]int, len(results))
+	for i, result := range results {
+		prNumbers[i] = result.Number
+	}
+	return prNumbers, nil
+}
 
-			if issue.Number == nil {
-				continue
-			}
+// SearchResult は検索でヒットしたPRの番号と最終更新日時
+type SearchResult struct {
+	Number    int
+	UpdatedAt time.Time
+}
 
-			allPRNumbers = append(allPRNumbers, *issue.Number)
-		}
+/**
+ * SearchPullRequestsと同じ検索を行い、PR番号に加えて最終更新日時を返す
+ * 差分同期で次回の検索の起点（最後に見たupdated_at）を記録するために使う
+ */
+func (c *Client) SearchPullRequestResults(ctx context.Context, query SearchQuery) ([]SearchResult, error) {
+	field := query.shardField()
 
-		if resp.NextPage == 0 {
-			break
-		}
-		page = resp.NextPage
+	results, err := c.searchPullRequestNumbers(ctx, query.build(c.Owner, c.Name, field), field, query.shardRange())
+	if err != nil {
+		return nil, fmt.Errorf("failed to search issues: %w", err)
 	}
 
-	return allPRNumbers, nil
+	return results, nil
 }
 
 /**
  * リポジトリ内のすべてのPull Requestを取得する
- * レート制限エラーが発生した場合、1時間5分待機してからリトライする
+ * レート制限に達した場合はリセット時刻まで待機してから同じページをリトライする
+ * 途中でエラーになった場合（ctxのキャンセルを含む）は、それまでに取得できたPRもエラーと一緒に返す
+ * PRの数が多いリポジトリでは、すべてをメモリに載せずに済むPullRequestsやPullRequestPagesを使う
  */
-func (c *Client) ListAllPullRequests() ([]*github.PullRequest, error) {
-	ctx := context.Background()
-
+func (c *Client) ListAllPullRequests(ctx context.Context) ([]*github.PullRequest, error) {
 	var allPRs []*github.PullRequest
-	page := 1
-	perPage := 100
 
 	fmt.Printf("Starting to fetch pull requests...\n")
 
-	for {
-		var prs []*github.PullRequest
-		var resp *github.Response
-		var err error
-
-		// レート制限エラーが発生するまでリトライ
-		for {
-			opts := &github.PullRequestListOptions{
-				State: "all", // open, closed, all
-				ListOptions: github.ListOptions{
-					Page:    page,
-					PerPage: perPage,
-				},
-			}
-
-			fmt.Printf("Fetching page %d (per page: %d)...\n", page, perPage)
-			prs, resp, err = c.github.PullRequests.List(ctx, c.Owner, c.Name, opts)
-			if err != nil {
-				if isRateLimitError(err) {
-					// レート制限エラーの場合、1時間5分待機してからリトライ
-					waitDuration := 65 * time.Minute // 1時間5分
-					fmt.Printf("\n⚠️  Rate limit exceeded. Waiting %v before retrying page %d...\n", waitDuration, page)
-					waitForRateLimit(waitDuration)
-					fmt.Printf("Retrying page %d...\n", page)
-					continue // リトライ
-				}
-				return nil, fmt.Errorf("failed to list pull requests: %w", err)
-			}
-			break // 成功したらループを抜ける
-		}
-
-		allPRs = append(allPRs, prs...)
-		fmt.Printf("  ✓ Fetched %d PRs from page %d (total: %d PRs)\n", len(prs), page, len(allPRs))
-
-		if resp.NextPage == 0 {
-			fmt.Printf("Reached last page. Total PRs fetched: %d\n", len(allPRs))
-			break
+	for page, err := range c.PullRequestPages(ctx, firstPage) {
+		if err != nil {
+			return allPRs, err
 		}
-		page = resp.NextPage
+		allPRs = append(allPRs, page.Items...)
+		fmt.Printf("  ✓ Fetched %d PRs (total: %d PRs)\n", len(page.Items), len(allPRs))
 	}
 
+	fmt.Printf("Reached last page. Total PRs fetched: %d\n", len(allPRs))
 	return allPRs, nil
 }
 
 /**
- * エラーがレート制限エラーかどうかを判定する
+ * リポジトリ内のPull Requestを1件ずつ返すイテレータ
+ * ページは必要になった時点で取得するため、途中でループを抜ければ残りのページは取得しない
  */
-func isRateLimitError(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	errStr := err.Error()
-
-	// HTTPレスポンスエラーの場合
-	if strings.Contains(errStr, "403") || strings.Contains(errStr, "429") {
-		return true
-	}
-
-	// go-githubのレート制限エラーメッセージをチェック
-	if strings.Contains(strings.ToLower(errStr), "rate limit") {
-		return true
-	}
-
-	// RateLimitError型をチェック
-	if rateLimitErr, ok := err.(*github.RateLimitError); ok {
-		return rateLimitErr != nil
-	}
-
-	// AbuseRateLimitError型をチェック
-	if abuseRateLimitErr, ok := err.(*github.AbuseRateLimitError); ok {
-		return abuseRateLimitErr != nil
-	}
-
-	return false
+func (c *Client) PullRequests(ctx context.Context) iter.Seq2[*github.PullRequest, error] {
+	return Items(c.PullRequestPages(ctx, firstPage))
 }
 
 /**
- * 指定時間待機する（レート制限リセット待ち）
+ * リポジトリ内のPull RequestをstartPageから1ページずつ返すイテレータ
+ * 作成日時の古い順に並べるため、新しいPRが作られても既存のページの中身はずれず、
+ * 途中で中断しても最後に処理し終えたページの次から再開できる
  */
-func waitForRateLimit(waitDuration time.Duration) {
-	// 待機中は定期的に進捗を表示
-	ticker := time.NewTicker(10 * time.Minute)
-	defer ticker.Stop()
-
-	done := make(chan bool)
-	go func() {
-		time.Sleep(waitDuration)
-		done <- true
-	}()
-
-	elapsed := time.Duration(0)
-	for {
-		select {
-		case <-done:
-			fmt.Printf("Rate limit wait completed. Resuming processing...\n")
-			return
-		case <-ticker.C:
-			elapsed += 10 * time.Minute
-			remaining := waitDuration - elapsed
-			if remaining > 0 {
-				fmt.Printf("Still waiting... %v remaining\n", remaining)
+func (c *Client) PullRequestPages(ctx context.Context, startPage int) iter.Seq2[Page[*github.PullRequest], error] {
+	pages := paginate(func(opts github.ListOptions) ([]*github.PullRequest, *github.Response, error) {
+		fmt.Printf("Fetching page %d (per page: %d)...\n", opts.Page, opts.PerPage)
+		return callWithRateLimit(ctx, c.limiter, RateResourceCore, func() ([]*github.PullRequest, *github.Response, error) {
+			return c.github.PullRequests.List(ctx, c.Owner, c.Name, &github.PullRequestListOptions{
+				State:       "all", // open, closed, all
+				Sort:        "created",
+				Direction:   "asc",
+				ListOptions: opts,
+			})
+		})
+	}, startPage)
+
+	return func(yield func(Page[*github.PullRequest], error) bool) {
+		for page, err := range pages {
+			if err != nil {
+				err = fmt.Errorf("failed to list pull requests: %w", err)
+			}
+			if !yield(page, err) {
+				return
 			}
 		}
 	}
@@ -179,10 +119,10 @@ func waitForRateLimit(waitDuration time.Duration) {
 /**
  * 指定されたPR番号のPull Requestの詳細を取得する
  */
-func (c *Client) GetPullRequest(prNumber int) (*github.PullRequest, error) {
-	ctx := context.Background()
-
-	pr, _, err := c.github.PullRequests.Get(ctx, c.Owner, c.Name, prNumber)
+func (c *Client) GetPullRequest(ctx context.Context, prNumber int) (*github.PullRequest, error) {
+	pr, _, err := callWithRateLimit(ctx, c.limiter, RateResourceCore, func() (*github.PullRequest, *github.Response, error) {
+		return c.github.PullRequests.Get(ctx, c.Owner, c.Name, prNumber)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pull request #%d: %w", prNumber, err)
 	}
@@ -0,0 +1,274 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v77/github"
+)
+
+// GitHub APIのレート制限リソース名（X-RateLimit-Resourceヘッダーの値）
+const (
+	RateResourceCore    = "core"
+	RateResourceSearch  = "search"
+	RateResourceGraphQL = "graphql"
+)
+
+const (
+	// 残量がlimitのこの割合以下になったら、リセットまでの残り時間に合わせてリクエスト間隔をあける
+	rateSlowdownRatio = 0.1
+	// リセット時刻ちょうどではまだ回復していないことがあるため、少し余分に待つ
+	rateResetMargin = time.Second
+	// Retry-Afterが付いていない二次レート制限では、GitHubの推奨どおり最低1分待つ
+	defaultSecondaryRateLimitWait = time.Minute
+	// この時間以上待つ場合は待機の開始と進捗をログに出す
+	longRateLimitWait = time.Minute
+	// 長時間待機中に進捗を表示する間隔
+	rateLimitProgressInterval = 10 * time.Minute
+)
+
+const (
+	headerRetryAfter         = "Retry-After"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+)
+
+// rateBudget は1つのリソースについて最後に観測したレート制限の状態
+type rateBudget struct {
+	limit        int
+	remaining    int
+	reset        time.Time
+	blockedUntil time.Time
+}
+
+// RateLimiter はレスポンスヘッダーとレート制限エラーからリソースごとの残量を追跡し、
+// リクエスト前に必要なだけ待機する。複数のgoroutineから共有できる。
+type RateLimiter struct {
+	mu      sync.Mutex
+	budgets map[string]*rateBudget
+	onWait  func(resource string, wait time.Duration)
+
+	now   func() time.Time
+	sleep func(ctx context.Context, wait time.Duration) error
+}
+
+// NewRateLimiter は空のRateLimiterを作成する。
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		budgets: make(map[string]*rateBudget),
+		now:     time.Now,
+		sleep:   sleepWithProgress,
+	}
+}
+
+// OnLongWait は長時間の待機に入る直前に呼ばれる関数を登録する。
+// 待機前に進捗を保存したい場合に使う。
+func (l *RateLimiter) OnLongWait(fn func(resource string, wait time.Duration)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onWait = fn
+}
+
+// Wait はresourceの残量に応じて必要な時間だけ待機する。
+// 残量が尽きていればリセット時刻まで、残り少なければリセットまで均等に間隔をあける。
+// 待機中にctxがキャンセルされた場合はctxのエラーを返す。
+func (l *RateLimiter) Wait(ctx context.Context, resource string) error {
+	l.mu.Lock()
+	wait := l.delayLocked(resource)
+	onWait := l.onWait
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	if wait >= longRateLimitWait && onWait != nil {
+		onWait(resource, wait)
+	}
+	return l.sleep(ctx, wait)
+}
+
+// Observe はレスポンスのレート制限ヘッダーを記録する。
+// X-RateLimit-Resourceが返されていればそちらを優先する。
+func (l *RateLimiter) Observe(resource string, resp *github.Response) {
+	if resp == nil || resp.Rate.Limit == 0 {
+		return
+	}
+	if resp.Rate.Resource != "" {
+		resource = resp.Rate.Resource
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	budget := l.budgetLocked(resource)
+	budget.limit = resp.Rate.Limit
+	budget.remaining = resp.Rate.Remaining
+	budget.reset = resp.Rate.Reset.Time
+}
+
+// Backoff はerrがレート制限によるものであれば再開可能な時刻を記録してtrueを返す。
+// レート制限以外のエラー（権限不足の403など）はfalseを返し、呼び出し側はリトライしない。
+func (l *RateLimiter) Backoff(resource string, err error) bool {
+	until, ok := l.retryTime(err)
+	if !ok {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	budget := l.budgetLocked(resource)
+	if until.After(budget.blockedUntil) {
+		budget.blockedUntil = until
+	}
+	return true
+}
+
+func (l *RateLimiter) retryTime(err error) (time.Time, bool) {
+	now := l.now()
+
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		if rateLimitErr.Rate.Reset.IsZero() {
+			return now.Add(defaultSecondaryRateLimitWait), true
+		}
+		return rateLimitErr.Rate.Reset.Time.Add(rateResetMargin), true
+	}
+
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		if abuseErr.RetryAfter != nil {
+			return now.Add(*abuseErr.RetryAfter), true
+		}
+		return now.Add(defaultSecondaryRateLimitWait), true
+	}
+
+	var errorResponse *github.ErrorResponse
+	if errors.As(err, &errorResponse) && errorResponse.Response != nil {
+		return retryTimeFromResponse(errorResponse.Response, now)
+	}
+
+	return time.Time{}, false
+}
+
+// retryTimeFromResponse はgo-githubがレート制限エラーに分類しない429/403のヘッダーから再開時刻を求める
+func retryTimeFromResponse(resp *http.Response, now time.Time) (time.Time, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden {
+		return time.Time{}, false
+	}
+
+	if v := resp.Header.Get(headerRetryAfter); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			return now.Add(time.Duration(seconds) * time.Second), true
+		}
+	}
+
+	if resp.Header.Get(headerRateLimitRemaining) == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get(headerRateLimitReset), 10, 64); err == nil {
+			return time.Unix(reset, 0).Add(rateResetMargin), true
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return now.Add(defaultSecondaryRateLimitWait), true
+	}
+	return time.Time{}, false
+}
+
+func (l *RateLimiter) budgetLocked(resource string) *rateBudget {
+	budget, ok := l.budgets[resource]
+	if !ok {
+		budget = &rateBudget{}
+		l.budgets[resource] = budget
+	}
+	return budget
+}
+
+func (l *RateLimiter) delayLocked(resource string) time.Duration {
+	budget, ok := l.budgets[resource]
+	if !ok {
+		return 0
+	}
+
+	now := l.now()
+	wait := budget.blockedUntil.Sub(now)
+
+	untilReset := budget.reset.Sub(now)
+	if untilReset <= 0 || budget.limit == 0 {
+		return wait
+	}
+
+	var paced time.Duration
+	switch {
+	case budget.remaining <= 0:
+		paced = untilReset + rateResetMargin
+	case float64(budget.remaining) <= float64(budget.limit)*rateSlowdownRatio:
+		paced = untilReset / time.Duration(budget.remaining)
+	}
+
+	// 待機する分のリクエストを先取りして、並行する呼び出しが同じ残量を当てにしないようにする
+	if budget.remaining > 0 {
+		budget.remaining--
+	}
+
+	return max(wait, paced)
+}
+
+/**
+ * 指定時間待機する（レート制限リセット待ち）
+ * 長時間の待機では定期的に残り時間を表示する
+ * ctxがキャンセルされた場合は待機を打ち切ってctxのエラーを返す
+ */
+func sleepWithProgress(ctx context.Context, waitDuration time.Duration) error {
+	long := waitDuration >= longRateLimitWait
+
+	if long {
+		fmt.Printf("Rate limit reached. Waiting %v before resuming...\n", waitDuration.Round(time.Second))
+	}
+
+	deadline := time.Now().Add(waitDuration)
+	ticker := time.NewTicker(rateLimitProgressInterval)
+	defer ticker.Stop()
+
+	timer := time.NewTimer(waitDuration)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if long {
+				fmt.Printf("Rate limit wait completed. Resuming processing...\n")
+			}
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			fmt.Printf("Still waiting... %v remaining\n", time.Until(deadline).Round(time.Second))
+		}
+	}
+}
+
+/**
+ * レート制限を考慮してGitHub APIを呼び出す
+ * 呼び出し前に残量に応じて待機し、レート制限エラーであればリセットを待ってリトライする
+ * 待機中にctxがキャンセルされた場合はリトライせずにctxのエラーを返す
+ */
+func callWithRateLimit[T any](ctx context.Context, limiter *RateLimiter, resource string, call func() (T, *github.Response, error)) (T, *github.Response, error) {
+	for {
+		if err := limiter.Wait(ctx, resource); err != nil {
+			var zero T
+			return zero, nil, err
+		}
+
+		result, resp, err := call()
+		limiter.Observe(resource, resp)
+		if err == nil || !limiter.Backoff(resource, err) {
+			return result, resp, err
+		}
+	}
+}
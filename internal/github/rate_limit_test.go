@@ -0,0 +1,245 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock はRateLimiterの時刻と待機を置き換えるテスト用の時計
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (f *fakeClock) install(l *RateLimiter) {
+	l.now = func() time.Time { return f.now }
+	l.sleep = func(_ context.Context, d time.Duration) error {
+		f.sleeps = append(f.sleeps, d)
+		f.now = f.now.Add(d)
+		return nil
+	}
+}
+
+func newFakeRateLimiter() (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := NewRateLimiter()
+	clock.install(limiter)
+	return limiter, clock
+}
+
+func rateResponse(resource string, limit, remaining int, reset time.Time) *gh.Response {
+	return &gh.Response{Rate: gh.Rate{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     gh.Timestamp{Time: reset},
+		Resource:  resource,
+	}}
+}
+
+func TestRateLimiter_WaitsUntilResetWhenExhausted(t *testing.T) {
+	limiter, clock := newFakeRateLimiter()
+	reset := clock.now.Add(20 * time.Minute)
+	limiter.Observe(RateResourceCore, rateResponse("", 5000, 0, reset))
+
+	limiter.Wait(context.Background(), RateResourceCore)
+
+	require.Len(t, clock.sleeps, 1)
+	assert.Equal(t, 20*time.Minute+rateResetMargin, clock.sleeps[0])
+}
+
+func TestRateLimiter_SlowsDownBeforeExhaustion(t *testing.T) {
+	limiter, clock := newFakeRateLimiter()
+	reset := clock.now.Add(10 * time.Minute)
+	limiter.Observe(RateResourceCore, rateResponse("", 5000, 100, reset))
+
+	limiter.Wait(context.Background(), RateResourceCore)
+
+	require.Len(t, clock.sleeps, 1)
+	assert.Equal(t, 10*time.Minute/100, clock.sleeps[0])
+}
+
+func TestRateLimiter_DoesNotWaitWithPlentyOfBudget(t *testing.T) {
+	limiter, clock := newFakeRateLimiter()
+	limiter.Observe(RateResourceCore, rateResponse("", 5000, 4000, clock.now.Add(time.Hour)))
+
+	limiter.Wait(context.Background(), RateResourceCore)
+
+	assert.Empty(t, clock.sleeps)
+}
+
+func TestRateLimiter_TracksResourcesSeparately(t *testing.T) {
+	limiter, clock := newFakeRateLimiter()
+	limiter.Observe(RateResourceCore, rateResponse(RateResourceSearch, 30, 0, clock.now.Add(time.Minute)))
+
+	limiter.Wait(context.Background(), RateResourceCore)
+	assert.Empty(t, clock.sleeps)
+
+	limiter.Wait(context.Background(), RateResourceSearch)
+	assert.Len(t, clock.sleeps, 1)
+}
+
+func TestRateLimiter_Backoff(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	retryAfter := 90 * time.Second
+
+	tests := []struct {
+		name      string
+		err       error
+		wantRetry bool
+		wantWait  time.Duration
+	}{
+		{
+			name:      "primary rate limit",
+			err:       &gh.RateLimitError{Rate: gh.Rate{Reset: gh.Timestamp{Time: now.Add(5 * time.Minute)}}},
+			wantRetry: true,
+			wantWait:  5*time.Minute + rateResetMargin,
+		},
+		{
+			name:      "secondary rate limit with Retry-After",
+			err:       &gh.AbuseRateLimitError{RetryAfter: &retryAfter},
+			wantRetry: true,
+			wantWait:  retryAfter,
+		},
+		{
+			name:      "secondary rate limit without Retry-After",
+			err:       &gh.AbuseRateLimitError{},
+			wantRetry: true,
+			wantWait:  defaultSecondaryRateLimitWait,
+		},
+		{
+			name:      "429 with Retry-After header",
+			err:       errorResponse(http.StatusTooManyRequests, map[string]string{headerRetryAfter: "30"}),
+			wantRetry: true,
+			wantWait:  30 * time.Second,
+		},
+		{
+			name: "429 with exhausted remaining",
+			err: errorResponse(http.StatusTooManyRequests, map[string]string{
+				headerRateLimitRemaining: "0",
+				headerRateLimitReset:     strconv.FormatInt(now.Add(2*time.Minute).Unix(), 10),
+			}),
+			wantRetry: true,
+			wantWait:  2*time.Minute + rateResetMargin,
+		},
+		{
+			name:      "403 permission denied",
+			err:       errorResponse(http.StatusForbidden, nil),
+			wantRetry: false,
+		},
+		{
+			name:      "404 not found",
+			err:       errorResponse(http.StatusNotFound, nil),
+			wantRetry: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter()
+			clock := &fakeClock{now: now}
+			clock.install(limiter)
+
+			retry := limiter.Backoff(RateResourceCore, tt.err)
+			assert.Equal(t, tt.wantRetry, retry)
+			if !tt.wantRetry {
+				return
+			}
+
+			limiter.Wait(context.Background(), RateResourceCore)
+			require.Len(t, clock.sleeps, 1)
+			assert.Equal(t, tt.wantWait, clock.sleeps[0])
+		})
+	}
+}
+
+func TestRateLimiter_OnLongWait(t *testing.T) {
+	limiter, clock := newFakeRateLimiter()
+	limiter.Observe(RateResourceCore, rateResponse("", 5000, 0, clock.now.Add(time.Hour)))
+
+	var notified time.Duration
+	limiter.OnLongWait(func(resource string, wait time.Duration) {
+		assert.Equal(t, RateResourceCore, resource)
+		notified = wait
+	})
+	limiter.Wait(context.Background(), RateResourceCore)
+
+	assert.Equal(t, time.Hour+rateResetMargin, notified)
+}
+
+func TestClient_RetriesAfterRateLimitReset(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set(headerRateLimitRemaining, "0")
+			// go-githubの事前チェックで止まらないよう、リセット時刻は現在時刻にしておく
+			w.Header().Set(headerRateLimitReset, strconv.FormatInt(time.Now().Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		writePagedJSON(t, w, r, []string{`[{"id":1}]`})
+	})
+	client := newTestClient(t, mux)
+
+	var slept []time.Duration
+	client.limiter.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+
+	comments, err := client.GetComments(context.Background(), 7)
+	require.NoError(t, err)
+	assert.Len(t, comments, 1)
+	assert.Equal(t, 2, requests)
+	assert.Len(t, slept, 1)
+}
+
+func TestClient_DoesNotRetryPermissionDenied(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	})
+	client := newTestClient(t, mux)
+
+	_, err := client.GetComments(context.Background(), 7)
+	require.Error(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+func errorResponse(status int, headers map[string]string) *gh.ErrorResponse {
+	header := http.Header{}
+	for key, value := range headers {
+		header.Set(key, value)
+	}
+	return &gh.ErrorResponse{Response: &http.Response{StatusCode: status, Header: header}}
+}
+
+func TestClient_StopsWaitingWhenContextIsCancelled(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set(headerRetryAfter, "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	client := newTestClient(t, mux)
+	client.limiter.OnLongWait(func(string, time.Duration) {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.GetComments(ctx, 7)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, requests)
+}
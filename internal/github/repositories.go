@@ -0,0 +1,140 @@
+package github
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v77/github"
+)
+
+// repositoryListCommentPrefix はリポジトリ一覧ファイルでコメントとして無視する行の接頭辞
+const repositoryListCommentPrefix = "#"
+
+// RepositoryFilter はクロール対象のリポジトリを絞り込む条件。ゼロ値はすべてのリポジトリを対象にする。
+type RepositoryFilter struct {
+	// Languages のいずれかを主要言語とするリポジトリだけを対象にする（大文字小文字は区別しない）
+	Languages       []string
+	ExcludeArchived bool
+	ExcludeForks    bool
+	MinStars        int
+}
+
+// IsZero は絞り込み条件がまったく指定されていないかどうかを返す。
+func (f RepositoryFilter) IsZero() bool {
+	return len(f.Languages) == 0 && !f.ExcludeArchived && !f.ExcludeForks && f.MinStars == 0
+}
+
+// Match はrepoが条件を満たすかどうかを返す。
+func (f RepositoryFilter) Match(repo *github.Repository) bool {
+	if f.ExcludeArchived && repo.GetArchived() {
+		return false
+	}
+	if f.ExcludeForks && repo.GetFork() {
+		return false
+	}
+	if repo.GetStargazersCount() < f.MinStars {
+		return false
+	}
+	if len(f.Languages) == 0 {
+		return true
+	}
+	for _, language := range f.Languages {
+		if strings.EqualFold(strings.TrimSpace(language), repo.GetLanguage()) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Organizationのリポジトリをすべて取得する
+ */
+func (c *Client) ListOrganizationRepositories(ctx context.Context, org string) ([]*github.Repository, error) {
+	repos, err := collectAllPages(func(opts github.ListOptions) ([]*github.Repository, *github.Response, error) {
+		return callWithRateLimit(ctx, c.limiter, RateResourceCore, func() ([]*github.Repository, *github.Response, error) {
+			return c.github.Repositories.ListByOrg(ctx, org, &github.RepositoryListByOrgOptions{ListOptions: opts})
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repositories for organization %s: %w", org, err)
+	}
+	return repos, nil
+}
+
+/**
+ * ユーザーが所有するリポジトリをすべて取得する
+ */
+func (c *Client) ListUserRepositories(ctx context.Context, user string) ([]*github.Repository, error) {
+	repos, err := collectAllPages(func(opts github.ListOptions) ([]*github.Repository, *github.Response, error) {
+		return callWithRateLimit(ctx, c.limiter, RateResourceCore, func() ([]*github.Repository, *github.Response, error) {
+			return c.github.Repositories.ListByUser(ctx, user, &github.RepositoryListByUserOptions{Type: "owner", ListOptions: opts})
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repositories for user %s: %w", user, err)
+	}
+	return repos, nil
+}
+
+/**
+ * 指定したリポジトリの情報（言語・スター数・アーカイブ状態など）を取得する
+ */
+func (c *Client) GetRepository(ctx context.Context, repo RepositoryRef) (*github.Repository, error) {
+	result, _, err := callWithRateLimit(ctx, c.limiter, RateResourceCore, func() (*github.Repository, *github.Response, error) {
+		return c.github.Repositories.Get(ctx, repo.Owner, repo.Name)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository %s: %w", repo, err)
+	}
+	return result, nil
+}
+
+/**
+ * リポジトリをfilterで絞り込み、hostのRepositoryRefに変換する
+ */
+func FilterRepositories(repos []*github.Repository, host string, filter RepositoryFilter) []RepositoryRef {
+	var refs []RepositoryRef
+	for _, repo := range repos {
+		if repo == nil || !filter.Match(repo) {
+			continue
+		}
+		refs = append(refs, RepositoryRef{Host: normalizeHost(host), Owner: repo.GetOwner().GetLogin(), Name: repo.GetName()})
+	}
+	return refs
+}
+
+// LoadRepositoryList はfileから1行に1つのリポジトリ参照を読み込む。空行と#で始まる行は無視する。
+func LoadRepositoryList(file string) ([]RepositoryRef, error) {
+	return ReadRepositoryList(file, ParseRepositoryRef)
+}
+
+// ReadRepositoryList はLoadRepositoryListと同じ形式のファイルを、各行をparseで解釈して読み込む。
+// GitLabのようにリポジトリの指定方法が異なる取得元の一覧に使う。
+func ReadRepositoryList(file string, parse func(ref string) (RepositoryRef, error)) ([]RepositoryRef, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository list: %w", err)
+	}
+	defer f.Close()
+
+	var refs []RepositoryRef
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, repositoryListCommentPrefix) {
+			continue
+		}
+		ref, err := parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, lineNumber, err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read repository list: %w", err)
+	}
+	return refs, nil
+}
@@ -0,0 +1,90 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryFilter_Match(t *testing.T) {
+	repo := &gh.Repository{
+		Language:        gh.Ptr("Go"),
+		StargazersCount: gh.Ptr(50),
+		Archived:        gh.Ptr(true),
+		Fork:            gh.Ptr(false),
+	}
+
+	tests := []struct {
+		name   string
+		filter RepositoryFilter
+		want   bool
+	}{
+		{name: "zero filter", filter: RepositoryFilter{}, want: true},
+		{name: "language matches case-insensitively", filter: RepositoryFilter{Languages: []string{"python", "go"}}, want: true},
+		{name: "language does not match", filter: RepositoryFilter{Languages: []string{"Rust"}}, want: false},
+		{name: "archived excluded", filter: RepositoryFilter{ExcludeArchived: true}, want: false},
+		{name: "forks excluded", filter: RepositoryFilter{ExcludeForks: true}, want: true},
+		{name: "enough stars", filter: RepositoryFilter{MinStars: 50}, want: true},
+		{name: "too few stars", filter: RepositoryFilter{MinStars: 51}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Match(repo))
+		})
+	}
+}
+
+func TestClient_ListOrganizationRepositories(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orgs/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+		writePagedJSON(t, w, r, []string{
+			`[{"name":"api","owner":{"login":"acme"},"language":"Go","stargazers_count":10}]`,
+			`[{"name":"old","owner":{"login":"acme"},"language":"Go","archived":true},{"name":"web","owner":{"login":"acme"},"language":"TypeScript"}]`,
+		})
+	})
+	client := newTestClient(t, mux)
+
+	repos, err := client.ListOrganizationRepositories(context.Background(), "acme")
+	require.NoError(t, err)
+	require.Len(t, repos, 3)
+
+	refs := FilterRepositories(repos, DefaultHost, RepositoryFilter{Languages: []string{"go"}, ExcludeArchived: true})
+	assert.Equal(t, []RepositoryRef{{Host: DefaultHost, Owner: "acme", Name: "api"}}, refs)
+}
+
+func TestClient_ForRepository(t *testing.T) {
+	client, err := NewHostClient("", "", nil)
+	require.NoError(t, err)
+
+	repoClient, err := client.ForRepository(RepositoryRef{Owner: "owner", Name: "repo"})
+	require.NoError(t, err)
+	assert.Equal(t, "owner", repoClient.Owner)
+	assert.Equal(t, "repo", repoClient.Name)
+	assert.Same(t, client.RateLimiter(), repoClient.RateLimiter())
+
+	_, err = client.ForRepository(RepositoryRef{Host: "ghe.example.com", Owner: "owner", Name: "repo"})
+	require.Error(t, err)
+}
+
+func TestLoadRepositoryList(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "repos.txt")
+	require.NoError(t, os.WriteFile(file, []byte("# targets\nowner/one\n\nhttps://ghe.example.com/team/two\n"), 0644))
+
+	refs, err := LoadRepositoryList(file)
+	require.NoError(t, err)
+	assert.Equal(t, []RepositoryRef{
+		{Host: DefaultHost, Owner: "owner", Name: "one"},
+		{Host: "ghe.example.com", Owner: "team", Name: "two"},
+	}, refs)
+
+	require.NoError(t, os.WriteFile(file, []byte("owner/one\ninvalid\n"), 0644))
+	_, err = LoadRepositoryList(file)
+	require.ErrorContains(t, err, ":2:")
+}
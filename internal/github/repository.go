@@ -3,43 +3,121 @@ package github
 import (
 	"fmt"
 	"net/url"
+	"path/filepath"
 	"strings"
 )
 
-// ParseRepository parses a repository reference such as:
+// DefaultHost is the host of public GitHub.
+const DefaultHost = "github.com"
+
+// wwwPrefix is stripped from hosts so that www.github.com and github.com are the same repository.
+const wwwPrefix = "www."
+
+// RepositoryRef identifies a repository on GitHub or on a GitHub Enterprise Server host.
+type RepositoryRef struct {
+	Host  string
+	Owner string
+	Name  string
+}
+
+// IsEnterprise reports whether the repository lives on a GitHub Enterprise Server host.
+func (r RepositoryRef) IsEnterprise() bool {
+	return r.Host != DefaultHost
+}
+
+// String returns owner/name for github.com and host/owner/name for enterprise hosts.
+func (r RepositoryRef) String() string {
+	if r.IsEnterprise() {
+		return fmt.Sprintf("%s/%s/%s", r.Host, r.Owner, r.Name)
+	}
+	return fmt.Sprintf("%s/%s", r.Owner, r.Name)
+}
+
+// CanonicalURL returns the HTTPS URL of the repository on its host.
+func (r RepositoryRef) CanonicalURL() string {
+	return fmt.Sprintf("https://%s/%s/%s", r.Host, r.Owner, r.Name)
+}
+
+// DataDir returns the directory under root where data for the repository is stored.
+// github.com repositories keep the historical root/owner/name layout, while enterprise
+// repositories are stored under root/host/owner/name. GitHub logins cannot contain dots,
+// so an enterprise host never collides with a public owner.
+func (r RepositoryRef) DataDir(root string) string {
+	if r.IsEnterprise() {
+		return filepath.Join(root, r.Host, r.Owner, r.Name)
+	}
+	return filepath.Join(root, r.Owner, r.Name)
+}
+
+// enterpriseBaseURL returns the web root of an enterprise host. go-github derives
+// the /api/v3/ and /api/uploads/ endpoints from it.
+func (r RepositoryRef) enterpriseBaseURL() string {
+	return fmt.Sprintf("https://%s/", r.Host)
+}
+
+// ParseRepositoryRef parses a repository reference such as:
 //   - owner/name
-//   - https://github.com/owner/name
-//   - git@github.com:owner/name.git
+//   - https://github.example.com/owner/name
+//   - git@github.example.com:owner/name.git
+//   - ssh://git@github.example.com/owner/name.git
 //
-// It returns the owner and repository name.
-func ParseRepository(ref string) (string, string, error) {
+// References without a host refer to github.com.
+func ParseRepositoryRef(ref string) (RepositoryRef, error) {
 	ref = strings.TrimSpace(ref)
 	if ref == "" {
-		return "", "", fmt.Errorf("repository reference is empty")
+		return RepositoryRef{}, fmt.Errorf("repository reference is empty")
 	}
 
-	// Handle git@github.com:owner/name(.git)
-	if strings.HasPrefix(ref, "git@") {
-		parts := strings.SplitN(ref, ":", 2)
-		if len(parts) != 2 {
-			return "", "", fmt.Errorf("invalid repository reference: %s", ref)
+	host := DefaultHost
+
+	// Handle git@host:owner/name(.git)
+	if strings.HasPrefix(ref, "git@") && !strings.Contains(ref, "://") {
+		parts := strings.SplitN(strings.TrimPrefix(ref, "git@"), ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return RepositoryRef{}, fmt.Errorf("invalid repository reference: %s", ref)
 		}
+		host = parts[0]
 		ref = parts[1]
 	}
 
 	if strings.Contains(ref, "://") {
 		u, err := url.Parse(ref)
 		if err != nil {
-			return "", "", fmt.Errorf("invalid repository url: %w", err)
+			return RepositoryRef{}, fmt.Errorf("invalid repository url: %w", err)
+		}
+		owner, name, err := ParseRepositoryURL(u)
+		if err != nil {
+			return RepositoryRef{}, err
+		}
+		if u.Host != "" {
+			host = u.Host
 		}
-		return ParseRepositoryURL(u)
+		return RepositoryRef{Host: normalizeHost(host), Owner: owner, Name: name}, nil
 	}
 
 	owner, name, err := parseOwnerAndName(ref)
+	if err != nil {
+		return RepositoryRef{}, err
+	}
+	if err := ValidateRepository(owner, name); err != nil {
+		return RepositoryRef{}, err
+	}
+	return RepositoryRef{Host: normalizeHost(host), Owner: owner, Name: name}, nil
+}
+
+// ParseRepository parses a repository reference like ParseRepositoryRef and
+// returns only the owner and repository name.
+func ParseRepository(ref string) (string, string, error) {
+	repo, err := ParseRepositoryRef(ref)
 	if err != nil {
 		return "", "", err
 	}
-	return owner, name, ValidateRepository(owner, name)
+	return repo.Owner, repo.Name, nil
+}
+
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	return strings.TrimPrefix(host, wwwPrefix)
 }
 
 // ParseRepositoryURL extracts the owner and name from a GitHub URL.
@@ -59,14 +137,15 @@ func ParseRepositoryURL(u *url.URL) (string, string, error) {
 	}
 
 	owner := strings.TrimSpace(parts[0])
-	name := strings.TrimSpace(parts[1])
+	name := strings.TrimSuffix(strings.TrimSpace(parts[1]), ".git")
 
 	return owner, name, ValidateRepository(owner, name)
 }
 
-// CanonicalGitURL returns the canonical HTTPS URL for a GitHub repository.
+// CanonicalGitURL returns the canonical HTTPS URL for a github.com repository.
+// Use RepositoryRef.CanonicalURL for repositories on other hosts.
 func CanonicalGitURL(owner, name string) string {
-	return fmt.Sprintf("https://github.com/%s/%s", owner, name)
+	return RepositoryRef{Host: DefaultHost, Owner: owner, Name: name}.CanonicalURL()
 }
 
 // ValidateRepository ensures owner and name are both non-empty.
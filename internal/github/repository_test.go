@@ -1,9 +1,11 @@
 package github
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseRepository(t *testing.T) {
@@ -64,3 +66,72 @@ func TestParseRepository(t *testing.T) {
 		})
 	}
 }
+
+func TestParseRepositoryRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    RepositoryRef
+		wantErr bool
+	}{
+		{
+			name:  "owner/name defaults to github.com",
+			input: "owner/repo",
+			want:  RepositoryRef{Host: "github.com", Owner: "owner", Name: "repo"},
+		},
+		{
+			name:  "www host is normalized",
+			input: "https://www.github.com/owner/repo",
+			want:  RepositoryRef{Host: "github.com", Owner: "owner", Name: "repo"},
+		},
+		{
+			name:  "enterprise https url",
+			input: "https://GHE.example.com/owner/repo.git",
+			want:  RepositoryRef{Host: "ghe.example.com", Owner: "owner", Name: "repo"},
+		},
+		{
+			name:  "enterprise https url with port",
+			input: "https://ghe.example.com:8443/owner/repo/pull/1",
+			want:  RepositoryRef{Host: "ghe.example.com:8443", Owner: "owner", Name: "repo"},
+		},
+		{
+			name:  "enterprise git ssh url",
+			input: "git@ghe.example.com:owner/repo.git",
+			want:  RepositoryRef{Host: "ghe.example.com", Owner: "owner", Name: "repo"},
+		},
+		{
+			name:  "ssh scheme url",
+			input: "ssh://git@ghe.example.com/owner/repo.git",
+			want:  RepositoryRef{Host: "ghe.example.com", Owner: "owner", Name: "repo"},
+		},
+		{
+			name:    "git ssh url without path",
+			input:   "git@ghe.example.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRepositoryRef(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRepositoryRef_Layout(t *testing.T) {
+	public := RepositoryRef{Host: DefaultHost, Owner: "owner", Name: "repo"}
+	enterprise := RepositoryRef{Host: "ghe.example.com", Owner: "owner", Name: "repo"}
+
+	assert.Equal(t, filepath.Join("data", "owner", "repo"), public.DataDir("data"))
+	assert.Equal(t, filepath.Join("data", "ghe.example.com", "owner", "repo"), enterprise.DataDir("data"))
+	assert.Equal(t, "owner/repo", public.String())
+	assert.Equal(t, "ghe.example.com/owner/repo", enterprise.String())
+	assert.Equal(t, "https://ghe.example.com/owner/repo", enterprise.CanonicalURL())
+	assert.Equal(t, "https://github.com/owner/repo", CanonicalGitURL("owner", "repo"))
+}
@@ -0,0 +1,281 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SearchScope は検索キーワードを照合する対象（in:修飾子）
+type SearchScope string
+
+const (
+	ScopeComments SearchScope = "comments"
+	ScopeTitle    SearchScope = "title"
+	ScopeBody     SearchScope = "body"
+)
+
+// PRState はPRの状態による絞り込み（is:open / is:closed）
+type PRState string
+
+const (
+	StateAny    PRState = ""
+	StateOpen   PRState = "open"
+	StateClosed PRState = "closed"
+)
+
+// MergeStatus はマージ済みかどうかによる絞り込み（is:merged / is:unmerged）
+type MergeStatus string
+
+const (
+	MergeAny      MergeStatus = ""
+	MergeMerged   MergeStatus = "merged"
+	MergeUnmerged MergeStatus = "unmerged"
+)
+
+// 日付範囲の修飾子に使う日付の種類
+const (
+	dateFieldCreated = "created"
+	dateFieldMerged  = "merged"
+	dateFieldUpdated = "updated"
+)
+
+// dateOnlyLayout は日付だけを指定する場合の書式
+const dateOnlyLayout = "2006-01-02"
+
+// dateOnlyEnd は日付だけで指定した終端に足す時間。その日の終わりまでを範囲に含める
+const dateOnlyEnd = 24*time.Hour - time.Nanosecond
+
+// DateRange は検索対象とする日時の範囲。ゼロ値の端は制限しないことを表す。
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// IsZero は範囲がまったく指定されていないかどうかを返す。
+func (r DateRange) IsZero() bool {
+	return r.From.IsZero() && r.To.IsZero()
+}
+
+// Contains はtが範囲に含まれるかどうかを返す（両端を含む）。ゼロ値の時刻は範囲が指定されていれば含まれない。
+func (r DateRange) Contains(t time.Time) bool {
+	if r.IsZero() {
+		return true
+	}
+	if t.IsZero() {
+		return false
+	}
+	return !t.Before(r.From) && (r.To.IsZero() || !t.After(r.To))
+}
+
+// qualifier はfieldの検索修飾子に変換する。範囲が指定されていなければ空文字列を返す。
+func (r DateRange) qualifier(field string) string {
+	switch {
+	case r.IsZero():
+		return ""
+	case r.To.IsZero():
+		return fmt.Sprintf("%s:>=%s", field, r.From.UTC().Format(searchDateLayout))
+	case r.From.IsZero():
+		return fmt.Sprintf("%s:<=%s", field, r.To.UTC().Format(searchDateLayout))
+	default:
+		return dateWindow{from: r.From, to: r.To}.qualifier(field)
+	}
+}
+
+// window は期間分割の起点となる範囲を返す。未指定の端はGitHub開設日またはnowで補う。
+func (r DateRange) window(now time.Time) dateWindow {
+	window := dateWindow{from: searchEpoch, to: now.UTC()}
+	if !r.From.IsZero() {
+		window.from = r.From
+	}
+	if !r.To.IsZero() {
+		window.to = r.To
+	}
+	return window
+}
+
+// ParseDateRange は "2020-01-01..2021-01-01"、">=2020-01-01"、"<=2021-01-01"、"2020-01-01.." のような
+// 範囲指定を解釈する。日付はYYYY-MM-DDまたはRFC3339で指定する。日付だけの終端はその日の終わりまでを含む。
+func ParseDateRange(value string) (DateRange, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return DateRange{}, nil
+	}
+
+	var fromStr, toStr string
+	switch {
+	case strings.HasPrefix(value, ">="):
+		fromStr = strings.TrimPrefix(value, ">=")
+	case strings.HasPrefix(value, "<="):
+		toStr = strings.TrimPrefix(value, "<=")
+	case strings.Contains(value, ".."):
+		fromStr, toStr, _ = strings.Cut(value, "..")
+	default:
+		return DateRange{}, fmt.Errorf("invalid date range %q: use FROM..TO, >=FROM or <=TO", value)
+	}
+
+	var r DateRange
+	var err error
+	if fromStr != "" {
+		if r.From, err = parseSearchDate(fromStr); err != nil {
+			return DateRange{}, err
+		}
+	}
+	if toStr != "" {
+		if r.To, err = parseSearchDate(toStr); err != nil {
+			return DateRange{}, err
+		}
+		if isDateOnly(toStr) {
+			r.To = r.To.Add(dateOnlyEnd)
+		}
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && r.To.Before(r.From) {
+		return DateRange{}, fmt.Errorf("invalid date range %q: end is before start", value)
+	}
+	return r, nil
+}
+
+func parseSearchDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(searchDateLayout, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(dateOnlyLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or RFC3339", value)
+	}
+	return t, nil
+}
+
+// isDateOnly はvalueが時刻を含まない日付だけの指定かどうかを返す
+func isDateOnly(value string) bool {
+	_, err := time.Parse(dateOnlyLayout, strings.TrimSpace(value))
+	return err == nil
+}
+
+// SearchQuery は/search/issuesに渡すPR検索クエリを組み立てる。
+// ゼロ値のフィールドは絞り込みに使わない。
+type SearchQuery struct {
+	Keyword string
+	Scopes  []SearchScope
+	State   PRState
+	Merged  MergeStatus
+	Labels  []string
+	Authors []string
+	Base    string
+	Created DateRange
+	// MergedAt はマージ日時の範囲（merged:修飾子）
+	MergedAt DateRange
+	// Updated は最終更新日時の範囲（updated:修飾子）。差分同期で前回以降に更新されたPRだけを探すときに使う
+	Updated        DateRange
+	ExcludeLabels  []string
+	ExcludeAuthors []string
+}
+
+// DefaultSearchQuery はマージ済みPRのコメントからkeywordを探す、従来どおりのクエリを返す。
+func DefaultSearchQuery(keyword string) SearchQuery {
+	return SearchQuery{
+		Keyword: keyword,
+		Scopes:  []SearchScope{ScopeComments},
+		Merged:  MergeMerged,
+	}
+}
+
+// WithKeyword はキーワードだけを差し替えたコピーを返す。
+func (q SearchQuery) WithKeyword(keyword string) SearchQuery {
+	q.Keyword = keyword
+	return q
+}
+
+// Build はowner/nameのリポジトリを対象とする検索クエリ文字列を返す。
+func (q SearchQuery) Build(owner, name string) string {
+	return q.build(owner, name, "")
+}
+
+// shardField は結果が上限を超えたときに期間分割に使う日付の種類を返す。
+// マージ済みPRに限定している場合はマージ日時、それ以外は作成日時で分割する。
+func (q SearchQuery) shardField() string {
+	if q.Merged == MergeMerged || !q.MergedAt.IsZero() {
+		return dateFieldMerged
+	}
+	return dateFieldCreated
+}
+
+// shardRange はshardFieldに対応する範囲指定を返す。
+func (q SearchQuery) shardRange() DateRange {
+	if q.shardField() == dateFieldMerged {
+		return q.MergedAt
+	}
+	return q.Created
+}
+
+// build はクエリ文字列を組み立てる。omitRangeに指定した日付の範囲修飾子は含めない。
+func (q SearchQuery) build(owner, name string, omitRange string) string {
+	terms := []string{fmt.Sprintf("repo:%s/%s", owner, name)}
+
+	if len(q.Scopes) > 0 {
+		scopes := make([]string, len(q.Scopes))
+		for i, scope := range q.Scopes {
+			scopes[i] = string(scope)
+		}
+		terms = append(terms, "in:"+strings.Join(scopes, ","))
+	}
+
+	terms = append(terms, "type:pr")
+
+	if q.State != StateAny {
+		terms = append(terms, "is:"+string(q.State))
+	}
+	if q.Merged != MergeAny {
+		terms = append(terms, "is:"+string(q.Merged))
+	}
+
+	terms = appendQualifiers(terms, "label:", q.Labels)
+	terms = appendQualifiers(terms, "author:", q.Authors)
+	if q.Base != "" {
+		terms = append(terms, "base:"+quoteSearchValue(q.Base))
+	}
+
+	if omitRange != dateFieldCreated {
+		if qualifier := q.Created.qualifier(dateFieldCreated); qualifier != "" {
+			terms = append(terms, qualifier)
+		}
+	}
+	if omitRange != dateFieldMerged {
+		if qualifier := q.MergedAt.qualifier(dateFieldMerged); qualifier != "" {
+			terms = append(terms, qualifier)
+		}
+	}
+
+	if qualifier := q.Updated.qualifier(dateFieldUpdated); qualifier != "" {
+		terms = append(terms, qualifier)
+	}
+
+	terms = appendQualifiers(terms, "-label:", q.ExcludeLabels)
+	terms = appendQualifiers(terms, "-author:", q.ExcludeAuthors)
+
+	if q.Keyword != "" {
+		terms = append(terms, q.Keyword)
+	}
+
+	return strings.Join(terms, " ")
+}
+
+func appendQualifiers(terms []string, prefix string, values []string) []string {
+	for _, value := range values {
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		terms = append(terms, prefix+quoteSearchValue(value))
+	}
+	return terms
+}
+
+// quoteSearchValue は空白を含む値を二重引用符で囲む
+func quoteSearchValue(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.ContainsAny(value, " \t") {
+		return `"` + strings.ReplaceAll(value, `"`, "") + `"`
+	}
+	return value
+}
@@ -0,0 +1,161 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchQueryBuild(t *testing.T) {
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  string
+	}{
+		{
+			name:  "default query",
+			query: DefaultSearchQuery("xss"),
+			want:  "repo:owner/repo in:comments type:pr is:merged xss",
+		},
+		{
+			name: "unmerged closed PRs searched by title and body",
+			query: SearchQuery{
+				Keyword: "overflow",
+				Scopes:  []SearchScope{ScopeTitle, ScopeBody},
+				State:   StateClosed,
+				Merged:  MergeUnmerged,
+			},
+			want: "repo:owner/repo in:title,body type:pr is:closed is:unmerged overflow",
+		},
+		{
+			name: "labels, authors, base and exclusions",
+			query: SearchQuery{
+				Keyword:        "csrf",
+				Labels:         []string{"security", "good first issue"},
+				Authors:        []string{"alice"},
+				Base:           "main",
+				ExcludeLabels:  []string{"wontfix"},
+				ExcludeAuthors: []string{"dependabot[bot]"},
+			},
+			want: `repo:owner/repo type:pr label:security label:"good first issue" author:alice base:main -label:wontfix -author:dependabot[bot] csrf`,
+		},
+		{
+			name: "date ranges",
+			query: SearchQuery{
+				Keyword:  "token",
+				Created:  DateRange{From: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+				MergedAt: DateRange{To: time.Date(2021, 6, 30, 0, 0, 0, 0, time.UTC)},
+			},
+			want: "repo:owner/repo type:pr created:>=2020-01-01T00:00:00Z merged:<=2021-06-30T00:00:00Z token",
+		},
+		{
+			name: "updated since the last sync",
+			query: SearchQuery{
+				Keyword: "xss",
+				Scopes:  []SearchScope{ScopeComments},
+				Merged:  MergeMerged,
+				Updated: DateRange{From: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+			},
+			want: "repo:owner/repo in:comments type:pr is:merged updated:>=2024-03-01T12:30:00Z xss",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.query.Build("owner", "repo"))
+		})
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	jan := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	dec := time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+	endOfDec := time.Date(2020, 12, 31, 23, 59, 59, 999999999, time.UTC)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    DateRange
+		wantErr bool
+	}{
+		{name: "empty", input: "", want: DateRange{}},
+		{name: "closed range", input: "2020-01-01..2020-12-31", want: DateRange{From: jan, To: endOfDec}},
+		{name: "open end", input: "2020-01-01..", want: DateRange{From: jan}},
+		{name: "greater or equal", input: ">=2020-01-01", want: DateRange{From: jan}},
+		{name: "less or equal", input: "<=2020-12-31", want: DateRange{To: endOfDec}},
+		{name: "same day", input: "2020-12-31..2020-12-31", want: DateRange{From: dec, To: endOfDec}},
+		{name: "rfc3339", input: "2020-01-01T00:00:00Z..2020-12-31T00:00:00Z", want: DateRange{From: jan, To: dec}},
+		{name: "reversed", input: "2020-12-31..2020-01-01", wantErr: true},
+		{name: "garbage", input: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDateRange(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSearchPullRequests_UsesDateRangeAsShardWindow(t *testing.T) {
+	mergedAt := map[int]time.Time{
+		1: time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC),
+		2: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		3: time.Date(2020, 12, 31, 12, 0, 0, 0, time.UTC),
+		4: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	var queries []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search/issues", fakeSearchHandler(t, mergedAt, &queries))
+	client := newTestClient(t, mux)
+
+	query := DefaultSearchQuery("xss")
+	mergedRange, err := ParseDateRange("2020-01-01..2020-12-31")
+	require.NoError(t, err)
+	query.MergedAt = mergedRange
+
+	numbers, err := client.SearchPullRequests(context.Background(), query)
+	require.NoError(t, err)
+
+	// 日付だけの終端はその日の終わりまでを含む
+	assert.Equal(t, []int{2, 3}, numbers)
+	require.Len(t, queries, 1)
+	assert.Equal(t, "repo:owner/repo in:comments type:pr is:merged xss merged:2020-01-01T00:00:00Z..2020-12-31T23:59:59Z", queries[0])
+}
+
+func TestDateRangeContains(t *testing.T) {
+	jan := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	dec := time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC)
+	jun := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		r    DateRange
+		t    time.Time
+		want bool
+	}{
+		{name: "unbounded", r: DateRange{}, t: jun, want: true},
+		{name: "unbounded zero time", r: DateRange{}, t: time.Time{}, want: true},
+		{name: "inside", r: DateRange{From: jan, To: dec}, t: jun, want: true},
+		{name: "start is inclusive", r: DateRange{From: jan, To: dec}, t: jan, want: true},
+		{name: "end is inclusive", r: DateRange{From: jan, To: dec}, t: dec, want: true},
+		{name: "before", r: DateRange{From: jun}, t: jan, want: false},
+		{name: "after", r: DateRange{To: jun}, t: dec, want: false},
+		{name: "zero time with a range", r: DateRange{From: jan}, t: time.Time{}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.r.Contains(tt.t))
+		})
+	}
+}
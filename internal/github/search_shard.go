@@ -0,0 +1,135 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/go-github/v77/github"
+)
+
+const (
+	// searchResultCap は/search/issuesが1つのクエリで返す結果数の上限
+	searchResultCap = 1000
+	// minSearchWindow はこれより短い期間には分割しない（それでも上限を超える場合は打ち切られた結果を受け入れる）
+	minSearchWindow = time.Minute
+	// searchDateLayout はcreated:/merged:修飾子に渡す日時の書式
+	searchDateLayout = time.RFC3339
+)
+
+// searchEpoch はGitHubの開設日。これより前に作成・マージされたPRは存在しない
+var searchEpoch = time.Date(2008, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// errSearchWindowTooLarge は検索結果が上限を超えたため期間を分割する必要があることを表す
+var errSearchWindowTooLarge = errors.New("search results exceed the result cap")
+
+// dateWindow は検索対象とする期間（両端を含む）
+type dateWindow struct {
+	from time.Time
+	to   time.Time
+}
+
+// qualifier は期間をfield（created/merged）の検索修飾子に変換する
+func (w dateWindow) qualifier(field string) string {
+	return fmt.Sprintf("%s:%s..%s", field, w.from.UTC().Format(searchDateLayout), w.to.UTC().Format(searchDateLayout))
+}
+
+func (w dateWindow) canSplit() bool {
+	return w.to.Sub(w.from) > minSearchWindow
+}
+
+// split は期間を重ならない前半と後半に分ける
+func (w dateWindow) split() (dateWindow, dateWindow) {
+	mid := w.from.Add(w.to.Sub(w.from) / 2).Truncate(time.Second)
+	return dateWindow{from: w.from, to: mid}, dateWindow{from: mid.Add(time.Second), to: w.to}
+}
+
+/**
+ * /search/issuesでqueryに一致するPR番号を重複なく取得する
+ * rangeが指定されていればfieldの日付でその期間に絞り込む
+ * total_countが取得上限（1000件）を超える場合は、fieldの日付で期間を再帰的に分割して検索し直す
+ */
+func (c *Client) searchPullRequestNumbers(ctx context.Context, query string, field string, dateRange DateRange) ([]SearchResult, error) {
+	seen := make(map[int]bool)
+	var results []SearchResult
+	add := func(issue *github.Issue) {
+		if issue.PullRequestLinks == nil || issue.Number == nil {
+			return
+		}
+		if seen[*issue.Number] {
+			return
+		}
+		seen[*issue.Number] = true
+		results = append(results, SearchResult{Number: *issue.Number, UpdatedAt: issue.GetUpdatedAt().Time})
+	}
+
+	window := dateRange.window(c.now())
+	var err error
+	if dateRange.IsZero() {
+		err = c.searchWindow(ctx, query, field, nil, add)
+		if errors.Is(err, errSearchWindowTooLarge) {
+			fmt.Printf("Search for %q exceeds %d results. Splitting by %s date...\n", query, searchResultCap, field)
+			err = c.searchWindow(ctx, query, field, &window, add)
+		}
+	} else {
+		err = c.searchWindow(ctx, query, field, &window, add)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+/**
+ * windowで絞り込んだqueryの検索結果をすべてaddに渡す
+ * 結果が上限を超える場合は期間を二分割して再帰的に検索する
+ * windowがnilの場合は期間で絞り込まず、上限を超えればerrSearchWindowTooLargeを返す
+ */
+func (c *Client) searchWindow(ctx context.Context, query string, field string, window *dateWindow, add func(issue *github.Issue)) error {
+	windowQuery := query
+	if window != nil {
+		windowQuery = fmt.Sprintf("%s %s", query, window.qualifier(field))
+	}
+
+	var total int
+	warned := false
+	err := forEachPage(func(opts github.ListOptions) ([]*github.Issue, *github.Response, error) {
+		return callWithRateLimit(ctx, c.limiter, RateResourceSearch, func() ([]*github.Issue, *github.Response, error) {
+			result, resp, err := c.github.Search.Issues(ctx, windowQuery, &github.SearchOptions{ListOptions: opts})
+			if err != nil {
+				return nil, resp, err
+			}
+			if opts.Page == 1 {
+				total = result.GetTotal()
+			}
+			return result.Issues, resp, nil
+		})
+	}, func(issues []*github.Issue) error {
+		if total > searchResultCap {
+			if window == nil || window.canSplit() {
+				return errSearchWindowTooLarge
+			}
+			if !warned {
+				log.Printf("⚠️  Search window %s still has %d results; only the first %d are returned", window.qualifier(field), total, searchResultCap)
+				warned = true
+			}
+		}
+		for _, issue := range issues {
+			add(issue)
+		}
+		return nil
+	})
+
+	if window == nil || !errors.Is(err, errSearchWindowTooLarge) {
+		return err
+	}
+
+	left, right := window.split()
+	if err := c.searchWindow(ctx, query, field, &left, add); err != nil {
+		return err
+	}
+	return c.searchWindow(ctx, query, field, &right, add)
+}
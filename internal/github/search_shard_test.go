@@ -0,0 +1,117 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var mergedRangePattern = regexp.MustCompile(`merged:(\S+)\.\.(\S+)`)
+
+// fakeSearchHandler はマージ日時で絞り込める/search/issuesを模倣し、GitHubと同じく1000件までしか返さない
+func fakeSearchHandler(t *testing.T, mergedAt map[int]time.Time, queries *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		*queries = append(*queries, query)
+
+		var matched []int
+		for number, merged := range mergedAt {
+			if m := mergedRangePattern.FindStringSubmatch(query); m != nil {
+				from, err := time.Parse(searchDateLayout, m[1])
+				require.NoError(t, err)
+				to, err := time.Parse(searchDateLayout, m[2])
+				require.NoError(t, err)
+				if merged.Before(from) || merged.After(to) {
+					continue
+				}
+			}
+			matched = append(matched, number)
+		}
+		sort.Ints(matched)
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		visible := matched[:min(len(matched), searchResultCap)]
+		start := min((page-1)*perPage, len(visible))
+		end := min(start+perPage, len(visible))
+
+		items := make([]map[string]any, 0, end-start)
+		for _, number := range visible[start:end] {
+			items = append(items, map[string]any{"number": number, "pull_request": map[string]any{}})
+		}
+
+		if end < len(visible) {
+			next := *r.URL
+			values := next.Query()
+			values.Set("page", strconv.Itoa(page+1))
+			next.RawQuery = values.Encode()
+			w.Header().Set("Link", `<http://`+r.Host+next.RequestURI()+`>; rel="next"`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]any{"total_count": len(matched), "items": items}))
+	}
+}
+
+func TestSearchPullRequestsWithCommentKeyword_SmallResultIsNotSharded(t *testing.T) {
+	mergedAt := map[int]time.Time{
+		1: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		2: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	var queries []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search/issues", fakeSearchHandler(t, mergedAt, &queries))
+	client := newTestClient(t, mux)
+
+	numbers, err := client.SearchPullRequestsWithCommentKeyword(context.Background(), "xss")
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []int{1, 2}, numbers)
+	require.Len(t, queries, 1)
+	assert.Equal(t, "repo:owner/repo in:comments type:pr is:merged xss", queries[0])
+}
+
+func TestSearchPullRequestsWithCommentKeyword_ShardsPastResultCap(t *testing.T) {
+	const prCount = 2500
+	mergedAt := make(map[int]time.Time, prCount)
+	start := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= prCount; i++ {
+		mergedAt[i] = start.Add(time.Duration(i) * 24 * time.Hour)
+	}
+	var queries []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search/issues", fakeSearchHandler(t, mergedAt, &queries))
+	client := newTestClient(t, mux)
+
+	numbers, err := client.SearchPullRequestsWithCommentKeyword(context.Background(), "check")
+	require.NoError(t, err)
+
+	assert.Len(t, numbers, prCount)
+	seen := make(map[int]bool)
+	for _, number := range numbers {
+		assert.False(t, seen[number], "PR #%d returned twice", number)
+		seen[number] = true
+	}
+	assert.Greater(t, len(queries), 1)
+}
+
+func TestDateWindowSplit_DoesNotOverlap(t *testing.T) {
+	window := dateWindow{
+		from: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		to:   time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC),
+	}
+
+	left, right := window.split()
+
+	assert.Equal(t, window.from, left.from)
+	assert.Equal(t, window.to, right.to)
+	assert.Equal(t, time.Second, right.from.Sub(left.to))
+	assert.Equal(t, "merged:2020-01-01T00:00:00Z..2020-01-02T00:00:00Z", left.qualifier("merged"))
+}
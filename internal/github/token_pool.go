@@ -0,0 +1,361 @@
+package github
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokensEnvVar は複数のトークンをカンマまたは空白区切りで渡す環境変数
+const TokensEnvVar = "GITHUB_TOKENS"
+
+const (
+	headerAuthorization     = "Authorization"
+	headerRateLimitLimit    = "X-RateLimit-Limit"
+	headerRateLimitUsed     = "X-RateLimit-Used"
+	headerRateLimitResource = "X-RateLimit-Resource"
+	graphQLPathSuffix       = "/graphql"
+	searchPathSegment       = "/search/"
+	tokenFileCommentPrefix  = "#"
+	tokenListSeparatorChars = ", \t\r\n"
+)
+
+// tokenBudget は1つのトークンの、1つのリソースについて最後に観測した残量
+type tokenBudget struct {
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+type pooledToken struct {
+	value   string
+	budgets map[string]*tokenBudget
+}
+
+// TokenPool は複数のトークンのリソースごとの残量をレスポンスヘッダーから追跡し、
+// リクエストごとに残量が最も多いトークンを選ぶ。複数のgoroutineから共有できる。
+type TokenPool struct {
+	mu     sync.Mutex
+	tokens []*pooledToken
+	now    func() time.Time
+}
+
+// NewTokenPool はtokensからTokenPoolを作成する。空文字列と重複は取り除く。
+func NewTokenPool(tokens []string) (*TokenPool, error) {
+	pool := &TokenPool{now: time.Now}
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		token = strings.TrimSpace(token)
+		if token == "" || seen[token] {
+			continue
+		}
+		seen[token] = true
+		pool.tokens = append(pool.tokens, &pooledToken{value: token, budgets: make(map[string]*tokenBudget)})
+	}
+	if len(pool.tokens) == 0 {
+		return nil, errors.New("token pool requires at least one token")
+	}
+	return pool, nil
+}
+
+// Len はプールに含まれるトークンの数を返す。
+func (p *TokenPool) Len() int {
+	return len(p.tokens)
+}
+
+// LoadTokens はfile（1行に1トークン、#で始まる行は無視）とGITHUB_TOKENS環境変数からトークンを集める。
+// fileが空文字列の場合は環境変数だけを読む。
+func LoadTokens(file string) ([]string, error) {
+	var tokens []string
+	if file != "" {
+		fromFile, err := readTokenFile(file)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, fromFile...)
+	}
+	tokens = append(tokens, splitTokenList(os.Getenv(TokensEnvVar))...)
+	return tokens, nil
+}
+
+func readTokenFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token file: %w", err)
+	}
+	defer f.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, tokenFileCommentPrefix) {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	return tokens, nil
+}
+
+func splitTokenList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return strings.ContainsRune(tokenListSeparatorChars, r)
+	})
+}
+
+// Transport はbaseの前段でトークンを付け替えるRoundTripperを返す。
+// レスポンスのX-RateLimit-*ヘッダーはプール全体の合計に書き換えるため、
+// RateLimiterはすべてのトークンを使い切ったときだけ待機する。
+func (p *TokenPool) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &tokenTransport{pool: p, base: base}
+}
+
+type tokenTransport struct {
+	pool *TokenPool
+	base http.RoundTripper
+}
+
+/**
+ * 残量が最も多いトークンでリクエストを送る
+ * 一次レート制限を使い切った403/429が返り、まだ残量のあるトークンがあれば、そのトークンで送り直す
+ * すべてのトークンを使い切っていれば、最も早く回復するトークンのリセット時刻を付けて返す
+ */
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resource := requestResource(req)
+	tried := make(map[*pooledToken]bool)
+
+	for attempt := 0; ; attempt++ {
+		token := t.pool.acquire(resource, tried)
+		tried[token] = true
+
+		authorized := req.Clone(req.Context())
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			authorized.Body = body
+		}
+		authorized.Header.Set(headerAuthorization, "Bearer "+token.value)
+
+		resp, err := t.base.RoundTrip(authorized)
+		if err != nil {
+			return nil, err
+		}
+
+		observed := t.pool.observe(token, resource, resp.Header)
+		if !isExhaustedResponse(resp) {
+			t.pool.rewriteHeaders(observed, resp.Header)
+			return resp, nil
+		}
+		if !t.pool.hasBudget(observed, tried) {
+			// 使い切ったことを示すレスポンスを返し、RateLimiterに最も早いリセットまで待たせる
+			t.pool.rewriteReset(observed, resp.Header)
+			return resp, nil
+		}
+		if !canReplay(req) {
+			return resp, nil
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+// requestResource はリクエスト先のパスからレート制限のリソースを推定する
+func requestResource(req *http.Request) string {
+	path := req.URL.Path
+	switch {
+	case strings.HasSuffix(path, graphQLPathSuffix):
+		return RateResourceGraphQL
+	case strings.Contains(path, searchPathSegment):
+		return RateResourceSearch
+	default:
+		return RateResourceCore
+	}
+}
+
+// isExhaustedResponse はトークンの一次レート制限を使い切ったことを示すレスポンスかどうかを返す
+func isExhaustedResponse(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+	return resp.Header.Get(headerRateLimitRemaining) == "0"
+}
+
+func canReplay(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// acquire はexclude以外のトークンからresourceの残量が最も多いものを選び、1リクエスト分を先取りする。
+// まだ使っていないトークンやリセット時刻を過ぎたトークンは満タンとみなす。
+// exclude以外にトークンが残っていない場合は全トークンから選ぶ。
+func (p *TokenPool) acquire(resource string, exclude map[*pooledToken]bool) *pooledToken {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var best *pooledToken
+	bestRemaining := -1
+	for _, token := range p.tokens {
+		if exclude[token] {
+			continue
+		}
+		if remaining := p.effectiveRemainingLocked(token, resource, now); remaining > bestRemaining {
+			best, bestRemaining = token, remaining
+		}
+	}
+	if best == nil {
+		best = p.tokens[0]
+	}
+
+	if budget, ok := best.budgets[resource]; ok && budget.remaining > 0 && now.Before(budget.reset) {
+		budget.remaining--
+	}
+	return best
+}
+
+// effectiveRemainingLocked はtokenが今使える残量を返す。残量が分からない場合は無制限とみなす。
+func (p *TokenPool) effectiveRemainingLocked(token *pooledToken, resource string, now time.Time) int {
+	budget, ok := token.budgets[resource]
+	if !ok {
+		return math.MaxInt
+	}
+	if !now.Before(budget.reset) {
+		return budget.limit
+	}
+	return budget.remaining
+}
+
+// observe はtokenのレスポンスヘッダーから残量を記録し、記録したリソース名を返す
+func (p *TokenPool) observe(token *pooledToken, resource string, header http.Header) string {
+	if r := header.Get(headerRateLimitResource); r != "" {
+		resource = r
+	}
+
+	limit, err := strconv.Atoi(header.Get(headerRateLimitLimit))
+	if err != nil {
+		return resource
+	}
+	remaining, err := strconv.Atoi(header.Get(headerRateLimitRemaining))
+	if err != nil {
+		return resource
+	}
+	reset, err := strconv.ParseInt(header.Get(headerRateLimitReset), 10, 64)
+	if err != nil {
+		return resource
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	token.budgets[resource] = &tokenBudget{limit: limit, remaining: remaining, reset: time.Unix(reset, 0)}
+	return resource
+}
+
+// hasBudget はexclude以外に、resourceについて残量のあるトークンが残っているかどうかを返す
+func (p *TokenPool) hasBudget(resource string, exclude map[*pooledToken]bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for _, token := range p.tokens {
+		if !exclude[token] && p.effectiveRemainingLocked(token, resource, now) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * X-RateLimit-*ヘッダーをプール全体の値に書き換える
+ * Limit・Remainingは全トークンの合計、Resetは最も早く回復するトークンのリセット時刻にする
+ * 残量が分からないトークンは、観測済みのトークンと同じlimitを持つものとして数える
+ */
+func (p *TokenPool) rewriteHeaders(resource string, header http.Header) {
+	if header.Get(headerRateLimitLimit) == "" {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var knownLimit int
+	for _, token := range p.tokens {
+		if budget, ok := token.budgets[resource]; ok {
+			knownLimit = max(knownLimit, budget.limit)
+		}
+	}
+	if knownLimit == 0 {
+		return
+	}
+
+	var totalLimit, totalRemaining int
+	for _, token := range p.tokens {
+		budget, ok := token.budgets[resource]
+		if !ok {
+			totalLimit += knownLimit
+			totalRemaining += knownLimit
+			continue
+		}
+		totalLimit += budget.limit
+		totalRemaining += p.effectiveRemainingLocked(token, resource, now)
+	}
+
+	header.Set(headerRateLimitLimit, strconv.Itoa(totalLimit))
+	header.Set(headerRateLimitRemaining, strconv.Itoa(totalRemaining))
+	header.Set(headerRateLimitUsed, strconv.Itoa(totalLimit-totalRemaining))
+	header.Set(headerRateLimitReset, strconv.FormatInt(p.earliestResetLocked(resource, now).Unix(), 10))
+}
+
+// rewriteReset はX-RateLimit-Resetを、resourceについて最も早く回復するトークンのリセット時刻に書き換える
+func (p *TokenPool) rewriteReset(resource string, header http.Header) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	header.Set(headerRateLimitReset, strconv.FormatInt(p.earliestResetLocked(resource, p.now()).Unix(), 10))
+}
+
+// earliestResetLocked はresourceの残量が回復する最も早い時刻を返す。リセット待ちのトークンがなければnowを返す
+func (p *TokenPool) earliestResetLocked(resource string, now time.Time) time.Time {
+	var earliest time.Time
+	for _, token := range p.tokens {
+		budget, ok := token.budgets[resource]
+		if ok && now.Before(budget.reset) && (earliest.IsZero() || budget.reset.Before(earliest)) {
+			earliest = budget.reset
+		}
+	}
+	if earliest.IsZero() {
+		return now
+	}
+	return earliest
+}
+
+// WithTokens はtokensの数に応じて認証方法を選ぶ。トークンがなければNewClientのtokenを使い、
+// 1つならそのトークン、複数ならTokenPoolで使い分ける。
+func WithTokens(tokens []string) ClientOption {
+	return func(o *clientOptions) {
+		pool, err := NewTokenPool(tokens)
+		if err != nil {
+			return
+		}
+		if pool.Len() == 1 {
+			o.token = pool.tokens[0].value
+			return
+		}
+		o.tokenPool = pool
+	}
+}
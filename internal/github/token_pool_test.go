@@ -0,0 +1,189 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQuotaServer はトークンごとの残量を管理し、使い切ったトークンには403を返すテスト用サーバー
+type fakeQuotaServer struct {
+	mu        sync.Mutex
+	limit     int
+	remaining map[string]int
+	reset     time.Time
+	used      []string
+}
+
+func newFakeQuotaServer(limit int, remaining map[string]int) *fakeQuotaServer {
+	// go-githubの事前チェックで止まらないよう、リセット時刻は十分先にしておく
+	return &fakeQuotaServer{limit: limit, remaining: remaining, reset: time.Now().Add(time.Hour)}
+}
+
+func (s *fakeQuotaServer) handler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		s.used = append(s.used, token)
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.limit))
+		w.Header().Set(headerRateLimitReset, strconv.FormatInt(s.reset.Unix(), 10))
+		w.Header().Set(headerRateLimitResource, RateResourceCore)
+		if s.remaining[token] <= 0 {
+			w.Header().Set(headerRateLimitRemaining, "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		s.remaining[token]--
+		w.Header().Set(headerRateLimitRemaining, strconv.Itoa(s.remaining[token]))
+		writePagedJSON(t, w, r, []string{`[{"id":1}]`})
+	})
+}
+
+func (s *fakeQuotaServer) usedTokens() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.used...)
+}
+
+func TestNewTokenPool(t *testing.T) {
+	pool, err := NewTokenPool([]string{"a", " b ", "", "a"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, pool.Len())
+
+	_, err = NewTokenPool([]string{"", " "})
+	require.Error(t, err)
+}
+
+func TestLoadTokens(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tokens.txt")
+	require.NoError(t, os.WriteFile(file, []byte("# comment\nfile-1\n\n  file-2  \n"), 0644))
+	t.Setenv(TokensEnvVar, "env-1, env-2\tenv-3")
+
+	tokens, err := LoadTokens(file)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"file-1", "file-2", "env-1", "env-2", "env-3"}, tokens)
+
+	_, err = LoadTokens(filepath.Join(t.TempDir(), "missing.txt"))
+	require.Error(t, err)
+}
+
+func TestTokenPool_PrefersTokenWithMostBudget(t *testing.T) {
+	server := newFakeQuotaServer(100, map[string]int{"low": 3, "high": 50})
+	pool, err := NewTokenPool([]string{"low", "high"})
+	require.NoError(t, err)
+	client := newTestClient(t, server.handler(t), WithTokenPool(pool))
+
+	for range 4 {
+		_, err := client.GetComments(context.Background(), 7)
+		require.NoError(t, err)
+	}
+
+	// 最初の2回で両方の残量を観測した後は、残量の多いトークンだけを使う
+	used := server.usedTokens()
+	require.Len(t, used, 4)
+	assert.ElementsMatch(t, []string{"low", "high"}, used[:2])
+	assert.Equal(t, []string{"high", "high"}, used[2:])
+}
+
+func TestTokenPool_RotatesAwayFromExhaustedToken(t *testing.T) {
+	server := newFakeQuotaServer(100, map[string]int{"empty": 0, "full": 10})
+	pool, err := NewTokenPool([]string{"empty", "full"})
+	require.NoError(t, err)
+	client := newTestClient(t, server.handler(t), WithTokenPool(pool))
+	client.limiter.sleep = func(_ context.Context, d time.Duration) error {
+		t.Fatalf("unexpected rate limit wait of %v while another token has budget", d)
+		return nil
+	}
+
+	comments, err := client.GetComments(context.Background(), 7)
+	require.NoError(t, err)
+	assert.Len(t, comments, 1)
+	assert.Equal(t, []string{"empty", "full"}, server.usedTokens())
+
+	// RateLimiterにはプール全体の残量が伝わる
+	budget := client.limiter.budgets[RateResourceCore]
+	require.NotNil(t, budget)
+	assert.Equal(t, 200, budget.limit)
+	assert.Equal(t, 9, budget.remaining)
+}
+
+func TestTokenPool_WaitsOnlyWhenEveryTokenIsExhausted(t *testing.T) {
+	server := newFakeQuotaServer(100, map[string]int{"a": 0, "b": 0})
+	// go-githubの事前チェックで止まらないよう、リセット時刻は現在時刻にしておく
+	server.reset = time.Now()
+	pool, err := NewTokenPool([]string{"a", "b"})
+	require.NoError(t, err)
+
+	// 両方のトークンで403が返った後に、bだけ回復させる
+	requests := 0
+	handler := server.handler(t)
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+		requests++
+		if requests == 2 {
+			server.mu.Lock()
+			server.remaining["b"] = 5
+			server.mu.Unlock()
+		}
+	}), WithTokenPool(pool))
+	client.limiter.sleep = func(_ context.Context, d time.Duration) error { return nil }
+
+	_, err = client.GetComments(context.Background(), 7)
+	require.NoError(t, err)
+
+	used := server.usedTokens()
+	assert.Equal(t, []string{"a", "b"}, used[:2])
+	assert.Equal(t, "b", used[len(used)-1])
+	// すべてのトークンを使い切ったときだけRateLimiterが待機する
+	assert.False(t, client.limiter.budgets[RateResourceCore].blockedUntil.IsZero())
+}
+
+func TestTokenPool_ReportsEarliestResetWhenEveryTokenIsExhausted(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	resets := map[string]time.Time{"a": now.Add(30 * time.Minute), "b": now.Add(2 * time.Hour)}
+	pool, err := NewTokenPool([]string{"a", "b"})
+	require.NoError(t, err)
+	pool.now = func() time.Time { return now }
+
+	var used []string
+	transport := pool.Transport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		token := strings.TrimPrefix(req.Header.Get(headerAuthorization), "Bearer ")
+		used = append(used, token)
+		header := http.Header{}
+		header.Set(headerRateLimitLimit, "100")
+		header.Set(headerRateLimitRemaining, "0")
+		header.Set(headerRateLimitReset, strconv.FormatInt(resets[token].Unix(), 10))
+		return &http.Response{StatusCode: http.StatusForbidden, Header: header, Body: http.NoBody, Request: req}, nil
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.github.com/repos/owner/repo", nil)
+	require.NoError(t, err)
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// 最後に試したトークンではなく、最も早く回復するトークンのリセット時刻を返す
+	assert.Equal(t, []string{"a", "b"}, used)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "0", resp.Header.Get(headerRateLimitRemaining))
+	assert.Equal(t, strconv.FormatInt(resets["a"].Unix(), 10), resp.Header.Get(headerRateLimitReset))
+}
+
+// roundTripFunc は関数をhttp.RoundTripperとして使う
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
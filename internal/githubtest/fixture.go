@@ -0,0 +1,106 @@
+package githubtest
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Fixture はフェイクサーバーに読み込ませるリポジトリとPRのデータ
+type Fixture struct {
+	Repositories []Repository `json:"repositories"`
+}
+
+// Repository は1つのリポジトリとそのPR
+type Repository struct {
+	Owner        string        `json:"owner"`
+	Name         string        `json:"name"`
+	PullRequests []PullRequest `json:"pull_requests"`
+}
+
+// PullRequest は1件のPRと、そこで交わされた会話
+type PullRequest struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	// State はopenまたはclosed。空ならopenとして扱う
+	State  string   `json:"state"`
+	User   string   `json:"user"`
+	Base   string   `json:"base"`
+	Labels []string `json:"labels"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// MergedAt がゼロ値ならマージされていない
+	MergedAt time.Time `json:"merged_at"`
+
+	IssueComments  []Comment `json:"issue_comments"`
+	ReviewComments []Comment `json:"review_comments"`
+	Reviews        []Review  `json:"reviews"`
+}
+
+// Comment はIssue CommentまたはReview Comment
+type Comment struct {
+	ID        int64     `json:"id"`
+	User      string    `json:"user"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	// Path とInReplyTo はReview Commentでだけ使う
+	Path      string `json:"path,omitempty"`
+	InReplyTo int64  `json:"in_reply_to_id,omitempty"`
+}
+
+// Review はAPPROVEDやCHANGES_REQUESTEDなどのレビュー
+type Review struct {
+	ID          int64     `json:"id"`
+	User        string    `json:"user"`
+	Body        string    `json:"body"`
+	State       string    `json:"state"`
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// sampleFixture はowner/repoに3件のPRを持つ、パッケージをまたいで共有するFixture
+//
+//go:embed testdata/sample_fixture.json
+var sampleFixture []byte
+
+// sampleFixtureName はエラーメッセージに使うsampleFixtureの名前
+const sampleFixtureName = "sample_fixture.json"
+
+// LoadFixture はJSONファイルからFixtureを読み込む。
+func LoadFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	return parseFixture(path, data)
+}
+
+// SampleFixture は共有のFixture（owner/repoのPR #1〜#3）を返す。
+// 呼び出すたびに新しく読み込むため、テストの中で書き換えてもほかのテストには影響しない。
+func SampleFixture() (*Fixture, error) {
+	return parseFixture(sampleFixtureName, sampleFixture)
+}
+
+func parseFixture(name string, data []byte) (*Fixture, error) {
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", name, err)
+	}
+	return &fixture, nil
+}
+
+// merged はPRがマージ済みかどうかを返す
+func (pr *PullRequest) merged() bool {
+	return !pr.MergedAt.IsZero()
+}
+
+// state はPRの状態（open/closed）を返す
+func (pr *PullRequest) state() string {
+	if pr.State == "" {
+		return "open"
+	}
+	return pr.State
+}
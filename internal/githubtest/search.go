@@ -0,0 +1,269 @@
+package githubtest
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// searchDateLayouts は日付範囲の修飾子で受け付ける書式
+var searchDateLayouts = []string{time.RFC3339, "2006-01-02"}
+
+// dateRange は日付範囲の修飾子（created:・merged:・updated:）の条件。ゼロ値の端は制限しない
+type dateRange struct {
+	from, to time.Time
+}
+
+func (r dateRange) contains(t time.Time) bool {
+	if t.IsZero() {
+		return false
+	}
+	if !r.from.IsZero() && t.Before(r.from) {
+		return false
+	}
+	if !r.to.IsZero() && t.After(r.to) {
+		return false
+	}
+	return true
+}
+
+// searchQuery は/search/issuesのqを解釈した条件。PRの検索で使う修飾子だけに対応する。
+type searchQuery struct {
+	repo           string
+	scopes         []string
+	states         []string
+	merged         *bool
+	labels         []string
+	excludeLabels  []string
+	authors        []string
+	excludeAuthors []string
+	base           string
+	dates          map[string]dateRange
+	terms          []string
+}
+
+/**
+ * qを修飾子とキーワードに分ける
+ * repo:は必須で、未対応の修飾子はエラーにする（テストの取りこぼしに気付けるように）
+ */
+func parseSearchQuery(q string) (*searchQuery, error) {
+	query := &searchQuery{dates: make(map[string]dateRange)}
+	for _, token := range splitSearchTokens(q) {
+		key, value, ok := strings.Cut(token, ":")
+		if !ok || value == "" {
+			query.terms = append(query.terms, strings.ToLower(unquote(token)))
+			continue
+		}
+		value = unquote(value)
+
+		switch key {
+		case "repo":
+			query.repo = strings.ToLower(value)
+		case "type":
+			if value != "pr" {
+				return nil, fmt.Errorf("unsupported type %q", value)
+			}
+		case "in":
+			query.scopes = strings.Split(value, ",")
+		case "is":
+			switch value {
+			case "open", "closed":
+				query.states = append(query.states, value)
+			case "merged", "unmerged":
+				merged := value == "merged"
+				query.merged = &merged
+			case "pr":
+			default:
+				return nil, fmt.Errorf("unsupported qualifier is:%s", value)
+			}
+		case "label":
+			query.labels = append(query.labels, value)
+		case "-label":
+			query.excludeLabels = append(query.excludeLabels, value)
+		case "author":
+			query.authors = append(query.authors, value)
+		case "-author":
+			query.excludeAuthors = append(query.excludeAuthors, value)
+		case "base":
+			query.base = value
+		case "created", "merged", "updated":
+			r, err := parseDateRange(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s qualifier: %w", key, err)
+			}
+			query.dates[key] = r
+		default:
+			return nil, fmt.Errorf("unsupported qualifier %s:", key)
+		}
+	}
+
+	if query.repo == "" {
+		return nil, fmt.Errorf("search query %q has no repo: qualifier", q)
+	}
+	return query, nil
+}
+
+// splitSearchTokens はqを空白で区切る。ダブルクォートで囲まれた部分は区切らない
+func splitSearchTokens(q string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case r == ' ' && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseDateRange は"A..B"、">=A"、">A"、"<=B"、"<B"、"A"の形式を解釈する
+func parseDateRange(value string) (dateRange, error) {
+	var fromStr, toStr string
+	switch {
+	case strings.Contains(value, ".."):
+		fromStr, toStr, _ = strings.Cut(value, "..")
+	case strings.HasPrefix(value, ">="):
+		fromStr = value[2:]
+	case strings.HasPrefix(value, ">"):
+		fromStr = value[1:]
+	case strings.HasPrefix(value, "<="):
+		toStr = value[2:]
+	case strings.HasPrefix(value, "<"):
+		toStr = value[1:]
+	default:
+		fromStr, toStr = value, value
+	}
+
+	var r dateRange
+	var err error
+	if fromStr != "" && fromStr != "*" {
+		if r.from, err = parseSearchDate(fromStr); err != nil {
+			return r, err
+		}
+	}
+	if toStr != "" && toStr != "*" {
+		if r.to, err = parseSearchDate(toStr); err != nil {
+			return r, err
+		}
+		if !strings.Contains(toStr, "T") {
+			// 日付だけの終端はその日の終わりまでを含む
+			r.to = r.to.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+	return r, nil
+}
+
+func parseSearchDate(value string) (time.Time, error) {
+	for _, layout := range searchDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// match はprがすべての条件を満たすかどうかを返す
+func (q *searchQuery) match(pr *PullRequest) bool {
+	if len(q.states) > 0 && !slices.Contains(q.states, pr.state()) {
+		return false
+	}
+	if q.merged != nil && *q.merged != pr.merged() {
+		return false
+	}
+	for _, label := range q.labels {
+		if !containsFold(pr.Labels, label) {
+			return false
+		}
+	}
+	for _, label := range q.excludeLabels {
+		if containsFold(pr.Labels, label) {
+			return false
+		}
+	}
+	if len(q.authors) > 0 && !containsFold(q.authors, pr.User) {
+		return false
+	}
+	if containsFold(q.excludeAuthors, pr.User) {
+		return false
+	}
+	if q.base != "" && q.base != pr.Base {
+		return false
+	}
+
+	dates := map[string]time.Time{"created": pr.CreatedAt, "merged": pr.MergedAt, "updated": pr.UpdatedAt}
+	for field, r := range q.dates {
+		if !r.contains(dates[field]) {
+			return false
+		}
+	}
+
+	texts := q.searchableTexts(pr)
+	for _, term := range q.terms {
+		found := false
+		for _, text := range texts {
+			if strings.Contains(text, term) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// searchableTexts はin:修飾子で指定された範囲のテキストを小文字にして返す（指定がなければタイトル・本文・コメントすべて）
+func (q *searchQuery) searchableTexts(pr *PullRequest) []string {
+	scopes := q.scopes
+	if len(scopes) == 0 {
+		scopes = []string{"title", "body", "comments"}
+	}
+
+	var texts []string
+	for _, scope := range scopes {
+		switch scope {
+		case "title":
+			texts = append(texts, strings.ToLower(pr.Title))
+		case "body":
+			texts = append(texts, strings.ToLower(pr.Body))
+		case "comments":
+			for _, comment := range pr.IssueComments {
+				texts = append(texts, strings.ToLower(comment.Body))
+			}
+			for _, comment := range pr.ReviewComments {
+				texts = append(texts, strings.ToLower(comment.Body))
+			}
+		}
+	}
+	return texts
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
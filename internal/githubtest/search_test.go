@@ -0,0 +1,63 @@
+package githubtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	pr := &PullRequest{
+		Title:     "Fix SQL injection",
+		Body:      "Use placeholders.",
+		State:     "closed",
+		User:      "alice",
+		Base:      "main",
+		Labels:    []string{"Security"},
+		CreatedAt: time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC),
+		MergedAt:  time.Date(2023, 5, 11, 12, 0, 0, 0, time.UTC),
+		IssueComments: []Comment{
+			{Body: "Thanks for the CVE report"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		q       string
+		want    bool
+		wantErr bool
+	}{
+		{name: "keyword in title", q: "repo:o/r type:pr injection", want: true},
+		{name: "keyword is case insensitive", q: "repo:o/r type:pr INJECTION", want: true},
+		{name: "quoted phrase", q: `repo:o/r "sql injection"`, want: true},
+		{name: "keyword in comments", q: "repo:o/r cve", want: true},
+		{name: "keyword outside scope", q: "repo:o/r cve in:title,body", want: false},
+		{name: "state and merged", q: "repo:o/r is:closed is:merged", want: true},
+		{name: "unmerged", q: "repo:o/r is:unmerged", want: false},
+		{name: "label", q: "repo:o/r label:security", want: true},
+		{name: "excluded label", q: "repo:o/r -label:security", want: false},
+		{name: "excluded author", q: "repo:o/r -author:alice", want: false},
+		{name: "base", q: "repo:o/r base:develop", want: false},
+		{name: "created range", q: "repo:o/r created:2023-05-01..2023-05-10", want: true},
+		{name: "created after", q: "repo:o/r created:>2023-05-10T12:00:01Z", want: false},
+		{name: "merged before", q: "repo:o/r merged:<=2023-05-11", want: true},
+		{name: "updated requires a timestamp", q: "repo:o/r updated:>=2023-01-01", want: false},
+		{name: "repo is required", q: "type:pr injection", wantErr: true},
+		{name: "unsupported qualifier", q: "repo:o/r sort:created", wantErr: true},
+		{name: "invalid date", q: "repo:o/r created:>=yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parseSearchQuery(tt.q)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, query.match(pr))
+		})
+	}
+}
@@ -0,0 +1,547 @@
+// Package githubtest はテスト用に、フィクスチャのデータを返すGitHub REST APIのフェイクサーバーを提供する。
+// 検索・PR・コメント・レビューのエンドポイントとページング、レート制限ヘッダー、403/429の注入に対応する。
+package githubtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultPerPage はper_pageが指定されていない場合の1ページあたりの件数（GitHubと同じ）
+	defaultPerPage = 30
+	// maxPerPage はper_pageの上限（GitHubと同じ）
+	maxPerPage = 100
+	// searchResultCap は検索で取得できる結果数の上限（GitHubと同じ）
+	searchResultCap = 1000
+	// DefaultRateLimit はリソースごとの既定のレート制限（リクエスト数）
+	DefaultRateLimit = 5000
+)
+
+// GitHub APIのレート制限リソース名
+const (
+	ResourceCore   = "core"
+	ResourceSearch = "search"
+)
+
+const (
+	headerRateLimitLimit     = "X-RateLimit-Limit"
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitUsed      = "X-RateLimit-Used"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+	headerRateLimitResource  = "X-RateLimit-Resource"
+	headerRetryAfter         = "Retry-After"
+	headerLink               = "Link"
+
+	// secondaryRateLimitDocURL は二次レート制限のエラーに付くドキュメントのURL（go-githubはこれで二次レート制限と判断する）
+	secondaryRateLimitDocURL = "https://docs.github.com/rest/overview/rate-limits-for-the-rest-api#about-secondary-rate-limits"
+)
+
+// Failure は注入するエラーレスポンス
+type Failure struct {
+	// Path はエラーを返すリクエストのパスの接頭辞（例: "/search/issues"）。空ならすべてのリクエストが対象
+	Path string
+	// Status は返すステータスコード（403や429）
+	Status int
+	// RetryAfter が正ならRetry-Afterヘッダー付きの二次レート制限として返す。
+	// 0の403は一次レート制限を使い切ったレスポンス（X-RateLimit-Remaining: 0）として返す
+	RetryAfter time.Duration
+	// Count は何回エラーを返すか。0なら1回
+	Count int
+}
+
+// rateLimit は1つのリソースのレート制限の状態
+type rateLimit struct {
+	limit     int
+	remaining int
+}
+
+// Server はフィクスチャのデータを返すGitHub APIのフェイクサーバー。複数のgoroutineから同時に使える。
+//
+// レート制限はリソース（core/search）ごとにリクエスト数を数え、使い切ったリクエストには403を返してから回復する。
+// リセット時刻は常に現在時刻を返すため、クライアントはほとんど待たずに再開できる。
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	repos    map[string]*Repository
+	limits   map[string]*rateLimit
+	failures []*Failure
+	requests []string
+}
+
+// NewServer はfixtureのデータを返すフェイクサーバーを起動する。使い終わったらCloseを呼ぶ。
+func NewServer(fixture *Fixture) *Server {
+	s := &Server{
+		repos:  make(map[string]*Repository),
+		limits: make(map[string]*rateLimit),
+	}
+	if fixture != nil {
+		for i := range fixture.Repositories {
+			s.AddRepository(fixture.Repositories[i])
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /search/issues", s.handleSearchIssues)
+	mux.HandleFunc("GET /repos/{owner}/{name}/pulls", s.handleListPullRequests)
+	mux.HandleFunc("GET /repos/{owner}/{name}/pulls/{number}", s.handleGetPullRequest)
+	mux.HandleFunc("GET /repos/{owner}/{name}/pulls/{number}/comments", s.handleReviewComments)
+	mux.HandleFunc("GET /repos/{owner}/{name}/pulls/{number}/reviews", s.handleReviews)
+	mux.HandleFunc("GET /repos/{owner}/{name}/issues/{number}/comments", s.handleIssueComments)
+
+	s.Server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// BaseURL はREST APIのベースURL（末尾に/が付く）を返す。
+func (s *Server) BaseURL() string {
+	return s.URL + "/"
+}
+
+// AddRepository はリポジトリを追加する。同じowner/nameのリポジトリがあれば置き換える。
+func (s *Server) AddRepository(repo Repository) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repos[repoKey(repo.Owner, repo.Name)] = &repo
+}
+
+// SetRateLimit はresourceのレート制限をlimitリクエストにし、残量を満タンにする。
+func (s *Server) SetRateLimit(resource string, limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits[resource] = &rateLimit{limit: limit, remaining: limit}
+}
+
+// Fail は条件に一致する次のリクエストからfailure.Count回、エラーを返すようにする。
+func (s *Server) Fail(failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if failure.Count <= 0 {
+		failure.Count = 1
+	}
+	s.failures = append(s.failures, &failure)
+}
+
+// Requests はこれまでに受け取ったリクエストを"GET /path?query"の形式で返す。
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// CountRequests はパスがprefixで始まるリクエストの数を返す。
+func (s *Server) CountRequests(prefix string) int {
+	count := 0
+	for _, request := range s.Requests() {
+		_, target, _ := strings.Cut(request, " ")
+		if strings.HasPrefix(target, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func repoKey(owner, name string) string {
+	return strings.ToLower(owner + "/" + name)
+}
+
+/**
+ * すべてのリクエストを記録し、レート制限ヘッダーを付ける
+ * 注入したエラーや、使い切ったレート制限のエラーがあればハンドラーを呼ばずにそれを返す
+ */
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resource := ResourceCore
+		if strings.HasPrefix(r.URL.Path, "/search/") {
+			resource = ResourceSearch
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		failure := s.takeFailureLocked(r.URL.Path)
+		limit := s.limitLocked(resource)
+		exhausted := limit.remaining <= 0
+		if exhausted {
+			// 使い切ったことを伝えた時点でリセットしたものとする
+			limit.remaining = limit.limit
+		} else if failure == nil {
+			limit.remaining--
+		}
+		remaining := limit.remaining
+		if exhausted {
+			remaining = 0
+		}
+		s.mu.Unlock()
+
+		header := w.Header()
+		header.Set(headerRateLimitLimit, strconv.Itoa(limit.limit))
+		header.Set(headerRateLimitRemaining, strconv.Itoa(remaining))
+		header.Set(headerRateLimitUsed, strconv.Itoa(limit.limit-remaining))
+		header.Set(headerRateLimitReset, strconv.FormatInt(time.Now().Unix(), 10))
+		header.Set(headerRateLimitResource, resource)
+
+		switch {
+		case failure != nil:
+			writeFailure(w, failure)
+		case exhausted:
+			writeError(w, http.StatusForbidden, "API rate limit exceeded", "")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func (s *Server) takeFailureLocked(path string) *Failure {
+	for i, failure := range s.failures {
+		if !strings.HasPrefix(path, failure.Path) {
+			continue
+		}
+		failure.Count--
+		if failure.Count <= 0 {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+		}
+		return failure
+	}
+	return nil
+}
+
+func (s *Server) limitLocked(resource string) *rateLimit {
+	limit, ok := s.limits[resource]
+	if !ok {
+		limit = &rateLimit{limit: DefaultRateLimit, remaining: DefaultRateLimit}
+		s.limits[resource] = limit
+	}
+	return limit
+}
+
+func writeFailure(w http.ResponseWriter, failure *Failure) {
+	if failure.RetryAfter > 0 {
+		w.Header().Set(headerRetryAfter, strconv.Itoa(int(failure.RetryAfter.Round(time.Second)/time.Second)))
+		writeError(w, failure.Status, "You have exceeded a secondary rate limit", secondaryRateLimitDocURL)
+		return
+	}
+	if failure.Status == http.StatusForbidden || failure.Status == http.StatusTooManyRequests {
+		w.Header().Set(headerRateLimitRemaining, "0")
+		w.Header().Set(headerRateLimitUsed, w.Header().Get(headerRateLimitLimit))
+		writeError(w, failure.Status, "API rate limit exceeded", "")
+		return
+	}
+	writeError(w, failure.Status, http.StatusText(failure.Status), "")
+}
+
+func writeError(w http.ResponseWriter, status int, message, documentationURL string) {
+	body := map[string]string{"message": message}
+	if documentationURL != "" {
+		body["documentation_url"] = documentationURL
+	}
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// repository はパスのowner/nameのリポジトリを返す。見つからなければ404を書き込んでnilを返す。
+func (s *Server) repository(w http.ResponseWriter, r *http.Request) *Repository {
+	s.mu.Lock()
+	repo := s.repos[repoKey(r.PathValue("owner"), r.PathValue("name"))]
+	s.mu.Unlock()
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "Not Found", "")
+	}
+	return repo
+}
+
+// pullRequest はパスのPRを返す。見つからなければ404を書き込んでnilを返す。
+func (s *Server) pullRequest(w http.ResponseWriter, r *http.Request) (*Repository, *PullRequest) {
+	repo := s.repository(w, r)
+	if repo == nil {
+		return nil, nil
+	}
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err == nil {
+		for i := range repo.PullRequests {
+			if repo.PullRequests[i].Number == number {
+				return repo, &repo.PullRequests[i]
+			}
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found", "")
+	return nil, nil
+}
+
+/**
+ * itemsのうちpage・per_pageで指定されたページを返し、次のページと最後のページのLinkヘッダーを付ける
+ * ページ番号が不正な場合は400を書き込んでokにfalseを返す
+ */
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T) (page []T, ok bool) {
+	query := r.URL.Query()
+	pageNumber, perPage := 1, defaultPerPage
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid page", "")
+			return nil, false
+		}
+		pageNumber = n
+	}
+	if v := query.Get("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid per_page", "")
+			return nil, false
+		}
+		perPage = min(n, maxPerPage)
+	}
+
+	lastPage := max((len(items)+perPage-1)/perPage, 1)
+	var links []string
+	if pageNumber < lastPage {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(r, pageNumber+1)))
+		links = append(links, fmt.Sprintf(`<%s>; rel="last"`, pageURL(r, lastPage)))
+	}
+	if len(links) > 0 {
+		w.Header().Set(headerLink, strings.Join(links, ", "))
+	}
+
+	start := (pageNumber - 1) * perPage
+	if start >= len(items) {
+		return []T{}, true
+	}
+	return items[start:min(start+perPage, len(items))], true
+}
+
+func pageURL(r *http.Request, page int) string {
+	u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+	query := r.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (s *Server) handleListPullRequests(w http.ResponseWriter, r *http.Request) {
+	repo := s.repository(w, r)
+	if repo == nil {
+		return
+	}
+
+	query := r.URL.Query()
+	state := query.Get("state")
+	if state == "" {
+		state = "open"
+	}
+	var prs []*PullRequest
+	for i := range repo.PullRequests {
+		pr := &repo.PullRequests[i]
+		if state == "all" || pr.state() == state {
+			prs = append(prs, pr)
+		}
+	}
+
+	// GitHubと同じく、既定では作成日時の新しい順
+	sortBy := query.Get("sort")
+	direction := query.Get("direction")
+	if direction == "" {
+		direction = "desc"
+		if sortBy != "" && sortBy != "created" {
+			direction = "asc"
+		}
+	}
+	sort.SliceStable(prs, func(i, j int) bool {
+		a, b := prs[i].CreatedAt, prs[j].CreatedAt
+		if sortBy == "updated" {
+			a, b = prs[i].UpdatedAt, prs[j].UpdatedAt
+		}
+		if a.Equal(b) {
+			a, b = time.Unix(int64(prs[i].Number), 0), time.Unix(int64(prs[j].Number), 0)
+		}
+		if direction == "asc" {
+			return a.Before(b)
+		}
+		return a.After(b)
+	})
+
+	page, ok := paginate(w, r, prs)
+	if !ok {
+		return
+	}
+	payload := make([]any, len(page))
+	for i, pr := range page {
+		payload[i] = pullRequestJSON(r, repo, pr)
+	}
+	writeJSON(w, http.StatusOK, payload)
+}
+
+func (s *Server) handleGetPullRequest(w http.ResponseWriter, r *http.Request) {
+	repo, pr := s.pullRequest(w, r)
+	if pr == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, pullRequestJSON(r, repo, pr))
+}
+
+func (s *Server) handleIssueComments(w http.ResponseWriter, r *http.Request) {
+	_, pr := s.pullRequest(w, r)
+	if pr == nil {
+		return
+	}
+	page, ok := paginate(w, r, pr.IssueComments)
+	if !ok {
+		return
+	}
+	payload := make([]any, len(page))
+	for i, comment := range page {
+		payload[i] = map[string]any{
+			"id":         comment.ID,
+			"body":       comment.Body,
+			"user":       userJSON(comment.User),
+			"created_at": comment.CreatedAt,
+			"updated_at": comment.CreatedAt,
+		}
+	}
+	writeJSON(w, http.StatusOK, payload)
+}
+
+func (s *Server) handleReviewComments(w http.ResponseWriter, r *http.Request) {
+	_, pr := s.pullRequest(w, r)
+	if pr == nil {
+		return
+	}
+	page, ok := paginate(w, r, pr.ReviewComments)
+	if !ok {
+		return
+	}
+	payload := make([]any, len(page))
+	for i, comment := range page {
+		item := map[string]any{
+			"id":         comment.ID,
+			"body":       comment.Body,
+			"user":       userJSON(comment.User),
+			"path":       comment.Path,
+			"created_at": comment.CreatedAt,
+			"updated_at": comment.CreatedAt,
+		}
+		if comment.InReplyTo != 0 {
+			item["in_reply_to_id"] = comment.InReplyTo
+		}
+		payload[i] = item
+	}
+	writeJSON(w, http.StatusOK, payload)
+}
+
+func (s *Server) handleReviews(w http.ResponseWriter, r *http.Request) {
+	_, pr := s.pullRequest(w, r)
+	if pr == nil {
+		return
+	}
+	page, ok := paginate(w, r, pr.Reviews)
+	if !ok {
+		return
+	}
+	payload := make([]any, len(page))
+	for i, review := range page {
+		payload[i] = map[string]any{
+			"id":           review.ID,
+			"body":         review.Body,
+			"user":         userJSON(review.User),
+			"state":        review.State,
+			"submitted_at": review.SubmittedAt,
+		}
+	}
+	writeJSON(w, http.StatusOK, payload)
+}
+
+/**
+ * /search/issuesのクエリに一致するPRを番号順に返す
+ * total_countは一致した件数をそのまま返し、取得できる結果はGitHubと同じく先頭の1000件までにする
+ */
+func (s *Server) handleSearchIssues(w http.ResponseWriter, r *http.Request) {
+	query, err := parseSearchQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error(), "")
+		return
+	}
+
+	s.mu.Lock()
+	repo := s.repos[query.repo]
+	s.mu.Unlock()
+	if repo == nil {
+		writeError(w, http.StatusUnprocessableEntity, "The listed users and repositories cannot be searched", "")
+		return
+	}
+
+	var matched []*PullRequest
+	for i := range repo.PullRequests {
+		if query.match(&repo.PullRequests[i]) {
+			matched = append(matched, &repo.PullRequests[i])
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Number < matched[j].Number })
+
+	total := len(matched)
+	if len(matched) > searchResultCap {
+		matched = matched[:searchResultCap]
+	}
+	page, ok := paginate(w, r, matched)
+	if !ok {
+		return
+	}
+
+	items := make([]any, len(page))
+	for i, pr := range page {
+		items[i] = map[string]any{
+			"number":     pr.Number,
+			"title":      pr.Title,
+			"state":      pr.state(),
+			"created_at": pr.CreatedAt,
+			"updated_at": pr.UpdatedAt,
+			"pull_request": map[string]any{
+				"url": fmt.Sprintf("http://%s/repos/%s/%s/pulls/%d", r.Host, repo.Owner, repo.Name, pr.Number),
+			},
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"total_count":        total,
+		"incomplete_results": false,
+		"items":              items,
+	})
+}
+
+func pullRequestJSON(r *http.Request, repo *Repository, pr *PullRequest) map[string]any {
+	labels := make([]any, len(pr.Labels))
+	for i, label := range pr.Labels {
+		labels[i] = map[string]any{"name": label}
+	}
+	payload := map[string]any{
+		"number":     pr.Number,
+		"title":      pr.Title,
+		"body":       pr.Body,
+		"state":      pr.state(),
+		"user":       userJSON(pr.User),
+		"labels":     labels,
+		"base":       map[string]any{"ref": pr.Base},
+		"merged":     pr.merged(),
+		"created_at": pr.CreatedAt,
+		"updated_at": pr.UpdatedAt,
+		"html_url":   fmt.Sprintf("http://%s/%s/%s/pull/%d", r.Host, repo.Owner, repo.Name, pr.Number),
+	}
+	if pr.merged() {
+		payload["merged_at"] = pr.MergedAt
+	}
+	return payload
+}
+
+func userJSON(login string) map[string]any {
+	return map[string]any{"login": login}
+}
@@ -0,0 +1,82 @@
+// Package gitlab はGitLabのMerge Requestをキーワードで検索し、一覧と会話を取得するクライアントを提供する。
+// Merge Requestとそのノート・ディスカッションはGitHubのPRと同じ形に変換して返すため、
+// 保存形式やLLMに渡す形式はGitHubから取得した場合と変わらない。
+package gitlab
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/malsuke/PRalyzer/internal/forge"
+)
+
+// DefaultHost はGitLab.comのホスト
+const DefaultHost = "gitlab.com"
+
+// TokenEnvVar はGitLabのアクセストークンを渡す環境変数
+const TokenEnvVar = "GITLAB_TOKEN"
+
+const (
+	// perPage は一覧系APIで1ページに取得する件数（GitLabの上限）
+	perPage = 100
+	// perPageParam は1ページの件数を指定するクエリパラメータ名
+	perPageParam = "per_page"
+	// headerNextPage は次のページの番号を返すヘッダー
+	headerNextPage = "X-Next-Page"
+)
+
+// Client はGitLabのREST API（v4）のクライアント。
+// ForProjectで対象のプロジェクトを指定したClientで、Merge Requestを検索・取得する。
+// リクエスト・ページ送り・レート制限の待機（OnLongWaitなど）はforge.Clientが扱う。
+type Client struct {
+	// Project はnamespace/projectの形式のプロジェクトのパス（サブグループを含むことがある）
+	Project string
+
+	*forge.Client
+}
+
+/**
+ * hostのGitLabに接続するClientを作成する
+ * tokenが空の場合は認証せずに公開プロジェクトだけを読む
+ * httpClientがnilの場合はhttp.DefaultClientを使う
+ */
+func NewClient(token string, host string, httpClient *http.Client, opts ...forge.Option) (*Client, error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if host == "" {
+		host = DefaultHost
+	}
+
+	config := forge.Config{
+		Service:    "GitLab",
+		APIPath:    "api/v4/",
+		HTTPClient: httpClient,
+		Paging:     forge.Paging{SizeParam: perPageParam, PerPage: perPage, Next: nextPage},
+	}
+	if token != "" {
+		config.Authorization = "Bearer " + token
+	}
+	rest, err := forge.New(host, config, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Client: rest}, nil
+}
+
+// ForProject はprojectのMerge Requestを扱うClientを返す。認証と接続先はcと共有する。
+func (c *Client) ForProject(project string) *Client {
+	return &Client{Project: strings.Trim(project, "/"), Client: c.Client}
+}
+
+// projectPath はプロジェクト配下のAPIのパスを返す（プロジェクトのパスはURLエンコードしてIDとして渡す）
+func (c *Client) projectPath(format string, args ...any) string {
+	return "projects/" + url.PathEscape(c.Project) + fmt.Sprintf(format, args...)
+}
+
+// nextPage はX-Next-Pageヘッダーから次のページの番号を返す（なければ0）
+func nextPage(_, _ int, header http.Header) int {
+	next, _ := strconv.Atoi(header.Get(headerNextPage))
+	return next
+}
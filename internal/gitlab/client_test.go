@@ -0,0 +1,321 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/malsuke/PRalyzer/internal/forge"
+	"github.com/malsuke/PRalyzer/internal/forgetest"
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testProject = "group/sub/project"
+
+// fakeGitLab はテスト用のGitLab API（v4）のスタンドイン。Merge Request・ノートの検索・ディスカッションだけに対応する
+type fakeGitLab struct {
+	*forgetest.Server
+
+	mergeRequests []mergeRequest
+	notes         []searchNote
+	discussions   map[int][]discussion
+	// pageSize は一覧のページの大きさの上限（0ならper_pageに従う）
+	pageSize int
+}
+
+func newFakeGitLab(t *testing.T) *fakeGitLab {
+	t.Helper()
+
+	f := &fakeGitLab{discussions: make(map[int][]discussion)}
+	prefix := "/api/v4/projects/" + strings.ReplaceAll(testProject, "/", "%2F")
+	f.Server = forgetest.NewServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, ok := strings.CutPrefix(r.URL.EscapedPath(), prefix)
+		if !ok {
+			forgetest.WriteError(w, http.StatusNotFound, "404 Project Not Found")
+			return
+		}
+		switch {
+		case path == "/merge_requests":
+			writePage(w, r, f.pageSize, f.listMergeRequests(r))
+		case path == "/search":
+			var notes []searchNote
+			for _, n := range f.notes {
+				if r.URL.Query().Get("scope") == "notes" {
+					notes = append(notes, n)
+				}
+			}
+			writePage(w, r, f.pageSize, notes)
+		case strings.HasSuffix(path, "/discussions"):
+			iid, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, "/merge_requests/"), "/discussions"))
+			writePage(w, r, f.pageSize, f.discussions[iid])
+		case strings.HasPrefix(path, "/merge_requests/"):
+			iid, _ := strconv.Atoi(strings.TrimPrefix(path, "/merge_requests/"))
+			for _, mr := range f.mergeRequests {
+				if mr.IID == iid {
+					forgetest.WriteJSON(w, mr)
+					return
+				}
+			}
+			forgetest.WriteError(w, http.StatusNotFound, "404 Not found")
+		default:
+			forgetest.WriteError(w, http.StatusNotFound, "404 Not Found")
+		}
+	}))
+	return f
+}
+
+// listMergeRequests はstate・search/in・iids[]・author_usernameで一覧を絞り込む
+func (f *fakeGitLab) listMergeRequests(r *http.Request) []mergeRequest {
+	query := r.URL.Query()
+	var mrs []mergeRequest
+	for _, mr := range f.mergeRequests {
+		if state := query.Get("state"); state != "" && state != stateAll && state != mr.State {
+			continue
+		}
+		if iids := query["iids[]"]; len(iids) > 0 && !slices.Contains(iids, strconv.Itoa(mr.IID)) {
+			continue
+		}
+		if author := query.Get("author_username"); author != "" && author != mr.author() {
+			continue
+		}
+		if search := strings.ToLower(query.Get("search")); search != "" {
+			in := query.Get("in")
+			found := strings.Contains(in, "title") && strings.Contains(strings.ToLower(mr.Title), search) ||
+				strings.Contains(in, "description") && strings.Contains(strings.ToLower(mr.Description), search)
+			if !found {
+				continue
+			}
+		}
+		mrs = append(mrs, mr)
+	}
+	return mrs
+}
+
+// writePage はitemsのうちpage・per_pageのページを返し、続きがあればX-Next-Pageを付ける
+func writePage[T any](w http.ResponseWriter, r *http.Request, pageSize int, items []T) {
+	page, start, end := forgetest.Page(r, perPageParam, len(items), pageSize)
+	if end < len(items) {
+		w.Header().Set(headerNextPage, strconv.Itoa(page+1))
+	}
+	forgetest.WriteJSON(w, append([]T{}, items[start:end]...))
+}
+
+func (f *fakeGitLab) client(t *testing.T, token string) *Client {
+	t.Helper()
+
+	client, err := NewClient(token, "gitlab.example.com", nil, forge.WithBaseURL(f.URL+"/api/v4"))
+	require.NoError(t, err)
+	return client.ForProject(testProject)
+}
+
+func date(day int) time.Time {
+	return time.Date(2024, 1, day, 9, 0, 0, 0, time.UTC)
+}
+
+func datePtr(day int) *time.Time {
+	t := date(day)
+	return &t
+}
+
+func seedMergeRequests(f *fakeGitLab) {
+	f.mergeRequests = []mergeRequest{
+		{IID: 1, Title: "Sanitize filenames", Description: "Prevents path traversal.", State: stateMerged, Author: &user{Username: "alice"}, Labels: []string{"security"}, CreatedAt: date(1), UpdatedAt: date(3), MergedAt: datePtr(3)},
+		{IID: 2, Title: "Refactor upload", Description: "", State: stateMerged, Author: &user{Username: "bob"}, CreatedAt: date(2), UpdatedAt: date(5), MergedAt: datePtr(5)},
+		{IID: 3, Title: "WIP traversal tests", Description: "", State: stateOpened, Author: &user{Username: "carol"}, CreatedAt: date(4), UpdatedAt: date(6)},
+		{IID: 4, Title: "Abandoned", Description: "traversal", State: stateClosed, Author: &user{Username: "bob"}, CreatedAt: date(5), UpdatedAt: date(7), ClosedAt: datePtr(7)},
+	}
+	f.notes = []searchNote{
+		{ID: 10, NoteableType: noteableMergeRequest, NoteableIID: 2},
+		{ID: 11, NoteableType: noteableMergeRequest, NoteableIID: 3},
+		{ID: 12, NoteableType: "Issue", NoteableIID: 9},
+		{ID: 13, NoteableType: noteableMergeRequest, NoteableIID: 2},
+	}
+}
+
+func TestClient_SearchPullRequestResults(t *testing.T) {
+	tests := []struct {
+		name  string
+		query github.SearchQuery
+		want  []int
+	}{
+		{name: "merged MRs with the keyword in comments", query: github.DefaultSearchQuery("traversal"), want: []int{2}},
+		{name: "keyword in comments in any state", query: github.SearchQuery{Keyword: "traversal", Scopes: []github.SearchScope{github.ScopeComments}}, want: []int{2, 3}},
+		{name: "keyword in title", query: github.SearchQuery{Keyword: "traversal", Scopes: []github.SearchScope{github.ScopeTitle}}, want: []int{3}},
+		{name: "keyword anywhere", query: github.SearchQuery{Keyword: "traversal"}, want: []int{1, 2, 3, 4}},
+		{name: "closed includes merged", query: github.SearchQuery{Keyword: "traversal", State: github.StateClosed}, want: []int{1, 2, 4}},
+		{name: "closed and unmerged", query: github.SearchQuery{Keyword: "traversal", State: github.StateClosed, Merged: github.MergeUnmerged}, want: []int{4}},
+		{name: "several authors", query: github.SearchQuery{Authors: []string{"alice", "carol"}}, want: []int{1, 3}},
+		{name: "excluded author and label", query: github.SearchQuery{ExcludeAuthors: []string{"bob"}, ExcludeLabels: []string{"security"}}, want: []int{3}},
+		{name: "merged within a range", query: github.SearchQuery{MergedAt: github.DateRange{From: date(4)}}, want: []int{2}},
+		{name: "updated since", query: github.SearchQuery{Updated: github.DateRange{From: date(6)}}, want: []int{3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeGitLab(t)
+			seedMergeRequests(f)
+
+			results, err := f.client(t, "").SearchPullRequestResults(context.Background(), tt.query)
+			require.NoError(t, err)
+
+			var numbers []int
+			for _, result := range results {
+				numbers = append(numbers, result.Number)
+			}
+			assert.Equal(t, tt.want, numbers)
+		})
+	}
+}
+
+func TestClient_SearchPassesFiltersToGitLab(t *testing.T) {
+	f := newFakeGitLab(t)
+	seedMergeRequests(f)
+
+	query := github.DefaultSearchQuery("traversal")
+	query.Labels = []string{"security", "backend"}
+	query.Base = "main"
+	query.Created = github.DateRange{From: date(1), To: date(31)}
+	query.Updated = github.DateRange{From: date(2)}
+	_, err := f.client(t, "").SearchPullRequestResults(context.Background(), query)
+	require.NoError(t, err)
+
+	searches := f.Requests("/search")
+	require.Len(t, searches, 1)
+	assert.Equal(t, "notes", searches[0].URL.Query().Get("scope"))
+	assert.Equal(t, "traversal", searches[0].URL.Query().Get("search"))
+
+	lists := f.Requests("/merge_requests")
+	require.Len(t, lists, 1)
+	params := lists[0].URL.Query()
+	assert.Equal(t, []string{"2", "3"}, params["iids[]"])
+	assert.Equal(t, stateMerged, params.Get("state"))
+	assert.Equal(t, "security,backend", params.Get("labels"))
+	assert.Equal(t, "main", params.Get("target_branch"))
+	assert.Equal(t, "2024-01-01T09:00:00Z", params.Get("created_after"))
+	assert.Equal(t, "2024-01-31T09:00:00Z", params.Get("created_before"))
+	assert.Equal(t, "2024-01-02T09:00:00Z", params.Get("updated_after"))
+	assert.Empty(t, params.Get("search"))
+}
+
+func TestClient_PullRequests(t *testing.T) {
+	f := newFakeGitLab(t)
+	seedMergeRequests(f)
+	f.pageSize = 3
+
+	var numbers []int
+	for pr, err := range f.client(t, "").PullRequests(context.Background()) {
+		require.NoError(t, err)
+		numbers = append(numbers, pr.GetNumber())
+	}
+
+	assert.Equal(t, []int{1, 2, 3, 4}, numbers)
+	assert.Len(t, f.Requests("/merge_requests"), 2)
+}
+
+func TestClient_FetchConversations(t *testing.T) {
+	f := newFakeGitLab(t)
+	seedMergeRequests(f)
+	f.mergeRequests[0].WebURL = "https://gitlab.example.com/group/sub/project/-/merge_requests/1"
+	f.mergeRequests[0].TargetBranch = "main"
+	f.mergeRequests[0].SourceBranch = "fix-traversal"
+	f.mergeRequests[0].ChangesCount = "3"
+	line := 42
+	oldLine := 7
+	f.discussions[1] = []discussion{
+		{ID: "a", IndividualNote: true, Notes: []note{
+			{ID: 100, Body: "Does this cover symlinks?", Author: &user{Username: "bob"}, CreatedAt: date(1)},
+		}},
+		{ID: "b", Notes: []note{
+			{ID: 200, Type: noteTypeDiff, Body: "Use filepath.Clean here.", Author: &user{Username: "bob"}, Resolvable: true, Resolved: true, CreatedAt: date(2),
+				Position: &position{HeadSHA: "abc", OldPath: "upload.go", NewPath: "upload.go", PositionType: "text", NewLine: &line}},
+			{ID: 201, Type: noteTypeDiff, Body: "Done.", Author: &user{Username: "alice"}, Resolvable: true, Resolved: true, CreatedAt: date(2),
+				Position: &position{HeadSHA: "abc", OldPath: "upload.go", NewPath: "upload.go", PositionType: "text", NewLine: &line}},
+		}},
+		{ID: "c", Notes: []note{
+			{ID: 300, Type: noteTypeDiff, Body: "Why was this removed?", Author: &user{Username: "carol"}, Resolvable: true, CreatedAt: date(2),
+				Position: &position{HeadSHA: "abc", OldPath: "legacy.go", PositionType: "text", OldLine: &oldLine}},
+		}},
+		{ID: "d", IndividualNote: true, Notes: []note{
+			{ID: 400, Body: "approved this merge request", System: true, Author: &user{Username: "bob"}, CreatedAt: date(3)},
+		}},
+		{ID: "e", IndividualNote: true, Notes: []note{
+			{ID: 500, Body: "added 1 commit", System: true, Author: &user{Username: "alice"}, CreatedAt: date(3)},
+		}},
+	}
+	f.pageSize = 2
+
+	conversations, err := f.client(t, "secret").FetchConversations(context.Background(), []int{1, 99})
+	require.Error(t, err)
+	require.Len(t, conversations, 1)
+	assert.Equal(t, "Bearer secret", f.Authorizations()[0])
+
+	conversation := conversations[1]
+	pr := conversation.PullRequest
+	assert.Equal(t, 1, pr.GetNumber())
+	assert.Equal(t, "closed", pr.GetState())
+	assert.True(t, pr.GetMerged())
+	assert.Equal(t, "alice", pr.GetUser().GetLogin())
+	assert.Equal(t, "main", pr.GetBase().GetRef())
+	assert.Equal(t, "fix-traversal", pr.GetHead().GetRef())
+	assert.Equal(t, 3, pr.GetChangedFiles())
+	assert.Equal(t, "security", pr.Labels[0].GetName())
+
+	require.Len(t, conversation.IssueComments, 1)
+	assert.Equal(t, "Does this cover symlinks?", conversation.IssueComments[0].GetBody())
+	assert.Equal(t, "https://gitlab.example.com/group/sub/project/-/merge_requests/1#note_100", conversation.IssueComments[0].GetHTMLURL())
+
+	require.Len(t, conversation.ReviewComments, 3)
+	root, reply, removed := conversation.ReviewComments[0], conversation.ReviewComments[1], conversation.ReviewComments[2]
+	assert.Equal(t, "upload.go", root.GetPath())
+	assert.Equal(t, 42, root.GetLine())
+	assert.Equal(t, "RIGHT", root.GetSide())
+	assert.Zero(t, root.GetInReplyTo())
+	assert.Equal(t, int64(200), reply.GetInReplyTo())
+	assert.Equal(t, "legacy.go", removed.GetPath())
+	assert.Equal(t, 7, removed.GetLine())
+	assert.Equal(t, "LEFT", removed.GetSide())
+	assert.Equal(t, map[int64]bool{200: true, 300: false}, conversation.ResolvedThreads)
+
+	require.Len(t, conversation.Reviews, 1)
+	assert.Equal(t, "APPROVED", conversation.Reviews[0].GetState())
+	assert.Equal(t, "bob", conversation.Reviews[0].GetUser().GetLogin())
+
+	assert.Len(t, f.Requests("/merge_requests/1/discussions"), 3)
+}
+
+func TestParseProjectRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		ref     string
+		want    github.RepositoryRef
+		wantErr bool
+	}{
+		{name: "path", ref: "group/project", want: github.RepositoryRef{Host: DefaultHost, Owner: "group", Name: "project"}},
+		{name: "subgroup", ref: "group/sub/project", want: github.RepositoryRef{Host: DefaultHost, Owner: "group/sub", Name: "project"}},
+		{name: "url", ref: "https://GitLab.example.com/group/sub/project.git", want: github.RepositoryRef{Host: "gitlab.example.com", Owner: "group/sub", Name: "project"}},
+		{name: "merge request url", ref: "https://gitlab.com/group/project/-/merge_requests/12", want: github.RepositoryRef{Host: DefaultHost, Owner: "group", Name: "project"}},
+		{name: "ssh", ref: "git@gitlab.example.com:group/project.git", want: github.RepositoryRef{Host: "gitlab.example.com", Owner: "group", Name: "project"}},
+		{name: "empty", ref: " ", wantErr: true},
+		{name: "name only", ref: "project", wantErr: true},
+		{name: "trailing slash only", ref: "https://gitlab.com/group/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProjectRef(tt.ref)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want.Owner+"/"+tt.want.Name, ProjectPath(got))
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/forge"
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// ノートの種類（typeの値）
+const (
+	noteTypeDiff = "DiffNote"
+)
+
+// diffの位置の種類（position_typeの値）
+const (
+	positionTypeFile = "file"
+)
+
+// reviewSystemNotes はレビューとして扱うシステムノートの本文と、対応するレビューのstate
+var reviewSystemNotes = map[string]string{
+	"approved this merge request": github.ReviewApproved,
+	"requested changes":           github.ReviewChangesRequested,
+}
+
+// discussion はMerge Requestのディスカッション（スレッド）。individual_noteがtrueなら返信のない単独のコメント
+type discussion struct {
+	ID             string `json:"id"`
+	IndividualNote bool   `json:"individual_note"`
+	Notes          []note `json:"notes"`
+}
+
+// note はディスカッション内の1件のコメント
+type note struct {
+	ID         int64     `json:"id"`
+	Type       string    `json:"type"`
+	Body       string    `json:"body"`
+	Author     *user     `json:"author"`
+	System     bool      `json:"system"`
+	Resolvable bool      `json:"resolvable"`
+	Resolved   bool      `json:"resolved"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Position   *position `json:"position"`
+}
+
+// position はdiffに付いたコメントの位置
+type position struct {
+	HeadSHA      string `json:"head_sha"`
+	OldPath      string `json:"old_path"`
+	NewPath      string `json:"new_path"`
+	PositionType string `json:"position_type"`
+	OldLine      *int   `json:"old_line"`
+	NewLine      *int   `json:"new_line"`
+}
+
+/**
+ * Merge Requestごとに本体とディスカッションを取得し、PRの会話の形に変換する
+ * diffに付いたディスカッションはReview Comments（2件目以降は先頭のコメントへの返信）、
+ * それ以外のコメントはIssue Comments、承認などのシステムノートはReviewsにする
+ * diffのディスカッションの解決状態は先頭のコメントのIDをキーにしてResolvedThreadsに設定する
+ */
+func (c *Client) FetchConversations(ctx context.Context, iids []int) (map[int]*github.Conversation, error) {
+	conversations := make(map[int]*github.Conversation, len(iids))
+	var errs []error
+
+	for _, iid := range iids {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		conversation, err := c.fetchConversation(ctx, iid)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		conversations[iid] = conversation
+	}
+
+	return conversations, errors.Join(errs...)
+}
+
+func (c *Client) fetchConversation(ctx context.Context, iid int) (*github.Conversation, error) {
+	pullRequest, err := c.GetMergeRequest(ctx, iid)
+	if err != nil {
+		return nil, err
+	}
+
+	discussions, err := forge.ListAll[discussion](ctx, c.Client, c.projectPath("/merge_requests/%d/discussions", iid), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list discussions of merge request !%d: %w", iid, err)
+	}
+
+	conversation := newConversation(discussions, pullRequest.GetHTMLURL())
+	conversation.PullRequest = pullRequest
+	return conversation, nil
+}
+
+// newConversation はディスカッションをPRの会話の形に振り分ける。webURLはコメントのURLの組み立てに使う
+func newConversation(discussions []discussion, webURL string) *github.Conversation {
+	conversation := &github.Conversation{}
+	for _, d := range discussions {
+		if len(d.Notes) == 0 {
+			continue
+		}
+		root := d.Notes[0]
+
+		if root.Type == noteTypeDiff && root.Position != nil {
+			for _, n := range d.Notes {
+				if n.System {
+					continue
+				}
+				comment := n.reviewComment(webURL)
+				if n.ID != root.ID {
+					comment.InReplyTo = gh.Ptr(root.ID)
+				}
+				conversation.ReviewComments = append(conversation.ReviewComments, comment)
+			}
+			if root.Resolvable {
+				if conversation.ResolvedThreads == nil {
+					conversation.ResolvedThreads = make(map[int64]bool)
+				}
+				conversation.ResolvedThreads[root.ID] = root.Resolved
+			}
+			continue
+		}
+
+		for _, n := range d.Notes {
+			if n.System {
+				if review := n.review(); review != nil {
+					conversation.Reviews = append(conversation.Reviews, review)
+				}
+				continue
+			}
+			conversation.IssueComments = append(conversation.IssueComments, n.issueComment(webURL))
+		}
+	}
+	return conversation
+}
+
+func (n *note) issueComment(webURL string) *gh.IssueComment {
+	return &gh.IssueComment{
+		ID:        gh.Ptr(n.ID),
+		Body:      gh.Ptr(n.Body),
+		User:      n.Author.githubUser(),
+		HTMLURL:   gh.Ptr(n.url(webURL)),
+		CreatedAt: timestamp(&n.CreatedAt),
+		UpdatedAt: timestamp(&n.UpdatedAt),
+	}
+}
+
+/**
+ * diffに付いたノートをReview Commentに変換する
+ * 新しい側の行があればその行（RIGHT）、削除された行だけに付いていれば古い側の行（LEFT）を行番号とする
+ * 行を持たないファイル全体へのコメントはsubject_typeをfileにする
+ */
+func (n *note) reviewComment(webURL string) *gh.PullRequestComment {
+	comment := &gh.PullRequestComment{
+		ID:        gh.Ptr(n.ID),
+		Body:      gh.Ptr(n.Body),
+		User:      n.Author.githubUser(),
+		HTMLURL:   gh.Ptr(n.url(webURL)),
+		CreatedAt: timestamp(&n.CreatedAt),
+		UpdatedAt: timestamp(&n.UpdatedAt),
+	}
+	if n.Position == nil {
+		return comment
+	}
+
+	path := n.Position.NewPath
+	if path == "" {
+		path = n.Position.OldPath
+	}
+	comment.Path = gh.Ptr(path)
+	comment.CommitID = gh.Ptr(n.Position.HeadSHA)
+	comment.OriginalCommitID = gh.Ptr(n.Position.HeadSHA)
+
+	switch {
+	case n.Position.PositionType == positionTypeFile:
+		comment.SubjectType = gh.Ptr(github.SubjectTypeFile)
+	case n.Position.NewLine != nil:
+		comment.SubjectType = gh.Ptr(github.SubjectTypeLine)
+		comment.Side = gh.Ptr(github.SideRight)
+		comment.Line = gh.Ptr(*n.Position.NewLine)
+		comment.OriginalLine = gh.Ptr(*n.Position.NewLine)
+	case n.Position.OldLine != nil:
+		comment.SubjectType = gh.Ptr(github.SubjectTypeLine)
+		comment.Side = gh.Ptr(github.SideLeft)
+		comment.Line = gh.Ptr(*n.Position.OldLine)
+		comment.OriginalLine = gh.Ptr(*n.Position.OldLine)
+	}
+	return comment
+}
+
+// review は承認や変更の要求を表すシステムノートをReviewに変換する。それ以外のシステムノートはnilを返す
+func (n *note) review() *gh.PullRequestReview {
+	state, ok := reviewSystemNotes[strings.TrimSpace(n.Body)]
+	if !ok {
+		return nil
+	}
+	return &gh.PullRequestReview{
+		ID:          gh.Ptr(n.ID),
+		User:        n.Author.githubUser(),
+		Body:        gh.Ptr(""),
+		State:       gh.Ptr(state),
+		SubmittedAt: timestamp(&n.CreatedAt),
+	}
+}
+
+// url はMerge Requestの画面でノートを指すURLを返す
+func (n *note) url(webURL string) string {
+	if webURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s#note_%d", webURL, n.ID)
+}
@@ -0,0 +1,371 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/forge"
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// Merge Requestの状態（stateの値）
+const (
+	stateOpened = "opened"
+	stateClosed = "closed"
+	stateMerged = "merged"
+	stateLocked = "locked"
+	stateAll    = "all"
+)
+
+// PRの状態（GitHubのstateの値）
+const (
+	prStateOpen   = "open"
+	prStateClosed = "closed"
+)
+
+const (
+	// noteableMergeRequest はMerge Requestに付いたノートのnoteable_type
+	noteableMergeRequest = "MergeRequest"
+	// maxIIDsPerRequest はiids[]で一度に絞り込むMerge Requestの数
+	maxIIDsPerRequest = perPage
+)
+
+// user はGitLabのユーザー
+type user struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	WebURL   string `json:"web_url"`
+}
+
+// mergeRequest はGitLabのMerge Request
+type mergeRequest struct {
+	ID           int64      `json:"id"`
+	IID          int        `json:"iid"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	State        string     `json:"state"`
+	Draft        bool       `json:"draft"`
+	Author       *user      `json:"author"`
+	Labels       []string   `json:"labels"`
+	TargetBranch string     `json:"target_branch"`
+	SourceBranch string     `json:"source_branch"`
+	SHA          string     `json:"sha"`
+	WebURL       string     `json:"web_url"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	MergedAt     *time.Time `json:"merged_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+	// ChangesCount は変更されたファイル数。多い場合は"1000+"のような文字列になる（詳細の取得でだけ返される）
+	ChangesCount string `json:"changes_count"`
+}
+
+// searchNote はプロジェクト内の検索（scope=notes）で返されるノート
+type searchNote struct {
+	ID           int64  `json:"id"`
+	NoteableType string `json:"noteable_type"`
+	NoteableIID  int    `json:"noteable_iid"`
+}
+
+/**
+ * queryに一致するMerge Requestを検索し、IIDと最終更新日時を返す
+ * タイトル・説明文はMerge Requestの一覧のsearchで、コメントはプロジェクト内のノートの検索で探し、
+ * コメントで見つかったMerge Requestは一覧のiids[]で絞り込み条件を適用する
+ * GitLabの一覧で絞り込めない条件（複数の作成者やマージ日時など）は取得後に適用する
+ */
+func (c *Client) SearchPullRequestResults(ctx context.Context, query github.SearchQuery) ([]github.SearchResult, error) {
+	seen := make(map[int]bool)
+	var results []github.SearchResult
+	add := func(mrs []mergeRequest) {
+		for _, mr := range mrs {
+			if seen[mr.IID] || !matches(&mr, query) {
+				continue
+			}
+			seen[mr.IID] = true
+			results = append(results, github.SearchResult{Number: mr.IID, UpdatedAt: mr.UpdatedAt})
+		}
+	}
+
+	params := listParams(query)
+	scopes := query.Scopes
+	if len(scopes) == 0 {
+		scopes = []github.SearchScope{github.ScopeTitle, github.ScopeBody, github.ScopeComments}
+	}
+
+	if query.Keyword == "" {
+		mrs, err := forge.ListAll[mergeRequest](ctx, c.Client, c.projectPath("/merge_requests"), params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search merge requests: %w", err)
+		}
+		add(mrs)
+		return sortResults(results), nil
+	}
+
+	if in := textScopes(scopes); in != "" {
+		textParams := cloneValues(params)
+		textParams.Set("search", query.Keyword)
+		textParams.Set("in", in)
+		mrs, err := forge.ListAll[mergeRequest](ctx, c.Client, c.projectPath("/merge_requests"), textParams)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search merge requests: %w", err)
+		}
+		add(mrs)
+	}
+
+	if slices.Contains(scopes, github.ScopeComments) {
+		iids, err := c.searchNoteIIDs(ctx, query.Keyword)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search merge request notes: %w", err)
+		}
+		for chunk := range slices.Chunk(iids, maxIIDsPerRequest) {
+			chunkParams := cloneValues(params)
+			for _, iid := range chunk {
+				chunkParams.Add("iids[]", strconv.Itoa(iid))
+			}
+			mrs, err := forge.ListAll[mergeRequest](ctx, c.Client, c.projectPath("/merge_requests"), chunkParams)
+			if err != nil {
+				return nil, fmt.Errorf("failed to search merge requests: %w", err)
+			}
+			add(mrs)
+		}
+	}
+
+	return sortResults(results), nil
+}
+
+// searchNoteIIDs はkeywordを含むノートが付いたMerge RequestのIIDを重複なく返す
+func (c *Client) searchNoteIIDs(ctx context.Context, keyword string) ([]int, error) {
+	notes, err := forge.ListAll[searchNote](ctx, c.Client, c.projectPath("/search"), url.Values{
+		"scope":  {"notes"},
+		"search": {keyword},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var iids []int
+	for _, note := range notes {
+		if note.NoteableType != noteableMergeRequest || note.NoteableIID == 0 || slices.Contains(iids, note.NoteableIID) {
+			continue
+		}
+		iids = append(iids, note.NoteableIID)
+	}
+	return iids, nil
+}
+
+/**
+ * Merge Requestを作成日時の古い順に1件ずつPRの形で返すイテレータ
+ * ページは必要になった時点で取得する
+ */
+func (c *Client) PullRequests(ctx context.Context) iter.Seq2[*gh.PullRequest, error] {
+	return func(yield func(*gh.PullRequest, error) bool) {
+		params := url.Values{"state": {stateAll}, "order_by": {"created_at"}, "sort": {"asc"}}
+		for mrs, err := range forge.Pages[mergeRequest](ctx, c.Client, c.projectPath("/merge_requests"), params) {
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to list merge requests: %w", err))
+				return
+			}
+			for i := range mrs {
+				if !yield(mrs[i].pullRequest(), nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// GetMergeRequest はIIDがiidのMerge RequestをPRの形で返す
+func (c *Client) GetMergeRequest(ctx context.Context, iid int) (*gh.PullRequest, error) {
+	var mr mergeRequest
+	if _, err := c.Get(ctx, c.projectPath("/merge_requests/%d", iid), nil, &mr); err != nil {
+		return nil, fmt.Errorf("failed to get merge request !%d: %w", iid, err)
+	}
+	return mr.pullRequest(), nil
+}
+
+// listParams はqueryのうちMerge Requestの一覧APIで絞り込める条件をパラメータにする
+func listParams(query github.SearchQuery) url.Values {
+	params := url.Values{"scope": {"all"}, "order_by": {"created_at"}, "sort": {"asc"}}
+	params.Set("state", listState(query))
+	if len(query.Labels) > 0 {
+		params.Set("labels", strings.Join(query.Labels, ","))
+	}
+	if len(query.ExcludeLabels) > 0 {
+		params.Set("not[labels]", strings.Join(query.ExcludeLabels, ","))
+	}
+	if len(query.Authors) == 1 {
+		params.Set("author_username", query.Authors[0])
+	}
+	if query.Base != "" {
+		params.Set("target_branch", query.Base)
+	}
+	setDateRange(params, "created", query.Created)
+	setDateRange(params, "updated", query.Updated)
+	return params
+}
+
+// listState はqueryの状態とマージの条件を、一覧APIのstateに変換する。1つの状態に絞れなければallを返す
+func listState(query github.SearchQuery) string {
+	switch {
+	case query.Merged == github.MergeMerged:
+		return stateMerged
+	case query.State == github.StateOpen:
+		return stateOpened
+	case query.State == github.StateClosed && query.Merged == github.MergeUnmerged:
+		return stateClosed
+	default:
+		return stateAll
+	}
+}
+
+func setDateRange(params url.Values, field string, r github.DateRange) {
+	if !r.From.IsZero() {
+		params.Set(field+"_after", r.From.UTC().Format(time.RFC3339))
+	}
+	if !r.To.IsZero() {
+		params.Set(field+"_before", r.To.UTC().Format(time.RFC3339))
+	}
+}
+
+// textScopes はキーワードを照合する範囲のうち、一覧APIのinで指定できるもの（title・description）を返す
+func textScopes(scopes []github.SearchScope) string {
+	var in []string
+	if slices.Contains(scopes, github.ScopeTitle) {
+		in = append(in, "title")
+	}
+	if slices.Contains(scopes, github.ScopeBody) {
+		in = append(in, "description")
+	}
+	return strings.Join(in, ",")
+}
+
+// matches はmrがqueryの絞り込み条件（キーワード以外）をすべて満たすかどうかを返す
+func matches(mr *mergeRequest, query github.SearchQuery) bool {
+	merged := mr.State == stateMerged
+	switch query.State {
+	case github.StateOpen:
+		if mr.prState() != prStateOpen {
+			return false
+		}
+	case github.StateClosed:
+		if mr.prState() != prStateClosed {
+			return false
+		}
+	}
+	switch query.Merged {
+	case github.MergeMerged:
+		if !merged {
+			return false
+		}
+	case github.MergeUnmerged:
+		if merged {
+			return false
+		}
+	}
+
+	for _, label := range query.Labels {
+		if !forge.ContainsFold(mr.Labels, label) {
+			return false
+		}
+	}
+	for _, label := range query.ExcludeLabels {
+		if forge.ContainsFold(mr.Labels, label) {
+			return false
+		}
+	}
+	author := mr.author()
+	if len(query.Authors) > 0 && !forge.ContainsFold(query.Authors, author) {
+		return false
+	}
+	if forge.ContainsFold(query.ExcludeAuthors, author) {
+		return false
+	}
+	if query.Base != "" && query.Base != mr.TargetBranch {
+		return false
+	}
+
+	var mergedAt time.Time
+	if mr.MergedAt != nil {
+		mergedAt = *mr.MergedAt
+	}
+	return query.Created.Contains(mr.CreatedAt) && query.MergedAt.Contains(mergedAt) && query.Updated.Contains(mr.UpdatedAt)
+}
+
+// prState はMerge Requestの状態をPRの状態（open/closed）に変換する。ロック中はまだ閉じていないものとして扱う
+func (mr *mergeRequest) prState() string {
+	if mr.State == stateOpened || mr.State == stateLocked {
+		return prStateOpen
+	}
+	return prStateClosed
+}
+
+func (mr *mergeRequest) author() string {
+	if mr.Author == nil {
+		return ""
+	}
+	return mr.Author.Username
+}
+
+// pullRequest はMerge RequestをPRの形に変換する。IIDをPR番号、target_branchをbase、source_branchをheadとする
+func (mr *mergeRequest) pullRequest() *gh.PullRequest {
+	pr := &gh.PullRequest{
+		ID:        gh.Ptr(mr.ID),
+		Number:    gh.Ptr(mr.IID),
+		Title:     gh.Ptr(mr.Title),
+		Body:      gh.Ptr(mr.Description),
+		State:     gh.Ptr(mr.prState()),
+		Draft:     gh.Ptr(mr.Draft),
+		Merged:    gh.Ptr(mr.State == stateMerged),
+		HTMLURL:   gh.Ptr(mr.WebURL),
+		CreatedAt: timestamp(&mr.CreatedAt),
+		UpdatedAt: timestamp(&mr.UpdatedAt),
+		MergedAt:  timestamp(mr.MergedAt),
+		ClosedAt:  timestamp(mr.ClosedAt),
+		User:      mr.Author.githubUser(),
+		Base:      &gh.PullRequestBranch{Ref: gh.Ptr(mr.TargetBranch)},
+		Head:      &gh.PullRequestBranch{Ref: gh.Ptr(mr.SourceBranch), SHA: gh.Ptr(mr.SHA)},
+	}
+	for _, label := range mr.Labels {
+		pr.Labels = append(pr.Labels, &gh.Label{Name: gh.Ptr(label)})
+	}
+	if changes, err := strconv.Atoi(strings.TrimSuffix(mr.ChangesCount, "+")); err == nil {
+		pr.ChangedFiles = gh.Ptr(changes)
+	}
+	return pr
+}
+
+func (u *user) githubUser() *gh.User {
+	if u == nil {
+		return nil
+	}
+	return &gh.User{ID: gh.Ptr(u.ID), Login: gh.Ptr(u.Username), HTMLURL: gh.Ptr(u.WebURL)}
+}
+
+// timestamp はゼロ値でない時刻をgo-githubのTimestampに変換する
+func timestamp(t *time.Time) *gh.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return &gh.Timestamp{Time: *t}
+}
+
+func sortResults(results []github.SearchResult) []github.SearchResult {
+	slices.SortFunc(results, func(a, b github.SearchResult) int {
+		return a.Number - b.Number
+	})
+	return results
+}
+
+func cloneValues(values url.Values) url.Values {
+	cloned := make(url.Values, len(values))
+	for key, v := range values {
+		cloned[key] = append([]string(nil), v...)
+	}
+	return cloned
+}
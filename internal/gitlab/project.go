@@ -0,0 +1,65 @@
+package gitlab
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// webPathSeparator はプロジェクトのURLで、プロジェクトのパスとページ（/-/merge_requestsなど）を区切る部分
+const webPathSeparator = "/-/"
+
+/**
+ * GitLabのプロジェクトの参照を解釈する
+ *   - group/project、group/subgroup/project
+ *   - https://gitlab.example.com/group/subgroup/project（/-/merge_requests/1のような後続のパスは無視する）
+ *   - git@gitlab.example.com:group/project.git
+ * ホストを含まない参照はgitlab.comのプロジェクトとする
+ * サブグループを含むパスは、最後の要素をName、それより前をOwnerとする
+ */
+func ParseProjectRef(ref string) (github.RepositoryRef, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return github.RepositoryRef{}, fmt.Errorf("project reference is empty")
+	}
+
+	host := DefaultHost
+	path := ref
+	switch {
+	case strings.HasPrefix(ref, "git@") && !strings.Contains(ref, "://"):
+		var ok bool
+		host, path, ok = strings.Cut(strings.TrimPrefix(ref, "git@"), ":")
+		if !ok || host == "" {
+			return github.RepositoryRef{}, fmt.Errorf("invalid project reference: %s", ref)
+		}
+	case strings.Contains(ref, "://"):
+		u, err := url.Parse(ref)
+		if err != nil {
+			return github.RepositoryRef{}, fmt.Errorf("invalid project url: %w", err)
+		}
+		if u.Host != "" {
+			host = u.Host
+		}
+		path = u.Path
+	}
+
+	path, _, _ = strings.Cut(path, webPathSeparator)
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	slash := strings.LastIndex(path, "/")
+	if slash <= 0 || slash == len(path)-1 {
+		return github.RepositoryRef{}, fmt.Errorf("project reference must contain namespace and name: %s", ref)
+	}
+
+	return github.RepositoryRef{
+		Host:  strings.ToLower(host),
+		Owner: path[:slash],
+		Name:  path[slash+1:],
+	}, nil
+}
+
+// ProjectPath はRepositoryRefをGitLabのプロジェクトのパス（namespace/project）に戻す
+func ProjectPath(ref github.RepositoryRef) string {
+	return ref.Owner + "/" + ref.Name
+}
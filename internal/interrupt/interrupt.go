@@ -0,0 +1,21 @@
+// Package interrupt はコマンドの中断（Ctrl-CやSIGTERM）を、進捗を保存してから終了できるcontextのキャンセルとして扱う。
+package interrupt
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+/**
+ * SIGINT（Ctrl-C）かSIGTERMを受け取るとキャンセルされるcontextを返す
+ * 呼び出し側はキャンセルされたら処理を打ち切り、進捗を保存して終了する
+ * 2回目のシグナルでは保存を待たずに終了できるよう、キャンセル後は通常のシグナル処理に戻す
+ * 返すstopは処理を終えたときに呼び、シグナルの受け取りをやめる
+ */
+func NotifyContext(parent context.Context) (ctx context.Context, stop context.CancelFunc) {
+	ctx, stop = signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, stop)
+	return ctx, stop
+}
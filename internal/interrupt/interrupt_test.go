@@ -0,0 +1,37 @@
+package interrupt
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// signalTimeout はシグナルを送ってからcontextがキャンセルされるまで待つ時間
+const signalTimeout = 5 * time.Second
+
+func TestNotifyContext(t *testing.T) {
+	ctx, stop := NotifyContext(context.Background())
+	defer stop()
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGTERM))
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(signalTimeout):
+		t.Fatal("the context was not canceled by SIGTERM")
+	}
+	require.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestNotifyContext_Stop(t *testing.T) {
+	ctx, stop := NotifyContext(context.Background())
+	stop()
+
+	require.ErrorIs(t, ctx.Err(), context.Canceled)
+}
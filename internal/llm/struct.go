@@ -11,10 +11,53 @@ type PullRequestCommentsPayload struct {
 	UserName  string           `json:"user_name"`
 	Body      string           `json:"body"`
 	Type      string           `json:"type"`
+	State     string           `json:"state,omitempty"`
 	CreatedAt github.Timestamp `json:"created_at"`
 	UpdatedAt github.Timestamp `json:"updated_at"`
 }
 
+// PullRequestPayload はLLMに渡すPR本体の情報（タイトル・説明文・メタデータ）
+type PullRequestPayload struct {
+	Number       int               `json:"number"`
+	Title        string            `json:"title"`
+	Body         string            `json:"body"`
+	Author       string            `json:"author"`
+	Labels       []string          `json:"labels"`
+	MergedAt     *github.Timestamp `json:"merged_at,omitempty"`
+	BaseRef      string            `json:"base_ref"`
+	HeadRef      string            `json:"head_ref"`
+	ChangedFiles int               `json:"changed_files"`
+}
+
+/*
+* *github.PullRequestからPullRequestPayloadを作成する
+* prがnilの場合はnilを返す
+ */
+func ConvertPullRequestToPayload(pr *github.PullRequest) *PullRequestPayload {
+	if pr == nil {
+		return nil
+	}
+
+	labels := make([]string, 0, len(pr.Labels))
+	for _, label := range pr.Labels {
+		if label != nil && label.Name != nil {
+			labels = append(labels, *label.Name)
+		}
+	}
+
+	return &PullRequestPayload{
+		Number:       pr.GetNumber(),
+		Title:        pr.GetTitle(),
+		Body:         pr.GetBody(),
+		Author:       pr.GetUser().GetLogin(),
+		Labels:       labels,
+		MergedAt:     pr.MergedAt,
+		BaseRef:      pr.GetBase().GetRef(),
+		HeadRef:      pr.GetHead().GetRef(),
+		ChangedFiles: pr.GetChangedFiles(),
+	}
+}
+
 /*
 *[]*IssueCommentをPullRequestCommentsPayloadの配列に変換する
  */
@@ -40,11 +83,19 @@ func ConvertToPullRequestCommentsPayload(comments []*github.IssueComment) []Pull
 	return payloads
 }
 
+// PullRequestCommentsPayload.Typeに設定する種別
+const (
+	PayloadTypeIssueComment  = "issue_comment"
+	PayloadTypeReviewComment = "review_comment"
+	PayloadTypeReview        = "review"
+)
+
 /*
-* Issue CommentsとReview Commentsを統合してPullRequestCommentsPayloadの配列に変換する
+* Issue Comments、Review Comments、Reviewsを統合してPullRequestCommentsPayloadの配列に変換する
+* 本文のないReview（コメントなしのAPPROVEなど）は含めない
 * 時系列順にソートされた結果を返す
  */
-func ConvertPRCommentsToPayload(issueComments []*github.IssueComment, reviewComments []*github.PullRequestComment) []PullRequestCommentsPayload {
+func ConvertPRCommentsToPayload(issueComments []*github.IssueComment, reviewComments []*github.PullRequestComment, reviews []*github.PullRequestReview) []PullRequestCommentsPayload {
 	var payloads []PullRequestCommentsPayload
 
 	// Issue Commentsを変換
@@ -80,7 +131,7 @@ func ConvertPRCommentsToPayload(issueComments []*github.IssueComment, reviewComm
 			CommentID: commentID,
 			UserName:  userName,
 			Body:      body,
-			Type:      "issue_comment",
+			Type:      PayloadTypeIssueComment,
 			CreatedAt: createdAt,
 			UpdatedAt: updatedAt,
 		})
@@ -119,12 +170,34 @@ func ConvertPRCommentsToPayload(issueComments []*github.IssueComment, reviewComm
 			CommentID: commentID,
 			UserName:  userName,
 			Body:      body,
-			Type:      "review_comment",
+			Type:      PayloadTypeReviewComment,
 			CreatedAt: createdAt,
 			UpdatedAt: updatedAt,
 		})
 	}
 
+	// Reviewsを変換（投稿日時を作成日時・更新日時として扱う）
+	for _, review := range reviews {
+		if review == nil || review.GetBody() == "" {
+			continue
+		}
+
+		var submittedAt github.Timestamp
+		if review.SubmittedAt != nil {
+			submittedAt = *review.SubmittedAt
+		}
+
+		payloads = append(payloads, PullRequestCommentsPayload{
+			CommentID: int(review.GetID()),
+			UserName:  review.GetUser().GetLogin(),
+			Body:      review.GetBody(),
+			Type:      PayloadTypeReview,
+			State:     review.GetState(),
+			CreatedAt: submittedAt,
+			UpdatedAt: submittedAt,
+		})
+	}
+
 	// 時系列順にソート
 	sortPayloadsByTime(payloads)
 
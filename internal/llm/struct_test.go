@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/go-github/v77/github"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConvertToPullRequestCommentsPayload(t *testing.T) {
@@ -139,3 +140,85 @@ func stringPtr(v string) *string {
 func timestampPtr(t time.Time) *github.Timestamp {
 	return &github.Timestamp{Time: t}
 }
+
+func TestConvertPullRequestToPayload(t *testing.T) {
+	mergedAt := timestampPtr(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	pr := &github.PullRequest{
+		Number:       intPtr(42),
+		Title:        stringPtr("Escape user input in templates"),
+		Body:         stringPtr("This fixes a XSS in the preview page."),
+		User:         &github.User{Login: stringPtr("author")},
+		Labels:       []*github.Label{{Name: stringPtr("security")}, {Name: stringPtr("bug")}},
+		MergedAt:     mergedAt,
+		Base:         &github.PullRequestBranch{Ref: stringPtr("main")},
+		Head:         &github.PullRequestBranch{Ref: stringPtr("fix-xss")},
+		ChangedFiles: intPtr(3),
+	}
+
+	got := ConvertPullRequestToPayload(pr)
+
+	assert.Equal(t, &PullRequestPayload{
+		Number:       42,
+		Title:        "Escape user input in templates",
+		Body:         "This fixes a XSS in the preview page.",
+		Author:       "author",
+		Labels:       []string{"security", "bug"},
+		MergedAt:     mergedAt,
+		BaseRef:      "main",
+		HeadRef:      "fix-xss",
+		ChangedFiles: 3,
+	}, got)
+}
+
+func TestConvertPullRequestToPayload_Nil(t *testing.T) {
+	assert.Nil(t, ConvertPullRequestToPayload(nil))
+}
+
+// ヘルパー関数: intのポインタを作成
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestConvertPRCommentsToPayload_MergesReviewsIntoTimeline(t *testing.T) {
+	issueComments := []*github.IssueComment{
+		{
+			ID:        int64Ptr(1),
+			User:      &github.User{Login: stringPtr("reporter")},
+			Body:      stringPtr("Is this input escaped?"),
+			CreatedAt: timestampPtr(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)),
+		},
+	}
+	reviewComments := []*github.PullRequestComment{
+		{
+			ID:        int64Ptr(2),
+			User:      &github.User{Login: stringPtr("reviewer")},
+			Body:      stringPtr("This concatenates SQL."),
+			CreatedAt: timestampPtr(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)),
+		},
+	}
+	reviews := []*github.PullRequestReview{
+		{
+			ID:          int64Ptr(3),
+			User:        &github.User{Login: stringPtr("reviewer")},
+			Body:        stringPtr("Requesting changes: SQL injection in the search handler."),
+			State:       stringPtr("CHANGES_REQUESTED"),
+			SubmittedAt: timestampPtr(time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)),
+		},
+		{
+			ID:          int64Ptr(4),
+			User:        &github.User{Login: stringPtr("maintainer")},
+			Body:        stringPtr(""),
+			State:       stringPtr("APPROVED"),
+			SubmittedAt: timestampPtr(time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)),
+		},
+	}
+
+	got := ConvertPRCommentsToPayload(issueComments, reviewComments, reviews)
+
+	require.Len(t, got, 3)
+	assert.Equal(t, PayloadTypeIssueComment, got[0].Type)
+	assert.Equal(t, PayloadTypeReview, got[1].Type)
+	assert.Equal(t, "CHANGES_REQUESTED", got[1].State)
+	assert.Equal(t, "Requesting changes: SQL injection in the search handler.", got[1].Body)
+	assert.Equal(t, PayloadTypeReviewComment, got[2].Type)
+}
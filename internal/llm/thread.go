@@ -0,0 +1,209 @@
+package llm
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v77/github"
+)
+
+// subjectTypeFile はファイル全体に対するレビューコメント（行を持たない）を表すsubject_type
+const subjectTypeFile = "file"
+
+// ReviewCommentPayload はスレッド内の1件のレビューコメント
+type ReviewCommentPayload struct {
+	CommentID    int              `json:"id"`
+	UserName     string           `json:"user_name"`
+	Body         string           `json:"body"`
+	InReplyTo    int              `json:"in_reply_to,omitempty"`
+	Line         int              `json:"line,omitempty"`
+	OriginalLine int              `json:"original_line,omitempty"`
+	CommitID     string           `json:"commit_id,omitempty"`
+	CreatedAt    github.Timestamp `json:"created_at"`
+	UpdatedAt    github.Timestamp `json:"updated_at"`
+}
+
+// ReviewThread はルートコメントとその返信からなるレビュースレッド
+// Resolvedは解決状態が分かる場合（GraphQLで取得した場合など）のみ設定される
+type ReviewThread struct {
+	RootCommentID int                    `json:"root_comment_id"`
+	Path          string                 `json:"path"`
+	Line          int                    `json:"line,omitempty"`
+	OriginalLine  int                    `json:"original_line,omitempty"`
+	CommitID      string                 `json:"commit_id,omitempty"`
+	DiffHunk      string                 `json:"diff_hunk"`
+	Resolved      *bool                  `json:"resolved,omitempty"`
+	Outdated      bool                   `json:"outdated"`
+	Comments      []ReviewCommentPayload `json:"comments"`
+}
+
+// ReviewLocation は同じファイル・行に対するレビュースレッドのまとまり
+// Lineはスレッドの行（古くなったスレッドは元の行）で、ファイル全体へのスレッドでは省略する
+type ReviewLocation struct {
+	Path    string         `json:"path"`
+	Line    int            `json:"line,omitempty"`
+	Threads []ReviewThread `json:"threads"`
+}
+
+/*
+* Review Commentsをスレッドにまとめ、さらにファイル・行ごとにまとめる
+* 同じ行への別々の指摘と、それぞれへの返信を並べて見られるようにする
+* まとまりはパス・行番号順、まとまり内のスレッドはルートコメントの時系列順に並べる
+ */
+func BuildReviewLocations(comments []*github.PullRequestComment, resolved map[int64]bool) []ReviewLocation {
+	type locationKey struct {
+		path string
+		line int
+	}
+
+	var locations []ReviewLocation
+	index := make(map[locationKey]int)
+	for _, thread := range BuildReviewThreads(comments, resolved) {
+		key := locationKey{path: thread.Path, line: threadLine(thread)}
+		i, ok := index[key]
+		if !ok {
+			i = len(locations)
+			index[key] = i
+			locations = append(locations, ReviewLocation{Path: key.path, Line: key.line})
+		}
+		locations[i].Threads = append(locations[i].Threads, thread)
+	}
+
+	for _, location := range locations {
+		sort.SliceStable(location.Threads, func(i, j int) bool {
+			return threadCreatedAt(location.Threads[i]).Before(threadCreatedAt(location.Threads[j]))
+		})
+	}
+	return locations
+}
+
+/*
+* Review Commentsをin_reply_to_idを辿ってルートコメントごとのスレッドにまとめる
+* resolvedにはルートコメントIDごとの解決状態を渡す（分からない場合はnil）
+* スレッドはパス・行番号順、スレッド内のコメントは時系列順に並べる
+ */
+func BuildReviewThreads(comments []*github.PullRequestComment, resolved map[int64]bool) []ReviewThread {
+	byID := make(map[int64]*github.PullRequestComment, len(comments))
+	for _, comment := range comments {
+		if comment != nil && comment.ID != nil {
+			byID[*comment.ID] = comment
+		}
+	}
+
+	var rootOrder []int64
+	members := make(map[int64][]*github.PullRequestComment)
+	for _, comment := range comments {
+		if comment == nil || comment.ID == nil {
+			continue
+		}
+		rootID := findRootCommentID(comment, byID)
+		if _, ok := members[rootID]; !ok {
+			rootOrder = append(rootOrder, rootID)
+		}
+		members[rootID] = append(members[rootID], comment)
+	}
+
+	threads := make([]ReviewThread, 0, len(rootOrder))
+	for _, rootID := range rootOrder {
+		threads = append(threads, buildReviewThread(rootID, members[rootID], byID, resolved))
+	}
+
+	sort.SliceStable(threads, func(i, j int) bool {
+		if threads[i].Path != threads[j].Path {
+			return threads[i].Path < threads[j].Path
+		}
+		return threadLine(threads[i]) < threadLine(threads[j])
+	})
+
+	return threads
+}
+
+// findRootCommentID はin_reply_to_idを辿ってスレッドのルートコメントIDを返す
+// 返信先が取得できていない場合は、辿れた最も古いコメントをルートとして扱う
+func findRootCommentID(comment *github.PullRequestComment, byID map[int64]*github.PullRequestComment) int64 {
+	current := comment
+	visited := map[int64]bool{current.GetID(): true}
+	for current.InReplyTo != nil {
+		parent, ok := byID[*current.InReplyTo]
+		if !ok || visited[parent.GetID()] {
+			break
+		}
+		visited[parent.GetID()] = true
+		current = parent
+	}
+	return current.GetID()
+}
+
+func buildReviewThread(rootID int64, comments []*github.PullRequestComment, byID map[int64]*github.PullRequestComment, resolved map[int64]bool) ReviewThread {
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].GetCreatedAt().Time.Before(comments[j].GetCreatedAt().Time)
+	})
+
+	root := byID[rootID]
+	thread := ReviewThread{
+		RootCommentID: int(rootID),
+		Path:          root.GetPath(),
+		Line:          root.GetLine(),
+		OriginalLine:  root.GetOriginalLine(),
+		CommitID:      root.GetCommitID(),
+		DiffHunk:      root.GetDiffHunk(),
+		Outdated:      isOutdated(root),
+		Comments:      make([]ReviewCommentPayload, 0, len(comments)),
+	}
+	if state, ok := resolved[rootID]; ok {
+		thread.Resolved = &state
+	}
+
+	for _, comment := range comments {
+		thread.Comments = append(thread.Comments, convertReviewComment(comment))
+	}
+	return thread
+}
+
+// isOutdated は後続のコミットでコメント対象の行が差分から消えたかどうかを返す
+func isOutdated(comment *github.PullRequestComment) bool {
+	if strings.EqualFold(comment.GetSubjectType(), subjectTypeFile) {
+		return false
+	}
+	return comment.Line == nil && comment.Position == nil
+}
+
+// threadCreatedAt はスレッドのルートコメントの作成日時を返す
+func threadCreatedAt(thread ReviewThread) time.Time {
+	for _, comment := range thread.Comments {
+		if comment.CommentID == thread.RootCommentID {
+			return comment.CreatedAt.Time
+		}
+	}
+	return time.Time{}
+}
+
+func threadLine(thread ReviewThread) int {
+	if thread.Line != 0 {
+		return thread.Line
+	}
+	return thread.OriginalLine
+}
+
+func convertReviewComment(comment *github.PullRequestComment) ReviewCommentPayload {
+	var createdAt, updatedAt github.Timestamp
+	if comment.CreatedAt != nil {
+		createdAt = *comment.CreatedAt
+	}
+	if comment.UpdatedAt != nil {
+		updatedAt = *comment.UpdatedAt
+	}
+
+	return ReviewCommentPayload{
+		CommentID:    int(comment.GetID()),
+		UserName:     comment.GetUser().GetLogin(),
+		Body:         comment.GetBody(),
+		InReplyTo:    int(comment.GetInReplyTo()),
+		Line:         comment.GetLine(),
+		OriginalLine: comment.GetOriginalLine(),
+		CommitID:     comment.GetCommitID(),
+		CreatedAt:    createdAt,
+		UpdatedAt:    updatedAt,
+	}
+}
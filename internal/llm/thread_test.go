@@ -0,0 +1,149 @@
+package llm
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v77/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reviewComment(id int64, inReplyTo int64, path string, line int, created time.Time) *github.PullRequestComment {
+	comment := &github.PullRequestComment{
+		ID:           int64Ptr(id),
+		User:         &github.User{Login: stringPtr("user")},
+		Body:         stringPtr("comment"),
+		Path:         stringPtr(path),
+		DiffHunk:     stringPtr("@@ -1,3 +1,3 @@"),
+		CommitID:     stringPtr("abc123"),
+		OriginalLine: intPtr(line),
+		CreatedAt:    timestampPtr(created),
+	}
+	if line != 0 {
+		comment.Line = intPtr(line)
+		comment.Position = intPtr(line)
+	}
+	if inReplyTo != 0 {
+		comment.InReplyTo = int64Ptr(inReplyTo)
+	}
+	return comment
+}
+
+func TestBuildReviewThreads_GroupsRepliesUnderRoot(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	comments := []*github.PullRequestComment{
+		reviewComment(1, 0, "b.go", 10, base),
+		reviewComment(2, 0, "a.go", 5, base.Add(time.Minute)),
+		reviewComment(3, 1, "b.go", 10, base.Add(2*time.Minute)),
+		// 返信への返信もルートのスレッドにまとめる
+		reviewComment(4, 3, "b.go", 10, base.Add(3*time.Minute)),
+	}
+
+	threads := BuildReviewThreads(comments, map[int64]bool{1: true})
+
+	require.Len(t, threads, 2)
+
+	assert.Equal(t, "a.go", threads[0].Path)
+	assert.Equal(t, 2, threads[0].RootCommentID)
+	assert.Nil(t, threads[0].Resolved)
+
+	assert.Equal(t, "b.go", threads[1].Path)
+	assert.Equal(t, 1, threads[1].RootCommentID)
+	assert.Equal(t, 10, threads[1].Line)
+	assert.Equal(t, "abc123", threads[1].CommitID)
+	require.NotNil(t, threads[1].Resolved)
+	assert.True(t, *threads[1].Resolved)
+	require.Len(t, threads[1].Comments, 3)
+	assert.Equal(t, []int{1, 3, 4}, []int{threads[1].Comments[0].CommentID, threads[1].Comments[1].CommentID, threads[1].Comments[2].CommentID})
+	assert.Equal(t, 3, threads[1].Comments[2].InReplyTo)
+}
+
+func TestBuildReviewThreads_OrphanReplyBecomesRoot(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	comments := []*github.PullRequestComment{
+		reviewComment(5, 99, "main.go", 3, base),
+	}
+
+	threads := BuildReviewThreads(comments, nil)
+
+	require.Len(t, threads, 1)
+	assert.Equal(t, 5, threads[0].RootCommentID)
+}
+
+func TestBuildReviewThreads_MarksOutdated(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	outdated := reviewComment(1, 0, "main.go", 0, base)
+	outdated.OriginalLine = intPtr(42)
+	fileLevel := reviewComment(2, 0, "go.mod", 0, base)
+	fileLevel.SubjectType = stringPtr("file")
+
+	threads := BuildReviewThreads([]*github.PullRequestComment{outdated, fileLevel}, nil)
+
+	require.Len(t, threads, 2)
+	assert.False(t, threads[0].Outdated, "file-level comments are never outdated")
+	assert.True(t, threads[1].Outdated)
+	assert.Equal(t, 42, threads[1].OriginalLine)
+}
+
+func TestBuildReviewLocations(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	comments := []*github.PullRequestComment{
+		reviewComment(1, 0, "b.go", 10, base.Add(time.Minute)),
+		reviewComment(2, 0, "a.go", 5, base.Add(2*time.Minute)),
+		// 同じ行への別の指摘は別のスレッドのまま、同じまとまりに入る
+		reviewComment(3, 0, "b.go", 10, base),
+		reviewComment(4, 1, "b.go", 10, base.Add(3*time.Minute)),
+		reviewComment(5, 0, "b.go", 20, base),
+	}
+
+	locations := BuildReviewLocations(comments, nil)
+
+	require.Len(t, locations, 3)
+	assert.Equal(t, "a.go", locations[0].Path)
+	assert.Equal(t, 5, locations[0].Line)
+	assert.Equal(t, "b.go", locations[1].Path)
+	assert.Equal(t, 10, locations[1].Line)
+	require.Len(t, locations[1].Threads, 2)
+	assert.Equal(t, 3, locations[1].Threads[0].RootCommentID, "threads are ordered by their root comment")
+	assert.Equal(t, 1, locations[1].Threads[1].RootCommentID)
+	assert.Len(t, locations[1].Threads[1].Comments, 2)
+	assert.Equal(t, 20, locations[2].Line)
+}
+
+func TestBuildReviewLocations_OmitsUnknownResolvedState(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	comments := []*github.PullRequestComment{reviewComment(1, 0, "main.go", 3, base)}
+
+	tests := []struct {
+		name         string
+		resolved     map[int64]bool
+		wantResolved any
+	}{
+		// REST APIでは解決状態が分からない
+		{name: "unknown", resolved: nil, wantResolved: nil},
+		{name: "unknown thread", resolved: map[int64]bool{99: true}, wantResolved: nil},
+		{name: "unresolved", resolved: map[int64]bool{1: false}, wantResolved: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(BuildReviewLocations(comments, tt.resolved))
+			require.NoError(t, err)
+
+			var locations []struct {
+				Threads []map[string]any `json:"threads"`
+			}
+			require.NoError(t, json.Unmarshal(data, &locations))
+			require.Len(t, locations, 1)
+			require.Len(t, locations[0].Threads, 1)
+			resolved, ok := locations[0].Threads[0]["resolved"]
+			if tt.wantResolved == nil {
+				assert.False(t, ok, "resolved must be left out when unknown: %s", data)
+			} else {
+				assert.Equal(t, tt.wantResolved, resolved)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+// Package mailinglist はメーリングリストのアーカイブ（mbox・maildir）を読み込み、
+// パッチのスレッドをGitHubのPRと同じ会話の形にして、PRと同じように検索・取得できるようにする。
+// カーネルや多くのCライブラリのようにPRではなくメーリングリストでパッチをレビューするプロジェクトを、
+// キーワードでの検索やLLMでの分析にそのまま掛けられるようにする。
+package mailinglist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maildirSubdirs はmaildirのメッセージが置かれるサブディレクトリ
+var maildirSubdirs = []string{"cur", "new"}
+
+// mboxFromPrefix はmboxでメッセージの区切りになる行の先頭
+const mboxFromPrefix = "From "
+
+// maxLineSize はmboxの1行の最大サイズ
+const maxLineSize = 16 << 20
+
+// escapedFromLine はmboxrd形式で本文中の"From "行をエスケープした行（>From、>>From …）
+var escapedFromLine = regexp.MustCompile(`^>+From `)
+
+// errNoParsableMessages はアーカイブのメッセージをどれも解釈できなかったことを表す
+var errNoParsableMessages = errors.New("no message could be parsed")
+
+// message はアーカイブ内の1通のメール
+type message struct {
+	// ID は<>を除いたMessage-ID
+	ID string
+	// Parent は返信先のMessage-ID（In-Reply-To、なければReferencesの最後）
+	Parent  string
+	Subject string
+	// From は送信者の表示名（なければアドレス）、Address は送信者のメールアドレス
+	From    string
+	Address string
+	Date    time.Time
+	// Body はtext/plainの本文（転送エンコーディングは復号済み）
+	Body string
+	// order はアーカイブ内での順番。日時が同じメールの並びに使う
+	order int
+}
+
+/**
+ * pathのアーカイブを読み込む
+ * ディレクトリの場合はmaildir（cur・newのファイルを1通ずつ）、ファイルの場合はmboxとして読む
+ */
+func readArchive(path string) ([]*message, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mail archive: %w", err)
+	}
+	if info.IsDir() {
+		return readMaildir(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mail archive: %w", err)
+	}
+	defer f.Close()
+	return readMbox(f, path)
+}
+
+// readMaildir はmaildirのcur・newにあるメッセージをファイル名の順に読み込む
+func readMaildir(dir string) ([]*message, error) {
+	var files []string
+	for _, sub := range maildirSubdirs {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read maildir: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+				files = append(files, filepath.Join(dir, sub, entry.Name()))
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s is not a maildir (no messages in cur or new)", dir)
+	}
+	sort.Strings(files)
+
+	var messages []*message
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message: %w", err)
+		}
+		msg, err := parseMessage(data, i)
+		if err != nil {
+			log.Printf("Skipping %s: %v", file, err)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("%s: %w", dir, errNoParsableMessages)
+	}
+	return messages, nil
+}
+
+/**
+ * mboxを1通ずつに分けて読み込む
+ * 空行（またはファイルの先頭）の次の"From "で始まる行をメッセージの区切りとし、
+ * mboxrd形式でエスケープされた">From "行は元に戻す
+ * 解釈できないメッセージ（ヘッダーが壊れたものなど）はログに出して読み飛ばす
+ */
+func readMbox(r io.Reader, name string) ([]*message, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var messages []*message
+	var current bytes.Buffer
+	// count は区切り行で見つけたメッセージの数（読み飛ばしたものを含む）
+	count := 0
+	previousBlank := true
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		defer current.Reset()
+		msg, err := parseMessage(current.Bytes(), count-1)
+		if err != nil {
+			log.Printf("Skipping message %d in %s: %v", count, name, err)
+			return
+		}
+		messages = append(messages, msg)
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if previousBlank && strings.HasPrefix(line, mboxFromPrefix) {
+			flush()
+			count++
+			previousBlank = false
+			continue
+		}
+		if escapedFromLine.MatchString(line) {
+			line = line[1:]
+		}
+		current.WriteString(line)
+		current.WriteByte('\n')
+		previousBlank = line == ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read mbox: %w", err)
+	}
+	flush()
+	if count == 0 {
+		return nil, fmt.Errorf("%s is not an mbox (no \"From \" separator lines)", name)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("%s: %w", name, errNoParsableMessages)
+	}
+	return messages, nil
+}
+
+var headerDecoder = new(mime.WordDecoder)
+
+// parseMessage は1通のメールのヘッダーと本文を解釈する。Message-IDがなければアーカイブ内の順番から作る
+func parseMessage(data []byte, order int) (*message, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	msg := &message{
+		ID:      messageID(m.Header.Get("Message-Id")),
+		Subject: decodeHeader(m.Header.Get("Subject")),
+		order:   order,
+	}
+	if msg.ID == "" {
+		msg.ID = fmt.Sprintf("message-%d@archive", order)
+	}
+	if parent := messageID(m.Header.Get("In-Reply-To")); parent != "" {
+		msg.Parent = parent
+	} else if references := strings.Fields(m.Header.Get("References")); len(references) > 0 {
+		msg.Parent = messageID(references[len(references)-1])
+	}
+	if from, err := mail.ParseAddress(m.Header.Get("From")); err == nil {
+		msg.From, msg.Address = from.Name, from.Address
+	} else {
+		msg.From = decodeHeader(m.Header.Get("From"))
+	}
+	if msg.From == "" {
+		msg.From = msg.Address
+	}
+	if date, err := m.Header.Date(); err == nil {
+		msg.Date = date.UTC()
+	}
+
+	body, err := textBody(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Body)
+	if err != nil {
+		return nil, err
+	}
+	msg.Body = strings.ReplaceAll(body, "\r\n", "\n")
+	return msg, nil
+}
+
+// messageID はMessage-IDの前後の空白と<>を取り除く
+func messageID(value string) string {
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+func decodeHeader(value string) string {
+	decoded, err := headerDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+/**
+ * 本文のうちtext/plainの部分を取り出す
+ * multipartの場合は最初のtext/plainのパートを使い、quoted-printableとbase64は復号する
+ */
+func textBody(contentType, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return "", nil
+			}
+			if err != nil {
+				return "", fmt.Errorf("failed to read multipart body: %w", err)
+			}
+			partType := part.Header.Get("Content-Type")
+			if partType == "" || strings.HasPrefix(partType, "text/plain") || strings.HasPrefix(partType, "multipart/") {
+				// multipart.Readerはquoted-printableを自動で復号し、Content-Transfer-Encodingを取り除く
+				return textBody(partType, part.Header.Get("Content-Transfer-Encoding"), part)
+			}
+		}
+	}
+	if mediaType != "text/plain" {
+		return "", nil
+	}
+
+	var decoded io.Reader = body
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		decoded = quotedprintable.NewReader(body)
+	case "base64":
+		decoded = base64.NewDecoder(base64.StdEncoding, newlineStripper{body})
+	}
+	data, err := io.ReadAll(decoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode body: %w", err)
+	}
+	return string(data), nil
+}
+
+// newlineStripper はbase64の本文から改行を取り除く
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (s newlineStripper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	kept := 0
+	for _, b := range p[:n] {
+		if b != '\n' && b != '\r' {
+			p[kept] = b
+			kept++
+		}
+	}
+	return kept, err
+}
@@ -0,0 +1,222 @@
+package mailinglist
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// diffHeaderPrefix はgit形式のdiffでファイルごとの差分の先頭に付く行
+const diffHeaderPrefix = "diff --git "
+
+// 統一diff形式で変更前・変更後のファイル名を示す行の先頭
+const (
+	oldFilePrefix = "--- "
+	newFilePrefix = "+++ "
+)
+
+// patchSeparator はgit format-patchでコミットメッセージとdiffstatを区切る行
+const patchSeparator = "---"
+
+// signatureSeparator はメールの署名の前に置かれる行
+const signatureSeparator = "-- "
+
+// quotePrefix は返信で引用した行の先頭に付く文字
+const quotePrefix = ">"
+
+// hunkHeader はdiffのハンクの先頭行（@@ -a,b +c,d @@）。cが変更後のファイルでの開始行
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// attributionLine は引用の前に置かれる「On ..., X wrote:」のような行
+var attributionLine = regexp.MustCompile(`(?i)(wrote|writes|said):\s*$`)
+
+// reviewTrailer はレビューの結果を表すタグ（Reviewed-by:、Acked-by:など）
+var reviewTrailer = regexp.MustCompile(`(?i)^\s*((?:reviewed|acked|tested|nacked|naked)-by):\s*(.+?)\s*$`)
+
+// trailer は返信に書かれたレビューのタグ
+type trailer struct {
+	// Name はReviewed-byのようなタグ名（小文字）、Value はタグの値（名前とメールアドレス）
+	Name  string
+	Value string
+}
+
+// inlineComment は引用したdiffの直後に書かれたコメント
+type inlineComment struct {
+	Path string
+	// Line は引用した最後の行の、変更後のファイルでの行番号
+	Line int
+	// DiffHunk はハンクの先頭行と、コメントの直前に引用されたdiffの行
+	DiffHunk string
+	Body     string
+}
+
+// reply は返信の本文を、diffへのコメントとそれ以外の文章、レビューのタグに分けたもの
+type reply struct {
+	Text     string
+	Comments []inlineComment
+	Trailers []trailer
+}
+
+// containsDiff は本文に引用ではないdiffが含まれるかどうかを返す
+func containsDiff(body string) bool {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, diffHeaderPrefix) {
+			return true
+		}
+		if strings.HasPrefix(line, oldFilePrefix) && i+2 < len(lines) &&
+			strings.HasPrefix(lines[i+1], newFilePrefix) && hunkHeader.MatchString(lines[i+2]) {
+			return true
+		}
+	}
+	return false
+}
+
+// countChangedFiles は本文のdiffに含まれるファイルの数を返す
+func countChangedFiles(body string) int {
+	count := 0
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, diffHeaderPrefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// patchDescription はパッチの本文のうち、diffstatとdiffより前の説明（コミットメッセージやカバーレター）を返す
+func patchDescription(body string) string {
+	var description []string
+	for _, line := range strings.Split(body, "\n") {
+		if line == patchSeparator || strings.HasPrefix(line, diffHeaderPrefix) || line == signatureSeparator {
+			break
+		}
+		description = append(description, line)
+	}
+	return strings.TrimSpace(strings.Join(description, "\n"))
+}
+
+/**
+ * 返信の本文を、引用したdiffへのコメントとそれ以外の文章に分ける
+ * 引用したdiffのハンクの直後に書かれた文章はそのハンクへのコメントとし、ファイル名はdiff --gitまたは+++の行、
+ * 行番号はハンクの先頭行から数えた変更後のファイルでの行番号にする
+ * ファイル名の行を引用せずにハンクだけを引用した場合は、返信先のパッチのhunkPaths（hunkPathsを参照）からファイル名を探す
+ * diff以外の引用（コミットメッセージなど）と引用の前の「... wrote:」の行、署名は取り除き、
+ * Reviewed-byなどのタグは文章から取り出してTrailersにする
+ */
+func parseReply(body string, paths map[string]string) reply {
+	var r reply
+	var text []string
+
+	var path, header string
+	var quoted []string
+	inHunk := false
+	newLine := 0
+
+	var own []string
+	var anchor []string
+	flush := func() {
+		for len(own) > 0 && (strings.TrimSpace(own[len(own)-1]) == "" || attributionLine.MatchString(own[len(own)-1])) {
+			own = own[:len(own)-1]
+		}
+		if body := strings.TrimSpace(strings.Join(own, "\n")); body != "" {
+			if anchor != nil {
+				r.Comments = append(r.Comments, inlineComment{
+					Path:     path,
+					Line:     newLine,
+					DiffHunk: strings.Join(anchor, "\n"),
+					Body:     body,
+				})
+			} else {
+				text = append(text, body)
+			}
+		}
+		own, anchor = nil, nil
+	}
+
+	for _, line := range strings.Split(body, "\n") {
+		if line == signatureSeparator {
+			break
+		}
+
+		if !strings.HasPrefix(line, quotePrefix) {
+			if len(own) == 0 && strings.TrimSpace(line) == "" {
+				continue
+			}
+			if match := reviewTrailer.FindStringSubmatch(line); match != nil {
+				r.Trailers = append(r.Trailers, trailer{Name: strings.ToLower(match[1]), Value: match[2]})
+				continue
+			}
+			if len(own) == 0 && len(quoted) > 0 {
+				anchor = append([]string{header}, quoted...)
+				quoted = nil
+			}
+			own = append(own, line)
+			continue
+		}
+
+		if len(own) > 0 {
+			flush()
+		}
+		line = unquote(line)
+		switch {
+		case strings.HasPrefix(line, diffHeaderPrefix):
+			if _, file, ok := strings.Cut(line, " b/"); ok {
+				path = file
+			}
+			inHunk, quoted = false, nil
+		case strings.HasPrefix(line, newFilePrefix) && !inHunk:
+			path = strings.TrimPrefix(strings.TrimPrefix(line, newFilePrefix), "b/")
+		case strings.HasPrefix(line, oldFilePrefix) && !inHunk:
+		case hunkHeader.MatchString(line):
+			start, _ := strconv.Atoi(hunkHeader.FindStringSubmatch(line)[1])
+			header, newLine, inHunk, quoted = line, start-1, true, nil
+			if file, ok := paths[line]; ok {
+				path = file
+			}
+		case inHunk && isDiffLine(line):
+			quoted = append(quoted, line)
+			if !strings.HasPrefix(line, "-") && !strings.HasPrefix(line, `\`) {
+				newLine++
+			}
+		default:
+			// diffではない引用（コミットメッセージやほかの人の文章）はコメントの対象にしない
+			inHunk, quoted = false, nil
+		}
+	}
+	flush()
+
+	r.Text = strings.Join(text, "\n\n")
+	return r
+}
+
+// hunkPaths はパッチの本文のハンクの先頭行ごとに、そのハンクのファイル名を返す
+func hunkPaths(body string) map[string]string {
+	paths := make(map[string]string)
+	path := ""
+	for _, line := range strings.Split(body, "\n") {
+		switch {
+		case strings.HasPrefix(line, diffHeaderPrefix):
+			if _, file, ok := strings.Cut(line, " b/"); ok {
+				path = file
+			}
+		case strings.HasPrefix(line, newFilePrefix):
+			path = strings.TrimPrefix(strings.TrimPrefix(line, newFilePrefix), "b/")
+		case hunkHeader.MatchString(line):
+			paths[line] = path
+		}
+	}
+	return paths
+}
+
+// unquote は引用の印（>、> >など）を取り除く。印の直後の空白は1つだけ取り除き、diffの行頭の空白は残す
+func unquote(line string) string {
+	for strings.HasPrefix(line, quotePrefix) {
+		line = strings.TrimPrefix(line[len(quotePrefix):], " ")
+	}
+	return line
+}
+
+// isDiffLine はハンクの中の行（空白・+・-・\で始まる行か空行）かどうかを返す
+func isDiffLine(line string) bool {
+	return line == "" || strings.ContainsAny(line[:1], " +-\\")
+}
@@ -0,0 +1,142 @@
+package mailinglist
+
+import (
+	"fmt"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// trailerStates はレビューのタグごとの、対応するGitHubのレビューの状態
+var trailerStates = map[string]string{
+	"reviewed-by": github.ReviewApproved,
+	"acked-by":    github.ReviewApproved,
+	"tested-by":   github.ReviewCommented,
+	"nacked-by":   github.ReviewChangesRequested,
+	"naked-by":    github.ReviewChangesRequested,
+}
+
+/**
+ * スレッドをPRと同じ形の会話に変換する
+ * ルートのメールがPRの本体（件名がタイトル、diffより前の説明が本文）になり、
+ * 返信のうち引用したdiffの直後に書かれた文章はReview Comment、それ以外の文章はIssue Comment、
+ * Reviewed-by・Acked-byなどのタグはReviewになる。スレッド内の別のパッチ（シリーズの各パッチや新しい版）は
+ * 件名と説明をIssue Commentにする
+ * 同じファイル・行へのReview Commentは、先頭のコメントへの返信としてつなげる
+ * コメントとレビューのIDはスレッド内で1から順に振る
+ */
+func (t *thread) conversation() *github.Conversation {
+	changedFiles := countChangedFiles(t.Root.Body)
+	updatedAt := t.Root.Date
+	conversation := &github.Conversation{}
+
+	var nextID int64
+	newID := func() *int64 {
+		nextID++
+		return gh.Ptr(nextID)
+	}
+	threadRoots := make(map[string]int64)
+	byID := map[string]*message{t.Root.ID: t.Root}
+	for _, msg := range t.Replies {
+		byID[msg.ID] = msg
+	}
+
+	for _, msg := range t.Replies {
+		if msg.Date.After(updatedAt) {
+			updatedAt = msg.Date
+		}
+		user := mailUser(msg)
+		date := &gh.Timestamp{Time: msg.Date}
+
+		if containsDiff(msg.Body) {
+			changedFiles += countChangedFiles(msg.Body)
+			conversation.IssueComments = append(conversation.IssueComments, &gh.IssueComment{
+				ID:        newID(),
+				Body:      gh.Ptr(fmt.Sprintf("%s\n\n%s", stripReplyPrefix(msg.Subject), patchDescription(msg.Body))),
+				User:      user,
+				CreatedAt: date,
+				UpdatedAt: date,
+			})
+			continue
+		}
+
+		parsed := parseReply(msg.Body, hunkPaths(repliedPatch(msg, byID).Body))
+		if parsed.Text != "" {
+			conversation.IssueComments = append(conversation.IssueComments, &gh.IssueComment{
+				ID:        newID(),
+				Body:      gh.Ptr(parsed.Text),
+				User:      user,
+				CreatedAt: date,
+				UpdatedAt: date,
+			})
+		}
+		for _, inline := range parsed.Comments {
+			comment := &gh.PullRequestComment{
+				ID:          newID(),
+				Body:        gh.Ptr(inline.Body),
+				Path:        gh.Ptr(inline.Path),
+				DiffHunk:    gh.Ptr(inline.DiffHunk),
+				Line:        gh.Ptr(inline.Line),
+				Side:        gh.Ptr(github.SideRight),
+				SubjectType: gh.Ptr(github.SubjectTypeLine),
+				User:        user,
+				CreatedAt:   date,
+				UpdatedAt:   date,
+			}
+			key := fmt.Sprintf("%s:%d", inline.Path, inline.Line)
+			if rootID, ok := threadRoots[key]; ok {
+				comment.InReplyTo = gh.Ptr(rootID)
+			} else {
+				threadRoots[key] = comment.GetID()
+			}
+			conversation.ReviewComments = append(conversation.ReviewComments, comment)
+		}
+		for _, tag := range parsed.Trailers {
+			conversation.Reviews = append(conversation.Reviews, &gh.PullRequestReview{
+				ID:          newID(),
+				Body:        gh.Ptr(fmt.Sprintf("%s: %s", tag.Name, tag.Value)),
+				State:       gh.Ptr(trailerStates[tag.Name]),
+				User:        user,
+				SubmittedAt: date,
+			})
+		}
+	}
+
+	conversation.PullRequest = &gh.PullRequest{
+		Number:         gh.Ptr(t.Number),
+		Title:          gh.Ptr(stripReplyPrefix(t.Root.Subject)),
+		Body:           gh.Ptr(patchDescription(t.Root.Body)),
+		User:           mailUser(t.Root),
+		CreatedAt:      &gh.Timestamp{Time: t.Root.Date},
+		UpdatedAt:      &gh.Timestamp{Time: updatedAt},
+		ChangedFiles:   gh.Ptr(changedFiles),
+		Comments:       gh.Ptr(len(conversation.IssueComments)),
+		ReviewComments: gh.Ptr(len(conversation.ReviewComments)),
+	}
+	return conversation
+}
+
+// repliedPatch はmsgの返信先をたどって最も近いパッチのメールを返す。見つからなければ返信先をたどった先頭のメールを返す
+func repliedPatch(msg *message, byID map[string]*message) *message {
+	visited := map[string]bool{msg.ID: true}
+	for {
+		parent, ok := byID[msg.Parent]
+		if !ok || visited[parent.ID] {
+			return msg
+		}
+		if containsDiff(parent.Body) {
+			return parent
+		}
+		visited[parent.ID] = true
+		msg = parent
+	}
+}
+
+// mailUser は送信者をGitHubのユーザーの形にする。ログイン名にはメールアドレス（なければ表示名）を使う
+func mailUser(msg *message) *gh.User {
+	login := msg.Address
+	if login == "" {
+		login = msg.From
+	}
+	return &gh.User{Login: gh.Ptr(login), Name: gh.Ptr(msg.From)}
+}
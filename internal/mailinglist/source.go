@@ -0,0 +1,190 @@
+package mailinglist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// ArchiveHost はアーカイブのRepositoryRefに使うホスト名。保存先はdata/mail/<ディレクトリ>/<アーカイブ名>になる
+const ArchiveHost = "mail"
+
+// defaultArchiveOwner はアーカイブの親ディレクトリ名が使えない場合のRepositoryRefのowner
+const defaultArchiveOwner = "local"
+
+// Archive は読み込んだメーリングリストのアーカイブ。パッチのスレッドをPRとして検索・取得できる
+type Archive struct {
+	threads  []*thread
+	byNumber map[int]*thread
+}
+
+/**
+ * pathのアーカイブ（mboxのファイルまたはmaildirのディレクトリ）を読み込み、パッチのスレッドにまとめる
+ */
+func Open(path string) (*Archive, error) {
+	messages, err := readArchive(path)
+	if err != nil {
+		return nil, err
+	}
+
+	threads := buildThreads(messages)
+	archive := &Archive{threads: threads, byNumber: make(map[int]*thread, len(threads))}
+	for _, t := range threads {
+		archive.byNumber[t.Number] = t
+	}
+	return archive, nil
+}
+
+// ArchiveRef はアーカイブのパスから保存先などに使うRepositoryRefを作る。
+// ownerは親ディレクトリ名、nameは拡張子を除いたファイル名（maildirならディレクトリ名）になる
+func ArchiveRef(path string) (github.RepositoryRef, error) {
+	path = filepath.Clean(strings.TrimSpace(path))
+	base := filepath.Base(path)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		return github.RepositoryRef{}, fmt.Errorf("invalid mail archive path: %q", path)
+	}
+
+	owner := filepath.Base(filepath.Dir(path))
+	if owner == "." || owner == string(filepath.Separator) {
+		owner = defaultArchiveOwner
+	}
+	return github.RepositoryRef{Host: ArchiveHost, Owner: owner, Name: name}, nil
+}
+
+/**
+ * queryに一致するパッチのスレッドの番号と最終更新日時（最後のメールの日時）を返す
+ * キーワードはタイトル（件名）・本文（パッチの説明）・コメント（返信）から大文字小文字を区別せずに探す
+ * メーリングリストのパッチには状態やマージの有無がないため、stateとマージ状態の条件は無視し、
+ * ラベル・baseブランチ・マージ日時が指定された場合はどのスレッドも一致しない
+ * 作成者は送信者のメールアドレスまたは表示名で照合する
+ */
+func (a *Archive) SearchPullRequestResults(ctx context.Context, query github.SearchQuery) ([]github.SearchResult, error) {
+	if len(query.Labels) > 0 || query.Base != "" || !query.MergedAt.IsZero() {
+		return nil, nil
+	}
+
+	var results []github.SearchResult
+	for _, t := range a.threads {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		conversation := t.conversation()
+		if !matches(conversation, query) {
+			continue
+		}
+		results = append(results, github.SearchResult{
+			Number:    t.Number,
+			UpdatedAt: conversation.PullRequest.GetUpdatedAt().Time,
+		})
+	}
+
+	slices.SortFunc(results, func(a, b github.SearchResult) int {
+		return a.Number - b.Number
+	})
+	return results, nil
+}
+
+/**
+ * パッチのスレッドをルートのメールの日時の古い順に1件ずつ返すイテレータ
+ */
+func (a *Archive) PullRequests(ctx context.Context) iter.Seq2[*gh.PullRequest, error] {
+	return func(yield func(*gh.PullRequest, error) bool) {
+		for _, t := range a.threads {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(t.conversation().PullRequest, nil) {
+				return
+			}
+		}
+	}
+}
+
+/**
+ * 番号がprNumbersのスレッドを会話に変換する。アーカイブにない番号はエラーにする
+ */
+func (a *Archive) FetchConversations(ctx context.Context, prNumbers []int) (map[int]*github.Conversation, error) {
+	conversations := make(map[int]*github.Conversation, len(prNumbers))
+	var errs []error
+
+	for _, prNumber := range prNumbers {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		t, ok := a.byNumber[prNumber]
+		if !ok {
+			errs = append(errs, &github.ConversationError{PRNumber: prNumber, Message: "thread not found in the archive"})
+			continue
+		}
+		conversations[prNumber] = t.conversation()
+	}
+
+	return conversations, errors.Join(errs...)
+}
+
+// matches はconversationがqueryの条件（ラベル・baseブランチ・マージ日時以外）をすべて満たすかどうかを返す
+func matches(conversation *github.Conversation, query github.SearchQuery) bool {
+	pr := conversation.PullRequest
+	author := pr.GetUser()
+	isAuthor := func(names []string) bool {
+		return slices.ContainsFunc(names, func(name string) bool {
+			return strings.EqualFold(name, author.GetLogin()) || strings.EqualFold(name, author.GetName())
+		})
+	}
+	if len(query.Authors) > 0 && !isAuthor(query.Authors) {
+		return false
+	}
+	if isAuthor(query.ExcludeAuthors) {
+		return false
+	}
+	if !query.Created.Contains(pr.GetCreatedAt().Time) || !query.Updated.Contains(pr.GetUpdatedAt().Time) {
+		return false
+	}
+
+	if query.Keyword == "" {
+		return true
+	}
+	keyword := strings.ToLower(query.Keyword)
+	return slices.ContainsFunc(searchableTexts(conversation, query.Scopes), func(text string) bool {
+		return strings.Contains(strings.ToLower(text), keyword)
+	})
+}
+
+// searchableTexts はscopesで指定された範囲のテキストを返す（指定がなければタイトル・本文・コメントすべて）
+func searchableTexts(conversation *github.Conversation, scopes []github.SearchScope) []string {
+	if len(scopes) == 0 {
+		scopes = []github.SearchScope{github.ScopeTitle, github.ScopeBody, github.ScopeComments}
+	}
+
+	var texts []string
+	for _, scope := range scopes {
+		switch scope {
+		case github.ScopeTitle:
+			texts = append(texts, conversation.PullRequest.GetTitle())
+		case github.ScopeBody:
+			texts = append(texts, conversation.PullRequest.GetBody())
+		case github.ScopeComments:
+			for _, comment := range conversation.IssueComments {
+				texts = append(texts, comment.GetBody())
+			}
+			for _, comment := range conversation.ReviewComments {
+				texts = append(texts, comment.GetBody())
+			}
+			for _, review := range conversation.Reviews {
+				texts = append(texts, review.GetBody())
+			}
+		}
+	}
+	return texts
+}
@@ -0,0 +1,229 @@
+package mailinglist
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testArchive = "testdata/patches.mbox"
+
+// threadBySubject はタイトルがtitleのスレッドの会話を返す
+func threadBySubject(t *testing.T, archive *Archive, title string) *github.Conversation {
+	t.Helper()
+	for _, th := range archive.threads {
+		if conversation := th.conversation(); conversation.PullRequest.GetTitle() == title {
+			return conversation
+		}
+	}
+	t.Fatalf("thread %q not found", title)
+	return nil
+}
+
+func TestOpen_Mbox(t *testing.T) {
+	archive, err := Open(testArchive)
+	require.NoError(t, err)
+
+	// パッチではない「Meeting notes」のスレッドと、ヘッダーを解釈できない「broken headers」のメールは含まない
+	require.Len(t, archive.threads, 2)
+	assert.Equal(t, "20240304-patch@example.org", archive.threads[0].Root.ID)
+	assert.Equal(t, "cover@example.org", archive.threads[1].Root.ID)
+
+	conversation := threadBySubject(t, archive, "[PATCH v2] net: fix buffer overflow in frame_parse()")
+	pr := conversation.PullRequest
+	assert.Equal(t, archive.threads[0].Number, pr.GetNumber())
+	assert.Equal(t, "alice@example.org", pr.GetUser().GetLogin())
+	assert.Equal(t, "frame_parse() copies the header into a fixed-size buffer without\n"+
+		"checking the length reported by the peer.\nFrom now on the length is validated first.\n\n"+
+		"Signed-off-by: Alice Author <alice@example.org>", pr.GetBody())
+	assert.Equal(t, 1, pr.GetChangedFiles())
+	assert.Equal(t, time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC), pr.GetCreatedAt().Time)
+	assert.Equal(t, time.Date(2024, 3, 4, 13, 0, 0, 0, time.UTC), pr.GetUpdatedAt().Time)
+
+	// 引用したdiffの直後の文章はReview Comment、それ以外の文章はIssue Commentになる
+	require.Len(t, conversation.IssueComments, 2)
+	assert.Equal(t, "Hi Alice,\n\nThanks for catching this.", conversation.IssueComments[0].GetBody())
+	assert.Equal(t, "bob@example.org", conversation.IssueComments[0].GetUser().GetLogin())
+	assert.Equal(t, "No, len == sizeof(header) fits exactly. I will add a check for zero.", conversation.IssueComments[1].GetBody())
+
+	require.Len(t, conversation.ReviewComments, 1)
+	comment := conversation.ReviewComments[0]
+	assert.Equal(t, "net/frame.c", comment.GetPath())
+	assert.Equal(t, 14, comment.GetLine())
+	assert.Equal(t, "Shouldn't this be >=? A zero-length frame is also invalid —\nthe caller dereferences header[0].", comment.GetBody())
+	assert.Equal(t, "@@ -10,6 +10,7 @@ int frame_parse(struct frame *f, const char *buf, size_t len)\n {\n \tchar header[64];\n \n"+
+		"-\tmemcpy(header, buf, len);\n+\tif (len > sizeof(header))\n+\t\treturn -EINVAL;", comment.GetDiffHunk())
+
+	require.Len(t, conversation.Reviews, 1)
+	assert.Equal(t, github.ReviewApproved, conversation.Reviews[0].GetState())
+	assert.Equal(t, "reviewed-by: Bob Reviewer <bob@example.org>", conversation.Reviews[0].GetBody())
+
+	// 保存・LLMへの入力と同じ形式に変換できる
+	payloads := llm.ConvertPRCommentsToPayload(conversation.IssueComments, conversation.ReviewComments, conversation.Reviews)
+	assert.Len(t, payloads, 4)
+	threads := llm.BuildReviewThreads(conversation.ReviewComments, conversation.ResolvedThreads)
+	require.Len(t, threads, 1)
+	assert.False(t, threads[0].Outdated)
+}
+
+func TestOpen_PatchSeries(t *testing.T) {
+	archive, err := Open(testArchive)
+	require.NoError(t, err)
+
+	conversation := threadBySubject(t, archive, "[RFC PATCH 0/2] docs: rework the build guide")
+	assert.Equal(t, "Dave Müller", conversation.PullRequest.GetUser().GetName())
+	assert.Equal(t, 1, conversation.PullRequest.GetChangedFiles())
+
+	// シリーズの各パッチは件名と説明だけをIssue Commentにする
+	require.Len(t, conversation.IssueComments, 2)
+	assert.Equal(t, "[RFC PATCH 1/2] docs: move the build guide\n\nMove the guide next to the other documents.", conversation.IssueComments[0].GetBody())
+	assert.Equal(t, "The old location is referenced by the release scripts.", conversation.IssueComments[1].GetBody())
+	assert.Empty(t, conversation.ReviewComments)
+	require.Len(t, conversation.Reviews, 1)
+	assert.Equal(t, github.ReviewChangesRequested, conversation.Reviews[0].GetState())
+}
+
+func TestOpen_Maildir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "cur"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "new"), 0755))
+	messages := map[string]string{
+		"cur/1:2,S": "From: a@example.org\nSubject: [PATCH] fix race\nMessage-ID: <p@x>\nDate: Mon, 4 Mar 2024 10:00:00 +0000\n\nFix a race.\n",
+		"new/2":     "From: b@example.org\nSubject: Re: [PATCH] fix race\nMessage-ID: <r@x>\nIn-Reply-To: <p@x>\nDate: Mon, 4 Mar 2024 11:00:00 +0000\n\nLooks good.\n\nAcked-by: B <b@example.org>\n",
+	}
+	for name, content := range messages {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	archive, err := Open(dir)
+	require.NoError(t, err)
+	require.Len(t, archive.threads, 1)
+
+	conversation := archive.threads[0].conversation()
+	assert.Equal(t, "[PATCH] fix race", conversation.PullRequest.GetTitle())
+	require.Len(t, conversation.IssueComments, 1)
+	assert.Equal(t, "Looks good.", conversation.IssueComments[0].GetBody())
+	require.Len(t, conversation.Reviews, 1)
+	assert.Equal(t, github.ReviewApproved, conversation.Reviews[0].GetState())
+}
+
+func TestOpen_NotAnArchive(t *testing.T) {
+	_, err := Open(t.TempDir())
+	assert.ErrorContains(t, err, "not a maildir")
+
+	file := filepath.Join(t.TempDir(), "empty.mbox")
+	require.NoError(t, os.WriteFile(file, []byte("just text\n"), 0644))
+	_, err = Open(file)
+	assert.ErrorContains(t, err, "not an mbox")
+
+	require.NoError(t, os.WriteFile(file, []byte("From a@example.org Mon Mar  4 10:00:00 2024\nno colon\n\nbody\n"), 0644))
+	_, err = Open(file)
+	assert.ErrorIs(t, err, errNoParsableMessages)
+}
+
+func TestArchive_SearchPullRequestResults(t *testing.T) {
+	archive, err := Open(testArchive)
+	require.NoError(t, err)
+	overflow, docs := archive.threads[0].Number, archive.threads[1].Number
+
+	tests := []struct {
+		name  string
+		query github.SearchQuery
+		want  []int
+	}{
+		{
+			name:  "default query ignores merge status",
+			query: github.DefaultSearchQuery("zero-length"),
+			want:  []int{overflow},
+		},
+		{
+			name:  "keyword only in the patch description is not a comment",
+			query: github.DefaultSearchQuery("fixed-size"),
+			want:  nil,
+		},
+		{
+			name:  "body scope",
+			query: github.SearchQuery{Keyword: "FIXED-SIZE", Scopes: []github.SearchScope{github.ScopeBody}},
+			want:  []int{overflow},
+		},
+		{
+			name:  "review trailers are comments",
+			query: github.DefaultSearchQuery("nacked-by"),
+			want:  []int{docs},
+		},
+		{
+			name:  "author by address",
+			query: github.SearchQuery{Authors: []string{"dave@example.org"}},
+			want:  []int{docs},
+		},
+		{
+			name:  "exclude author by name",
+			query: github.SearchQuery{ExcludeAuthors: []string{"alice author"}},
+			want:  []int{docs},
+		},
+		{
+			name:  "created range",
+			query: github.SearchQuery{Created: github.DateRange{From: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)}},
+			want:  []int{docs},
+		},
+		{
+			name:  "labels never match",
+			query: github.SearchQuery{Labels: []string{"bug"}},
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := archive.SearchPullRequestResults(context.Background(), tt.query)
+			require.NoError(t, err)
+
+			var numbers []int
+			for _, result := range results {
+				numbers = append(numbers, result.Number)
+			}
+			assert.ElementsMatch(t, tt.want, numbers)
+		})
+	}
+}
+
+func TestArchive_FetchConversations(t *testing.T) {
+	archive, err := Open(testArchive)
+	require.NoError(t, err)
+	number := archive.threads[0].Number
+
+	conversations, err := archive.FetchConversations(context.Background(), []int{number, 1})
+	require.Error(t, err)
+	assert.Contains(t, conversations, number)
+	assert.NotContains(t, conversations, 1)
+
+	// 同じアーカイブからは同じスレッド番号になる
+	reopened, err := Open(testArchive)
+	require.NoError(t, err)
+	assert.Equal(t, number, reopened.threads[0].Number)
+}
+
+func TestArchiveRef(t *testing.T) {
+	tests := []struct {
+		path string
+		want github.RepositoryRef
+	}{
+		{path: "/srv/lists/linux-kernel.mbox", want: github.RepositoryRef{Host: ArchiveHost, Owner: "lists", Name: "linux-kernel"}},
+		{path: "lists/netdev/", want: github.RepositoryRef{Host: ArchiveHost, Owner: "lists", Name: "netdev"}},
+		{path: "devel.mbox", want: github.RepositoryRef{Host: ArchiveHost, Owner: defaultArchiveOwner, Name: "devel"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			ref, err := ArchiveRef(tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ref)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package mailinglist
+
+import (
+	"cmp"
+	"hash/fnv"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// patchSubject はパッチのメールの件名に付くタグ（[PATCH]、[PATCH v2 1/3]、[RFC PATCH]など）
+var patchSubject = regexp.MustCompile(`(?i)\[[^\]]*\b(PATCH|RFC)\b[^\]]*\]`)
+
+// replySubjectPrefix は返信の件名の先頭に付く接頭辞（Re:、RE:、Aw:など）
+var replySubjectPrefix = regexp.MustCompile(`(?i)^\s*((re|aw|sv|fwd?)\s*:\s*)+`)
+
+// maxThreadNumber はスレッド番号の上限。ファイル名やPR番号としてintに収まるよう31ビットにする
+const maxThreadNumber = 1<<31 - 1
+
+// thread はMessage-ID・In-Reply-Toでつながった、1通のパッチ（またはカバーレター）とその返信
+type thread struct {
+	// Number はルートのMessage-IDから決まるスレッド番号。保存先のファイル名（<Number>.json）になる
+	Number int
+	// Root はスレッドの起点となるメール、Replies はそれ以外のメール（日時の古い順）
+	Root    *message
+	Replies []*message
+}
+
+/**
+ * メールを返信先をたどってスレッドにまとめ、パッチのスレッドだけを返す
+ * 返信先がアーカイブにないメールはスレッドのルートとみなし、ルートの件名に[PATCH]や[RFC]が付くか、
+ * 本文に引用ではないdiffを含むスレッドをパッチのスレッドとする
+ * スレッド番号はルートのMessage-IDのハッシュから決めるため、アーカイブにメールが増えても変わらない
+ */
+func buildThreads(messages []*message) []*thread {
+	byID := make(map[string]*message, len(messages))
+	for _, msg := range messages {
+		if _, ok := byID[msg.ID]; !ok {
+			byID[msg.ID] = msg
+		}
+	}
+
+	threads := make(map[string]*thread)
+	var roots []string
+	for _, msg := range messages {
+		if byID[msg.ID] != msg {
+			// 同じMessage-IDのメール（複数のリストに送られたものなど）は最初の1通だけを使う
+			continue
+		}
+		root := rootOf(msg, byID)
+		t, ok := threads[root.ID]
+		if !ok {
+			t = &thread{Root: root}
+			threads[root.ID] = t
+			roots = append(roots, root.ID)
+		}
+		if msg != root {
+			t.Replies = append(t.Replies, msg)
+		}
+	}
+
+	slices.Sort(roots)
+	used := make(map[int]bool, len(roots))
+	var patches []*thread
+	for _, id := range roots {
+		t := threads[id]
+		if !isPatch(t.Root) {
+			continue
+		}
+		t.Number = threadNumber(id, used)
+		slices.SortStableFunc(t.Replies, compareMessages)
+		patches = append(patches, t)
+	}
+
+	slices.SortFunc(patches, func(a, b *thread) int {
+		return compareMessages(a.Root, b.Root)
+	})
+	return patches
+}
+
+// rootOf は返信先をアーカイブ内でたどれるところまでたどったメールを返す。返信先が循環していれば途中で打ち切る
+func rootOf(msg *message, byID map[string]*message) *message {
+	visited := map[string]bool{msg.ID: true}
+	for {
+		parent, ok := byID[msg.Parent]
+		if !ok || visited[parent.ID] {
+			return msg
+		}
+		visited[parent.ID] = true
+		msg = parent
+	}
+}
+
+// threadNumber はMessage-IDのハッシュからスレッド番号を決める。番号が衝突した場合は空いている次の番号にする
+func threadNumber(id string, used map[int]bool) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	number := int(h.Sum32()%maxThreadNumber) + 1
+	for used[number] {
+		number = number%maxThreadNumber + 1
+	}
+	used[number] = true
+	return number
+}
+
+// isPatch はmsgがパッチ（またはパッチのカバーレター）かどうかを返す
+func isPatch(msg *message) bool {
+	return patchSubject.MatchString(msg.Subject) || containsDiff(msg.Body)
+}
+
+// compareMessages はメールを日時の古い順（同じならアーカイブ内の順）に並べる
+func compareMessages(a, b *message) int {
+	return cmp.Or(a.Date.Compare(b.Date), cmp.Compare(a.order, b.order))
+}
+
+// stripReplyPrefix は件名の先頭のRe:などを取り除く
+func stripReplyPrefix(subject string) string {
+	return strings.TrimSpace(replySubjectPrefix.ReplaceAllString(subject, ""))
+}
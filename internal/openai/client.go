@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
@@ -14,23 +15,26 @@ type Client struct {
 	client openai.Client
 }
 
-func NewClient(apiKey string) *Client {
-	client := openai.NewClient(
-		option.WithAPIKey(apiKey),
-	)
+// NewClient はOpenAI APIのクライアントを作成する。httpClientがnilの場合は既定のクライアントを使う。
+func NewClient(apiKey string, httpClient *http.Client) *Client {
+	opts := []option.RequestOption{option.WithAPIKey(apiKey)}
+	if httpClient != nil {
+		opts = append(opts, option.WithHTTPClient(httpClient))
+	}
+	client := openai.NewClient(opts...)
 	return &Client{
 		client: client,
 	}
 }
 
-func (c *Client) DetectVulnerabilityDiscussion(conversationJSON []byte) (*VulnerabilityDetectionResponse, error) {
+func (c *Client) DetectVulnerabilityDiscussion(ctx context.Context, conversationJSON []byte) (*VulnerabilityDetectionResponse, error) {
 	prompt := c.buildPrompt(conversationJSON)
 
 	responseFormat := openai.ChatCompletionNewParamsResponseFormatUnion{
 		OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
 	}
 
-	chatCompletion, err := c.client.Chat.Completions.New(context.Background(), openai.ChatCompletionNewParams{
+	chatCompletion, err := c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
 		Model: openai.ChatModelGPT5Mini,
 		Messages: []openai.ChatCompletionMessageParamUnion{
 			openai.SystemMessage("Analyze code review discussions for security vulnerability findings. Return JSON only."),
@@ -61,6 +65,8 @@ func (c *Client) DetectVulnerabilityDiscussion(conversationJSON []byte) (*Vulner
 
 func (c *Client) buildPrompt(conversationJSON []byte) string {
 	return fmt.Sprintf(`Analyze this code review conversation for security vulnerability findings.
+The "pull_request" section, when present, holds the PR title, description and metadata.
+The description often states which vulnerability the change fixes, so consider it together with the comments.
 
 Conversation:
 %s
@@ -0,0 +1,742 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/openai"
+)
+
+// リポジトリごとの記録のファイル名
+const (
+	// ProcessedPRsFileName は処理済みPR番号を保存するファイル名
+	ProcessedPRsFileName = ".processed_prs.json"
+	// SyncStateFileName は差分同期の状態を保存するファイル名
+	SyncStateFileName = ".sync_state.json"
+	// KeywordHitsFileName はPRごとのキーワードの一致の記録を保存するファイル名
+	KeywordHitsFileName = ".keyword_hits.json"
+)
+
+// リポジトリのディレクトリの下のディレクトリ名
+const (
+	// pullsDirName はPRの会話を1件ずつ保存するディレクトリ
+	pullsDirName = "pulls"
+	// keywordsDirName はキーワードごとのビュー（pullsのファイルへのシンボリックリンク）を置くディレクトリ
+	keywordsDirName = "keywords"
+)
+
+// analyzedBufferSize は分析済みPRを何件ごとにインデックスファイルへ書き出すか
+const analyzedBufferSize = 100
+
+// conversationFileExt はPRの会話を保存するファイルの拡張子
+const conversationFileExt = ".json"
+
+var _ Store = (*FileStore)(nil)
+
+/**
+ * FileStore は従来どおりのJSONファイルのディレクトリ構成に保存するStore
+ *   - PRの会話: <root>/<owner>/<repo>/pulls/<PRの番号>.json（github.com以外は<root>/<host>/<owner>/<repo>/...）
+ *   - キーワードの一致の記録: <root>/<owner>/<repo>/.keyword_hits.json（PR番号ごとのKeywordHitの配列）
+ *   - キーワードごとのビュー: <root>/<owner>/<repo>/keywords/<キーワード>/<PRの番号>.json（pullsのファイルへのシンボリックリンク）
+ *   - 処理済みPR番号・差分同期の状態: <root>/<owner>/<repo>/.processed_prs.json・.sync_state.json
+ *   - LLMの分析結果: 結果ファイル（JSON Lines。各行にリポジトリを付ける）と、分析済みPRのインデックス（.<結果ファイル名>_index.json）
+ *   - 変換したJSON: 変換先ディレクトリに、rootからの相対パスを保って書き出す
+ * 分析済みPRはリポジトリとPR番号の組（"owner/repo#7"）で記録する。PR番号だけで記録していた以前のインデックスの番号は、
+ * どのリポジトリかわからないため、すべてのリポジトリで分析済みとみなす
+ * 以前の構成の<キーワード>/<PRの番号>.jsonも、親ディレクトリ名をキーワードとして読み出せる。
+ * crawlはリポジトリを処理する前にMigrateLegacyLayoutでpullsと.keyword_hits.jsonに移す
+ */
+type FileStore struct {
+	root         string
+	resultsFile  string
+	convertedDir string
+
+	mu sync.Mutex
+	// analyzed は分析済みPRのanalysisKeyの集合
+	analyzed map[string]bool
+	// legacyAnalyzed は以前のインデックスにPR番号だけで記録された分析済みPR
+	legacyAnalyzed map[int]bool
+	// hitsMu はキーワードの一致の記録の読み書きを直列にする
+	hitsMu sync.Mutex
+	// unsaved はインデックスファイルにまだ書き出していない分析済みPRのanalysisKey
+	unsaved []string
+}
+
+// analyzedResult は結果ファイルの1行。どのリポジトリのPRかわかるよう、分析結果にリポジトリを付ける
+type analyzedResult struct {
+	Repo string `json:"repo,omitempty"`
+	openai.VulnerabilityDetectionResult
+}
+
+// FileStoreOption はFileStoreの設定を変更する
+type FileStoreOption func(*FileStore)
+
+// WithResultsFile はLLMの分析結果を書き出すJSON Linesのファイルを指定する
+func WithResultsFile(path string) FileStoreOption {
+	return func(s *FileStore) {
+		s.resultsFile = path
+	}
+}
+
+// WithConvertedDir は変換したJSONを書き出すディレクトリを指定する
+func WithConvertedDir(dir string) FileStoreOption {
+	return func(s *FileStore) {
+		s.convertedDir = dir
+	}
+}
+
+// NewFileStore はrootをデータのディレクトリとするFileStoreを作成する
+func NewFileStore(root string, opts ...FileStoreOption) *FileStore {
+	s := &FileStore{root: root}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Location はrepoのデータを保存するディレクトリを返す
+func (s *FileStore) Location(repo github.RepositoryRef) string {
+	return repo.DataDir(s.root)
+}
+
+func conversationFileName(number int) string {
+	return strconv.Itoa(number) + conversationFileExt
+}
+
+func (s *FileStore) conversationPath(repo github.RepositoryRef, number int) string {
+	return filepath.Join(repo.DataDir(s.root), pullsDirName, conversationFileName(number))
+}
+
+// SaveConversation はPRの会話をpulls/<PRの番号>.jsonに書き込む
+func (s *FileStore) SaveConversation(repo github.RepositoryRef, number int, comments PRComments) error {
+	data, err := json.MarshalIndent(comments, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal comments: %w", err)
+	}
+
+	path := s.conversationPath(repo, number)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// LoadConversation はpulls/<PRの番号>.jsonを読み込む
+func (s *FileStore) LoadConversation(repo github.RepositoryRef, number int) (PRComments, error) {
+	return ReadConversationFile(s.conversationPath(repo, number))
+}
+
+// ReadConversationFile はPRの会話を保存したJSONファイルを読み込む。ファイルがなければErrNotFoundを返す
+func ReadConversationFile(path string) (PRComments, error) {
+	var comments PRComments
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return comments, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	if err != nil {
+		return comments, fmt.Errorf("failed to read comments file: %w", err)
+	}
+	if err := json.Unmarshal(data, &comments); err != nil {
+		return comments, fmt.Errorf("failed to parse comments file: %w", err)
+	}
+	return comments, nil
+}
+
+// LoadProcessed は.processed_prs.jsonから処理済みPR番号を読み込む。ファイルがなければ空のマップを返す
+func (s *FileStore) LoadProcessed(repo github.RepositoryRef) (map[int]bool, error) {
+	processed := make(map[int]bool)
+	prNumbers, err := readIntList(filepath.Join(repo.DataDir(s.root), ProcessedPRsFileName))
+	if err != nil {
+		return processed, fmt.Errorf("failed to load processed PRs: %w", err)
+	}
+	for _, prNumber := range prNumbers {
+		processed[prNumber] = true
+	}
+	return processed, nil
+}
+
+// SaveProcessed は処理済みPR番号を昇順に並べて.processed_prs.jsonに保存する
+func (s *FileStore) SaveProcessed(repo github.RepositoryRef, prs map[int]bool) error {
+	prNumbers := make([]int, 0, len(prs))
+	for prNumber := range prs {
+		prNumbers = append(prNumbers, prNumber)
+	}
+	sort.Ints(prNumbers)
+
+	data, err := json.MarshalIndent(prNumbers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal processed PRs: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(repo.DataDir(s.root), ProcessedPRsFileName), data)
+}
+
+// LoadSyncState は.sync_state.jsonから差分同期の状態を読み込む。ファイルがなければゼロ値を返す
+func (s *FileStore) LoadSyncState(repo github.RepositoryRef) (SyncState, error) {
+	data, err := os.ReadFile(filepath.Join(repo.DataDir(s.root), SyncStateFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return SyncState{}, nil
+		}
+		return SyncState{}, fmt.Errorf("failed to read sync state file: %w", err)
+	}
+
+	var state SyncState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return SyncState{}, fmt.Errorf("failed to parse sync state JSON: %w", err)
+	}
+	return state, nil
+}
+
+// SaveSyncState は差分同期の状態を.sync_state.jsonに保存する
+func (s *FileStore) SaveSyncState(repo github.RepositoryRef, state SyncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync state: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(repo.DataDir(s.root), SyncStateFileName), data)
+}
+
+// LoadKeywordHits は.keyword_hits.jsonからPRごとのキーワードの一致の記録を読み込む。ファイルがなければ空のマップを返す
+func (s *FileStore) LoadKeywordHits(repo github.RepositoryRef) (map[int][]KeywordHit, error) {
+	return readKeywordHits(filepath.Join(repo.DataDir(s.root), KeywordHitsFileName))
+}
+
+func readKeywordHits(path string) (map[int][]KeywordHit, error) {
+	hits := make(map[int][]KeywordHit)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return hits, nil
+		}
+		return hits, fmt.Errorf("failed to read keyword hits file: %w", err)
+	}
+	if err := json.Unmarshal(data, &hits); err != nil {
+		return hits, fmt.Errorf("failed to parse keyword hits JSON: %w", err)
+	}
+	return hits, nil
+}
+
+// SaveKeywordHits はnumbersのPRにhitを加えて.keyword_hits.jsonを書き直す
+func (s *FileStore) SaveKeywordHits(repo github.RepositoryRef, hit KeywordHit, numbers []int) error {
+	s.hitsMu.Lock()
+	defer s.hitsMu.Unlock()
+
+	hits, err := s.LoadKeywordHits(repo)
+	if err != nil {
+		return err
+	}
+	if !addKeywordHits(hits, hit, numbers) {
+		return nil
+	}
+	return s.writeKeywordHits(repo, hits)
+}
+
+// writeKeywordHits は.keyword_hits.jsonを書き直す（呼び出し側でhitsMuを保持すること）
+func (s *FileStore) writeKeywordHits(repo github.RepositoryRef, hits map[int][]KeywordHit) error {
+	data, err := json.MarshalIndent(hits, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keyword hits: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(repo.DataDir(s.root), KeywordHitsFileName), data)
+}
+
+// addKeywordHits はhitsのnumbersのPRにhitを加える。同じキーワードの記録があるPRには加えない。hitsを変更したかどうかを返す
+func addKeywordHits(hits map[int][]KeywordHit, hit KeywordHit, numbers []int) bool {
+	changed := false
+	for _, number := range numbers {
+		if slices.ContainsFunc(hits[number], func(h KeywordHit) bool { return h.Keyword == hit.Keyword }) {
+			continue
+		}
+		hits[number] = append(hits[number], hit)
+		changed = true
+	}
+	return changed
+}
+
+/**
+ * キーワードの一致の記録から、keywords/<キーワード>/<PRの番号>.jsonをpulls/<PRの番号>.jsonへの相対パスの
+ * シンボリックリンクとして作り直す。保存されていない（コメントがなかった・取得に失敗した）PRのリンクは作らない
+ */
+func (s *FileStore) BuildKeywordViews(repo github.RepositoryRef) error {
+	s.hitsMu.Lock()
+	defer s.hitsMu.Unlock()
+
+	hits, err := s.LoadKeywordHits(repo)
+	if err != nil {
+		return err
+	}
+
+	viewsDir := filepath.Join(repo.DataDir(s.root), keywordsDirName)
+	if err := os.RemoveAll(viewsDir); err != nil {
+		return fmt.Errorf("failed to remove keyword views: %w", err)
+	}
+	for number, prHits := range hits {
+		if _, err := os.Stat(s.conversationPath(repo, number)); err != nil {
+			continue
+		}
+		for _, hit := range prHits {
+			keywordDir := filepath.Join(viewsDir, hit.Keyword)
+			target, err := filepath.Rel(keywordDir, s.conversationPath(repo, number))
+			if err != nil {
+				return fmt.Errorf("failed to build keyword view for PR #%d: %w", number, err)
+			}
+			if err := os.MkdirAll(keywordDir, 0755); err != nil {
+				return fmt.Errorf("failed to create directory for keyword '%s': %w", hit.Keyword, err)
+			}
+			if err := os.Symlink(target, filepath.Join(keywordDir, conversationFileName(number))); err != nil {
+				return fmt.Errorf("failed to build keyword view for PR #%d: %w", number, err)
+			}
+		}
+	}
+	return nil
+}
+
+// legacyConversation は以前の構成の<キーワード>/<PRの番号>.jsonのファイル
+type legacyConversation struct {
+	path    string
+	keyword string
+	number  int
+	modTime time.Time
+}
+
+/**
+ * 以前の構成の<キーワード>/<PRの番号>.jsonを、pulls/<PRの番号>.jsonと.keyword_hits.jsonに移し、移したPRの数を返す
+ * 同じPRが複数のキーワードのディレクトリにあれば、pulls/にあるものも含めて更新日時の新しいファイルを残す
+ * 一致の記録のクエリは分からないため空にし、一致した日時はファイルの更新日時にする
+ * PR番号でないファイルは移さずに残し、空になったキーワードのディレクトリは削除する
+ * pulls・keywordsという名前のキーワードのディレクトリは、今の構成のディレクトリと区別できないため移さない
+ */
+func (s *FileStore) MigrateLegacyLayout(repo github.RepositoryRef) (int, error) {
+	repoDir := repo.DataDir(s.root)
+	legacy, keywordDirs, err := findLegacyConversations(repoDir)
+	if err != nil || len(legacy) == 0 {
+		return 0, err
+	}
+
+	s.hitsMu.Lock()
+	defer s.hitsMu.Unlock()
+
+	// 途中で中断しても移していないファイルから記録し直せるよう、一致の記録を先に書き込む
+	hits, err := s.LoadKeywordHits(repo)
+	if err != nil {
+		return 0, err
+	}
+	// 一致の記録は初めて一致した順に並べる
+	slices.SortStableFunc(legacy, func(a, b legacyConversation) int { return a.modTime.Compare(b.modTime) })
+	newest := make(map[int]legacyConversation)
+	for _, file := range legacy {
+		addKeywordHits(hits, KeywordHit{Keyword: file.keyword, MatchedAt: file.modTime}, []int{file.number})
+		if current, ok := newest[file.number]; !ok || file.modTime.After(current.modTime) {
+			newest[file.number] = file
+		}
+	}
+	if err := s.writeKeywordHits(repo, hits); err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Join(repoDir, pullsDirName), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory: %w", err)
+	}
+	for number, file := range newest {
+		target := s.conversationPath(repo, number)
+		if info, err := os.Stat(target); err == nil && !info.ModTime().Before(file.modTime) {
+			continue
+		}
+		if err := os.Rename(file.path, target); err != nil {
+			return 0, fmt.Errorf("failed to move %s: %w", file.path, err)
+		}
+	}
+	for _, file := range legacy {
+		if err := os.Remove(file.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return 0, fmt.Errorf("failed to remove %s: %w", file.path, err)
+		}
+	}
+	for _, dir := range keywordDirs {
+		// PR番号でないファイルが残っていれば削除できないが、そのまま残す
+		os.Remove(dir)
+	}
+	return len(newest), nil
+}
+
+// findLegacyConversations はrepoDirの下の以前の構成の会話のファイルと、それを含むキーワードのディレクトリを返す
+func findLegacyConversations(repoDir string) ([]legacyConversation, []string, error) {
+	entries, err := os.ReadDir(repoDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", repoDir, err)
+	}
+
+	var legacy []legacyConversation
+	var keywordDirs []string
+	for _, entry := range entries {
+		keyword := entry.Name()
+		if !entry.IsDir() || strings.HasPrefix(keyword, ".") || keyword == pullsDirName || keyword == keywordsDirName {
+			continue
+		}
+		dir := filepath.Join(repoDir, keyword)
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+		found := false
+		for _, file := range files {
+			name := file.Name()
+			if !file.Type().IsRegular() || !strings.EqualFold(filepath.Ext(name), conversationFileExt) {
+				continue
+			}
+			number, err := strconv.Atoi(strings.TrimSuffix(name, filepath.Ext(name)))
+			if err != nil {
+				continue
+			}
+			info, err := file.Info()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to stat %s: %w", filepath.Join(dir, name), err)
+			}
+			legacy = append(legacy, legacyConversation{path: filepath.Join(dir, name), keyword: keyword, number: number, modTime: info.ModTime()})
+			found = true
+		}
+		if found {
+			keywordDirs = append(keywordDirs, dir)
+		}
+	}
+	return legacy, keywordDirs, nil
+}
+
+/**
+ * rootの下の<PRの番号>.jsonを順に読み込んで返す
+ * pulls/のPRのキーワードは同じリポジトリの.keyword_hits.jsonから、以前の構成のPRは親ディレクトリ名から読み出す
+ * .http_cacheなどの隠しディレクトリ、.processed_prs.jsonなどの隠しファイル、キーワードごとのビューのシンボリックリンクは対象外
+ * ファイル名がPR番号でないファイルや読み込めないファイルはエラーとして返し、続きを読む
+ */
+func (s *FileStore) Conversations() iter.Seq2[SavedConversation, error] {
+	return func(yield func(SavedConversation, error) bool) {
+		// hitsByRepo はリポジトリのディレクトリごとのキーワードの一致の記録
+		hitsByRepo := make(map[string]map[int][]KeywordHit)
+		stopped := false
+		err := filepath.WalkDir(s.root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				if path != s.root && strings.HasPrefix(entry.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.EqualFold(filepath.Ext(path), conversationFileExt) || strings.HasPrefix(entry.Name(), ".") || entry.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+
+			conversation, err := s.readConversation(path, hitsByRepo)
+			if !yield(conversation, err) {
+				stopped = true
+				return filepath.SkipAll
+			}
+			return nil
+		})
+		if err != nil && !stopped {
+			yield(SavedConversation{}, fmt.Errorf("failed to walk %s: %w", s.root, err))
+		}
+	}
+}
+
+func (s *FileStore) readConversation(path string, hitsByRepo map[string]map[int][]KeywordHit) (SavedConversation, error) {
+	name := filepath.Base(path)
+	number, err := strconv.Atoi(strings.TrimSuffix(name, filepath.Ext(name)))
+	if err != nil {
+		return SavedConversation{}, fmt.Errorf("file %s: invalid PR number format: %w", name, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SavedConversation{}, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	relPath, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return SavedConversation{}, fmt.Errorf("failed to get relative path for %s: %w", path, err)
+	}
+	conversation := SavedConversation{Number: number, Data: data, path: relPath}
+
+	dir := filepath.Dir(path)
+	repoDir := filepath.Dir(dir)
+	conversation.Repo = repositoryFromDir(filepath.Dir(filepath.Dir(relPath)))
+	if filepath.Base(dir) != pullsDirName {
+		conversation.Keywords = []string{filepath.Base(dir)}
+		return conversation, nil
+	}
+	hits, ok := hitsByRepo[repoDir]
+	if !ok {
+		if hits, err = readKeywordHits(filepath.Join(repoDir, KeywordHitsFileName)); err != nil {
+			return conversation, fmt.Errorf("failed to read keyword hits for %s: %w", path, err)
+		}
+		hitsByRepo[repoDir] = hits
+	}
+	for _, hit := range hits[number] {
+		conversation.Keywords = append(conversation.Keywords, hit.Keyword)
+	}
+	return conversation, nil
+}
+
+// repositoryFromDir はrootからの相対パスのリポジトリのディレクトリ（RepositoryRef.DataDirの逆）からリポジトリを返す。
+// owner/nameの2階層ならgithub.com、それより深ければ先頭をホスト、末尾をリポジトリ名、間をオーナーとみなす
+func repositoryFromDir(rel string) github.RepositoryRef {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	switch {
+	case len(parts) < 2:
+		// rootの直下に置かれた会話はリポジトリが分からない
+		return github.RepositoryRef{}
+	case len(parts) == 2:
+		return github.RepositoryRef{Host: github.DefaultHost, Owner: parts[0], Name: parts[1]}
+	default:
+		last := len(parts) - 1
+		return github.RepositoryRef{Host: parts[0], Owner: strings.Join(parts[1:last], "/"), Name: parts[last]}
+	}
+}
+
+// analyzedIndexFile は結果ファイルと同じディレクトリにある分析済みPRのインデックスファイルのパスを返す
+func (s *FileStore) analyzedIndexFile() string {
+	dir := filepath.Dir(s.resultsFile)
+	base := filepath.Base(s.resultsFile)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	return filepath.Join(dir, "."+name+"_index.json")
+}
+
+// analysisKey は分析済みのインデックスでPRを表すキー（"owner/repo#7"）を返す
+func analysisKey(conversation SavedConversation) string {
+	return fmt.Sprintf("%s#%d", repositoryName(conversation.Repo), conversation.Number)
+}
+
+// repositoryName はリポジトリの名前（owner/repoまたはhost/owner/repo）を返す。リポジトリが分からなければ空文字列を返す
+func repositoryName(repo github.RepositoryRef) string {
+	if repo.Name == "" {
+		return ""
+	}
+	return repo.String()
+}
+
+// Analyzed はPRが分析済みのインデックスに含まれるかどうかを返す。初回の呼び出しでインデックスファイルを読み込む
+func (s *FileStore) Analyzed(conversation SavedConversation) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.loadAnalyzedLocked(); err != nil {
+		return false, err
+	}
+	return s.analyzed[analysisKey(conversation)] || s.legacyAnalyzed[conversation.Number], nil
+}
+
+func (s *FileStore) loadAnalyzedLocked() error {
+	if s.analyzed != nil {
+		return nil
+	}
+	if s.resultsFile == "" {
+		return fmt.Errorf("no results file is configured for the file store")
+	}
+	keys, prNumbers, err := readAnalyzedIndex(s.analyzedIndexFile())
+	if err != nil {
+		return fmt.Errorf("failed to load analyzed PRs: %w", err)
+	}
+	s.analyzed = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		s.analyzed[key] = true
+	}
+	s.legacyAnalyzed = make(map[int]bool, len(prNumbers))
+	for _, prNumber := range prNumbers {
+		s.legacyAnalyzed[prNumber] = true
+	}
+	return nil
+}
+
+/**
+ * 分析結果をリポジトリを付けて結果ファイルに1行追記し、PRを分析済みにする
+ * インデックスファイルへはanalyzedBufferSize件ごとと、Closeのときにまとめて書き出す
+ */
+func (s *FileStore) SaveAnalysis(conversation SavedConversation, result openai.VulnerabilityDetectionResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.loadAnalyzedLocked(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.resultsFile), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	line := analyzedResult{Repo: repositoryName(conversation.Repo), VulnerabilityDetectionResult: result}
+	if err := appendJSONLine(s.resultsFile, line); err != nil {
+		return err
+	}
+
+	key := analysisKey(conversation)
+	s.analyzed[key] = true
+	s.unsaved = append(s.unsaved, key)
+	if len(s.unsaved) >= analyzedBufferSize {
+		return s.flushAnalyzedLocked()
+	}
+	return nil
+}
+
+// flushAnalyzedLocked はまだ書き出していない分析済みPRを、既存のインデックスと合わせて書き出す
+func (s *FileStore) flushAnalyzedLocked() error {
+	if len(s.unsaved) == 0 {
+		return nil
+	}
+
+	keys, prNumbers, err := readAnalyzedIndex(s.analyzedIndexFile())
+	if err != nil {
+		keys, prNumbers = nil, nil
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		seen[key] = true
+	}
+	for _, key := range s.unsaved {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	// 以前のPR番号だけの記録も残す
+	entries := make([]any, 0, len(prNumbers)+len(keys))
+	for _, prNumber := range prNumbers {
+		entries = append(entries, prNumber)
+	}
+	for _, key := range keys {
+		entries = append(entries, key)
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal analyzed PRs: %w", err)
+	}
+	if err := os.WriteFile(s.analyzedIndexFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+	s.unsaved = s.unsaved[:0]
+	return nil
+}
+
+// readAnalyzedIndex は分析済みPRのインデックスを読み込み、analysisKeyと、以前の形式のPR番号に分けて返す。
+// ファイルがなければ空のスライスを返す
+func readAnalyzedIndex(path string) ([]string, []int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	var keys []string
+	var prNumbers []int
+	for _, entry := range entries {
+		var key string
+		if err := json.Unmarshal(entry, &key); err == nil {
+			keys = append(keys, key)
+			continue
+		}
+		var prNumber int
+		if err := json.Unmarshal(entry, &prNumber); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		prNumbers = append(prNumbers, prNumber)
+	}
+	return keys, prNumbers, nil
+}
+
+// SaveConverted は変換先ディレクトリに、rootからの相対パスを保って書き出す
+func (s *FileStore) SaveConverted(conversation SavedConversation, data []byte) error {
+	if s.convertedDir == "" {
+		return fmt.Errorf("no output directory is configured for the file store")
+	}
+	if conversation.path == "" {
+		return fmt.Errorf("PR #%d was not read from the file store", conversation.Number)
+	}
+
+	outputPath := filepath.Join(s.convertedDir, conversation.path)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w", filepath.Dir(outputPath), err)
+	}
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", outputPath, err)
+	}
+	return nil
+}
+
+// Close はまだ書き出していない分析済みPRをインデックスファイルに書き出す
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushAnalyzedLocked()
+}
+
+// readIntList は数値のJSON配列のファイルを読み込む。ファイルがなければ空のスライスを返す
+func readIntList(path string) ([]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var values []int
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// appendJSONLine はvalueをJSONにしてファイルの末尾に1行追記する
+func appendJSONLine(path string, value any) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal result: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write result: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic は一時ファイルに書いてから置き換えることで、書き込み途中で中断しても既存のファイルを壊さない
+func writeFileAtomic(filePath string, data []byte) error {
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
+}
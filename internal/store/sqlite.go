@@ -0,0 +1,720 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/url"
+	"time"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/llm"
+	"github.com/malsuke/PRalyzer/internal/openai"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteDriverName はmodernc.org/sqlite（CGO不要のSQLiteドライバ）の登録名
+const sqliteDriverName = "sqlite"
+
+// sqliteBusyTimeout は他のプロセスがデータベースをロックしているときに待つ時間（ミリ秒）
+const sqliteBusyTimeout = 5000
+
+// commentKind はcommentsテーブルのkind列の値
+const (
+	commentKindIssue  = "issue"
+	commentKindReview = "review"
+)
+
+/**
+ * sqliteSchema はSQLiteStoreのテーブル
+ *   - repositories: 取得元のリポジトリ
+ *   - pull_requests: PR本体（リポジトリとPR番号で1件）。payloadはllm.PullRequestPayloadのJSON
+ *   - comments: Issue Comments（kind = 'issue'）とReview Comments（kind = 'review'）。dataは取得したコメントのJSON
+ *   - reviews: Reviews。dataは取得したレビューのJSON
+ *   - keyword_hits: PRがキーワードの検索に一致した記録。会話を保存していないPR（処理済み・コメントなし）の一致も記録する
+ *   - keyword_pull_requests: keyword_hitsから作る、キーワードごとの保存済みのPRのビュー
+ *   - processed_pull_requests・sync_states: クロールの再開と差分同期の記録
+ *   - llm_results: LLMの分析結果
+ *   - converted_pull_requests: LLM向けに変換したJSON
+ * 取得したままのJSONを保存しつつ、よく使う項目は列にも持たせてSQLで集計できるようにする
+ */
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS repositories (
+	id    INTEGER PRIMARY KEY,
+	host  TEXT NOT NULL,
+	owner TEXT NOT NULL,
+	name  TEXT NOT NULL,
+	UNIQUE (host, owner, name)
+);
+CREATE TABLE IF NOT EXISTS pull_requests (
+	id               INTEGER PRIMARY KEY,
+	repository_id    INTEGER NOT NULL REFERENCES repositories (id) ON DELETE CASCADE,
+	number           INTEGER NOT NULL,
+	title            TEXT,
+	author           TEXT,
+	merged_at        TEXT,
+	payload          TEXT,
+	resolved_threads TEXT,
+	saved_at         TEXT NOT NULL,
+	UNIQUE (repository_id, number)
+);
+CREATE TABLE IF NOT EXISTS comments (
+	pull_request_id INTEGER NOT NULL REFERENCES pull_requests (id) ON DELETE CASCADE,
+	kind            TEXT NOT NULL,
+	position        INTEGER NOT NULL,
+	comment_id      INTEGER,
+	in_reply_to     INTEGER,
+	user            TEXT,
+	path            TEXT,
+	body            TEXT,
+	created_at      TEXT,
+	data            TEXT NOT NULL,
+	PRIMARY KEY (pull_request_id, kind, position)
+);
+CREATE TABLE IF NOT EXISTS reviews (
+	pull_request_id INTEGER NOT NULL REFERENCES pull_requests (id) ON DELETE CASCADE,
+	position        INTEGER NOT NULL,
+	review_id       INTEGER,
+	user            TEXT,
+	state           TEXT,
+	body            TEXT,
+	submitted_at    TEXT,
+	data            TEXT NOT NULL,
+	PRIMARY KEY (pull_request_id, position)
+);
+CREATE TABLE IF NOT EXISTS keyword_hits (
+	repository_id INTEGER NOT NULL REFERENCES repositories (id) ON DELETE CASCADE,
+	number        INTEGER NOT NULL,
+	keyword       TEXT NOT NULL,
+	query         TEXT NOT NULL,
+	matched_at    TEXT NOT NULL,
+	PRIMARY KEY (repository_id, number, keyword)
+);
+CREATE VIEW IF NOT EXISTS keyword_pull_requests AS
+	SELECT k.keyword, r.host, r.owner, r.name, k.number, p.id AS pull_request_id, p.title, k.query, k.matched_at
+	FROM keyword_hits k
+	JOIN repositories r ON r.id = k.repository_id
+	JOIN pull_requests p ON p.repository_id = k.repository_id AND p.number = k.number;
+CREATE TABLE IF NOT EXISTS processed_pull_requests (
+	repository_id INTEGER NOT NULL REFERENCES repositories (id) ON DELETE CASCADE,
+	number        INTEGER NOT NULL,
+	PRIMARY KEY (repository_id, number)
+);
+CREATE TABLE IF NOT EXISTS sync_states (
+	repository_id   INTEGER PRIMARY KEY REFERENCES repositories (id) ON DELETE CASCADE,
+	last_updated_at TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS llm_results (
+	pull_request_id     INTEGER PRIMARY KEY REFERENCES pull_requests (id) ON DELETE CASCADE,
+	relevant_discussion TEXT NOT NULL,
+	reason              TEXT NOT NULL,
+	analyzed_at         TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS converted_pull_requests (
+	pull_request_id INTEGER PRIMARY KEY REFERENCES pull_requests (id) ON DELETE CASCADE,
+	data            TEXT NOT NULL,
+	converted_at    TEXT NOT NULL
+);
+`
+
+// sqliteSchemaVersion はスキーマの版（PRAGMA user_version）
+const sqliteSchemaVersion = 1
+
+var _ Store = (*SQLiteStore)(nil)
+
+/**
+ * SQLiteStore は1つのSQLiteデータベースに保存するStore
+ * PRはリポジトリとPR番号ごとに1件だけ保存し、一致したキーワードはkeyword_hitsに記録する
+ * キーワードごとのビューはkeyword_pull_requestsビューとしてスキーマに含まれる
+ * 書き込みが競合しないよう接続は1本だけ使う
+ */
+type SQLiteStore struct {
+	db   *sql.DB
+	path string
+	// now は保存日時の記録に使う（テストで差し替える）
+	now func() time.Time
+}
+
+// OpenSQLite はpathのSQLiteデータベースを開き、テーブルがなければ作成する
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	dsn := "file:" + path + "?" + url.Values{
+		"_pragma": {"foreign_keys(1)", fmt.Sprintf("busy_timeout(%d)", sqliteBusyTimeout)},
+	}.Encode()
+	db, err := sql.Open(sqliteDriverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := initSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create tables in %s: %w", path, err)
+	}
+	return &SQLiteStore{db: db, path: path, now: time.Now}, nil
+}
+
+// initSQLite はテーブルを作成してスキーマの版を記録する。このプログラムより新しい版のデータベースはエラーにする
+func initSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version > sqliteSchemaVersion {
+		return fmt.Errorf("schema version %d is newer than supported version %d", version, sqliteSchemaVersion)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	if _, err := db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, sqliteSchemaVersion)); err != nil {
+		return fmt.Errorf("failed to write schema version: %w", err)
+	}
+	return nil
+}
+
+// Location はデータベースファイルのパスを返す
+func (s *SQLiteStore) Location(repo github.RepositoryRef) string {
+	return s.path
+}
+
+// Close はデータベースを閉じる
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// queryer は*sql.DBと*sql.Txに共通のメソッド
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// repositoryID はrepoの行のIDを返す。createがtrueであれば行がなければ作成し、falseであればErrNotFoundを返す
+func repositoryID(q queryer, repo github.RepositoryRef, create bool) (int64, error) {
+	if create {
+		if _, err := q.Exec(`INSERT INTO repositories (host, owner, name) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+			repo.Host, repo.Owner, repo.Name); err != nil {
+			return 0, fmt.Errorf("failed to save repository %s: %w", repo, err)
+		}
+	}
+
+	var id int64
+	err := q.QueryRow(`SELECT id FROM repositories WHERE host = ? AND owner = ? AND name = ?`,
+		repo.Host, repo.Owner, repo.Name).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("repository %s: %w", repo, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up repository %s: %w", repo, err)
+	}
+	return id, nil
+}
+
+// pullRequestID はrepoのPR番号numberの行のIDを返す。保存されていなければErrNotFoundを返す
+func pullRequestID(q queryer, repo github.RepositoryRef, number int) (int64, error) {
+	var id int64
+	err := q.QueryRow(`
+		SELECT p.id FROM pull_requests p JOIN repositories r ON r.id = p.repository_id
+		WHERE r.host = ? AND r.owner = ? AND r.name = ? AND p.number = ?`,
+		repo.Host, repo.Owner, repo.Name, number).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("PR #%d of %s: %w", number, repo, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up PR #%d of %s: %w", number, repo, err)
+	}
+	return id, nil
+}
+
+// withTx はfnをトランザクションの中で実行し、fnがエラーを返せばロールバックする
+func (s *SQLiteStore) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// SaveConversation はPR本体とコメント・レビューを保存する。保存済みであれば新しい内容で置き換える
+func (s *SQLiteStore) SaveConversation(repo github.RepositoryRef, number int, comments PRComments) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		repoID, err := repositoryID(tx, repo, true)
+		if err != nil {
+			return err
+		}
+
+		var title, author, mergedAt, payload, resolved sql.NullString
+		if pr := comments.PullRequest; pr != nil {
+			title = sql.NullString{String: pr.Title, Valid: true}
+			author = sql.NullString{String: pr.Author, Valid: true}
+			mergedAt = formatTimestamp(pr.MergedAt)
+			if payload, err = marshalNullString(pr); err != nil {
+				return fmt.Errorf("failed to marshal PR #%d: %w", number, err)
+			}
+		}
+		if len(comments.ResolvedThreads) > 0 {
+			if resolved, err = marshalNullString(comments.ResolvedThreads); err != nil {
+				return fmt.Errorf("failed to marshal resolved threads of PR #%d: %w", number, err)
+			}
+		}
+
+		var prID int64
+		err = tx.QueryRow(`
+			INSERT INTO pull_requests (repository_id, number, title, author, merged_at, payload, resolved_threads, saved_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (repository_id, number) DO UPDATE SET
+				title = excluded.title, author = excluded.author, merged_at = excluded.merged_at,
+				payload = excluded.payload, resolved_threads = excluded.resolved_threads, saved_at = excluded.saved_at
+			RETURNING id`,
+			repoID, number, title, author, mergedAt, payload, resolved, formatTime(s.now())).Scan(&prID)
+		if err != nil {
+			return fmt.Errorf("failed to save PR #%d: %w", number, err)
+		}
+
+		if err := saveComments(tx, prID, comments); err != nil {
+			return fmt.Errorf("failed to save comments of PR #%d: %w", number, err)
+		}
+		if err := saveReviews(tx, prID, comments.Reviews); err != nil {
+			return fmt.Errorf("failed to save reviews of PR #%d: %w", number, err)
+		}
+		return nil
+	})
+}
+
+// SaveKeywordHits はnumbersのPRがhitのキーワードに一致したことを記録する
+func (s *SQLiteStore) SaveKeywordHits(repo github.RepositoryRef, hit KeywordHit, numbers []int) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		repoID, err := repositoryID(tx, repo, true)
+		if err != nil {
+			return err
+		}
+		for _, number := range numbers {
+			if _, err := tx.Exec(`
+				INSERT INTO keyword_hits (repository_id, number, keyword, query, matched_at) VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT DO NOTHING`,
+				repoID, number, hit.Keyword, hit.Query, formatTime(hit.MatchedAt)); err != nil {
+				return fmt.Errorf("failed to save keyword hit of PR #%d: %w", number, err)
+			}
+		}
+		return nil
+	})
+}
+
+// LoadKeywordHits はrepoのPRごとのキーワードの一致の記録を、初めて一致した順に読み込む
+func (s *SQLiteStore) LoadKeywordHits(repo github.RepositoryRef) (map[int][]KeywordHit, error) {
+	hits := make(map[int][]KeywordHit)
+	rows, err := s.db.Query(`
+		SELECT k.number, k.keyword, k.query, k.matched_at
+		FROM keyword_hits k JOIN repositories r ON r.id = k.repository_id
+		WHERE r.host = ? AND r.owner = ? AND r.name = ?
+		ORDER BY k.number, k.matched_at, k.rowid`, repo.Host, repo.Owner, repo.Name)
+	if err != nil {
+		return hits, fmt.Errorf("failed to load keyword hits: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var number int
+		var hit KeywordHit
+		var matchedAt string
+		if err := rows.Scan(&number, &hit.Keyword, &hit.Query, &matchedAt); err != nil {
+			return hits, fmt.Errorf("failed to load keyword hits: %w", err)
+		}
+		if hit.MatchedAt, err = time.Parse(time.RFC3339Nano, matchedAt); err != nil {
+			return hits, fmt.Errorf("failed to parse keyword hit of PR #%d: %w", number, err)
+		}
+		hits[number] = append(hits[number], hit)
+	}
+	if err := rows.Err(); err != nil {
+		return hits, fmt.Errorf("failed to load keyword hits: %w", err)
+	}
+	return hits, nil
+}
+
+// BuildKeywordViews は何もしない。SQLiteではkeyword_pull_requestsビューが常にkeyword_hitsから作られる
+func (s *SQLiteStore) BuildKeywordViews(repo github.RepositoryRef) error {
+	return nil
+}
+
+// saveComments はPRのIssue CommentsとReview Commentsを取得した順に保存し直す
+func saveComments(tx *sql.Tx, prID int64, comments PRComments) error {
+	if _, err := tx.Exec(`DELETE FROM comments WHERE pull_request_id = ?`, prID); err != nil {
+		return err
+	}
+
+	insert := `INSERT INTO comments (pull_request_id, kind, position, comment_id, in_reply_to, user, path, body, created_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	for i, comment := range comments.IssueComments {
+		data, err := json.Marshal(comment)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(insert, prID, commentKindIssue, i, comment.ID, nil, comment.GetUser().GetLogin(), nil,
+			comment.GetBody(), formatTimestamp(comment.CreatedAt), string(data)); err != nil {
+			return err
+		}
+	}
+	for i, comment := range comments.ReviewComments {
+		data, err := json.Marshal(comment)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(insert, prID, commentKindReview, i, comment.ID, comment.InReplyTo, comment.GetUser().GetLogin(),
+			comment.GetPath(), comment.GetBody(), formatTimestamp(comment.CreatedAt), string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveReviews はPRのReviewsを取得した順に保存し直す
+func saveReviews(tx *sql.Tx, prID int64, reviews []*gh.PullRequestReview) error {
+	if _, err := tx.Exec(`DELETE FROM reviews WHERE pull_request_id = ?`, prID); err != nil {
+		return err
+	}
+
+	for i, review := range reviews {
+		data, err := json.Marshal(review)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO reviews (pull_request_id, position, review_id, user, state, body, submitted_at, data)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			prID, i, review.ID, review.GetUser().GetLogin(), review.GetState(), review.GetBody(),
+			formatTimestamp(review.SubmittedAt), string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadConversation はrepoのPRを読み込む
+func (s *SQLiteStore) LoadConversation(repo github.RepositoryRef, number int) (PRComments, error) {
+	prID, err := pullRequestID(s.db, repo, number)
+	if err != nil {
+		return PRComments{}, err
+	}
+	return s.loadComments(prID)
+}
+
+// loadComments はPR本体とコメント・レビューを保存した順に読み込んでPRCommentsに戻す
+func (s *SQLiteStore) loadComments(prID int64) (PRComments, error) {
+	comments := PRComments{
+		IssueComments:  []*gh.IssueComment{},
+		ReviewComments: []*gh.PullRequestComment{},
+		Reviews:        []*gh.PullRequestReview{},
+	}
+
+	var payload, resolved sql.NullString
+	if err := s.db.QueryRow(`SELECT payload, resolved_threads FROM pull_requests WHERE id = ?`, prID).Scan(&payload, &resolved); err != nil {
+		return comments, fmt.Errorf("failed to read PR: %w", err)
+	}
+	if payload.Valid {
+		comments.PullRequest = &llm.PullRequestPayload{}
+		if err := json.Unmarshal([]byte(payload.String), comments.PullRequest); err != nil {
+			return comments, fmt.Errorf("failed to parse PR: %w", err)
+		}
+	}
+	if resolved.Valid {
+		if err := json.Unmarshal([]byte(resolved.String), &comments.ResolvedThreads); err != nil {
+			return comments, fmt.Errorf("failed to parse resolved threads: %w", err)
+		}
+	}
+
+	rows, err := s.db.Query(`SELECT kind, data FROM comments WHERE pull_request_id = ? ORDER BY kind, position`, prID)
+	if err != nil {
+		return comments, fmt.Errorf("failed to read comments: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kind, data string
+		if err := rows.Scan(&kind, &data); err != nil {
+			return comments, fmt.Errorf("failed to read comments: %w", err)
+		}
+		switch kind {
+		case commentKindIssue:
+			var comment gh.IssueComment
+			if err := json.Unmarshal([]byte(data), &comment); err != nil {
+				return comments, fmt.Errorf("failed to parse issue comment: %w", err)
+			}
+			comments.IssueComments = append(comments.IssueComments, &comment)
+		case commentKindReview:
+			var comment gh.PullRequestComment
+			if err := json.Unmarshal([]byte(data), &comment); err != nil {
+				return comments, fmt.Errorf("failed to parse review comment: %w", err)
+			}
+			comments.ReviewComments = append(comments.ReviewComments, &comment)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return comments, fmt.Errorf("failed to read comments: %w", err)
+	}
+	rows.Close()
+
+	reviewRows, err := s.db.Query(`SELECT data FROM reviews WHERE pull_request_id = ? ORDER BY position`, prID)
+	if err != nil {
+		return comments, fmt.Errorf("failed to read reviews: %w", err)
+	}
+	defer reviewRows.Close()
+	for reviewRows.Next() {
+		var data string
+		if err := reviewRows.Scan(&data); err != nil {
+			return comments, fmt.Errorf("failed to read reviews: %w", err)
+		}
+		var review gh.PullRequestReview
+		if err := json.Unmarshal([]byte(data), &review); err != nil {
+			return comments, fmt.Errorf("failed to parse review: %w", err)
+		}
+		comments.Reviews = append(comments.Reviews, &review)
+	}
+	if err := reviewRows.Err(); err != nil {
+		return comments, fmt.Errorf("failed to read reviews: %w", err)
+	}
+	return comments, nil
+}
+
+// LoadProcessed はrepoの処理済みPR番号を読み込む
+func (s *SQLiteStore) LoadProcessed(repo github.RepositoryRef) (map[int]bool, error) {
+	processed := make(map[int]bool)
+	rows, err := s.db.Query(`
+		SELECT p.number FROM processed_pull_requests p JOIN repositories r ON r.id = p.repository_id
+		WHERE r.host = ? AND r.owner = ? AND r.name = ?`, repo.Host, repo.Owner, repo.Name)
+	if err != nil {
+		return processed, fmt.Errorf("failed to load processed PRs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var number int
+		if err := rows.Scan(&number); err != nil {
+			return processed, fmt.Errorf("failed to load processed PRs: %w", err)
+		}
+		processed[number] = true
+	}
+	if err := rows.Err(); err != nil {
+		return processed, fmt.Errorf("failed to load processed PRs: %w", err)
+	}
+	return processed, nil
+}
+
+// SaveProcessed はrepoの処理済みPR番号をprsで置き換える
+func (s *SQLiteStore) SaveProcessed(repo github.RepositoryRef, prs map[int]bool) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		repoID, err := repositoryID(tx, repo, true)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM processed_pull_requests WHERE repository_id = ?`, repoID); err != nil {
+			return fmt.Errorf("failed to save processed PRs: %w", err)
+		}
+		for number, processed := range prs {
+			if !processed {
+				continue
+			}
+			if _, err := tx.Exec(`INSERT INTO processed_pull_requests (repository_id, number) VALUES (?, ?)`, repoID, number); err != nil {
+				return fmt.Errorf("failed to save processed PRs: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// LoadSyncState はrepoの差分同期の状態を読み込む。記録がなければゼロ値を返す
+func (s *SQLiteStore) LoadSyncState(repo github.RepositoryRef) (SyncState, error) {
+	var value string
+	err := s.db.QueryRow(`
+		SELECT s.last_updated_at FROM sync_states s JOIN repositories r ON r.id = s.repository_id
+		WHERE r.host = ? AND r.owner = ? AND r.name = ?`, repo.Host, repo.Owner, repo.Name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return SyncState{}, nil
+	}
+	if err != nil {
+		return SyncState{}, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	lastUpdatedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return SyncState{}, fmt.Errorf("failed to parse sync state: %w", err)
+	}
+	return SyncState{LastUpdatedAt: lastUpdatedAt}, nil
+}
+
+// SaveSyncState はrepoの差分同期の状態を保存する
+func (s *SQLiteStore) SaveSyncState(repo github.RepositoryRef, state SyncState) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		repoID, err := repositoryID(tx, repo, true)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO sync_states (repository_id, last_updated_at) VALUES (?, ?)
+			ON CONFLICT (repository_id) DO UPDATE SET last_updated_at = excluded.last_updated_at`,
+			repoID, formatTime(state.LastUpdatedAt)); err != nil {
+			return fmt.Errorf("failed to save sync state: %w", err)
+		}
+		return nil
+	})
+}
+
+/**
+ * 保存済みのPRをリポジトリ・PR番号の順に1件ずつ返す
+ * 一覧を先に読み切ってから1件ずつ読み込むため、受け取った側が途中でSaveAnalysisなどを呼んでもよい
+ */
+func (s *SQLiteStore) Conversations() iter.Seq2[SavedConversation, error] {
+	return func(yield func(SavedConversation, error) bool) {
+		type entry struct {
+			id           int64
+			conversation SavedConversation
+		}
+
+		rows, err := s.db.Query(`
+			SELECT p.id, r.host, r.owner, r.name, p.number
+			FROM pull_requests p JOIN repositories r ON r.id = p.repository_id
+			ORDER BY r.host, r.owner, r.name, p.number`)
+		if err != nil {
+			yield(SavedConversation{}, fmt.Errorf("failed to list PRs: %w", err))
+			return
+		}
+		var entries []entry
+		for rows.Next() {
+			var e entry
+			repo := &e.conversation.Repo
+			if err := rows.Scan(&e.id, &repo.Host, &repo.Owner, &repo.Name, &e.conversation.Number); err != nil {
+				rows.Close()
+				yield(SavedConversation{}, fmt.Errorf("failed to list PRs: %w", err))
+				return
+			}
+			entries = append(entries, e)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			yield(SavedConversation{}, fmt.Errorf("failed to list PRs: %w", err))
+			return
+		}
+
+		for _, e := range entries {
+			conversation, err := s.readConversation(e.id, e.conversation)
+			if !yield(conversation, err) {
+				return
+			}
+		}
+	}
+}
+
+// readConversation はPRのキーワードとPRCommentsのJSONを読み込む
+func (s *SQLiteStore) readConversation(prID int64, conversation SavedConversation) (SavedConversation, error) {
+	keywords, err := s.keywords(prID)
+	if err != nil {
+		return conversation, fmt.Errorf("failed to read keywords of PR #%d of %s: %w", conversation.Number, conversation.Repo, err)
+	}
+	conversation.Keywords = keywords
+
+	comments, err := s.loadComments(prID)
+	if err != nil {
+		return conversation, fmt.Errorf("failed to read PR #%d of %s: %w", conversation.Number, conversation.Repo, err)
+	}
+	if conversation.Data, err = json.MarshalIndent(comments, "", "  "); err != nil {
+		return conversation, fmt.Errorf("failed to marshal PR #%d of %s: %w", conversation.Number, conversation.Repo, err)
+	}
+	return conversation, nil
+}
+
+func (s *SQLiteStore) keywords(prID int64) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT k.keyword FROM keyword_hits k JOIN pull_requests p ON p.repository_id = k.repository_id AND p.number = k.number
+		WHERE p.id = ? ORDER BY k.matched_at, k.rowid`, prID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keywords []string
+	for rows.Next() {
+		var keyword string
+		if err := rows.Scan(&keyword); err != nil {
+			return nil, err
+		}
+		keywords = append(keywords, keyword)
+	}
+	return keywords, rows.Err()
+}
+
+// Analyzed はconversationのLLMの分析結果が保存済みかどうかを返す
+func (s *SQLiteStore) Analyzed(conversation SavedConversation) (bool, error) {
+	var analyzed bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM llm_results l
+			JOIN pull_requests p ON p.id = l.pull_request_id
+			JOIN repositories r ON r.id = p.repository_id
+			WHERE r.host = ? AND r.owner = ? AND r.name = ? AND p.number = ?
+		)`, conversation.Repo.Host, conversation.Repo.Owner, conversation.Repo.Name, conversation.Number).Scan(&analyzed)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up analysis of PR #%d: %w", conversation.Number, err)
+	}
+	return analyzed, nil
+}
+
+// SaveAnalysis はconversationのLLMの分析結果を保存する（保存済みであれば置き換える）
+func (s *SQLiteStore) SaveAnalysis(conversation SavedConversation, result openai.VulnerabilityDetectionResult) error {
+	prID, err := pullRequestID(s.db, conversation.Repo, conversation.Number)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`
+		INSERT INTO llm_results (pull_request_id, relevant_discussion, reason, analyzed_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (pull_request_id) DO UPDATE SET
+			relevant_discussion = excluded.relevant_discussion, reason = excluded.reason, analyzed_at = excluded.analyzed_at`,
+		prID, result.RelevantDiscussion, result.Reason, formatTime(s.now())); err != nil {
+		return fmt.Errorf("failed to save analysis of PR #%d: %w", conversation.Number, err)
+	}
+	return nil
+}
+
+// SaveConverted はconversationをLLM向けに変換したJSONを保存する（保存済みであれば置き換える）
+func (s *SQLiteStore) SaveConverted(conversation SavedConversation, data []byte) error {
+	prID, err := pullRequestID(s.db, conversation.Repo, conversation.Number)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`
+		INSERT INTO converted_pull_requests (pull_request_id, data, converted_at) VALUES (?, ?, ?)
+		ON CONFLICT (pull_request_id) DO UPDATE SET data = excluded.data, converted_at = excluded.converted_at`,
+		prID, string(data), formatTime(s.now())); err != nil {
+		return fmt.Errorf("failed to save converted PR #%d: %w", conversation.Number, err)
+	}
+	return nil
+}
+
+// sqliteTimeFormat は日時の列の形式。小数部の桁数を固定したRFC 3339で、文字列の順序が日時の順序と一致する
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// formatTime は日時をUTCのsqliteTimeFormatの文字列にする
+func formatTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+func formatTimestamp(t *gh.Timestamp) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(t.Time), Valid: true}
+}
+
+func marshalNullString(value any) (sql.NullString, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
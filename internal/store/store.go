@@ -0,0 +1,120 @@
+// Package store は収集したPRの会話・キーワードの一致・処理済みの記録・LLMの分析結果の保存先を扱う。
+// 従来どおりのJSONファイルのディレクトリ構成（FileStore）と、1つのSQLiteデータベース（SQLiteStore）の
+// どちらにも同じStoreインターフェースで読み書きできる。
+package store
+
+import (
+	"errors"
+	"iter"
+	"time"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/llm"
+	"github.com/malsuke/PRalyzer/internal/openai"
+)
+
+// ErrNotFound は保存されていないPRを読み込もうとしたときのエラー
+var ErrNotFound = errors.New("not found in the store")
+
+// PRComments はPR本体の情報とIssue Comments、Review Comments、Reviewsを保持する構造体。
+// 1件のPRの保存形式であり、LLMにはこのJSONをそのまま渡す
+type PRComments struct {
+	PullRequest     *llm.PullRequestPayload  `json:"pull_request,omitempty"`
+	IssueComments   []*gh.IssueComment       `json:"issue_comments"`
+	ReviewComments  []*gh.PullRequestComment `json:"review_comments"`
+	Reviews         []*gh.PullRequestReview  `json:"reviews"`
+	ResolvedThreads map[int64]bool           `json:"resolved_threads,omitempty"`
+}
+
+// NewPRComments は取得した会話を保存形式に変換する
+func NewPRComments(conversation *github.Conversation) PRComments {
+	return PRComments{
+		PullRequest:     llm.ConvertPullRequestToPayload(conversation.PullRequest),
+		IssueComments:   conversation.IssueComments,
+		ReviewComments:  conversation.ReviewComments,
+		Reviews:         conversation.Reviews,
+		ResolvedThreads: conversation.ResolvedThreads,
+	}
+}
+
+// Conversation は保存済みのコメントを、新しく取得した会話と突き合わせられる形に戻す（PR本体は含まない）
+func (p PRComments) Conversation() *github.Conversation {
+	return &github.Conversation{
+		IssueComments:   p.IssueComments,
+		ReviewComments:  p.ReviewComments,
+		Reviews:         p.Reviews,
+		ResolvedThreads: p.ResolvedThreads,
+	}
+}
+
+// SyncState は差分同期の状態。LastUpdatedAt は前回までに見たPRの最新のupdated_at（ハイウォーターマーク）。
+type SyncState struct {
+	LastUpdatedAt time.Time `json:"last_updated_at"`
+}
+
+// KeywordHit はPRがキーワードの検索に一致した記録
+type KeywordHit struct {
+	Keyword string `json:"keyword"`
+	// Query は一致したときの検索クエリ
+	Query string `json:"query"`
+	// MatchedAt は初めて一致した日時
+	MatchedAt time.Time `json:"matched_at"`
+}
+
+// SavedConversation は保存済みのPR1件。LLMでの分析や変換の対象として読み出す
+type SavedConversation struct {
+	// Repo はPRのリポジトリ。FileStoreではリポジトリのディレクトリ（owner/repoまたはhost/owner/repo）から求める
+	Repo   github.RepositoryRef
+	Number int
+	// Keywords はPRが一致したキーワード（キーワードの一致の記録から読み出す）
+	Keywords []string
+	// Data はPRCommentsのJSON
+	Data []byte
+
+	// path はFileStoreでのファイルのパス（ルートからの相対パス）
+	path string
+}
+
+// Store は収集・分析・変換の結果の保存先。
+// 中断したときにも進捗を保存できるよう、メソッドはcontextを受け取らない
+type Store interface {
+	// SaveConversation はrepoのPRの会話を保存する。PRは一致したキーワードの数によらず1件だけ保存する
+	SaveConversation(repo github.RepositoryRef, number int, comments PRComments) error
+	// LoadConversation はrepoのPRの会話を読み込む。保存されていなければErrNotFoundを返す
+	LoadConversation(repo github.RepositoryRef, number int) (PRComments, error)
+	// SaveKeywordHits はrepoのnumbersのPRがhitのキーワードに一致したことを記録する。記録済みのキーワードは初めの記録を残す
+	SaveKeywordHits(repo github.RepositoryRef, hit KeywordHit, numbers []int) error
+	// LoadKeywordHits はrepoのPRごとのキーワードの一致の記録を読み込む
+	LoadKeywordHits(repo github.RepositoryRef) (map[int][]KeywordHit, error)
+	// BuildKeywordViews はキーワードの一致の記録から、キーワードごとに保存済みのPRを引けるビューを作り直す
+	BuildKeywordViews(repo github.RepositoryRef) error
+	// LoadProcessed はrepoの処理済みPR番号を読み込む
+	LoadProcessed(repo github.RepositoryRef) (map[int]bool, error)
+	// SaveProcessed はrepoの処理済みPR番号を保存する（保存済みの番号を置き換える）
+	SaveProcessed(repo github.RepositoryRef, prs map[int]bool) error
+	// LoadSyncState はrepoの差分同期の状態を読み込む。記録がなければゼロ値を返す
+	LoadSyncState(repo github.RepositoryRef) (SyncState, error)
+	// SaveSyncState はrepoの差分同期の状態を保存する
+	SaveSyncState(repo github.RepositoryRef, state SyncState) error
+	// Location はrepoのデータの保存先（ディレクトリやデータベースファイル）を返す
+	Location(repo github.RepositoryRef) string
+
+	// Conversations は保存済みのPRを1件ずつ返す。読み込めなかったPRはエラーとして返し、続きを返す
+	Conversations() iter.Seq2[SavedConversation, error]
+	// Analyzed はconversationのLLMの分析結果が保存済みかどうかを返す
+	Analyzed(conversation SavedConversation) (bool, error)
+	// SaveAnalysis はconversationのLLMの分析結果を保存する
+	SaveAnalysis(conversation SavedConversation, result openai.VulnerabilityDetectionResult) error
+	// SaveConverted はconversationをLLM向けに変換したJSONを保存する
+	SaveConverted(conversation SavedConversation, data []byte) error
+
+	// Close は書きかけの記録を保存して保存先を閉じる
+	Close() error
+}
+
+// LegacyLayoutMigrator は以前の保存形式のデータを今の形式に移せるStore（FileStoreが実装する）
+type LegacyLayoutMigrator interface {
+	// MigrateLegacyLayout はrepoの以前の保存形式のPRを今の形式に移し、移したPRの数を返す
+	MigrateLegacyLayout(repo github.RepositoryRef) (int, error)
+}
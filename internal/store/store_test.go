@@ -0,0 +1,352 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/malsuke/PRalyzer/internal/llm"
+	"github.com/malsuke/PRalyzer/internal/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stores はStoreの実装ごとに、テスト用の保存先を作成する
+var stores = []struct {
+	name string
+	open func(t *testing.T) Store
+}{
+	{
+		name: "file",
+		open: func(t *testing.T) Store {
+			dir := t.TempDir()
+			return NewFileStore(filepath.Join(dir, "data"),
+				WithResultsFile(filepath.Join(dir, "results", "results.jsonl")),
+				WithConvertedDir(filepath.Join(dir, "output")))
+		},
+	},
+	{
+		name: "sqlite",
+		open: func(t *testing.T) Store {
+			s, err := OpenSQLite(filepath.Join(t.TempDir(), "pralyzer.db"))
+			require.NoError(t, err)
+			return s
+		},
+	},
+}
+
+func sampleComments(body string) PRComments {
+	created := &gh.Timestamp{Time: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	return PRComments{
+		PullRequest: &llm.PullRequestPayload{Number: 7, Title: "Escape output", Author: "alice", Labels: []string{"security"}},
+		IssueComments: []*gh.IssueComment{
+			{ID: gh.Ptr(int64(1)), Body: gh.Ptr(body), User: &gh.User{Login: gh.Ptr("bob")}, CreatedAt: created},
+			{ID: gh.Ptr(int64(2)), Body: gh.Ptr("thanks"), User: &gh.User{Login: gh.Ptr("alice")}, CreatedAt: created},
+		},
+		ReviewComments: []*gh.PullRequestComment{
+			{ID: gh.Ptr(int64(10)), Body: gh.Ptr("this is an xss"), Path: gh.Ptr("escape.go"), CreatedAt: created},
+			{ID: gh.Ptr(int64(11)), InReplyTo: gh.Ptr(int64(10)), Body: gh.Ptr("fixed"), Path: gh.Ptr("escape.go"), CreatedAt: created},
+		},
+		Reviews:         []*gh.PullRequestReview{{ID: gh.Ptr(int64(20)), State: gh.Ptr("APPROVED"), User: &gh.User{Login: gh.Ptr("bob")}}},
+		ResolvedThreads: map[int64]bool{10: true},
+	}
+}
+
+func TestStore_Conversations(t *testing.T) {
+	repo := github.RepositoryRef{Host: github.DefaultHost, Owner: "owner", Name: "repo"}
+
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.open(t)
+			defer s.Close()
+
+			_, err := s.LoadConversation(repo, 7)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			require.NoError(t, s.SaveConversation(repo, 7, sampleComments("first")))
+			require.NoError(t, s.SaveConversation(repo, 7, sampleComments("updated")))
+			require.NoError(t, s.SaveKeywordHits(repo, KeywordHit{Keyword: "xss", Query: "xss", MatchedAt: time.Now()}, []int{7}))
+			require.NoError(t, s.SaveKeywordHits(repo, KeywordHit{Keyword: "sanitize", Query: "sanitize", MatchedAt: time.Now()}, []int{7}))
+
+			got, err := s.LoadConversation(repo, 7)
+			require.NoError(t, err)
+			assert.Equal(t, sampleComments("updated"), got)
+
+			var saved []SavedConversation
+			for conversation, err := range s.Conversations() {
+				require.NoError(t, err)
+				saved = append(saved, conversation)
+			}
+			require.Len(t, saved, 1)
+			assert.Equal(t, 7, saved[0].Number)
+			assert.Equal(t, []string{"xss", "sanitize"}, saved[0].Keywords)
+
+			var decoded PRComments
+			require.NoError(t, json.Unmarshal(saved[0].Data, &decoded))
+			assert.Equal(t, sampleComments("updated"), decoded)
+		})
+	}
+}
+
+func TestStore_KeywordHits(t *testing.T) {
+	repo := github.RepositoryRef{Host: github.DefaultHost, Owner: "owner", Name: "repo"}
+	first := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.open(t)
+			defer s.Close()
+
+			hits, err := s.LoadKeywordHits(repo)
+			require.NoError(t, err)
+			assert.Empty(t, hits)
+
+			xss := KeywordHit{Keyword: "xss", Query: "repo:owner/repo in:comments type:pr is:merged xss", MatchedAt: first}
+			injection := KeywordHit{Keyword: "injection", Query: "repo:owner/repo in:comments type:pr is:merged injection", MatchedAt: second}
+			require.NoError(t, s.SaveKeywordHits(repo, xss, []int{1, 2}))
+			require.NoError(t, s.SaveKeywordHits(repo, injection, []int{2}))
+			// 記録済みのキーワードは初めの記録を残す
+			require.NoError(t, s.SaveKeywordHits(repo, KeywordHit{Keyword: "xss", Query: "later", MatchedAt: second}, []int{2}))
+
+			hits, err = s.LoadKeywordHits(repo)
+			require.NoError(t, err)
+			require.Len(t, hits, 2)
+			assert.Equal(t, "xss", hits[1][0].Keyword)
+			require.Len(t, hits[2], 2)
+			assert.Equal(t, xss.Query, hits[2][0].Query)
+			assert.True(t, first.Equal(hits[2][0].MatchedAt))
+			assert.Equal(t, "injection", hits[2][1].Keyword)
+
+			require.NoError(t, s.BuildKeywordViews(repo))
+		})
+	}
+}
+
+func TestStore_ProcessedAndSyncState(t *testing.T) {
+	repo := github.RepositoryRef{Host: "github.example.com", Owner: "owner", Name: "repo"}
+	other := github.RepositoryRef{Host: github.DefaultHost, Owner: "owner", Name: "repo"}
+	lastUpdatedAt := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.open(t)
+			defer s.Close()
+
+			processed, err := s.LoadProcessed(repo)
+			require.NoError(t, err)
+			assert.Empty(t, processed)
+			state, err := s.LoadSyncState(repo)
+			require.NoError(t, err)
+			assert.True(t, state.LastUpdatedAt.IsZero())
+
+			require.NoError(t, s.SaveProcessed(repo, map[int]bool{3: true, 1: true}))
+			require.NoError(t, s.SaveProcessed(repo, map[int]bool{3: true, 1: true, 2: true}))
+			require.NoError(t, s.SaveSyncState(repo, SyncState{LastUpdatedAt: lastUpdatedAt}))
+
+			processed, err = s.LoadProcessed(repo)
+			require.NoError(t, err)
+			assert.Equal(t, map[int]bool{1: true, 2: true, 3: true}, processed)
+			state, err = s.LoadSyncState(repo)
+			require.NoError(t, err)
+			assert.True(t, lastUpdatedAt.Equal(state.LastUpdatedAt))
+
+			processed, err = s.LoadProcessed(other)
+			require.NoError(t, err)
+			assert.Empty(t, processed)
+		})
+	}
+}
+
+func TestStore_AnalysisAndConverted(t *testing.T) {
+	repo := github.RepositoryRef{Host: github.DefaultHost, Owner: "owner", Name: "repo"}
+	// other は同じ番号のPRを持つ別のリポジトリ
+	other := github.RepositoryRef{Host: "github.example.com", Owner: "owner", Name: "repo"}
+
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.open(t)
+			require.NoError(t, s.SaveConversation(repo, 7, sampleComments("first")))
+			require.NoError(t, s.SaveConversation(other, 7, sampleComments("other")))
+
+			var saved []SavedConversation
+			for conversation, err := range s.Conversations() {
+				require.NoError(t, err)
+				saved = append(saved, conversation)
+				if conversation.Repo != repo {
+					continue
+				}
+				analyzed, err := s.Analyzed(conversation)
+				require.NoError(t, err)
+				assert.False(t, analyzed)
+
+				require.NoError(t, s.SaveAnalysis(conversation, openai.VulnerabilityDetectionResult{PR: 7, RelevantDiscussion: "yes", Reason: "xss"}))
+				require.NoError(t, s.SaveConverted(conversation, []byte(`{"converted":true}`)))
+			}
+			require.Len(t, saved, 2)
+
+			// 分析済みかどうかはリポジトリごとに区別する
+			for _, conversation := range saved {
+				analyzed, err := s.Analyzed(conversation)
+				require.NoError(t, err)
+				assert.Equal(t, conversation.Repo == repo, analyzed, conversation.Repo.String())
+			}
+			require.NoError(t, s.Close())
+		})
+	}
+}
+
+func TestFileStore_Layout(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "data")
+	results := filepath.Join(dir, "results.jsonl")
+	repo := github.RepositoryRef{Host: github.DefaultHost, Owner: "owner", Name: "repo"}
+
+	repoDir := filepath.Join(root, "owner", "repo")
+	hit := KeywordHit{Keyword: "xss", Query: "xss", MatchedAt: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+
+	s := NewFileStore(root, WithResultsFile(results), WithConvertedDir(filepath.Join(dir, "output")))
+	require.NoError(t, s.SaveConversation(repo, 7, sampleComments("first")))
+	require.NoError(t, s.SaveKeywordHits(repo, hit, []int{7, 8}))
+	require.NoError(t, s.SaveProcessed(repo, map[int]bool{7: true, 3: true}))
+	require.NoError(t, s.BuildKeywordViews(repo))
+	// 以前の構成の<キーワード>/<PRの番号>.jsonも読み出せる
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, "csrf"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "csrf", "5.json"), []byte("{}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "csrf", "notes.json"), []byte("{}"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".http_cache"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".http_cache", "1.json"), []byte("{}"), 0644))
+
+	assert.FileExists(t, filepath.Join(repoDir, "pulls", "7.json"))
+	data, err := os.ReadFile(filepath.Join(repoDir, ProcessedPRsFileName))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[3, 7]`, string(data))
+	data, err = os.ReadFile(filepath.Join(repoDir, KeywordHitsFileName))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"7": [{"keyword": "xss", "query": "xss", "matched_at": "2024-05-01T09:00:00Z"}],
+		"8": [{"keyword": "xss", "query": "xss", "matched_at": "2024-05-01T09:00:00Z"}]
+	}`, string(data))
+
+	// ビューは保存済みのPRだけを指す
+	view, err := ReadConversationFile(filepath.Join(repoDir, "keywords", "xss", "7.json"))
+	require.NoError(t, err)
+	assert.Equal(t, sampleComments("first"), view)
+	assert.NoFileExists(t, filepath.Join(repoDir, "keywords", "xss", "8.json"))
+
+	keywords := make(map[int][]string)
+	var errs int
+	for conversation, err := range s.Conversations() {
+		if err != nil {
+			errs++
+			continue
+		}
+		keywords[conversation.Number] = conversation.Keywords
+		if conversation.Number != 7 {
+			continue
+		}
+		require.NoError(t, s.SaveAnalysis(conversation, openai.VulnerabilityDetectionResult{PR: conversation.Number, RelevantDiscussion: "yes", Reason: "xss"}))
+		require.NoError(t, s.SaveConverted(conversation, []byte(`{}`)))
+	}
+	assert.Equal(t, map[int][]string{7: {"xss"}, 5: {"csrf"}}, keywords)
+	assert.Equal(t, 1, errs, "notes.json is reported as an error")
+	require.NoError(t, s.Close())
+
+	data, err = os.ReadFile(results)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"repo":"owner/repo","pr":7,"relevant_discussion":"yes","reason":"xss"}`, string(data))
+	data, err = os.ReadFile(filepath.Join(dir, ".results_index.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `["owner/repo#7"]`, string(data))
+	assert.FileExists(t, filepath.Join(dir, "output", "owner", "repo", "pulls", "7.json"))
+
+	// 分析済みのインデックスは次回の実行で読み込まれる
+	reopened := NewFileStore(root, WithResultsFile(results))
+	analyzed, err := reopened.Analyzed(SavedConversation{Repo: repo, Number: 7})
+	require.NoError(t, err)
+	assert.True(t, analyzed)
+	analyzed, err = reopened.Analyzed(SavedConversation{Repo: github.RepositoryRef{Host: github.DefaultHost, Owner: "owner", Name: "fork"}, Number: 7})
+	require.NoError(t, err)
+	assert.False(t, analyzed)
+
+	// PR番号だけで記録していた以前のインデックスは、どのリポジトリでも分析済みとみなし、書き直しても残す
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".results_index.json"), []byte(`[5, "owner/repo#7"]`), 0644))
+	legacy := NewFileStore(root, WithResultsFile(results))
+	analyzed, err = legacy.Analyzed(SavedConversation{Repo: github.RepositoryRef{Host: github.DefaultHost, Owner: "owner", Name: "fork"}, Number: 5})
+	require.NoError(t, err)
+	assert.True(t, analyzed)
+	require.NoError(t, legacy.SaveAnalysis(SavedConversation{Repo: repo, Number: 8}, openai.VulnerabilityDetectionResult{PR: 8}))
+	require.NoError(t, legacy.Close())
+	data, err = os.ReadFile(filepath.Join(dir, ".results_index.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[5, "owner/repo#7", "owner/repo#8"]`, string(data))
+}
+
+func TestFileStore_MigrateLegacyLayout(t *testing.T) {
+	root := t.TempDir()
+	repo := github.RepositoryRef{Host: github.DefaultHost, Owner: "owner", Name: "repo"}
+	repoDir := filepath.Join(root, "owner", "repo")
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	hit := KeywordHit{Keyword: "rce", Query: "rce", MatchedAt: time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)}
+
+	s := NewFileStore(root)
+	require.NoError(t, s.SaveConversation(repo, 9, sampleComments("current")))
+	require.NoError(t, s.SaveKeywordHits(repo, hit, []int{9}))
+	writeLegacy := func(keyword string, number int, body string, modTime time.Time) {
+		t.Helper()
+		path := filepath.Join(repoDir, keyword, fmt.Sprintf("%d.json", number))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		data, err := json.Marshal(sampleComments(body))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	// 同じPRが複数のキーワードのディレクトリにあれば、新しいファイルを残す
+	writeLegacy("xss", 7, "newer", newer)
+	writeLegacy("csrf", 7, "older", older)
+	writeLegacy("csrf", 5, "five", older)
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "csrf", "notes.json"), []byte("{}"), 0644))
+
+	migrated, err := s.MigrateLegacyLayout(repo)
+	require.NoError(t, err)
+	assert.Equal(t, 2, migrated)
+
+	comments, err := s.LoadConversation(repo, 7)
+	require.NoError(t, err)
+	assert.Equal(t, sampleComments("newer"), comments)
+	comments, err = s.LoadConversation(repo, 9)
+	require.NoError(t, err)
+	assert.Equal(t, sampleComments("current"), comments)
+	assert.NoDirExists(t, filepath.Join(repoDir, "xss"))
+	assert.NoFileExists(t, filepath.Join(repoDir, "csrf", "5.json"))
+	assert.FileExists(t, filepath.Join(repoDir, "csrf", "notes.json"), "files that are not PRs are left in place")
+
+	hits, err := s.LoadKeywordHits(repo)
+	require.NoError(t, err)
+	assert.Equal(t, map[int][]KeywordHit{
+		5: {{Keyword: "csrf", MatchedAt: older}},
+		7: {{Keyword: "csrf", MatchedAt: older}, {Keyword: "xss", MatchedAt: newer}},
+		9: {hit},
+	}, hits)
+
+	// 移したPRは1件ずつだけ読み出される
+	require.NoError(t, s.BuildKeywordViews(repo))
+	assert.FileExists(t, filepath.Join(repoDir, "keywords", "csrf", "7.json"))
+	count := 0
+	for _, err := range s.Conversations() {
+		if err == nil {
+			count++
+		}
+	}
+	assert.Equal(t, 3, count)
+
+	migrated, err = s.MigrateLegacyLayout(repo)
+	require.NoError(t, err)
+	assert.Zero(t, migrated)
+}
@@ -0,0 +1,161 @@
+// Package target はクロール対象のリポジトリを複数まとめて指定するフラグ（-org・-user・-reposと絞り込み条件）を扱う。
+package target
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	gh "github.com/google/go-github/v77/github"
+	"github.com/malsuke/PRalyzer/internal/github"
+)
+
+// Flags はクロール対象のリポジトリを複数まとめて指定するフラグ
+type Flags struct {
+	org             string
+	user            string
+	reposFile       string
+	host            string
+	languages       string
+	excludeArchived bool
+	excludeForks    bool
+	minStars        int
+}
+
+// RegisterFlags は対象リポジトリのフラグをfsに登録する
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.org, "org", "", "crawl every repository of this organization instead of a single repository")
+	fs.StringVar(&f.user, "user", "", "crawl every repository owned by this user instead of a single repository")
+	fs.StringVar(&f.reposFile, "repos", "", "crawl the repositories listed in this file (one owner/name or URL per line)")
+	fs.StringVar(&f.host, "host", github.DefaultHost, "GitHub host of the -org or -user account (for GitHub Enterprise Server)")
+	fs.StringVar(&f.languages, "language", "", "comma-separated primary languages of repositories to crawl")
+	fs.BoolVar(&f.excludeArchived, "exclude-archived", false, "skip archived repositories")
+	fs.BoolVar(&f.excludeForks, "exclude-forks", false, "skip forked repositories")
+	fs.IntVar(&f.minStars, "min-stars", 0, "skip repositories with fewer stars")
+	return f
+}
+
+// MultiRepository は複数リポジトリのモードが指定されているかどうかを返す
+func (f *Flags) MultiRepository() bool {
+	return f.org != "" || f.user != "" || f.reposFile != ""
+}
+
+// Account は-orgか-userでアカウントのリポジトリ一覧が指定されているかどうかを返す
+func (f *Flags) Account() bool {
+	return f.org != "" || f.user != ""
+}
+
+// ReposFile は-reposで指定されたファイルを返す
+func (f *Flags) ReposFile() string {
+	return f.reposFile
+}
+
+// Validate はモードの指定が1つだけであることを確認する
+func (f *Flags) Validate() error {
+	modes := 0
+	for _, value := range []string{f.org, f.user, f.reposFile} {
+		if value != "" {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return fmt.Errorf("use only one of -org, -user and -repos")
+	}
+	if f.minStars < 0 {
+		return fmt.Errorf("invalid -min-stars %d: must not be negative", f.minStars)
+	}
+	return nil
+}
+
+// Filter はフラグで指定された絞り込み条件を返す
+func (f *Flags) Filter() github.RepositoryFilter {
+	return github.RepositoryFilter{
+		Languages:       splitList(f.languages),
+		ExcludeArchived: f.excludeArchived,
+		ExcludeForks:    f.excludeForks,
+		MinStars:        f.minStars,
+	}
+}
+
+// Failure はリポジトリ情報を取得できずに対象から外したリポジトリ
+type Failure struct {
+	Repository github.RepositoryRef
+	Err        error
+}
+
+/**
+ * -org・-user・-reposで指定されたリポジトリを一覧にして、絞り込み条件を適用する
+ * clientForはホストごとのClientを返す
+ * -reposのリポジトリは絞り込み条件が指定されている場合だけリポジトリ情報を取得して判定する
+ * リポジトリ情報を取得できなかったリポジトリはログに残して飛ばし、Failureとして返す
+ */
+func (f *Flags) Resolve(ctx context.Context, clientFor func(host string) (*github.Client, error)) ([]github.RepositoryRef, []Failure, error) {
+	filter := f.Filter()
+
+	if f.reposFile != "" {
+		refs, err := github.LoadRepositoryList(f.reposFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if filter.IsZero() {
+			return refs, nil, nil
+		}
+
+		var matched []github.RepositoryRef
+		var failed []Failure
+		for _, ref := range refs {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			repo, err := getRepository(ctx, clientFor, ref)
+			if err != nil {
+				log.Printf("Skipping %s: %v", ref, err)
+				failed = append(failed, Failure{Repository: ref, Err: err})
+				continue
+			}
+			if filter.Match(repo) {
+				matched = append(matched, ref)
+			}
+		}
+		return matched, failed, nil
+	}
+
+	client, err := clientFor(f.host)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var repos []*gh.Repository
+	if f.org != "" {
+		repos, err = client.ListOrganizationRepositories(ctx, f.org)
+	} else {
+		repos, err = client.ListUserRepositories(ctx, f.user)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return github.FilterRepositories(repos, f.host, filter), nil, nil
+}
+
+// getRepository はrefのホストのClientでリポジトリ情報を取得する
+func getRepository(ctx context.Context, clientFor func(host string) (*github.Client, error), ref github.RepositoryRef) (*gh.Repository, error) {
+	client, err := clientFor(ref.Host)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetRepository(ctx, ref)
+}
+
+// splitList はカンマ区切りの値を分割し、空の要素を取り除く
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
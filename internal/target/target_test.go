@@ -0,0 +1,41 @@
+package target
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/malsuke/PRalyzer/internal/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve_SkipsRepositoriesThatCannotBeFetched(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/found" {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"found","owner":{"login":"owner"},"stargazers_count":10}`))
+	}))
+	t.Cleanup(server.Close)
+
+	reposFile := filepath.Join(t.TempDir(), "repos.txt")
+	require.NoError(t, os.WriteFile(reposFile, []byte("owner/missing\nowner/found\n"), 0644))
+	clientFor := func(host string) (*github.Client, error) {
+		return github.NewHostClient("test-token", host, nil, github.WithBaseURL(server.URL+"/"))
+	}
+	targets := &Flags{reposFile: reposFile, minStars: 1}
+
+	repos, failed, err := targets.Resolve(context.Background(), clientFor)
+
+	require.NoError(t, err)
+	assert.Equal(t, []github.RepositoryRef{{Host: github.DefaultHost, Owner: "owner", Name: "found"}}, repos)
+	require.Len(t, failed, 1)
+	assert.Equal(t, "owner/missing", failed[0].Repository.String())
+	assert.ErrorContains(t, failed[0].Err, "404")
+}